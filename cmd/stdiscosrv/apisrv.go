@@ -44,7 +44,8 @@ type apiSrv struct {
 	cert           tls.Certificate
 	db             database
 	listener       net.Listener
-	repl           replicator // optional
+	repl           replicator    // optional
+	policy         *policyLoader // optional
 	useHTTP        bool
 	compression    bool
 	gzipWriters    sync.Pool
@@ -66,12 +67,13 @@ type contextKey int
 
 const idKey contextKey = iota
 
-func newAPISrv(addr string, cert tls.Certificate, db database, repl replicator, useHTTP, compression bool, desiredNotFoundRate float64) *apiSrv {
+func newAPISrv(addr string, cert tls.Certificate, db database, repl replicator, policy *policyLoader, useHTTP, compression bool, desiredNotFoundRate float64) *apiSrv {
 	return &apiSrv{
 		addr:        addr,
 		cert:        cert,
 		db:          db,
 		repl:        repl,
+		policy:      policy,
 		useHTTP:     useHTTP,
 		compression: compression,
 		seenTracker: &retryAfterTracker{
@@ -184,7 +186,7 @@ func (s *apiSrv) handler(w http.ResponseWriter, req *http.Request) {
 
 	switch req.Method {
 	case http.MethodGet:
-		s.handleGET(lw, req)
+		s.handleGET(remoteAddr, lw, req)
 	case http.MethodPost:
 		s.handlePOST(remoteAddr, lw, req)
 	default:
@@ -192,9 +194,20 @@ func (s *apiSrv) handler(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
-func (s *apiSrv) handleGET(w http.ResponseWriter, req *http.Request) {
+func (s *apiSrv) handleGET(remoteAddr *net.TCPAddr, w http.ResponseWriter, req *http.Request) {
 	reqID := req.Context().Value(idKey).(requestID)
 
+	rawCert, _ := s.clientCertificate(req) // nil if there is none
+	if !s.policy.policy().allowsQuery(rawCert, remoteAddr.IP) {
+		if debug {
+			log.Println(reqID, "query not allowed from", remoteAddr.IP)
+		}
+		lookupRequestsTotal.WithLabelValues("forbidden").Inc()
+		w.Header().Set("Retry-After", errorRetryAfterString())
+		http.Error(w, "Forbidden: not allowed to query", http.StatusForbidden)
+		return
+	}
+
 	deviceID, err := protocol.DeviceIDFromString(req.URL.Query().Get("device"))
 	if err != nil {
 		if debug {
@@ -257,7 +270,7 @@ func (s *apiSrv) handleGET(w http.ResponseWriter, req *http.Request) {
 func (s *apiSrv) handlePOST(remoteAddr *net.TCPAddr, w http.ResponseWriter, req *http.Request) {
 	reqID := req.Context().Value(idKey).(requestID)
 
-	rawCert, err := certificateBytes(req)
+	rawCert, err := s.clientCertificate(req)
 	if err != nil {
 		if debug {
 			log.Println(reqID, "no certificates:", err)
@@ -268,6 +281,18 @@ func (s *apiSrv) handlePOST(remoteAddr *net.TCPAddr, w http.ResponseWriter, req
 		return
 	}
 
+	deviceID := protocol.NewDeviceID(rawCert)
+
+	if !s.policy.policy().allowsAnnounce(rawCert, remoteAddr.IP) {
+		if debug {
+			log.Println(reqID, "announce not allowed for", deviceID)
+		}
+		announceRequestsTotal.WithLabelValues("forbidden").Inc()
+		w.Header().Set("Retry-After", errorRetryAfterString())
+		http.Error(w, fmt.Sprintf("Forbidden: device %s is not allowed to announce", deviceID), http.StatusForbidden)
+		return
+	}
+
 	var ann announcement
 	if err := json.NewDecoder(req.Body).Decode(&ann); err != nil {
 		if debug {
@@ -279,8 +304,6 @@ func (s *apiSrv) handlePOST(remoteAddr *net.TCPAddr, w http.ResponseWriter, req
 		return
 	}
 
-	deviceID := protocol.NewDeviceID(rawCert)
-
 	addresses := fixupAddresses(remoteAddr, ann.Addresses)
 	if len(addresses) == 0 {
 		if debug {
@@ -343,6 +366,20 @@ func handlePing(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// clientCertificate returns the certificate presented by the client. The
+// certificate headers are only trusted when running behind a reverse
+// proxy, which sets them (and must strip them from client requests); on
+// our own TLS listener anyone could send them.
+func (s *apiSrv) clientCertificate(req *http.Request) ([]byte, error) {
+	if s.useHTTP {
+		return certificateBytes(req)
+	}
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return nil, errors.New("no client certificate")
+	}
+	return req.TLS.PeerCertificates[0].Raw, nil
+}
+
 func certificateBytes(req *http.Request) ([]byte, error) {
 	if req.TLS != nil && len(req.TLS.PeerCertificates) > 0 {
 		return req.TLS.PeerCertificates[0].Raw, nil
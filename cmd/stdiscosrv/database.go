@@ -12,6 +12,7 @@ import (
 	"context"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"os"
@@ -41,12 +42,22 @@ func (defaultClock) Now() time.Time {
 	return time.Now()
 }
 
+// The database is the storage of the records, one per device. Merging
+// records is commutative, associative and idempotent (see merge), so
+// instances replicating their changes to each other converge on the same
+// records regardless of the order in which they see them.
 type database interface {
 	put(key *protocol.DeviceID, rec *discosrv.DatabaseRecord) error
 	merge(key *protocol.DeviceID, addrs []*discosrv.DatabaseAddress, seen int64) error
 	get(key *protocol.DeviceID) (*discosrv.DatabaseRecord, error)
+	// iterate calls fn for each record, until it returns false.
+	iterate(fn func(key protocol.DeviceID, rec *discosrv.DatabaseRecord) bool) error
 }
 
+// The largest record we accept when reading snapshots or replication
+// streams.
+const maxRecordSize = 1 << 20
+
 type inMemoryStore struct {
 	m             *xsync.MapOf[protocol.DeviceID, *discosrv.DatabaseRecord]
 	dir           string
@@ -140,6 +151,13 @@ func (s *inMemoryStore) get(key *protocol.DeviceID) (*discosrv.DatabaseRecord, e
 	return rec, nil
 }
 
+func (s *inMemoryStore) iterate(fn func(key protocol.DeviceID, rec *discosrv.DatabaseRecord) bool) error {
+	s.m.Range(func(key protocol.DeviceID, rec *discosrv.DatabaseRecord) bool {
+		return fn(key, proto.Clone(rec).(*discosrv.DatabaseRecord))
+	})
+	return nil
+}
+
 func (s *inMemoryStore) Serve(ctx context.Context) error {
 	if s.flushInterval <= 0 {
 		<-ctx.Done()
@@ -173,9 +191,7 @@ loop:
 
 func (s *inMemoryStore) expireAndCalculateStatistics() {
 	now := s.clock.Now()
-	cutoff24h := now.Add(-24 * time.Hour).UnixNano()
-	cutoff1w := now.Add(-7 * 24 * time.Hour).UnixNano()
-	current, currentIPv4, currentIPv6, currentIPv6GUA, last24h, last1w := 0, 0, 0, 0, 0, 0
+	stats := newDatabaseStatistics(now)
 
 	n := 0
 	s.m.Range(func(key protocol.DeviceID, rec *discosrv.DatabaseRecord) bool {
@@ -193,53 +209,83 @@ func (s *inMemoryStore) expireAndCalculateStatistics() {
 			s.m.Store(key, rec)
 		}
 
-		switch {
-		case len(rec.Addresses) > 0:
-			current++
-			seenIPv4, seenIPv6, seenIPv6GUA := false, false, false
-			for _, addr := range rec.Addresses {
-				// We do fast and loose matching on strings here instead of
-				// parsing the address and the IP and doing "proper" checks,
-				// to keep things fast and generate less garbage.
-				if strings.Contains(addr.Address, "[") {
-					seenIPv6 = true
-					if strings.Contains(addr.Address, "[2") {
-						seenIPv6GUA = true
-					}
-				} else {
-					seenIPv4 = true
-				}
-				if seenIPv4 && seenIPv6 && seenIPv6GUA {
-					break
-				}
-			}
-			if seenIPv4 {
-				currentIPv4++
-			}
-			if seenIPv6 {
-				currentIPv6++
-			}
-			if seenIPv6GUA {
-				currentIPv6GUA++
-			}
-		case rec.Seen > cutoff24h:
-			last24h++
-		case rec.Seen > cutoff1w:
-			last1w++
-		default:
+		if !stats.add(rec) {
 			// drop the record if it's older than a week
 			s.m.Delete(key)
 		}
 		return true
 	})
 
-	databaseKeys.WithLabelValues("current").Set(float64(current))
-	databaseKeys.WithLabelValues("currentIPv4").Set(float64(currentIPv4))
-	databaseKeys.WithLabelValues("currentIPv6").Set(float64(currentIPv6))
-	databaseKeys.WithLabelValues("currentIPv6GUA").Set(float64(currentIPv6GUA))
-	databaseKeys.WithLabelValues("last24h").Set(float64(last24h))
-	databaseKeys.WithLabelValues("last1w").Set(float64(last1w))
-	databaseStatisticsSeconds.Set(time.Since(now).Seconds())
+	stats.publish()
+}
+
+// databaseStatistics counts records by how recently their device was
+// seen, for the metrics.
+type databaseStatistics struct {
+	started                                           time.Time
+	cutoff24h, cutoff1w                               int64
+	current, currentIPv4, currentIPv6, currentIPv6GUA int
+	last24h, last1w                                   int
+}
+
+func newDatabaseStatistics(now time.Time) *databaseStatistics {
+	return &databaseStatistics{
+		started:   now,
+		cutoff24h: now.Add(-24 * time.Hour).UnixNano(),
+		cutoff1w:  now.Add(-7 * 24 * time.Hour).UnixNano(),
+	}
+}
+
+// add counts the record, the expired addresses of which must have been
+// removed, and returns false if it's too old to keep.
+func (s *databaseStatistics) add(rec *discosrv.DatabaseRecord) bool {
+	switch {
+	case len(rec.Addresses) > 0:
+		s.current++
+		seenIPv4, seenIPv6, seenIPv6GUA := false, false, false
+		for _, addr := range rec.Addresses {
+			// We do fast and loose matching on strings here instead of
+			// parsing the address and the IP and doing "proper" checks,
+			// to keep things fast and generate less garbage.
+			if strings.Contains(addr.Address, "[") {
+				seenIPv6 = true
+				if strings.Contains(addr.Address, "[2") {
+					seenIPv6GUA = true
+				}
+			} else {
+				seenIPv4 = true
+			}
+			if seenIPv4 && seenIPv6 && seenIPv6GUA {
+				break
+			}
+		}
+		if seenIPv4 {
+			s.currentIPv4++
+		}
+		if seenIPv6 {
+			s.currentIPv6++
+		}
+		if seenIPv6GUA {
+			s.currentIPv6GUA++
+		}
+	case rec.Seen > s.cutoff24h:
+		s.last24h++
+	case rec.Seen > s.cutoff1w:
+		s.last1w++
+	default:
+		return false
+	}
+	return true
+}
+
+func (s *databaseStatistics) publish() {
+	databaseKeys.WithLabelValues("current").Set(float64(s.current))
+	databaseKeys.WithLabelValues("currentIPv4").Set(float64(s.currentIPv4))
+	databaseKeys.WithLabelValues("currentIPv6").Set(float64(s.currentIPv6))
+	databaseKeys.WithLabelValues("currentIPv6GUA").Set(float64(s.currentIPv6GUA))
+	databaseKeys.WithLabelValues("last24h").Set(float64(s.last24h))
+	databaseKeys.WithLabelValues("last1w").Set(float64(s.last1w))
+	databaseStatisticsSeconds.Set(time.Since(s.started).Seconds())
 }
 
 func (s *inMemoryStore) write() (err error) {
@@ -278,21 +324,8 @@ func (s *inMemoryStore) write() (err error) {
 			Addresses: value.Addresses,
 			Seen:      value.Seen,
 		}
-		s := proto.Size(rec)
-		if s+4 > len(buf) {
-			buf = make([]byte, s+4)
-		}
-		n, err := protoutil.MarshalTo(buf[4:], rec)
-		if err != nil {
-			rangeErr = err
-			return false
-		}
-		binary.BigEndian.PutUint32(buf, uint32(n))
-		if _, err := bw.Write(buf[:n+4]); err != nil {
-			rangeErr = err
-			return false
-		}
-		return true
+		buf, rangeErr = writeRecord(bw, rec, buf)
+		return rangeErr == nil
 	})
 	if rangeErr != nil {
 		_ = fd.Close()
@@ -334,21 +367,52 @@ func (s *inMemoryStore) read() (int, error) {
 	}
 	defer fd.Close()
 
-	br := bufio.NewReader(fd)
+	return readRecords(bufio.NewReader(fd), func(key protocol.DeviceID, rec *discosrv.DatabaseRecord) error {
+		rec.Addresses = expire(rec.Addresses, s.clock.Now())
+		s.m.Store(key, rec)
+		return nil
+	})
+}
+
+// writeRecord writes the record prefixed by its length, as in database
+// snapshots and replication streams. The given buffer is used if it's
+// large enough, and the one used is returned for the next call.
+func writeRecord(w io.Writer, rec *discosrv.ReplicationRecord, buf []byte) ([]byte, error) {
+	s := proto.Size(rec)
+	if s+4 > len(buf) {
+		buf = make([]byte, s+4)
+	}
+	n, err := protoutil.MarshalTo(buf[4:], rec)
+	if err != nil {
+		return buf, err
+	}
+	binary.BigEndian.PutUint32(buf, uint32(n))
+	_, err = w.Write(buf[:n+4])
+	return buf, err
+}
+
+// readRecords reads records written by writeRecord until EOF, calling fn
+// with each of them, and returns the number of records read. Records
+// with bad device IDs are skipped. The addresses are sorted, as merge
+// requires.
+func readRecords(r io.Reader, fn func(key protocol.DeviceID, rec *discosrv.DatabaseRecord) error) (int, error) {
 	var buf []byte
 	nr := 0
 	for {
 		var n uint32
-		if err := binary.Read(br, binary.BigEndian, &n); err != nil {
+		if err := binary.Read(r, binary.BigEndian, &n); err != nil {
 			if errors.Is(err, io.EOF) {
 				break
 			}
 			return nr, err
 		}
+		if n > maxRecordSize {
+			return nr, fmt.Errorf("record size %d too large", n)
+		}
 		if int(n) > len(buf) {
 			buf = make([]byte, n)
 		}
-		if _, err := io.ReadFull(br, buf[:n]); err != nil {
+		if _, err := io.ReadFull(r, buf[:n]); err != nil {
 			return nr, err
 		}
 		rec := &discosrv.ReplicationRecord{}
@@ -366,10 +430,9 @@ func (s *inMemoryStore) read() (int, error) {
 
 		slices.SortFunc(rec.Addresses, Cmp)
 		rec.Addresses = slices.CompactFunc(rec.Addresses, Equal)
-		s.m.Store(key, &discosrv.DatabaseRecord{
-			Addresses: expire(rec.Addresses, s.clock.Now()),
-			Seen:      rec.Seen,
-		})
+		if err := fn(key, &discosrv.DatabaseRecord{Addresses: rec.Addresses, Seen: rec.Seen}); err != nil {
+			return nr, err
+		}
 		nr++
 	}
 	return nr, nil
@@ -377,8 +440,9 @@ func (s *inMemoryStore) read() (int, error) {
 
 // merge returns the merged result of the two database records a and b. The
 // result is the union of the two address sets, with the newer expiry time
-// chosen for any duplicates. The address list in a is overwritten and
-// reused for the result.
+// chosen for any duplicates, and the later seen time. This makes records a
+// state based CRDT: merging is commutative, associative and idempotent.
+// The address list in a is overwritten and reused for the result.
 func merge(a, b *discosrv.DatabaseRecord) *discosrv.DatabaseRecord {
 	// Both lists must be sorted for this to work.
 
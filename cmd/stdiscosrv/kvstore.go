@@ -0,0 +1,237 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/syncthing/syncthing/internal/gen/discosrv"
+	"github.com/syncthing/syncthing/lib/db/backend"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// The kvStore keeps the records in an embedded key-value database, keyed
+// by device ID. Unlike the inMemoryStore every change is persisted as it
+// happens, and the records don't need to fit in memory.
+type kvStore struct {
+	db    backend.Backend
+	mut   sync.Mutex // serializes read-modify-write of records
+	clock clock
+}
+
+func newKVStore(db backend.Backend) *kvStore {
+	return &kvStore{
+		db:    db,
+		clock: defaultClock{},
+	}
+}
+
+func (s *kvStore) put(key *protocol.DeviceID, rec *discosrv.DatabaseRecord) error {
+	t0 := time.Now()
+	defer func() {
+		databaseOperationSeconds.WithLabelValues(dbOpPut).Observe(time.Since(t0).Seconds())
+	}()
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if err := s.putLocked(key, rec); err != nil {
+		databaseOperations.WithLabelValues(dbOpPut, dbResError).Inc()
+		return err
+	}
+	databaseOperations.WithLabelValues(dbOpPut, dbResSuccess).Inc()
+	return nil
+}
+
+func (s *kvStore) merge(key *protocol.DeviceID, addrs []*discosrv.DatabaseAddress, seen int64) error {
+	t0 := time.Now()
+	defer func() {
+		databaseOperationSeconds.WithLabelValues(dbOpMerge).Observe(time.Since(t0).Seconds())
+	}()
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	rec, err := s.getLocked(key)
+	if err == nil {
+		rec = merge(rec, &discosrv.DatabaseRecord{Addresses: addrs, Seen: seen})
+		err = s.putLocked(key, rec)
+	}
+	if err != nil {
+		databaseOperations.WithLabelValues(dbOpMerge, dbResError).Inc()
+		return err
+	}
+	databaseOperations.WithLabelValues(dbOpMerge, dbResSuccess).Inc()
+	return nil
+}
+
+func (s *kvStore) get(key *protocol.DeviceID) (*discosrv.DatabaseRecord, error) {
+	t0 := time.Now()
+	defer func() {
+		databaseOperationSeconds.WithLabelValues(dbOpGet).Observe(time.Since(t0).Seconds())
+	}()
+
+	rec, err := s.getLocked(key)
+	if err != nil {
+		databaseOperations.WithLabelValues(dbOpGet, dbResError).Inc()
+		return nil, err
+	}
+	if rec.Seen == 0 {
+		databaseOperations.WithLabelValues(dbOpGet, dbResNotFound).Inc()
+		return rec, nil
+	}
+
+	rec.Addresses = expire(rec.Addresses, s.clock.Now())
+	databaseOperations.WithLabelValues(dbOpGet, dbResSuccess).Inc()
+	return rec, nil
+}
+
+func (s *kvStore) iterate(fn func(key protocol.DeviceID, rec *discosrv.DatabaseRecord) bool) error {
+	snap, err := s.db.NewReadTransaction()
+	if err != nil {
+		return err
+	}
+	defer snap.Release()
+	it, err := snap.NewPrefixIterator(nil)
+	if err != nil {
+		return err
+	}
+	defer it.Release()
+	for it.Next() {
+		key, err := protocol.DeviceIDFromBytes(it.Key())
+		if err != nil {
+			continue
+		}
+		rec := &discosrv.DatabaseRecord{}
+		if err := proto.Unmarshal(it.Value(), rec); err != nil {
+			return err
+		}
+		if !fn(key, rec) {
+			break
+		}
+	}
+	return it.Error()
+}
+
+// getLocked returns the record for the device, or an empty one if there
+// is none. Reads don't need the lock, but the merges that follow do.
+func (s *kvStore) getLocked(key *protocol.DeviceID) (*discosrv.DatabaseRecord, error) {
+	rec := &discosrv.DatabaseRecord{}
+	bs, err := s.db.Get(key[:])
+	if backend.IsNotFound(err) {
+		return rec, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := proto.Unmarshal(bs, rec); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+func (s *kvStore) putLocked(key *protocol.DeviceID, rec *discosrv.DatabaseRecord) error {
+	bs, err := proto.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.db.Put(key[:], bs)
+}
+
+// importSnapshot reads the records from an inMemoryStore snapshot, if the
+// store is empty, to switch from one to the other without losing them.
+func (s *kvStore) importSnapshot(path string) (int, error) {
+	empty := true
+	if err := s.iterate(func(protocol.DeviceID, *discosrv.DatabaseRecord) bool {
+		empty = false
+		return false
+	}); err != nil {
+		return 0, err
+	}
+	if !empty {
+		return 0, nil
+	}
+
+	fd, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	defer fd.Close()
+
+	return readRecords(bufio.NewReader(fd), func(key protocol.DeviceID, rec *discosrv.DatabaseRecord) error {
+		return s.merge(&key, rec.Addresses, rec.Seen)
+	})
+}
+
+func (s *kvStore) Serve(ctx context.Context) error {
+	defer s.db.Close()
+
+	s.expireAndCalculateStatistics()
+	t := time.NewTicker(databaseStatisticsInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			s.expireAndCalculateStatistics()
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// expireAndCalculateStatistics removes expired addresses and records of
+// devices not seen for a week, and updates the metrics.
+func (s *kvStore) expireAndCalculateStatistics() {
+	now := s.clock.Now()
+	stats := newDatabaseStatistics(now)
+
+	var changed []protocol.DeviceID
+	err := s.iterate(func(key protocol.DeviceID, rec *discosrv.DatabaseRecord) bool {
+		n := len(rec.Addresses)
+		rec.Addresses = expire(rec.Addresses, now)
+		if !stats.add(rec) || len(rec.Addresses) != n {
+			changed = append(changed, key)
+		}
+		return true
+	})
+	if err != nil {
+		log.Println("Error iterating database:", err)
+		return
+	}
+
+	// The records may have changed since we looked at them, so redo the
+	// expiry under the lock.
+	for _, key := range changed {
+		if err := s.expire(&key, now, stats.cutoff1w); err != nil {
+			log.Println("Error expiring database record:", err)
+		}
+	}
+
+	stats.publish()
+}
+
+func (s *kvStore) expire(key *protocol.DeviceID, now time.Time, cutoff int64) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	rec, err := s.getLocked(key)
+	if err != nil {
+		return err
+	}
+	rec.Addresses = expire(rec.Addresses, now)
+	if len(rec.Addresses) == 0 && rec.Seen <= cutoff {
+		return s.db.Delete(key[:])
+	}
+	return s.putLocked(key, rec)
+}
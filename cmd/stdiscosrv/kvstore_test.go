@@ -0,0 +1,128 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/internal/gen/discosrv"
+	"github.com/syncthing/syncthing/lib/db/backend"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestKVStoreGetMerge(t *testing.T) {
+	db := newKVStore(backend.OpenMemory())
+	defer db.db.Close()
+	tc := &testClock{time.Now()}
+	db.clock = tc
+
+	// Check missing record
+
+	rec, err := db.get(&protocol.EmptyDeviceID)
+	if err != nil {
+		t.Fatal("not found should not be an error:", err)
+	}
+	if len(rec.Addresses) != 0 || rec.Seen != 0 {
+		t.Fatal("record should be empty")
+	}
+
+	// Merge in two addresses, one at a time
+
+	addrs := []*discosrv.DatabaseAddress{
+		{Address: "tcp://1.2.3.4:5", Expires: tc.Now().Add(time.Minute).UnixNano()},
+	}
+	if err := db.merge(&protocol.EmptyDeviceID, addrs, tc.Now().UnixNano()); err != nil {
+		t.Fatal(err)
+	}
+	tc.wind(30 * time.Second)
+	addrs = []*discosrv.DatabaseAddress{
+		{Address: "tcp://6.7.8.9:0", Expires: tc.Now().Add(time.Minute).UnixNano()},
+	}
+	if err := db.merge(&protocol.EmptyDeviceID, addrs, tc.Now().UnixNano()); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err = db.get(&protocol.EmptyDeviceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Addresses) != 2 {
+		t.Fatal("should have two addresses, got", rec.Addresses)
+	}
+
+	// The first address expires
+
+	tc.wind(45 * time.Second)
+	rec, err = db.get(&protocol.EmptyDeviceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Addresses) != 1 || rec.Addresses[0].Address != "tcp://6.7.8.9:0" {
+		t.Fatal("should have the second address only, got", rec.Addresses)
+	}
+
+	// The sweep removes the expired address, and a week later the record
+
+	db.expireAndCalculateStatistics()
+	raw, err := db.getLocked(&protocol.EmptyDeviceID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raw.Addresses) != 1 {
+		t.Fatal("expired address should have been removed, got", raw.Addresses)
+	}
+
+	tc.wind(8 * 24 * time.Hour)
+	db.expireAndCalculateStatistics()
+	if _, err := db.db.Get(protocol.EmptyDeviceID[:]); !backend.IsNotFound(err) {
+		t.Fatal("record should have been removed, got", err)
+	}
+}
+
+func TestKVStoreImportSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	mem := newInMemoryStore(dir, 0, nil)
+	devs := []protocol.DeviceID{{1}, {2}, {3}}
+	for _, dev := range devs {
+		addrs := []*discosrv.DatabaseAddress{
+			{Address: "tcp://1.2.3.4:5", Expires: time.Now().Add(time.Hour).UnixNano()},
+		}
+		if err := mem.merge(&dev, addrs, time.Now().UnixNano()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mem.write(); err != nil {
+		t.Fatal(err)
+	}
+
+	db := newKVStore(backend.OpenMemory())
+	defer db.db.Close()
+	nr, err := db.importSnapshot(path.Join(dir, "records.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nr != len(devs) {
+		t.Fatalf("imported %d records, expected %d", nr, len(devs))
+	}
+	for _, dev := range devs {
+		rec, err := db.get(&dev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rec.Addresses) != 1 {
+			t.Errorf("device %v should have one address, got %v", dev, rec.Addresses)
+		}
+	}
+
+	// Importing again doesn't overwrite what's in the store.
+	nr, err = db.importSnapshot(path.Join(dir, "records.db"))
+	if err != nil || nr != 0 {
+		t.Fatal("should not import into a non-empty store:", nr, err)
+	}
+}
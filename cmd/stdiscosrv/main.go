@@ -14,6 +14,7 @@ import (
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
+	"path"
 	"runtime"
 	"time"
 
@@ -26,6 +27,7 @@ import (
 	"github.com/syncthing/syncthing/internal/blob/s3"
 	_ "github.com/syncthing/syncthing/lib/automaxprocs"
 	"github.com/syncthing/syncthing/lib/build"
+	"github.com/syncthing/syncthing/lib/db/backend"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/rand"
 	"github.com/syncthing/syncthing/lib/tlsutil"
@@ -68,8 +70,11 @@ type CLI struct {
 	MetricsListen       string  `group:"Listen" help:"Metrics listen address" env:"DISCOVERY_METRICS_LISTEN"`
 	DesiredNotFoundRate float64 `group:"Listen" help:"Desired maximum rate of not-found replies (/s)" default:"1000"`
 
+	PolicyFile string `group:"Access control" help:"Policy file restricting who may announce and query, reloaded on change" env:"DISCOVERY_POLICY_FILE"`
+
 	DBDir           string        `group:"Database" help:"Database directory" default:"." env:"DISCOVERY_DB_DIR"`
 	DBFlushInterval time.Duration `group:"Database" help:"Interval between database flushes" default:"5m" env:"DISCOVERY_DB_FLUSH_INTERVAL"`
+	DBBackend       string        `group:"Database" help:"Database backend (memory, leveldb)" enum:"memory,leveldb" default:"memory" env:"DISCOVERY_DB_BACKEND"`
 
 	DBS3Endpoint    string `name:"db-s3-endpoint" group:"Database (S3 backup)" hidden:"true" help:"S3 endpoint for database" env:"DISCOVERY_DB_S3_ENDPOINT"`
 	DBS3Region      string `name:"db-s3-region" group:"Database (S3 backup)" hidden:"true" help:"S3 region for database" env:"DISCOVERY_DB_S3_REGION"`
@@ -83,6 +88,9 @@ type CLI struct {
 
 	AMQPAddress string `group:"AMQP replication" hidden:"true" help:"Address to AMQP broker" env:"DISCOVERY_AMQP_ADDRESS"`
 
+	ReplicationListen string   `group:"Peer replication" help:"Listen address for replication from peers" env:"DISCOVERY_REPLICATION_LISTEN"`
+	ReplicationPeers  []string `group:"Peer replication" help:"Peers to replicate to, as DEVICEID@host:port" env:"DISCOVERY_REPLICATION_PEERS"`
+
 	Debug   bool `short:"d" help:"Print debug output" env:"DISCOVERY_DEBUG"`
 	Version bool `short:"v" help:"Print version and exit"`
 }
@@ -101,8 +109,18 @@ func main() {
 
 	buildInfo.WithLabelValues(build.Version, runtime.Version(), build.User, build.Date.UTC().Format("2006-01-02T15:04:05Z")).Set(1)
 
+	peers, err := parseReplicationPeers(cli.ReplicationPeers)
+	if err != nil {
+		log.Fatalln("Failed to parse replication peers:", err)
+	}
+	peerReplication := len(peers) > 0 || cli.ReplicationListen != ""
+	if peerReplication && cli.AMQPAddress != "" {
+		log.Fatalln("AMQP and peer replication are mutually exclusive")
+	}
+
+	// The certificate is also our identity towards replication peers.
 	var cert tls.Certificate
-	if !cli.HTTP {
+	if !cli.HTTP || peerReplication {
 		var err error
 		cert, err = tls.LoadX509KeyPair(cli.Cert, cli.Key)
 		if os.IsNotExist(err) {
@@ -126,7 +144,6 @@ func main() {
 
 	// If configured, use blob storage for database backups.
 	var blobs blob.Store
-	var err error
 	if cli.DBS3Endpoint != "" {
 		blobs, err = s3.NewSession(cli.DBS3Endpoint, cli.DBS3Region, cli.DBS3Bucket, cli.DBS3AccessKeyID, cli.DBS3SecretKey)
 	} else if cli.DBAzureBlobAccount != "" {
@@ -137,8 +154,31 @@ func main() {
 	}
 
 	// Start the database.
-	db := newInMemoryStore(cli.DBDir, cli.DBFlushInterval, blobs)
-	main.Add(db)
+	var db database
+	switch cli.DBBackend {
+	case "leveldb":
+		if blobs != nil {
+			log.Println("Blob storage backups are not supported with the leveldb backend; ignoring")
+		}
+		be, err := backend.OpenLevelDBAuto(path.Join(cli.DBDir, "records.leveldb"))
+		if err != nil {
+			log.Fatalln("Failed to open database:", err)
+		}
+		kv := newKVStore(be)
+		// Carry over the records when switching from the memory backend.
+		nr, err := kv.importSnapshot(path.Join(cli.DBDir, "records.db"))
+		if err != nil {
+			log.Println("Error importing database snapshot:", err)
+		} else if nr > 0 {
+			log.Printf("Imported %d records from database snapshot", nr)
+		}
+		main.Add(kv)
+		db = kv
+	default:
+		mem := newInMemoryStore(cli.DBDir, cli.DBFlushInterval, blobs)
+		main.Add(mem)
+		db = mem
+	}
 
 	// If we have an AMQP broker for replication, start that
 	var repl replicator
@@ -149,8 +189,25 @@ func main() {
 		repl = kr
 	}
 
+	// If we have replication peers, replicate directly with them.
+	if peerReplication {
+		pr := newPeerReplicator(cli.ReplicationListen, peers, cert, db)
+		main.Add(pr)
+		repl = pr
+	}
+
+	// If we have a policy file, enforce and keep watching it.
+	var policy *policyLoader
+	if cli.PolicyFile != "" {
+		policy, err = newPolicyLoader(cli.PolicyFile)
+		if err != nil {
+			log.Fatalln("Failed to load policy file:", err)
+		}
+		main.Add(policy)
+	}
+
 	// Start the main API server.
-	qs := newAPISrv(cli.Listen, cert, db, repl, cli.HTTP, cli.Compression, cli.DesiredNotFoundRate)
+	qs := newAPISrv(cli.Listen, cert, db, repl, policy, cli.HTTP, cli.Compression, cli.DesiredNotFoundRate)
 	main.Add(qs)
 
 	// If we have a metrics port configured, start a metrics handler.
@@ -0,0 +1,284 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+const policyReloadInterval = 10 * time.Second
+
+// The policy file restricts who may announce and who may query. A missing
+// section allows anyone; a present one allows only the clients it lists,
+// by device ID, certificate name (common name or DNS name) or network.
+// Syncthing doesn't present its certificate for lookups, so query access
+// for Syncthing devices is usually given by network.
+//
+// Anyone can create a certificate with any name, so names are only
+// accepted from certificates issued by one of the certificate authorities
+// in the given PEM bundle. A relative path is relative to the policy file,
+// and the bundle is read again when the policy file changes.
+//
+//	{
+//	  "certificateAuthorities": "ca.pem",
+//	  "announce": {
+//	    "devices": ["MFZWI3D-BONSGYC-YLTMRWG-C43ENR5-QXGZDMM-FZWI3DP-BONSGYY-LTMRWAD"],
+//	    "certificateNames": ["nas.example.com"]
+//	  },
+//	  "query": {
+//	    "networks": ["10.0.0.0/8", "fd00::/8"]
+//	  }
+//	}
+type policyFile struct {
+	CertificateAuthorities string          `json:"certificateAuthorities"`
+	Announce               *policyRuleFile `json:"announce"`
+	Query                  *policyRuleFile `json:"query"`
+}
+
+type policyRuleFile struct {
+	Devices          []string `json:"devices"`
+	CertificateNames []string `json:"certificateNames"`
+	Networks         []string `json:"networks"`
+}
+
+type accessPolicy struct {
+	announce *accessRule // nil allows everyone
+	query    *accessRule // nil allows everyone
+}
+
+type accessRule struct {
+	devices  map[protocol.DeviceID]struct{}
+	names    map[string]struct{}
+	roots    *x509.CertPool // issuers of certificates whose names we trust
+	networks []*net.IPNet
+}
+
+// parsePolicy parses the policy file, with relative paths in it being
+// relative to dir.
+func parsePolicy(bs []byte, dir string) (*accessPolicy, error) {
+	var pf policyFile
+	dec := json.NewDecoder(bytes.NewReader(bs))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&pf); err != nil {
+		return nil, err
+	}
+
+	var roots *x509.CertPool
+	if pf.CertificateAuthorities != "" {
+		caFile := pf.CertificateAuthorities
+		if !filepath.IsAbs(caFile) {
+			caFile = filepath.Join(dir, caFile)
+		}
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("certificate authorities: %w", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("certificate authorities: no certificates in %s", caFile)
+		}
+	}
+
+	var p accessPolicy
+	var err error
+	if p.announce, err = parseAccessRule(pf.Announce, roots); err != nil {
+		return nil, fmt.Errorf("announce: %w", err)
+	}
+	if p.query, err = parseAccessRule(pf.Query, roots); err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	return &p, nil
+}
+
+func parseAccessRule(rf *policyRuleFile, roots *x509.CertPool) (*accessRule, error) {
+	if rf == nil {
+		return nil, nil
+	}
+	if len(rf.CertificateNames) > 0 && roots == nil {
+		return nil, errors.New("certificate names require certificate authorities to verify them against")
+	}
+	r := &accessRule{
+		devices: make(map[protocol.DeviceID]struct{}, len(rf.Devices)),
+		names:   make(map[string]struct{}, len(rf.CertificateNames)),
+		roots:   roots,
+	}
+	for _, s := range rf.Devices {
+		id, err := protocol.DeviceIDFromString(s)
+		if err != nil {
+			return nil, fmt.Errorf("device %q: %w", s, err)
+		}
+		r.devices[id] = struct{}{}
+	}
+	for _, s := range rf.CertificateNames {
+		r.names[strings.ToLower(s)] = struct{}{}
+	}
+	for _, s := range rf.Networks {
+		_, ipnet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("network %q: %w", s, err)
+		}
+		r.networks = append(r.networks, ipnet)
+	}
+	return r, nil
+}
+
+// allowsAnnounce returns whether the device with the given certificate
+// may announce from the given IP.
+func (p *accessPolicy) allowsAnnounce(rawCert []byte, ip net.IP) bool {
+	if p == nil || p.announce == nil {
+		return true
+	}
+	return p.announce.allows(rawCert, ip)
+}
+
+// allowsQuery returns whether the client may query from the given IP,
+// considering the certificate it presented with the request, if any.
+func (p *accessPolicy) allowsQuery(rawCert []byte, ip net.IP) bool {
+	if p == nil || p.query == nil {
+		return true
+	}
+	return p.query.allows(rawCert, ip)
+}
+
+func (r *accessRule) allows(rawCert []byte, ip net.IP) bool {
+	if rawCert != nil {
+		if _, ok := r.devices[protocol.NewDeviceID(rawCert)]; ok {
+			return true
+		}
+		if len(r.names) > 0 && r.allowsNameOf(rawCert) {
+			return true
+		}
+	}
+	if ip != nil {
+		for _, ipnet := range r.networks {
+			if ipnet.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowsNameOf returns whether the certificate has one of the allowed
+// names and is issued by one of the certificate authorities.
+func (r *accessRule) allowsNameOf(rawCert []byte) bool {
+	cert, err := x509.ParseCertificate(rawCert)
+	if err != nil {
+		return false
+	}
+	named := false
+	if _, ok := r.names[strings.ToLower(cert.Subject.CommonName)]; ok {
+		named = true
+	}
+	for _, name := range cert.DNSNames {
+		if _, ok := r.names[strings.ToLower(name)]; ok {
+			named = true
+		}
+	}
+	if !named {
+		return false
+	}
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:     r.roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return err == nil
+}
+
+// The policyLoader keeps the access policy up to date with the policy
+// file, reloading it when it changes. A file that fails to load leaves the
+// previous policy in place.
+type policyLoader struct {
+	path    string
+	current atomic.Pointer[accessPolicy]
+	modTime time.Time
+	size    int64
+}
+
+func newPolicyLoader(path string) (*policyLoader, error) {
+	l := &policyLoader{path: path}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// policy returns the current policy; nil allows everything.
+func (l *policyLoader) policy() *accessPolicy {
+	if l == nil {
+		return nil
+	}
+	return l.current.Load()
+}
+
+func (l *policyLoader) Serve(ctx context.Context) error {
+	t := time.NewTicker(policyReloadInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := l.reloadIfChanged(); err != nil {
+				log.Println("Error reloading policy file:", err)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (l *policyLoader) String() string {
+	return fmt.Sprintf("policyLoader(%q)", l.path)
+}
+
+func (l *policyLoader) reloadIfChanged() error {
+	fi, err := os.Stat(l.path)
+	if err != nil {
+		return err
+	}
+	if fi.ModTime().Equal(l.modTime) && fi.Size() == l.size {
+		return nil
+	}
+	if err := l.load(); err != nil {
+		return err
+	}
+	log.Println("Reloaded policy file", l.path)
+	return nil
+}
+
+func (l *policyLoader) load() error {
+	fi, err := os.Stat(l.path)
+	if err != nil {
+		return err
+	}
+	// Remember the version even if it's bad, so we complain about it once
+	// rather than on every check.
+	l.modTime, l.size = fi.ModTime(), fi.Size()
+	bs, err := os.ReadFile(l.path)
+	if err != nil {
+		return err
+	}
+	p, err := parsePolicy(bs, filepath.Dir(l.path))
+	if err != nil {
+		return fmt.Errorf("%s: %w", l.path, err)
+	}
+	l.current.Store(p)
+	return nil
+}
@@ -0,0 +1,338 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/tlsutil"
+)
+
+func TestParsePolicy(t *testing.T) {
+	devID := protocol.DeviceID{1, 2, 3}
+
+	p, err := parsePolicy([]byte(`{"query": {"networks": ["10.0.0.0/8"]}}`), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.announce != nil || p.query == nil || len(p.query.networks) != 1 {
+		t.Error("unexpected policy", p)
+	}
+
+	for _, bad := range []string{
+		`{"announce": {"devices": ["foo"]}}`,
+		`{"query": {"networks": ["10.0.0.0"]}}`,
+		`{"query": {"network": ["10.0.0.0/8"]}}`,
+		`{"announce": {"devices": ["` + devID.String() + `"]`,
+		// Names need certificate authorities to verify them
+		`{"announce": {"certificateNames": ["nas.example.com"]}}`,
+		`{"certificateAuthorities": "missing.pem", "announce": {"certificateNames": ["nas.example.com"]}}`,
+	} {
+		if _, err := parsePolicy([]byte(bad), t.TempDir()); err == nil {
+			t.Errorf("expected an error for %s", bad)
+		}
+	}
+}
+
+func TestAccessPolicy(t *testing.T) {
+	cert, err := tlsutil.NewCertificateInMemory("nas.example.com", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := tlsutil.NewCertificateInMemory("syncthing", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	devID := protocol.NewDeviceID(cert.Certificate[0])
+	inside, outside := net.ParseIP("10.1.2.3"), net.ParseIP("192.0.2.1")
+
+	dir := t.TempDir()
+	issued := newTestCA(t, filepath.Join(dir, "ca.pem")).issue(t, "nas.example.com")
+	const names = `{"certificateAuthorities": "ca.pem", "announce": {"certificateNames": ["NAS.example.com"]}}`
+
+	cases := []struct {
+		policy  string
+		rawCert []byte
+		ip      net.IP
+		allowed bool
+	}{
+		{`{}`, other.Certificate[0], outside, true},
+		{`{"announce": {}}`, cert.Certificate[0], inside, false},
+		{`{"announce": {"devices": ["` + devID.String() + `"]}}`, cert.Certificate[0], outside, true},
+		{`{"announce": {"devices": ["` + devID.String() + `"]}}`, other.Certificate[0], outside, false},
+		{names, issued, outside, true},
+		// Self-signed certificates can have any name
+		{names, cert.Certificate[0], outside, false},
+		{names, other.Certificate[0], outside, false},
+		{`{"announce": {"networks": ["10.0.0.0/8"]}}`, other.Certificate[0], inside, true},
+		{`{"announce": {"networks": ["10.0.0.0/8"]}}`, other.Certificate[0], outside, false},
+		// Query rules don't apply to announcements
+		{`{"query": {}}`, other.Certificate[0], outside, true},
+	}
+	for _, tc := range cases {
+		p, err := parsePolicy([]byte(tc.policy), dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed := p.allowsAnnounce(tc.rawCert, tc.ip); allowed != tc.allowed {
+			t.Errorf("policy %s from %v: allowed %v, expected %v", tc.policy, tc.ip, allowed, tc.allowed)
+		}
+	}
+
+	// Queries without a certificate can only be allowed by network.
+	p, err := parsePolicy([]byte(`{"query": {"devices": ["`+devID.String()+`"], "networks": ["10.0.0.0/8"]}}`), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.allowsQuery(nil, inside) || p.allowsQuery(nil, outside) {
+		t.Error("expected queries to be allowed from inside the network only")
+	}
+	if !p.allowsQuery(cert.Certificate[0], outside) {
+		t.Error("expected queries to be allowed with the device certificate")
+	}
+}
+
+func TestPolicyLoaderReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(path, []byte(`{"announce": {}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := newPolicyLoader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.policy().announce == nil {
+		t.Fatal("expected an announce rule")
+	}
+
+	// A broken file keeps the previous policy.
+	if err := os.WriteFile(path, []byte(`{"announce": {"devices": ["foo"]}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.reloadIfChanged(); err == nil {
+		t.Error("expected an error for the broken policy")
+	}
+	if l.policy().announce == nil {
+		t.Fatal("expected the previous policy to remain")
+	}
+
+	// A fixed file is picked up.
+	if err := os.WriteFile(path, []byte(`{"query": {"networks": ["10.0.0.0/8"]}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.reloadIfChanged(); err != nil {
+		t.Fatal(err)
+	}
+	if p := l.policy(); p.announce != nil || p.query == nil {
+		t.Error("expected the new policy, got", p)
+	}
+}
+
+func TestAPIPolicy(t *testing.T) {
+	allowed, err := tlsutil.NewCertificateInMemory("syncthing", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	denied, err := tlsutil.NewCertificateInMemory("syncthing", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowedID := protocol.NewDeviceID(allowed.Certificate[0])
+
+	path := filepath.Join(t.TempDir(), "policy.json")
+	policy := `{"announce": {"devices": ["` + allowedID.String() + `"]}, "query": {"networks": ["192.0.2.0/24"]}}`
+	if err := os.WriteFile(path, []byte(policy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := newPolicyLoader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := newInMemoryStore(t.TempDir(), 0, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go db.Serve(ctx)
+	api := newAPISrv("127.0.0.1:0", tls.Certificate{}, db, nil, l, true, false, 1000)
+	srv := httptest.NewServer(http.HandlerFunc(api.handler))
+	defer srv.Close()
+
+	do := func(method string, cert tls.Certificate, forwardedFor string) *http.Response {
+		t.Helper()
+		var body string
+		if method == http.MethodPost {
+			body = `{"addresses":["tcp://192.0.2.42:22000"]}`
+		}
+		req, _ := http.NewRequest(method, srv.URL+"/v2/?device="+allowedID.String(), strings.NewReader(body))
+		req.Header.Set("X-Tls-Client-Cert-Der-Base64", base64.StdEncoding.EncodeToString(cert.Certificate[0]))
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := do(http.MethodPost, denied, "192.0.2.1"); resp.StatusCode != http.StatusForbidden {
+		t.Error("expected unknown devices to be denied, got", resp.Status)
+	}
+	if resp := do(http.MethodPost, allowed, "192.0.2.1"); resp.StatusCode != http.StatusNoContent {
+		t.Error("expected the allowed device to announce, got", resp.Status)
+	}
+	if resp := do(http.MethodGet, denied, "198.51.100.1"); resp.StatusCode != http.StatusForbidden {
+		t.Error("expected queries from outside the network to be denied, got", resp.Status)
+	}
+	if resp := do(http.MethodGet, denied, "192.0.2.1"); resp.StatusCode != http.StatusOK {
+		t.Error("expected queries from the network to be allowed, got", resp.Status)
+	}
+}
+
+func TestAPIPolicyTLS(t *testing.T) {
+	allowed, err := tlsutil.NewCertificateInMemory("syncthing", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	denied, err := tlsutil.NewCertificateInMemory("syncthing", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowedID := protocol.NewDeviceID(allowed.Certificate[0])
+
+	path := filepath.Join(t.TempDir(), "policy.json")
+	policy := `{"announce": {"devices": ["` + allowedID.String() + `"]}, "query": {"devices": ["` + allowedID.String() + `"]}}`
+	if err := os.WriteFile(path, []byte(policy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := newPolicyLoader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := newInMemoryStore(t.TempDir(), 0, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go db.Serve(ctx)
+	api := newAPISrv("127.0.0.1:0", tls.Certificate{}, db, nil, l, false, false, 1000)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(api.handler))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequestClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+
+	// Without a proxy in front, certificate headers are set by the client
+	// and must not be trusted.
+	do := func(method string, certs []tls.Certificate) *http.Response {
+		t.Helper()
+		var body string
+		if method == http.MethodPost {
+			body = `{"addresses":["tcp://192.0.2.42:22000"]}`
+		}
+		req, _ := http.NewRequest(method, srv.URL+"/v2/?device="+allowedID.String(), strings.NewReader(body))
+		req.Header.Set("X-Tls-Client-Cert-Der-Base64", base64.StdEncoding.EncodeToString(allowed.Certificate[0]))
+		tr := srv.Client().Transport.(*http.Transport).Clone()
+		tr.TLSClientConfig.Certificates = certs
+		defer tr.CloseIdleConnections()
+		resp, err := (&http.Client{Transport: tr}).Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	for _, certs := range [][]tls.Certificate{nil, {denied}} {
+		if resp := do(http.MethodPost, certs); resp.StatusCode != http.StatusForbidden {
+			t.Error("expected an announcement with a forged header to be denied, got", resp.Status)
+		}
+		if resp := do(http.MethodGet, certs); resp.StatusCode != http.StatusForbidden {
+			t.Error("expected a query with a forged header to be denied, got", resp.Status)
+		}
+	}
+	if resp := do(http.MethodPost, []tls.Certificate{allowed}); resp.StatusCode != http.StatusNoContent {
+		t.Error("expected the allowed device to announce, got", resp.Status)
+	}
+	if resp := do(http.MethodGet, []tls.Certificate{allowed}); resp.StatusCode != http.StatusOK {
+		t.Error("expected the allowed device to query, got", resp.Status)
+	}
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCA creates a certificate authority, writing its certificate to
+// the given file.
+func newTestCA(t *testing.T, path string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+// issue returns a client certificate with the given name, issued by the
+// certificate authority.
+func (ca *testCA) issue(t *testing.T, name string) []byte {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
@@ -0,0 +1,293 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/thejerf/suture/v4"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/syncthing/syncthing/internal/gen/discosrv"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/tlsutil"
+)
+
+const (
+	replicationDialTimeout  = 10 * time.Second
+	replicationWriteTimeout = 30 * time.Second
+)
+
+// A replicationPeer is another discovery server we replicate our records
+// to, identified by the device ID of its certificate.
+type replicationPeer struct {
+	id   protocol.DeviceID
+	addr string
+}
+
+// parseReplicationPeers parses peers given as "DEVICEID@host:port".
+func parseReplicationPeers(ss []string) ([]replicationPeer, error) {
+	peers := make([]replicationPeer, 0, len(ss))
+	for _, s := range ss {
+		idStr, addr, ok := strings.Cut(s, "@")
+		if !ok {
+			return nil, fmt.Errorf("replication peer %q: expected DEVICEID@host:port", s)
+		}
+		id, err := protocol.DeviceIDFromString(idStr)
+		if err != nil {
+			return nil, fmt.Errorf("replication peer %q: %w", s, err)
+		}
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			return nil, fmt.Errorf("replication peer %q: %w", s, err)
+		}
+		peers = append(peers, replicationPeer{id: id, addr: addr})
+	}
+	return peers, nil
+}
+
+// The peerReplicator replicates records directly between discovery
+// servers over mutually authenticated TLS, without a message broker. Each
+// server sends its announcements to every peer, so the peers must form a
+// full mesh. On connecting, a sender first sends all records it has, so
+// that peers catch up on what they missed while disconnected; since
+// merging records is idempotent and commutative (see merge) the servers
+// converge on the same records.
+type peerReplicator struct {
+	suture.Service
+	senders []*peerSender
+}
+
+func newPeerReplicator(listen string, peers []replicationPeer, cert tls.Certificate, db database) *peerReplicator {
+	svc := suture.New("peerReplicator", suture.Spec{PassThroughPanics: true})
+
+	allowed := make(map[protocol.DeviceID]struct{}, len(peers))
+	senders := make([]*peerSender, 0, len(peers))
+	for _, peer := range peers {
+		allowed[peer.id] = struct{}{}
+		sender := &peerSender{
+			peer:   peer,
+			cert:   cert,
+			db:     db,
+			outbox: make(chan *discosrv.ReplicationRecord, replicationOutboxSize),
+		}
+		svc.Add(sender)
+		senders = append(senders, sender)
+	}
+
+	if listen != "" {
+		svc.Add(&peerReceiver{
+			listen:  listen,
+			cert:    cert,
+			allowed: allowed,
+			db:      db,
+		})
+	}
+
+	return &peerReplicator{
+		Service: svc,
+		senders: senders,
+	}
+}
+
+func (s *peerReplicator) send(key *protocol.DeviceID, ps []*discosrv.DatabaseAddress, seen int64) {
+	// The addresses are shared with the database, which may modify them
+	// before the senders get around to it.
+	item := &discosrv.ReplicationRecord{
+		Key:       key[:],
+		Addresses: ps,
+		Seen:      seen,
+	}
+	item = proto.Clone(item).(*discosrv.ReplicationRecord)
+
+	for _, sender := range s.senders {
+		// The send should never block. A peer that falls behind gets
+		// everything again on reconnecting anyway.
+		select {
+		case sender.outbox <- item:
+		default:
+			replicationSendsTotal.WithLabelValues("drop").Inc()
+		}
+	}
+}
+
+type peerSender struct {
+	peer   replicationPeer
+	cert   tls.Certificate
+	db     database
+	outbox chan *discosrv.ReplicationRecord
+}
+
+func (s *peerSender) Serve(ctx context.Context) error {
+	tlsCfg := tlsutil.SecureDefaultTLS13()
+	tlsCfg.Certificates = []tls.Certificate{s.cert}
+	tlsCfg.InsecureSkipVerify = true // we verify the device ID below
+
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: replicationDialTimeout},
+		Config:    tlsCfg,
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", s.peer.addr)
+	if err != nil {
+		return fmt.Errorf("replication dial: %w", err)
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := verifyPeer(conn.(*tls.Conn), s.peer.id); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(conn)
+	var buf []byte
+	write := func(rec *discosrv.ReplicationRecord) error {
+		if err := conn.SetWriteDeadline(time.Now().Add(replicationWriteTimeout)); err != nil {
+			return err
+		}
+		var err error
+		buf, err = writeRecord(bw, rec, buf)
+		if err != nil {
+			replicationSendsTotal.WithLabelValues("error").Inc()
+			return fmt.Errorf("replication write: %w", err)
+		}
+		replicationSendsTotal.WithLabelValues("success").Inc()
+		return nil
+	}
+
+	// Bring the peer up to date with everything we know.
+	var werr error
+	err = s.db.iterate(func(key protocol.DeviceID, rec *discosrv.DatabaseRecord) bool {
+		werr = write(&discosrv.ReplicationRecord{Key: key[:], Addresses: rec.Addresses, Seen: rec.Seen})
+		return werr == nil
+	})
+	if err == nil {
+		err = werr
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		return ctxErr(ctx, err)
+	}
+
+	for {
+		select {
+		case rec := <-s.outbox:
+			if err := write(rec); err != nil {
+				return ctxErr(ctx, err)
+			}
+			if len(s.outbox) == 0 {
+				if err := bw.Flush(); err != nil {
+					return ctxErr(ctx, fmt.Errorf("replication write: %w", err))
+				}
+			}
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (s *peerSender) String() string {
+	return fmt.Sprintf("peerSender(%s@%s)", s.peer.id.Short(), s.peer.addr)
+}
+
+type peerReceiver struct {
+	listen  string
+	cert    tls.Certificate
+	allowed map[protocol.DeviceID]struct{}
+	db      database
+}
+
+func (s *peerReceiver) Serve(ctx context.Context) error {
+	tlsCfg := tlsutil.SecureDefaultTLS13()
+	tlsCfg.Certificates = []tls.Certificate{s.cert}
+	tlsCfg.ClientAuth = tls.RequireAnyClientCert
+
+	listener, err := tls.Listen("tcp", s.listen, tlsCfg)
+	if err != nil {
+		return fmt.Errorf("replication listen: %w", err)
+	}
+	defer listener.Close()
+	stop := context.AfterFunc(ctx, func() { listener.Close() })
+	defer stop()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return ctxErr(ctx, fmt.Errorf("replication accept: %w", err))
+		}
+		go s.handle(ctx, conn.(*tls.Conn))
+	}
+}
+
+func (s *peerReceiver) handle(ctx context.Context, conn *tls.Conn) {
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	hctx, cancel := context.WithTimeout(ctx, replicationDialTimeout)
+	err := conn.HandshakeContext(hctx)
+	cancel()
+	if err != nil {
+		if debug {
+			log.Println("Replication handshake:", conn.RemoteAddr(), err)
+		}
+		return
+	}
+	id := protocol.NewDeviceID(conn.ConnectionState().PeerCertificates[0].Raw)
+	if _, ok := s.allowed[id]; !ok {
+		log.Println("Replication connection from unknown peer", id, "at", conn.RemoteAddr())
+		return
+	}
+
+	_, err = readRecords(bufio.NewReader(conn), func(key protocol.DeviceID, rec *discosrv.DatabaseRecord) error {
+		if err := s.db.merge(&key, rec.Addresses, rec.Seen); err != nil {
+			replicationRecvsTotal.WithLabelValues("error").Inc()
+			return fmt.Errorf("replication database merge: %w", err)
+		}
+		replicationRecvsTotal.WithLabelValues("success").Inc()
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Printf("Replication from %s at %s: %v", id, conn.RemoteAddr(), err)
+	}
+}
+
+func (s *peerReceiver) String() string {
+	return fmt.Sprintf("peerReceiver(%q)", s.listen)
+}
+
+// verifyPeer checks that the other side of the connection has the
+// certificate of the given device.
+func verifyPeer(conn *tls.Conn, id protocol.DeviceID) error {
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return errors.New("replication peer presented no certificate")
+	}
+	if got := protocol.NewDeviceID(certs[0].Raw); got != id {
+		return fmt.Errorf("replication peer has device ID %s, expected %s", got, id)
+	}
+	return nil
+}
+
+// ctxErr returns nil if the context is done, as errors are then the
+// result of closing the connection, and err otherwise.
+func ctxErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,175 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/syncthing/syncthing/internal/gen/discosrv"
+	"github.com/syncthing/syncthing/lib/db/backend"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/tlsutil"
+)
+
+func TestParseReplicationPeers(t *testing.T) {
+	id := protocol.DeviceID{1, 2, 3}
+	peers, err := parseReplicationPeers([]string{id.String() + "@192.0.2.1:19300", id.String() + "@[2001:db8::1]:19300"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 2 || peers[0].id != id || peers[1].addr != "[2001:db8::1]:19300" {
+		t.Error("unexpected peers", peers)
+	}
+
+	for _, bad := range []string{"192.0.2.1:19300", "foo@192.0.2.1:19300", id.String() + "@192.0.2.1"} {
+		if _, err := parseReplicationPeers([]string{bad}); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestPeerReplication(t *testing.T) {
+	certA, err := tlsutil.NewCertificateInMemory("stdiscosrv", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certB, err := tlsutil.NewCertificateInMemory("stdiscosrv", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrA, addrB := freeAddress(t), freeAddress(t)
+
+	dbA := newKVStore(backend.OpenMemory())
+	dbB := newInMemoryStore(t.TempDir(), 0, nil)
+
+	// A record that A has before the peers connect.
+	devA := protocol.DeviceID{1}
+	addrs := []*discosrv.DatabaseAddress{{Address: "tcp://192.0.2.1:22000", Expires: time.Now().Add(time.Hour).UnixNano()}}
+	if err := dbA.merge(&devA, addrs, time.Now().UnixNano()); err != nil {
+		t.Fatal(err)
+	}
+
+	replA := newPeerReplicator(addrA, []replicationPeer{{protocol.NewDeviceID(certB.Certificate[0]), addrB}}, certA, dbA)
+	replB := newPeerReplicator(addrB, []replicationPeer{{protocol.NewDeviceID(certA.Certificate[0]), addrA}}, certB, dbB)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dbA.Serve(ctx)
+	go replA.Serve(ctx)
+	go replB.Serve(ctx)
+
+	// B gets the existing record when A connects.
+	waitForAddresses(t, dbB, devA, 1)
+
+	// An announcement to B is replicated to A as it happens.
+	devB := protocol.DeviceID{2}
+	addrs = []*discosrv.DatabaseAddress{{Address: "tcp://192.0.2.2:22000", Expires: time.Now().Add(time.Hour).UnixNano()}}
+	replB.send(&devB, addrs, time.Now().UnixNano())
+	if err := dbB.merge(&devB, addrs, time.Now().UnixNano()); err != nil {
+		t.Fatal(err)
+	}
+	waitForAddresses(t, dbA, devB, 1)
+}
+
+func TestPeerReplicationUnknownPeer(t *testing.T) {
+	certA, err := tlsutil.NewCertificateInMemory("stdiscosrv", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certB, err := tlsutil.NewCertificateInMemory("stdiscosrv", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrB := freeAddress(t)
+
+	// B only accepts replication from some other device.
+	dbA := newKVStore(backend.OpenMemory())
+	dbB := newKVStore(backend.OpenMemory())
+	replB := newPeerReplicator(addrB, []replicationPeer{{protocol.DeviceID{42}, "127.0.0.1:1"}}, certB, dbB)
+	sender := &peerSender{
+		peer:   replicationPeer{protocol.NewDeviceID(certB.Certificate[0]), addrB},
+		cert:   certA,
+		db:     dbA,
+		outbox: make(chan *discosrv.ReplicationRecord, 1),
+	}
+
+	dev := protocol.DeviceID{1}
+	addrs := []*discosrv.DatabaseAddress{{Address: "tcp://192.0.2.1:22000", Expires: time.Now().Add(time.Hour).UnixNano()}}
+	if err := dbA.merge(&dev, addrs, time.Now().UnixNano()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go replB.Serve(ctx)
+	go sender.Serve(ctx)
+
+	time.Sleep(time.Second)
+	rec, err := dbB.get(&dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Addresses) != 0 {
+		t.Error("records from unknown peers should be ignored, got", rec.Addresses)
+	}
+}
+
+func TestMergeConverges(t *testing.T) {
+	// Replicas merging the same updates in any order, any number of times,
+	// end up with the same record.
+	updates := []*discosrv.DatabaseRecord{
+		{Seen: 1, Addresses: []*discosrv.DatabaseAddress{{Address: "a", Expires: 10}, {Address: "b", Expires: 10}}},
+		{Seen: 3, Addresses: []*discosrv.DatabaseAddress{{Address: "b", Expires: 20}}},
+		{Seen: 2, Addresses: []*discosrv.DatabaseAddress{{Address: "a", Expires: 5}, {Address: "c", Expires: 15}}},
+	}
+	orders := [][]int{{0, 1, 2}, {2, 1, 0}, {1, 0, 2, 1}, {2, 2, 0, 1, 0}}
+
+	var expected string
+	for _, order := range orders {
+		rec := &discosrv.DatabaseRecord{}
+		for _, i := range order {
+			rec = merge(rec, proto.Clone(updates[i]).(*discosrv.DatabaseRecord))
+		}
+		res := fmt.Sprint(rec.Seen, rec.Addresses)
+		if expected == "" {
+			expected = res
+		} else if res != expected {
+			t.Errorf("order %v resulted in %v, expected %v", order, res, expected)
+		}
+	}
+}
+
+// freeAddress returns a localhost address that is free to listen on.
+func freeAddress(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func waitForAddresses(t *testing.T, db database, dev protocol.DeviceID, n int) {
+	t.Helper()
+	for start := time.Now(); time.Since(start) < 20*time.Second; time.Sleep(50 * time.Millisecond) {
+		rec, err := db.get(&dev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rec.Addresses) == n {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %d addresses for %v", n, dev)
+}
@@ -0,0 +1,21 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"io"
+	"log/syslog"
+)
+
+// auditSyslogWriter returns a writer sending each write, i.e. each audit
+// log entry, as a message to the local syslog daemon.
+func auditSyslogWriter() (io.Writer, error) {
+	return syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, "syncthing-audit")
+}
@@ -0,0 +1,19 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"errors"
+	"io"
+)
+
+func auditSyslogWriter() (io.Writer, error) {
+	return nil, errors.New("syslog is not supported on Windows")
+}
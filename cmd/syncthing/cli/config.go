@@ -53,7 +53,12 @@ func (c *configCommand) Run(ctx Context, _ *kong.Context) error {
 		return fmt.Errorf("config reflect: %w", err)
 	}
 
-	app.Commands = commands
+	app.Commands = append(commands, cli.Command{
+		Name:      "diff",
+		Usage:     "Show the changes that reconciling towards a desired state file would make",
+		ArgsUsage: "FILE",
+		Action:    h.diff,
+	})
 	app.HideHelp = true
 	app.Before = h.configBefore
 	app.After = h.configAfter
@@ -95,3 +100,25 @@ func (h *configHandler) configAfter(_ *cli.Context) error {
 	}
 	return nil
 }
+
+func (h *configHandler) diff(c *cli.Context) error {
+	if c.NArg() != 1 {
+		return errors.New("expected the path of a desired state file")
+	}
+	state, err := config.ReadDesiredState(c.Args().First())
+	if err != nil {
+		return err
+	}
+	myID, err := getMyID(h.client)
+	if err != nil {
+		return err
+	}
+	_, changes, err := state.Reconcile(h.cfg, myID)
+	if err != nil {
+		return err
+	}
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"net/url"
+)
+
+type conflictsCommand struct {
+	List    conflictsListCommand    `cmd:"" help:"List the conflict copies in a folder"`
+	Resolve conflictsResolveCommand `cmd:"" help:"Resolve a conflict by keeping either the conflict copy or the original; the other is versioned if the folder has versioning, otherwise deleted"`
+}
+
+type conflictsListCommand struct {
+	FolderID string `arg:""`
+}
+
+type conflictsResolveCommand struct {
+	FolderID string `arg:""`
+	File     string `arg:"" help:"Path of the conflict copy, relative to the folder"`
+	Keep     string `required:"" enum:"copy,original" help:"Which version to keep (copy, original)"`
+}
+
+func (c *conflictsListCommand) Run(ctx Context) error {
+	query := url.Values{}
+	query.Set("folder", c.FolderID)
+	return indexDumpOutput("folder/conflicts?"+query.Encode(), ctx.clientFactory)
+}
+
+func (c *conflictsResolveCommand) Run(ctx Context) error {
+	client, err := ctx.clientFactory.getClient()
+	if err != nil {
+		return err
+	}
+	query := url.Values{}
+	query.Set("folder", c.FolderID)
+	query.Set("file", c.File)
+	query.Set("keep", c.Keep)
+	_, err = client.Post("folder/conflicts?"+query.Encode(), "")
+	return err
+}
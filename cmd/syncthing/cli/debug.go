@@ -39,7 +39,9 @@ func (p *profileCommand) Run(ctx Context) error {
 }
 
 type debugCommand struct {
-	File    fileCommand    `cmd:"" help:"Show information about a file (or directory/symlink)"`
-	Profile profileCommand `cmd:"" help:"Save a profile to help figuring out what Syncthing does"`
-	Index   indexCommand   `cmd:"" help:"Show information about the index (database)"`
+	File     fileCommand     `cmd:"" help:"Show information about a file (or directory/symlink)"`
+	Profile  profileCommand  `cmd:"" help:"Save a profile to help figuring out what Syncthing does"`
+	Index    indexCommand    `cmd:"" help:"Show information about the index (database)"`
+	ExportDB exportDBCommand `cmd:"" name:"export-db" help:"Export the database to an archive (Syncthing must not be running)"`
+	ImportDB importDBCommand `cmd:"" name:"import-db" help:"Import the database from an archive on a fresh install (Syncthing must not be running)"`
 }
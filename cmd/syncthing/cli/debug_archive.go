@@ -0,0 +1,123 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/db/backend"
+)
+
+type exportDBCommand struct {
+	File      string `arg:"" help:"Archive file to write (\"-\" for stdout)"`
+	DBBackend string `name:"db-backend" enum:"leveldb,sqlite" default:"leveldb" env:"STDBBACKEND" help:"Database backend to export from (leveldb, sqlite)"`
+}
+
+func (c *exportDBCommand) Run() error {
+	var src backend.Backend
+	var err error
+	if c.DBBackend == "sqlite" {
+		src, err = backend.OpenSQLite(dbLocation(c.DBBackend), backend.TuningAuto)
+	} else {
+		src, err = getDB()
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	out := os.Stdout
+	if c.File != "-" {
+		out, err = os.Create(c.File)
+		if err != nil {
+			return err
+		}
+	}
+
+	trailer, err := db.ExportArchive(src, out)
+	if c.File != "-" {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(c.File)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("exporting database: %w", err)
+	}
+
+	names := make([]string, 0, len(trailer.Records))
+	for name := range trailer.Records {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "%s: %d records\n", name, trailer.Records[name])
+	}
+	return nil
+}
+
+type importDBCommand struct {
+	File      string `arg:"" help:"Archive file to read (\"-\" for stdin)"`
+	DBBackend string `name:"db-backend" enum:"leveldb,sqlite" default:"leveldb" env:"STDBBACKEND" help:"Database backend to import into (leveldb, sqlite)"`
+}
+
+func (c *importDBCommand) Run() error {
+	location := dbLocation(c.DBBackend)
+	if _, err := os.Stat(location); err == nil {
+		return fmt.Errorf("database %s already exists, remove it first (e.g. using serve --reset-database) to import", location)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if c.File != "-" {
+		fd, err := os.Open(c.File)
+		if err != nil {
+			return err
+		}
+		defer fd.Close()
+		in = fd
+	}
+
+	if err := os.MkdirAll(filepath.Dir(location), 0o700); err != nil {
+		return err
+	}
+	var dst backend.Backend
+	var err error
+	if c.DBBackend == "sqlite" {
+		dst, err = backend.OpenSQLite(location, backend.TuningAuto)
+	} else {
+		dst, err = backend.OpenLevelDB(location, backend.TuningAuto)
+	}
+	if err != nil {
+		return err
+	}
+
+	header, err := db.ImportArchive(dst, in)
+	if err == nil {
+		err = dst.Compact()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// Don't leave a partial database behind.
+		os.RemoveAll(location)
+		return fmt.Errorf("importing database: %w", err)
+	}
+
+	fmt.Printf("Imported database exported by Syncthing %s at %s, containing folders %q\n", header.SyncthingVersion, header.Created.Format("2006-01-02 15:04:05"), header.Folders)
+	return nil
+}
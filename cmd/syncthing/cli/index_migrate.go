@@ -0,0 +1,52 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/syncthing/syncthing/lib/db/backend"
+	"github.com/syncthing/syncthing/lib/locations"
+)
+
+func indexMigrateSQLite() error {
+	dstPath := locations.Get(locations.SQLiteDB)
+	if _, err := os.Stat(dstPath); err == nil {
+		return fmt.Errorf("SQLite database %s already exists, remove it first to migrate again", dstPath)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	src, err := getDB()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := backend.OpenSQLite(dstPath, backend.TuningAuto)
+	if err != nil {
+		return err
+	}
+	if err := backend.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(dstPath)
+		return fmt.Errorf("migrating database: %w", err)
+	}
+	if err := dst.Compact(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Migrated %s to %s\n", src.Location(), dstPath)
+	fmt.Println("Start Syncthing with --db-backend=sqlite (or STDBBACKEND=sqlite) to use it.")
+	return nil
+}
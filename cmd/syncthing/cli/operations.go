@@ -10,7 +10,9 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"net/url"
 	"path/filepath"
+	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/syncthing/syncthing/lib/config"
@@ -25,12 +27,19 @@ type defaultIgnoresCommand struct {
 	Path string `arg:""`
 }
 
+type folderRestoreCommand struct {
+	FolderID string `arg:""`
+	Time     string `arg:"" help:"Point in time, as RFC 3339 or YYYY-MM-DD HH:MM:SS in local time"`
+	DryRun   bool   `help:"Only list the versions that would be restored"`
+}
+
 type operationCommand struct {
 	Restart        struct{}              `cmd:"" help:"Restart syncthing"`
 	Shutdown       struct{}              `cmd:"" help:"Shutdown syncthing"`
 	Upgrade        struct{}              `cmd:"" help:"Upgrade syncthing (if a newer version is available)"`
 	FolderOverride folderOverrideCommand `cmd:"" help:"Override changes on folder (remote for sendonly, local for receiveonly). WARNING: Destructive - deletes/changes your data"`
 	DefaultIgnores defaultIgnoresCommand `cmd:"" help:"Set the default ignores (config) from a file"`
+	FolderRestore  folderRestoreCommand  `cmd:"" help:"Restore all files in a folder to the newest version at or before the given time"`
 }
 
 func (*operationCommand) Run(ctx Context, kongCtx *kong.Context) error {
@@ -106,3 +115,29 @@ func (d *defaultIgnoresCommand) Run(ctx Context) error {
 	_, err = client.PutJSON("config/defaults/ignores", config.Ignores{Lines: lines})
 	return err
 }
+
+func (f *folderRestoreCommand) Run(ctx Context) error {
+	at, err := time.Parse(time.RFC3339, f.Time)
+	if err != nil {
+		at, err = time.ParseInLocation(time.DateTime, f.Time, time.Local)
+		if err != nil {
+			return fmt.Errorf("parsing time %q: expected RFC 3339 or YYYY-MM-DD HH:MM:SS", f.Time)
+		}
+	}
+
+	client, err := ctx.clientFactory.getClient()
+	if err != nil {
+		return err
+	}
+	query := url.Values{}
+	query.Set("folder", f.FolderID)
+	query.Set("time", at.Format(time.RFC3339))
+	if f.DryRun {
+		query.Set("dryrun", "true")
+	}
+	response, err := client.Post("folder/restore?"+query.Encode(), "")
+	if err != nil {
+		return err
+	}
+	return prettyPrintResponse(response)
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/db/backend"
 	"github.com/syncthing/syncthing/lib/locations"
+	"github.com/syncthing/syncthing/lib/protocol"
 )
 
 func responseToBArray(response *http.Response) ([]byte, error) {
@@ -114,6 +115,24 @@ func getConfig(c APIClient) (config.Configuration, error) {
 	return cfg, nil
 }
 
+func getMyID(c APIClient) (protocol.DeviceID, error) {
+	response, err := c.Get("system/status")
+	if err != nil {
+		return protocol.EmptyDeviceID, err
+	}
+	bytes, err := responseToBArray(response)
+	if err != nil {
+		return protocol.EmptyDeviceID, err
+	}
+	var status struct {
+		MyID protocol.DeviceID `json:"myID"`
+	}
+	if err := json.Unmarshal(bytes, &status); err != nil {
+		return protocol.EmptyDeviceID, err
+	}
+	return status.MyID, nil
+}
+
 func prettyPrintJSON(data interface{}) error {
 	enc := json.NewEncoder(os.Stdout)
 	enc.SetIndent("", "  ")
@@ -137,6 +156,15 @@ func getDB() (backend.Backend, error) {
 	return backend.OpenLevelDBRO(locations.Get(locations.Database))
 }
 
+// dbLocation returns the location of the database for the given backend,
+// as selected by the --db-backend option to serve.
+func dbLocation(dbBackend string) string {
+	if dbBackend == "sqlite" {
+		return locations.Get(locations.SQLiteDB)
+	}
+	return locations.Get(locations.Database)
+}
+
 func nulString(bs []byte) string {
 	for i := range bs {
 		if bs[i] == 0 {
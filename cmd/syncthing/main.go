@@ -42,6 +42,7 @@ import (
 	"github.com/syncthing/syncthing/lib/build"
 	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/db/backend"
 	"github.com/syncthing/syncthing/lib/dialer"
 	"github.com/syncthing/syncthing/lib/events"
 	"github.com/syncthing/syncthing/lib/fs"
@@ -141,9 +142,11 @@ type serveOptions struct {
 	cmdutil.CommonOptions
 	AllowNewerConfig bool   `help:"Allow loading newer than current config version"`
 	Audit            bool   `help:"Write events to audit file"`
-	AuditFile        string `name:"auditfile" placeholder:"PATH" help:"Specify audit file (use \"-\" for stdout, \"--\" for stderr)"`
+	AuditFile        string `name:"auditfile" placeholder:"PATH" help:"Specify audit file (use \"-\" for stdout, \"--\" for stderr, \"syslog\" for syslog)"`
 	BrowserOnly      bool   `help:"Open GUI in browser"`
 	DataDir          string `name:"data" placeholder:"PATH" env:"STDATADIR" help:"Set data directory (database and logs)"`
+	DBBackend        string `name:"db-backend" enum:"leveldb,sqlite" default:"leveldb" env:"STDBBACKEND" help:"Database backend to use (leveldb, sqlite)"`
+	DesiredState     string `name:"desired-state" placeholder:"PATH" env:"STDESIREDSTATE" help:"Reconcile the configuration towards the desired state in a JSON or YAML file"`
 	DeviceID         bool   `help:"Show the device ID"`
 	GenerateDir      string `name:"generate" placeholder:"PATH" help:"Generate key and config in specified dir, then exit"` // DEPRECATED: replaced by subcommand!
 	GUIAddress       string `name:"gui-address" placeholder:"URL" help:"Override GUI address (e.g. \"http://192.0.2.42:8443\")"`
@@ -594,8 +597,7 @@ func syncthingMain(options serveOptions) {
 		})
 	}
 
-	dbFile := locations.Get(locations.Database)
-	ldb, err := syncthing.OpenDBBackend(dbFile, cfgWrapper.Options().DatabaseTuning)
+	ldb, err := openDBBackend(options.DBBackend, cfgWrapper.Options().DatabaseTuning)
 	if err != nil {
 		l.Warnln("Error opening database:", err)
 		os.Exit(1)
@@ -631,6 +633,7 @@ func syncthingMain(options serveOptions) {
 	}
 
 	appOpts := syncthing.Options{
+		DesiredStateFile:     options.DesiredState,
 		NoUpgrade:            options.NoUpgrade,
 		ProfilerAddr:         options.DebugProfilerListen,
 		ResetDeltaIdxs:       options.DebugResetDeltaIdxs,
@@ -650,7 +653,7 @@ func syncthingMain(options serveOptions) {
 			auditFile = options.AuditFile
 		}
 
-		appOpts.AuditWriter = auditWriter(auditFile)
+		appOpts.AuditWriter = auditWriter(auditFile, cfgWrapper.Options())
 	}
 
 	if dur, err := time.ParseDuration(os.Getenv("STRECHECKDBEVERY")); err == nil {
@@ -747,7 +750,7 @@ func loadOrDefaultConfig() (config.Wrapper, error) {
 	return cfg, err
 }
 
-func auditWriter(auditFile string) io.Writer {
+func auditWriter(auditFile string, opts config.OptionsConfiguration) io.Writer {
 	var fd io.Writer
 	var err error
 	var auditDest string
@@ -759,6 +762,13 @@ func auditWriter(auditFile string) io.Writer {
 	} else if auditFile == "--" {
 		fd = os.Stderr
 		auditDest = "stderr"
+	} else if auditFile == "syslog" {
+		fd, err = auditSyslogWriter()
+		if err != nil {
+			l.Warnln("Audit:", err)
+			os.Exit(svcutil.ExitError.AsInt())
+		}
+		auditDest = "syslog"
 	} else {
 		if auditFile == "" {
 			auditFile = locations.GetTimestamped(locations.AuditLog)
@@ -766,7 +776,14 @@ func auditWriter(auditFile string) io.Writer {
 		} else {
 			auditFlags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
 		}
-		fd, err = os.OpenFile(auditFile, auditFlags, 0o600)
+		if opts.AuditMaxSize > 0 {
+			open := func(name string) (io.WriteCloser, error) {
+				return os.OpenFile(name, auditFlags, 0o600)
+			}
+			fd, err = newRotatedFile(auditFile, open, opts.AuditMaxSize, opts.AuditMaxFiles)
+		} else {
+			fd, err = os.OpenFile(auditFile, auditFlags, 0o600)
+		}
 		if err != nil {
 			l.Warnln("Audit:", err)
 			os.Exit(svcutil.ExitError.AsInt())
@@ -779,8 +796,30 @@ func auditWriter(auditFile string) io.Writer {
 	return fd
 }
 
+func openDBBackend(dbBackend string, tuning config.Tuning) (backend.Backend, error) {
+	if dbBackend == "sqlite" {
+		dbFile := locations.Get(locations.SQLiteDB)
+		if _, err := os.Stat(dbFile); os.IsNotExist(err) {
+			if _, err := os.Stat(locations.Get(locations.Database)); err == nil {
+				l.Warnln("Creating a new SQLite database; the existing LevelDB database can be migrated first using `syncthing cli debug index migrate-sqlite` to avoid a full rescan and resync.")
+			}
+		}
+		return syncthing.OpenSQLiteDBBackend(dbFile, tuning)
+	}
+	return syncthing.OpenDBBackend(locations.Get(locations.Database), tuning)
+}
+
 func resetDB() error {
-	return os.RemoveAll(locations.Get(locations.Database))
+	if err := os.RemoveAll(locations.Get(locations.Database)); err != nil {
+		return err
+	}
+	sqliteFile := locations.Get(locations.SQLiteDB)
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		if err := os.Remove(sqliteFile + suffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
 }
 
 func autoUpgradePossible(options serveOptions) bool {
@@ -579,7 +579,7 @@ angular.module('syncthing.core')
             // This function should match IsAuthEnabled() in guiconfiguration.go
             var guiCfg = $scope.config && $scope.config.gui;
             if (guiCfg) {
-                return guiCfg.authMode === 'ldap' || (guiCfg.user && guiCfg.password);
+                return guiCfg.authMode === 'ldap' || guiCfg.authMode === 'oidc' || (guiCfg.user && guiCfg.password) || (guiCfg.users && guiCfg.users.length > 0);
             }
             return false;
         };
@@ -601,7 +601,7 @@ angular.module('syncthing.core')
                 && !$scope.isAuthEnabled()
                 && !guiCfg.insecureAdminAccess;
 
-            if ((guiCfg.user && guiCfg.password) || guiCfg.authMode === 'ldap') {
+            if ((guiCfg.user && guiCfg.password) || guiCfg.authMode === 'ldap' || guiCfg.authMode === 'oidc') {
                 $scope.dismissNotification('authenticationUserAndPassword');
             }
         }
@@ -1019,7 +1019,7 @@ angular.module('syncthing.core')
             if (status == 'paused') {
                 return 'default';
             }
-            if (status === 'syncing' || status === 'sync-preparing' || status === 'scanning' || status === 'cleaning') {
+            if (status === 'syncing' || status === 'sync-preparing' || status === 'scanning' || status === 'cleaning' || status === 'scrubbing') {
                 return 'primary';
             }
             if (status === 'unknown') {
@@ -1028,7 +1028,7 @@ angular.module('syncthing.core')
             if (status === 'stopped' || status === 'outofsync' || status === 'error' || status === 'faileditems' || status === 'localunencrypted') {
                 return 'danger';
             }
-            if (status === 'unshared' || status === 'scan-waiting' || status === 'sync-waiting' || status === 'clean-waiting') {
+            if (status === 'unshared' || status === 'scan-waiting' || status === 'sync-waiting' || status === 'clean-waiting' || status === 'scrub-waiting') {
                 return 'warning';
             }
 
@@ -1215,6 +1215,7 @@ angular.module('syncthing.core')
             switch ($scope.folderStatus(cfg)) {
                 case 'clean-waiting':
                 case 'scan-waiting':
+                case 'scrub-waiting':
                 case 'sync-preparing':
                 case 'sync-waiting':
                     return 'fa-hourglass-half';
@@ -1230,6 +1231,7 @@ angular.module('syncthing.core')
                 case 'paused':
                     return 'fa-pause';
                 case 'scanning':
+                case 'scrubbing':
                     return 'fa-search';
                 case 'stopped':
                     return 'fa-stop';
@@ -1264,6 +1266,10 @@ angular.module('syncthing.core')
                     return $translate.instant('Waiting to Scan');
                 case 'scanning':
                     return $translate.instant('Scanning');
+                case 'scrub-waiting':
+                    return $translate.instant('Waiting to Verify');
+                case 'scrubbing':
+                    return $translate.instant('Verifying');
                 case 'stopped':
                     return $translate.instant('Stopped');
                 case 'sync-preparing':
@@ -2367,6 +2373,7 @@ angular.module('syncthing.core')
                 $scope.currentFolder._guiVersioning.trashcanClean = +currentVersioning.params.cleanoutDays;
                 break;
             case "simple":
+            case "dedup":
                 $scope.currentFolder._guiVersioning.simpleKeep = +currentVersioning.params.keep;
                 $scope.currentFolder._guiVersioning.trashcanClean = +currentVersioning.params.cleanoutDays;
                 break;
@@ -2552,6 +2559,7 @@ angular.module('syncthing.core')
                 folderCfg.versioning.params.cleanoutDays = '' + folderCfg._guiVersioning.trashcanClean;
                 break;
             case "simple":
+            case "dedup":
                 folderCfg.versioning.params.keep = '' + folderCfg._guiVersioning.simpleKeep,
                 folderCfg.versioning.params.cleanoutDays = '' + folderCfg._guiVersioning.trashcanClean;
                 break;
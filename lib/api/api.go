@@ -36,6 +36,7 @@ import (
 	"github.com/rcrowley/go-metrics"
 	"github.com/thejerf/suture/v4"
 	"github.com/vitrun/qart/qr"
+	"golang.org/x/net/websocket"
 	"golang.org/x/text/runes"
 	"golang.org/x/text/transform"
 	"golang.org/x/text/unicode/norm"
@@ -52,6 +53,7 @@ import (
 	"github.com/syncthing/syncthing/lib/model"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/rand"
+	"github.com/syncthing/syncthing/lib/structutil"
 	"github.com/syncthing/syncthing/lib/svcutil"
 	"github.com/syncthing/syncthing/lib/sync"
 	"github.com/syncthing/syncthing/lib/tlsutil"
@@ -69,6 +71,10 @@ const (
 	httpsCertLifetimeDays = 820
 )
 
+// eventStreamKeepAlive is how often something is sent on idle Server-Sent
+// Events streams.
+var eventStreamKeepAlive = 30 * time.Second
+
 type service struct {
 	suture.Service
 
@@ -260,9 +266,10 @@ func (s *service) Serve(ctx context.Context) error {
 	restMux.HandlerFunc(http.MethodGet, "/rest/db/browse", s.getDBBrowse)                     // folder [prefix] [dirsonly] [levels]
 	restMux.HandlerFunc(http.MethodGet, "/rest/folder/versions", s.getFolderVersions)         // folder
 	restMux.HandlerFunc(http.MethodGet, "/rest/folder/errors", s.getFolderErrors)             // folder [perpage] [page]
+	restMux.HandlerFunc(http.MethodGet, "/rest/folder/conflicts", s.getFolderConflicts)       // folder
 	restMux.HandlerFunc(http.MethodGet, "/rest/folder/pullerrors", s.getFolderErrors)         // folder (deprecated)
-	restMux.HandlerFunc(http.MethodGet, "/rest/events", s.getIndexEvents)                     // [since] [limit] [timeout] [events]
-	restMux.HandlerFunc(http.MethodGet, "/rest/events/disk", s.getDiskEvents)                 // [since] [limit] [timeout]
+	restMux.HandlerFunc(http.MethodGet, "/rest/events", s.getIndexEvents)                     // [since] [limit] [timeout] [events], streams with SSE or WebSocket
+	restMux.HandlerFunc(http.MethodGet, "/rest/events/disk", s.getDiskEvents)                 // [since] [limit] [timeout], streams with SSE or WebSocket
 	restMux.HandlerFunc(http.MethodGet, "/rest/noauth/health", s.getHealth)                   // -
 	restMux.HandlerFunc(http.MethodGet, "/rest/stats/device", s.getDeviceStats)               // -
 	restMux.HandlerFunc(http.MethodGet, "/rest/stats/folder", s.getFolderStats)               // -
@@ -290,6 +297,8 @@ func (s *service) Serve(ctx context.Context) error {
 	restMux.HandlerFunc(http.MethodPost, "/rest/db/revert", s.postDBRevert)                      // folder
 	restMux.HandlerFunc(http.MethodPost, "/rest/db/scan", s.postDBScan)                          // folder [sub...] [delay]
 	restMux.HandlerFunc(http.MethodPost, "/rest/folder/versions", s.postFolderVersionsRestore)   // folder <body>
+	restMux.HandlerFunc(http.MethodPost, "/rest/folder/restore", s.postFolderRestore)            // folder time [dryrun]
+	restMux.HandlerFunc(http.MethodPost, "/rest/folder/conflicts", s.postFolderConflictResolve)  // folder file keep
 	restMux.HandlerFunc(http.MethodPost, "/rest/system/error", s.postSystemError)                // <body>
 	restMux.HandlerFunc(http.MethodPost, "/rest/system/error/clear", s.postSystemErrorClear)     // -
 	restMux.HandlerFunc(http.MethodPost, "/rest/system/ping", s.restPing)                        // -
@@ -308,9 +317,10 @@ func (s *service) Serve(ctx context.Context) error {
 	// Config endpoints
 
 	configBuilder := &configMuxBuilder{
-		Router: restMux,
-		id:     s.id,
-		cfg:    s.cfg,
+		Router:   restMux,
+		id:       s.id,
+		cfg:      s.cfg,
+		evLogger: s.evLogger,
 	}
 
 	configBuilder.registerConfig("/rest/config")
@@ -320,6 +330,8 @@ func (s *service) Serve(ctx context.Context) error {
 	configBuilder.registerDevices("/rest/config/devices")
 	configBuilder.registerFolder("/rest/config/folders/:id")
 	configBuilder.registerDevice("/rest/config/devices/:id")
+	configBuilder.registerDeviceGroups("/rest/config/devicegroups")
+	configBuilder.registerDeviceGroup("/rest/config/devicegroups/:id")
 	configBuilder.registerDefaultFolder("/rest/config/defaults/folder")
 	configBuilder.registerDefaultDevice("/rest/config/defaults/device")
 	configBuilder.registerDefaultIgnores("/rest/config/defaults/ignores")
@@ -361,9 +373,12 @@ func (s *service) Serve(ctx context.Context) error {
 
 	guiCfg := s.cfg.GUI()
 
+	// Check that whoever makes the request is allowed to.
+	var handler http.Handler = newAuthzMiddleware(guiCfg, mux)
+
 	// Wrap everything in CSRF protection. The /rest prefix should be
 	// protected, other requests will grant cookies.
-	var handler http.Handler = newCsrfManager(s.id.Short().String(), "/rest", guiCfg, mux, s.miscDB)
+	handler = newCsrfManager(s.id.Short().String(), "/rest", guiCfg, handler, s.miscDB)
 
 	// Add our version and ID as a header to responses
 	handler = withDetailsMiddleware(s.id, handler)
@@ -371,11 +386,18 @@ func (s *service) Serve(ctx context.Context) error {
 	// Wrap everything in basic auth, if user/password is set.
 	if guiCfg.IsAuthEnabled() {
 		tokenCookieManager := newTokenCookieManager(s.id.Short().String(), guiCfg, s.evLogger, s.miscDB)
-		authMW := newBasicAuthAndSessionMiddleware(tokenCookieManager, guiCfg, s.cfg.LDAP(), handler, s.evLogger)
+		oidcCfg := s.cfg.RawCopy().OIDC
+		authMW := newBasicAuthAndSessionMiddleware(tokenCookieManager, guiCfg, s.cfg.LDAP(), oidcCfg, handler, s.evLogger)
 		handler = authMW
 
 		restMux.Handler(http.MethodPost, "/rest/noauth/auth/password", http.HandlerFunc(authMW.passwordAuthHandler))
 
+		if guiCfg.AuthMode == config.AuthModeOIDC {
+			oidc := newOIDCAuthenticator(tokenCookieManager, guiCfg, oidcCfg, s.evLogger)
+			restMux.Handler(http.MethodGet, oidcLoginPath, http.HandlerFunc(oidc.loginHandler))
+			restMux.Handler(http.MethodGet, oidcCallbackPath, http.HandlerFunc(oidc.callbackHandler))
+		}
+
 		// Logout is a no-op without a valid session cookie, so /noauth/ is fine here
 		restMux.Handler(http.MethodPost, "/rest/noauth/auth/logout", http.HandlerFunc(authMW.handleLogout))
 	}
@@ -395,8 +417,15 @@ func (s *service) Serve(ctx context.Context) error {
 
 	handler = debugMiddleware(handler)
 
+	// Event streams last for as long as the client is connected, so they
+	// are stopped by cancelling the base context of all requests instead
+	// of being waited for when shutting down.
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+	defer cancelBase()
+
 	srv := http.Server{
-		Handler: handler,
+		Handler:     handler,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
 		// ReadTimeout must be longer than SyncthingController $scope.refresh
 		// interval to avoid HTTP keepalive/GUI refresh race.
 		ReadTimeout: 15 * time.Second,
@@ -453,6 +482,7 @@ func (s *service) Serve(ctx context.Context) error {
 	}
 	// Give it a moment to shut down gracefully, e.g. if we are restarting
 	// due to a config change through the API, let that finish successfully.
+	cancelBase()
 	timeout, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
 	defer cancel()
 	if err := srv.Shutdown(timeout); err == timeout.Err() {
@@ -489,8 +519,13 @@ func (s *service) CommitConfiguration(from, to config.Configuration) bool {
 	// No action required when this changes, so mask the fact that it changed at all.
 	from.GUI.Debugging = to.GUI.Debugging
 
-	if to.GUI == from.GUI {
-		// No GUI changes, we're done here.
+	// Nil and empty lists are the same to us.
+	for _, v := range []any{&from.GUI, &to.GUI, &from.LDAP, &to.LDAP, &from.OIDC, &to.OIDC} {
+		structutil.FillNil(v)
+	}
+
+	if reflect.DeepEqual(to.GUI, from.GUI) && reflect.DeepEqual(to.LDAP, from.LDAP) && reflect.DeepEqual(to.OIDC, from.OIDC) {
+		// No GUI, LDAP or OIDC changes, we're done here.
 		return true
 	}
 
@@ -837,12 +872,14 @@ func (s *service) getDBStatus(w http.ResponseWriter, r *http.Request) {
 func (s *service) postDBOverride(_ http.ResponseWriter, r *http.Request) {
 	qs := r.URL.Query()
 	folder := qs.Get("folder")
+	logUserAction(s.evLogger, r, "override", map[string]any{"folder": folder})
 	go s.model.Override(folder)
 }
 
 func (s *service) postDBRevert(_ http.ResponseWriter, r *http.Request) {
 	qs := r.URL.Query()
 	folder := qs.Get("folder")
+	logUserAction(s.evLogger, r, "revert", map[string]any{"folder": folder})
 	go s.model.Revert(folder)
 }
 
@@ -928,7 +965,12 @@ func (s *service) getDBLocalChanged(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *service) getSystemConnections(w http.ResponseWriter, _ *http.Request) {
-	sendJSON(w, s.model.ConnectionStats())
+	res := s.model.ConnectionStats()
+	if res == nil {
+		res = make(map[string]interface{})
+	}
+	res["bandwidth"] = s.connectionsService.BandwidthStatus()
+	sendJSON(w, res)
 }
 
 func (s *service) getDeviceStats(w http.ResponseWriter, _ *http.Request) {
@@ -940,12 +982,18 @@ func (s *service) getDeviceStats(w http.ResponseWriter, _ *http.Request) {
 	sendJSON(w, stats)
 }
 
-func (s *service) getFolderStats(w http.ResponseWriter, _ *http.Request) {
+func (s *service) getFolderStats(w http.ResponseWriter, r *http.Request) {
 	stats, err := s.model.FolderStatistics()
 	if err != nil {
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	p := requestPrincipal(r)
+	for folder := range stats {
+		if !p.hasFolder(folder) {
+			delete(stats, folder)
+		}
+	}
 	sendJSON(w, stats)
 }
 
@@ -1410,16 +1458,25 @@ func (s *service) postDBIgnores(w http.ResponseWriter, r *http.Request) {
 
 func (s *service) getIndexEvents(w http.ResponseWriter, r *http.Request) {
 	mask := s.getEventMask(r.URL.Query().Get("events"))
-	sub := s.getEventSub(mask)
+	sub := principalEventSub(s.getEventSub(mask), requestPrincipal(r))
 	s.getEvents(w, r, sub)
 }
 
 func (s *service) getDiskEvents(w http.ResponseWriter, r *http.Request) {
-	sub := s.getEventSub(DiskEventMask)
+	sub := principalEventSub(s.getEventSub(DiskEventMask), requestPrincipal(r))
 	s.getEvents(w, r, sub)
 }
 
 func (*service) getEvents(w http.ResponseWriter, r *http.Request, eventSub events.BufferedSubscription) {
+	switch {
+	case strings.EqualFold(r.Header.Get("Upgrade"), "websocket"):
+		websocketEvents(w, r, eventSub)
+		return
+	case strings.Contains(r.Header.Get("Accept"), "text/event-stream"):
+		streamEvents(w, r, eventSub)
+		return
+	}
+
 	qs := r.URL.Query()
 	sinceStr := qs.Get("since")
 	limitStr := qs.Get("limit")
@@ -1448,6 +1505,126 @@ func (*service) getEvents(w http.ResponseWriter, r *http.Request, eventSub event
 	sendJSON(w, evs)
 }
 
+// streamEvents sends events as Server-Sent Events as soon as they are
+// emitted, until the client goes away. Each event carries its ID, so that
+// on reconnecting the client resumes where it left off by passing the last
+// one in the Last-Event-ID header (or the since parameter).
+func streamEvents(w http.ResponseWriter, r *http.Request, eventSub events.BufferedSubscription) {
+	since, limit := eventStreamStart(r, eventSub)
+
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	// Ask reverse proxies not to buffer the response.
+	w.Header().Set("X-Accel-Buffering", "no")
+	f := w.(http.Flusher)
+	f.Flush()
+
+	ctx := r.Context()
+	for ctx.Err() == nil {
+		evs := nextEvents(ctx, eventSub, since)
+		if 0 < limit && limit < len(evs) {
+			evs = evs[len(evs)-limit:]
+		}
+		limit = 0 // only applies to the backlog
+
+		var err error
+		if len(evs) == 0 {
+			// Comment lines are ignored by clients, but keep
+			// intermediaries from timing out the connection.
+			_, err = io.WriteString(w, ": keepalive\n\n")
+		}
+		for _, ev := range evs {
+			var bs []byte
+			if bs, err = json.Marshal(ev); err != nil {
+				break
+			}
+			if _, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.SubscriptionID, ev.Type, bs); err != nil {
+				break
+			}
+			since = ev.SubscriptionID
+		}
+		if err != nil {
+			l.Debugln("event stream:", err)
+			return
+		}
+		f.Flush()
+	}
+}
+
+// websocketEvents sends events as WebSocket text messages, one JSON encoded
+// event per message, until the client closes the connection. It resumes
+// from the since parameter or the Last-Event-ID header like streamEvents.
+func websocketEvents(w http.ResponseWriter, r *http.Request, eventSub events.BufferedSubscription) {
+	since, limit := eventStreamStart(r, eventSub)
+
+	// Authentication and CSRF protection have been handled by then, so
+	// there is no need to check the origin.
+	websocket.Server{Handler: func(ws *websocket.Conn) {
+		// The server timeouts don't make sense for a hijacked long
+		// lived connection.
+		_ = ws.SetDeadline(time.Time{})
+
+		// We don't expect anything from the client, but reading tells
+		// us when it has gone away.
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+		go func() {
+			var discard []byte
+			for websocket.Message.Receive(ws, &discard) == nil {
+			}
+			cancel()
+		}()
+
+		for ctx.Err() == nil {
+			evs := nextEvents(ctx, eventSub, since)
+			if 0 < limit && limit < len(evs) {
+				evs = evs[len(evs)-limit:]
+			}
+			limit = 0
+			for _, ev := range evs {
+				if err := websocket.JSON.Send(ws, ev); err != nil {
+					l.Debugln("event websocket:", err)
+					return
+				}
+				since = ev.SubscriptionID
+			}
+		}
+	}}.ServeHTTP(w, r)
+}
+
+// nextEvents waits for events after since. It returns without any when
+// the context is cancelled, or after eventStreamKeepAlive has passed.
+func nextEvents(ctx context.Context, eventSub events.BufferedSubscription, since int) []events.Event {
+	deadline := time.Now().Add(eventStreamKeepAlive)
+	for {
+		// Wake up regularly to notice cancellation.
+		evs := eventSub.Since(since, nil, min(time.Until(deadline), time.Second))
+		if len(evs) > 0 || ctx.Err() != nil || !time.Now().Before(deadline) {
+			return evs
+		}
+	}
+}
+
+// eventStreamStart returns the ID to stream events after, and the limit on
+// the number of events already available to send.
+func eventStreamStart(r *http.Request, eventSub events.BufferedSubscription) (int, int) {
+	qs := r.URL.Query()
+	since, _ := strconv.Atoi(qs.Get("since"))
+	if id, err := strconv.Atoi(r.Header.Get("Last-Event-ID")); err == nil {
+		since = id
+	}
+	limit, _ := strconv.Atoi(qs.Get("limit"))
+
+	if since > 0 {
+		// Event IDs start over when Syncthing restarts. Rather than
+		// waiting for them to catch up with an ID from before, start from
+		// the beginning.
+		if evs := eventSub.Since(0, nil, 0); len(evs) == 0 || evs[len(evs)-1].SubscriptionID < since {
+			since = 0
+		}
+	}
+	return since, limit
+}
+
 func (*service) getEventMask(evs string) events.EventType {
 	eventMask := DefaultEventMask
 	if evs != "" {
@@ -1570,9 +1747,14 @@ func (s *service) makeDevicePauseHandler(paused bool) http.HandlerFunc {
 		qs := r.URL.Query()
 		deviceStr := qs.Get("device")
 
+		action := "resume"
+		if paused {
+			action = "pause"
+		}
+
 		var msg string
 		var status int
-		_, err := s.cfg.Modify(func(cfg *config.Configuration) {
+		_, err := auditedModify(s.cfg, s.evLogger, r, action, func(cfg *config.Configuration) {
 			if deviceStr == "" {
 				for i := range cfg.Devices {
 					cfg.Devices[i].Paused = paused
@@ -1719,6 +1901,75 @@ func (s *service) postFolderVersionsRestore(w http.ResponseWriter, r *http.Reque
 	sendJSON(w, errorStringMap(ferr))
 }
 
+func (s *service) postFolderRestore(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	at, err := time.Parse(time.RFC3339, qs.Get("time"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var dryRun bool
+	if v := qs.Get("dryrun"); v != "" {
+		dryRun, err = strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	versions, ferr, err := s.model.RestoreFolderVersionsAt(qs.Get("folder"), at, dryRun)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sendJSON(w, map[string]interface{}{
+		"dryRun":   dryRun,
+		"versions": versions,
+		"errors":   errorStringMap(ferr),
+	})
+}
+
+func (s *service) getFolderConflicts(w http.ResponseWriter, r *http.Request) {
+	conflicts, err := s.model.Conflicts(r.URL.Query().Get("folder"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if conflicts == nil {
+		conflicts = []model.Conflict{}
+	}
+	sendJSON(w, conflicts)
+}
+
+// postFolderConflictResolve resolves the conflict of the given conflict
+// copy by keeping either the "copy" or the "original".
+func (s *service) postFolderConflictResolve(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	var keepCopy bool
+	switch qs.Get("keep") {
+	case "copy":
+		keepCopy = true
+	case "original":
+	default:
+		http.Error(w, `keep must be "copy" or "original"`, http.StatusBadRequest)
+		return
+	}
+
+	if err := s.model.ResolveConflict(qs.Get("folder"), qs.Get("file"), keepCopy); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case isFolderNotFound(err), errors.Is(err, model.ErrConflictMissing):
+			status = http.StatusNotFound
+		case errors.Is(err, model.ErrNotConflictCopy), errors.Is(err, model.ErrConflictsEncrypted):
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+}
+
 func (s *service) getFolderErrors(w http.ResponseWriter, r *http.Request) {
 	qs := r.URL.Query()
 	folder := qs.Get("folder")
@@ -0,0 +1,52 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+)
+
+// logUserAction logs an action taken through the API, attributed to the
+// principal of the request, for the audit log.
+func logUserAction(evLogger events.Logger, r *http.Request, action string, data map[string]any) {
+	remoteAddress, proxy := remoteAddress(r)
+	data["action"] = action
+	data["user"] = requestPrincipal(r).name
+	data["remoteAddress"] = remoteAddress
+	if proxy != "" {
+		data["proxy"] = proxy
+	}
+	evLogger.Log(events.UserAction, data)
+}
+
+// logConfigChanges logs the changes between the two configurations as an
+// action, if there are any.
+func logConfigChanges(evLogger events.Logger, r *http.Request, action string, from, to config.Configuration) {
+	changes := config.Diff(from, to)
+	if len(changes) == 0 {
+		return
+	}
+	logUserAction(evLogger, r, action, map[string]any{"changes": changes})
+}
+
+// auditedModify is like cfg.Modify, also logging the changes made by fn as
+// an action of the principal of the request.
+func auditedModify(cfg config.Wrapper, evLogger events.Logger, r *http.Request, action string, fn config.ModifyFunction) (config.Waiter, error) {
+	var from, to config.Configuration
+	waiter, err := cfg.Modify(func(cfg *config.Configuration) {
+		from = cfg.Copy()
+		fn(cfg)
+		to = cfg.Copy()
+	})
+	if err == nil {
+		logConfigChanges(evLogger, r, action, from, to)
+	}
+	return waiter, err
+}
@@ -0,0 +1,68 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestAuditedModify(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	w := config.Wrap("/dev/null", config.New(protocol.LocalDeviceID), protocol.LocalDeviceID, events.NoopLogger)
+	go w.Serve(ctx)
+	evLogger := events.NewLogger()
+	go evLogger.Serve(ctx)
+	sub := evLogger.Subscribe(events.UserAction)
+	defer sub.Unsubscribe()
+
+	r := httptest.NewRequest("PATCH", "/rest/config/options", nil)
+	r = r.WithContext(withPrincipal(r.Context(), newPrincipal("ops", config.GUIRoleAdmin, nil)))
+
+	// Nothing is logged without changes.
+	waiter, err := auditedModify(w, evLogger, r, "config", func(*config.Configuration) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waiter.Wait()
+
+	waiter, err = auditedModify(w, evLogger, r, "config", func(cfg *config.Configuration) {
+		cfg.Options.RelaysEnabled = false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waiter.Wait()
+
+	select {
+	case ev := <-sub.C():
+		data := ev.Data.(map[string]any)
+		changes, _ := data["changes"].([]config.Change)
+		if data["user"] != "ops" || data["action"] != "config" || data["remoteAddress"] != "192.0.2.1" {
+			t.Error("unexpected attribution", data)
+		}
+		if len(changes) != 1 || changes[0].String() != "options.relaysEnabled: true -> false" {
+			t.Error("unexpected changes", changes)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out")
+	}
+	select {
+	case ev := <-sub.C():
+		t.Error("unexpected event", ev)
+	default:
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"time"
 
 	ldap "github.com/go-ldap/ldap/v3"
+	"github.com/syncthing/syncthing/internal/gen/apiproto"
 	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/events"
 	"github.com/syncthing/syncthing/lib/osutil"
@@ -126,15 +127,17 @@ type basicAuthAndSessionMiddleware struct {
 	tokenCookieManager *tokenCookieManager
 	guiCfg             config.GUIConfiguration
 	ldapCfg            config.LDAPConfiguration
+	oidcCfg            config.OIDCConfiguration
 	next               http.Handler
 	evLogger           events.Logger
 }
 
-func newBasicAuthAndSessionMiddleware(tokenCookieManager *tokenCookieManager, guiCfg config.GUIConfiguration, ldapCfg config.LDAPConfiguration, next http.Handler, evLogger events.Logger) *basicAuthAndSessionMiddleware {
+func newBasicAuthAndSessionMiddleware(tokenCookieManager *tokenCookieManager, guiCfg config.GUIConfiguration, ldapCfg config.LDAPConfiguration, oidcCfg config.OIDCConfiguration, next http.Handler, evLogger events.Logger) *basicAuthAndSessionMiddleware {
 	return &basicAuthAndSessionMiddleware{
 		tokenCookieManager: tokenCookieManager,
 		guiCfg:             guiCfg,
 		ldapCfg:            ldapCfg,
+		oidcCfg:            oidcCfg,
 		next:               next,
 		evLogger:           evLogger,
 	}
@@ -146,15 +149,26 @@ func (m *basicAuthAndSessionMiddleware) ServeHTTP(w http.ResponseWriter, r *http
 		return
 	}
 
-	if m.tokenCookieManager.hasValidSession(r) {
-		m.next.ServeHTTP(w, r)
-		return
+	// Sessions of users that have since been removed, or lost their role,
+	// are no longer valid.
+	if owner, ok := m.tokenCookieManager.sessionOwner(r); ok {
+		if p, ok := ownerPrincipal(owner, m.guiCfg, m.ldapCfg, m.oidcCfg); ok {
+			m.next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), p)))
+			return
+		}
 	}
 
 	// Fall back to Basic auth if provided
-	if username, ok := attemptBasicAuth(r, m.guiCfg, m.ldapCfg, m.evLogger); ok {
-		m.tokenCookieManager.createSession(username, false, w, r)
-		m.next.ServeHTTP(w, r)
+	if owner, p, ok := attemptBasicAuth(r, m.guiCfg, m.ldapCfg, m.evLogger); ok {
+		m.tokenCookieManager.createSession(owner, false, w, r)
+		m.next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), p)))
+		return
+	}
+
+	// Send browsers loading the GUI to the OpenID Connect provider to log
+	// in, as there is no other way to.
+	if m.guiCfg.AuthMode == config.AuthModeOIDC && r.Method == http.MethodGet && (r.URL.Path == "/" || r.URL.Path == "/index.html") {
+		http.Redirect(w, r, oidcLoginPath, http.StatusFound)
 		return
 	}
 
@@ -186,8 +200,8 @@ func (m *basicAuthAndSessionMiddleware) passwordAuthHandler(w http.ResponseWrite
 		return
 	}
 
-	if auth(req.Username, req.Password, m.guiCfg, m.ldapCfg) {
-		m.tokenCookieManager.createSession(req.Username, req.StayLoggedIn, w, r)
+	if owner, _, ok := auth(req.Username, req.Password, m.guiCfg, m.ldapCfg); ok {
+		m.tokenCookieManager.createSession(owner, req.StayLoggedIn, w, r)
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
@@ -197,27 +211,27 @@ func (m *basicAuthAndSessionMiddleware) passwordAuthHandler(w http.ResponseWrite
 	forbidden(w)
 }
 
-func attemptBasicAuth(r *http.Request, guiCfg config.GUIConfiguration, ldapCfg config.LDAPConfiguration, evLogger events.Logger) (string, bool) {
+func attemptBasicAuth(r *http.Request, guiCfg config.GUIConfiguration, ldapCfg config.LDAPConfiguration, evLogger events.Logger) (*apiproto.TokenOwner, principal, bool) {
 	username, password, ok := r.BasicAuth()
 	if !ok {
-		return "", false
+		return nil, principal{}, false
 	}
 
 	l.Debugln("Sessionless HTTP request with authentication; this is expensive.")
 
-	if auth(username, password, guiCfg, ldapCfg) {
-		return username, true
+	if owner, p, ok := auth(username, password, guiCfg, ldapCfg); ok {
+		return owner, p, true
 	}
 
 	usernameFromIso := string(iso88591ToUTF8([]byte(username)))
 	passwordFromIso := string(iso88591ToUTF8([]byte(password)))
-	if auth(usernameFromIso, passwordFromIso, guiCfg, ldapCfg) {
-		return usernameFromIso, true
+	if owner, p, ok := auth(usernameFromIso, passwordFromIso, guiCfg, ldapCfg); ok {
+		return owner, p, true
 	}
 
 	emitLoginAttempt(false, username, r, evLogger)
 	antiBruteForceSleep()
-	return "", false
+	return nil, principal{}, false
 }
 
 func (m *basicAuthAndSessionMiddleware) handleLogout(w http.ResponseWriter, r *http.Request) {
@@ -225,19 +239,44 @@ func (m *basicAuthAndSessionMiddleware) handleLogout(w http.ResponseWriter, r *h
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func auth(username string, password string, guiCfg config.GUIConfiguration, ldapCfg config.LDAPConfiguration) bool {
-	if guiCfg.AuthMode == config.AuthModeLDAP {
-		return authLDAP(username, password, ldapCfg)
-	} else {
-		return authStatic(username, password, guiCfg)
+// auth returns the session owner and principal for the given credentials,
+// if they are valid and the user is allowed in.
+func auth(username string, password string, guiCfg config.GUIConfiguration, ldapCfg config.LDAPConfiguration) (*apiproto.TokenOwner, principal, bool) {
+	owner := &apiproto.TokenOwner{Username: username}
+	switch guiCfg.AuthMode {
+	case config.AuthModeLDAP:
+		groups, ok := authLDAP(username, password, ldapCfg)
+		if !ok {
+			return nil, principal{}, false
+		}
+		owner.Groups = groups
+	case config.AuthModeOIDC:
+		// Users log in through the provider.
+		return nil, principal{}, false
+	default:
+		if !authStatic(username, password, guiCfg) {
+			return nil, principal{}, false
+		}
+	}
+
+	p, ok := ownerPrincipal(owner, guiCfg, ldapCfg, config.OIDCConfiguration{})
+	if !ok {
+		l.Infof("User %q has no role in the GUI", username)
+		return nil, principal{}, false
 	}
+	return owner, p, true
 }
 
 func authStatic(username string, password string, guiCfg config.GUIConfiguration) bool {
+	if user, ok := guiCfg.GUIUser(username); ok {
+		return user.CompareHashedPassword(password) == nil
+	}
 	return guiCfg.CompareHashedPassword(password) == nil && username == guiCfg.User
 }
 
-func authLDAP(username string, password string, cfg config.LDAPConfiguration) bool {
+// authLDAP returns true if the credentials are valid, along with the groups
+// the user is a member of if any group roles are configured.
+func authLDAP(username string, password string, cfg config.LDAPConfiguration) ([]string, bool) {
 	address := cfg.Address
 	hostname, _, err := net.SplitHostPort(address)
 	if err != nil {
@@ -255,14 +294,14 @@ func authLDAP(username string, password string, cfg config.LDAPConfiguration) bo
 
 	if err != nil {
 		l.Warnln("LDAP Dial:", err)
-		return false
+		return nil, false
 	}
 
 	if cfg.Transport == config.LDAPTransportStartTLS {
 		err = connection.StartTLS(&tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify})
 		if err != nil {
 			l.Warnln("LDAP Start TLS:", err)
-			return false
+			return nil, false
 		}
 	}
 
@@ -272,17 +311,38 @@ func authLDAP(username string, password string, cfg config.LDAPConfiguration) bo
 	err = connection.Bind(bindDN, password)
 	if err != nil {
 		l.Warnln("LDAP Bind:", err)
-		return false
+		return nil, false
+	}
+
+	groupAttribute := cfg.GroupAttribute
+	if groupAttribute == "" {
+		groupAttribute = "memberOf"
 	}
+	const timeLimit = 60 // Search for up to a minute...
 
 	if cfg.SearchFilter == "" && cfg.SearchBaseDN == "" {
-		// We're done here.
-		return true
+		if len(cfg.GroupRoles) == 0 {
+			// We're done here.
+			return nil, true
+		}
+
+		// Look up the groups on the entry of the user we bound as.
+		searchReq := ldap.NewSearchRequest(bindDN, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 1, timeLimit, false, "(objectClass=*)", []string{groupAttribute}, nil)
+		res, err := connection.Search(searchReq)
+		if err != nil {
+			l.Warnln("LDAP group search:", err)
+			return nil, false
+		}
+		if len(res.Entries) != 1 {
+			l.Infof("Wrong number of LDAP group search results, %d != 1", len(res.Entries))
+			return nil, false
+		}
+		return res.Entries[0].GetAttributeValues(groupAttribute), true
 	}
 
 	if cfg.SearchFilter == "" || cfg.SearchBaseDN == "" {
 		l.Warnln("LDAP configuration: both searchFilter and searchBaseDN must be set, or neither.")
-		return false
+		return nil, false
 	}
 
 	// If a search filter and search base is set we do an LDAP search for
@@ -290,21 +350,27 @@ func authLDAP(username string, password string, cfg config.LDAPConfiguration) bo
 	// The search filter uses the same %s interpolation as the bind DN.
 
 	searchString := formatOptionalPercentS(cfg.SearchFilter, escapeForLDAPFilter(username))
-	const sizeLimit = 2  // we search for up to two users -- we only want to match one, so getting any number >1 is a failure.
-	const timeLimit = 60 // Search for up to a minute...
-	searchReq := ldap.NewSearchRequest(cfg.SearchBaseDN, ldap.ScopeWholeSubtree, ldap.DerefFindingBaseObj, sizeLimit, timeLimit, false, searchString, nil, nil)
+	const sizeLimit = 2 // we search for up to two users -- we only want to match one, so getting any number >1 is a failure.
+	var attributes []string
+	if len(cfg.GroupRoles) > 0 {
+		attributes = []string{groupAttribute}
+	}
+	searchReq := ldap.NewSearchRequest(cfg.SearchBaseDN, ldap.ScopeWholeSubtree, ldap.DerefFindingBaseObj, sizeLimit, timeLimit, false, searchString, attributes, nil)
 
 	res, err := connection.Search(searchReq)
 	if err != nil {
 		l.Warnln("LDAP Search:", err)
-		return false
+		return nil, false
 	}
 	if len(res.Entries) != 1 {
 		l.Infof("Wrong number of LDAP search results, %d != 1", len(res.Entries))
-		return false
+		return nil, false
 	}
 
-	return true
+	if len(cfg.GroupRoles) == 0 {
+		return nil, true
+	}
+	return res.Entries[0].GetAttributeValues(groupAttribute), true
 }
 
 // escapeForLDAPFilter escapes a value that will be used in a filter clause
@@ -10,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/syncthing/syncthing/internal/gen/apiproto"
 	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/db"
 	"github.com/syncthing/syncthing/lib/db/backend"
@@ -186,4 +187,18 @@ func TestTokenManager(t *testing.T) {
 	if tm.Check(t3) {
 		t.Errorf("token %q should be invalid", t3)
 	}
+
+	// Owners are kept with their tokens
+	owner := &apiproto.TokenOwner{Username: "user", Groups: []string{"group"}}
+	t4 := tm.NewOwned(owner)
+	if got, ok := tm.CheckOwner(t4); !ok || got.GetUsername() != "user" {
+		t.Errorf("token %q should be valid and owned, got %v", t4, got)
+	}
+	if got, ok := tm.CheckOwner(t1); !ok || got != nil {
+		t.Errorf("token %q should be valid and unowned, got %v", t1, got)
+	}
+	tm.Delete(t4)
+	if _, ok := tm.tokens.Owners[t4]; ok {
+		t.Errorf("owner of token %q should have been removed", t4)
+	}
 }
@@ -0,0 +1,417 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/syncthing/syncthing/internal/gen/apiproto"
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+)
+
+// A principal is whoever a request is made on behalf of: a GUI user or the
+// holder of an API key.
+type principal struct {
+	name string
+	role config.GUIRole
+	// The folders the principal has access to; all of them if empty.
+	// Always empty for admins.
+	folders []string
+}
+
+// The principal of requests when authentication is disabled, and of those
+// made with the main API key.
+var adminPrincipal = principal{role: config.GUIRoleAdmin}
+
+func newPrincipal(name string, role config.GUIRole, folders []string) principal {
+	if role == config.GUIRoleAdmin {
+		folders = nil
+	}
+	return principal{name: name, role: role, folders: folders}
+}
+
+func (p principal) isAdmin() bool {
+	return p.role == config.GUIRoleAdmin
+}
+
+// isScoped returns true if the principal only has access to some folders.
+func (p principal) isScoped() bool {
+	return len(p.folders) > 0
+}
+
+func (p principal) hasFolder(folder string) bool {
+	return !p.isScoped() || slices.Contains(p.folders, folder)
+}
+
+type principalContextKey struct{}
+
+func withPrincipal(ctx context.Context, p principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, p)
+}
+
+// requestPrincipal returns the principal of the request, as set by the
+// authorization middleware. Requests that didn't pass through it are
+// treated as made by an admin.
+func requestPrincipal(r *http.Request) principal {
+	if p, ok := r.Context().Value(principalContextKey{}).(principal); ok {
+		return p
+	}
+	return adminPrincipal
+}
+
+// apiKeyPrincipal returns the principal for the API key in the request
+// headers, if there is a valid one.
+func apiKeyPrincipal(r *http.Request, guiCfg config.GUIConfiguration) (principal, bool) {
+	keys := []string{r.Header.Get("X-API-Key")}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(strings.ToLower(auth), "bearer ") {
+		keys = append(keys, auth[len("bearer "):])
+	}
+	for _, key := range keys {
+		switch {
+		case key == "":
+			continue
+		case key == guiCfg.APIKey || key == os.Getenv("STGUIAPIKEY"):
+			return principal{name: "apikey", role: config.GUIRoleAdmin}, true
+		}
+		if scoped, ok := guiCfg.ScopedAPIKey(key); ok {
+			return newPrincipal(scoped.Name, scoped.Role, scoped.Folders), true
+		}
+	}
+	return principal{}, false
+}
+
+// ownerPrincipal returns the principal for a session owner, or false if
+// they're no longer allowed in. Sessions created before sessions had
+// owners have a nil owner.
+func ownerPrincipal(owner *apiproto.TokenOwner, guiCfg config.GUIConfiguration, ldapCfg config.LDAPConfiguration, oidcCfg config.OIDCConfiguration) (principal, bool) {
+	switch guiCfg.AuthMode {
+	case config.AuthModeLDAP:
+		return ldapPrincipal(owner.GetUsername(), owner.GetGroups(), ldapCfg)
+	case config.AuthModeOIDC:
+		return oidcPrincipal(owner.GetUsername(), owner.GetGroups(), oidcCfg)
+	}
+
+	name := owner.GetUsername()
+	if owner == nil {
+		// Only the main user could log in back then.
+		name = guiCfg.User
+	}
+	if name != "" && name == guiCfg.User {
+		return principal{name: name, role: config.GUIRoleAdmin}, true
+	}
+	if user, ok := guiCfg.GUIUser(name); ok {
+		return newPrincipal(name, user.Role, user.Folders), true
+	}
+	return principal{}, false
+}
+
+// ldapPrincipal returns the principal for an LDAP user that is a member of
+// the given groups. Without any group roles configured all users are
+// admins.
+func ldapPrincipal(name string, groups []string, ldapCfg config.LDAPConfiguration) (principal, bool) {
+	if len(ldapCfg.GroupRoles) == 0 {
+		return principal{name: name, role: config.GUIRoleAdmin}, true
+	}
+	return groupPrincipal(name, groups, ldapCfg.GroupRoles)
+}
+
+// oidcPrincipal returns the principal for an OpenID Connect user that is a
+// member of the given groups. Allowed users are admins, and the others get
+// the role of their groups.
+func oidcPrincipal(name string, groups []string, oidcCfg config.OIDCConfiguration) (principal, bool) {
+	if name != "" && slices.Contains(oidcCfg.AllowedUsers, name) {
+		return principal{name: name, role: config.GUIRoleAdmin}, true
+	}
+	return groupPrincipal(name, groups, oidcCfg.GroupRoles)
+}
+
+// groupPrincipal returns the principal for a user that is a member of the
+// given groups. The user gets the highest role of their groups, for the
+// folders of the groups with that role, and isn't allowed in if none of
+// their groups has a role.
+func groupPrincipal(name string, groups []string, groupRoles []config.GroupRoleConfiguration) (principal, bool) {
+	var matches []config.GroupRoleConfiguration
+	for _, groupRole := range groupRoles {
+		if slices.ContainsFunc(groups, func(group string) bool { return strings.EqualFold(group, groupRole.Group) }) {
+			matches = append(matches, groupRole)
+		}
+	}
+	if len(matches) == 0 {
+		return principal{}, false
+	}
+
+	role := slices.MaxFunc(matches, func(a, b config.GroupRoleConfiguration) int { return int(a.Role - b.Role) }).Role
+	var folders []string
+	for _, match := range matches {
+		if match.Role != role {
+			continue
+		}
+		if len(match.Folders) == 0 {
+			folders = nil
+			break
+		}
+		folders = append(folders, match.Folders...)
+	}
+	return newPrincipal(name, role, folders), true
+}
+
+// These require the admin role for all methods, as they expose the
+// filesystem, logs or internals.
+var adminOnlyPaths = []string{
+	"/rest/system/browse",
+	"/rest/system/debug",
+	"/rest/system/log",
+	"/rest/system/log.txt",
+	"/rest/system/paths",
+}
+
+// These modify state without changing the configuration and are allowed
+// for operators.
+var operatorPaths = []string{
+	"/rest/db/override",
+	"/rest/db/prio",
+	"/rest/db/revert",
+	"/rest/db/scan",
+	"/rest/folder/conflicts",
+	"/rest/folder/restore",
+	"/rest/folder/versions",
+	"/rest/system/error/clear",
+	"/rest/system/ping",
+}
+
+// requiredRole returns the role required for the request.
+func requiredRole(r *http.Request) config.GUIRole {
+	switch {
+	case slices.Contains(adminOnlyPaths, r.URL.Path) || strings.HasPrefix(r.URL.Path, "/rest/debug/"):
+		return config.GUIRoleAdmin
+	case r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions:
+		return config.GUIRoleMonitor
+	case slices.Contains(operatorPaths, r.URL.Path):
+		return config.GUIRoleOperator
+	default:
+		return config.GUIRoleAdmin
+	}
+}
+
+// isFolderPath returns true for the requests that concern a single folder,
+// given as the folder parameter, or all of them when it's missing.
+func isFolderPath(path string) bool {
+	return strings.HasPrefix(path, "/rest/db/") || strings.HasPrefix(path, "/rest/folder/")
+}
+
+// authorize returns true if the principal may make the request.
+func (p principal) authorize(r *http.Request) bool {
+	if p.role < requiredRole(r) {
+		return false
+	}
+	if !p.isScoped() {
+		return true
+	}
+	if folder := r.URL.Query().Get("folder"); folder != "" {
+		return p.hasFolder(folder)
+	}
+	// Without a folder parameter these concern all folders.
+	return !isFolderPath(r.URL.Path)
+}
+
+// The authzMiddleware determines the principal of REST requests and
+// rejects those it isn't allowed to make. Authentication has been handled
+// by then, so requests without an API key or a principal set by the
+// authentication middleware are only seen when authentication is
+// disabled.
+type authzMiddleware struct {
+	guiCfg config.GUIConfiguration
+	next   http.Handler
+}
+
+func newAuthzMiddleware(guiCfg config.GUIConfiguration, next http.Handler) *authzMiddleware {
+	return &authzMiddleware{
+		guiCfg: guiCfg,
+		next:   next,
+	}
+}
+
+func (m *authzMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/rest/") || isNoAuthPath(r.URL.Path, false) {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+
+	p, ok := apiKeyPrincipal(r, m.guiCfg)
+	if !ok {
+		p = requestPrincipal(r)
+	}
+	if !p.authorize(r) {
+		l.Debugf("Denying %s %s to %q with role %v", r.Method, r.URL.Path, p.name, p.role)
+		forbidden(w)
+		return
+	}
+	m.next.ServeHTTP(w, r.WithContext(withPrincipal(r.Context(), p)))
+}
+
+// visibleConfig returns the configuration as the principal may see it:
+// without secrets for non-admins, and only with the folders they have
+// access to.
+func visibleConfig(cfg config.Configuration, p principal) config.Configuration {
+	if p.isAdmin() {
+		return cfg
+	}
+	cfg.GUI = visibleGUI(cfg.GUI, p)
+	cfg.OIDC.ClientSecret = ""
+	cfg.Webhooks = slices.Clone(cfg.Webhooks)
+	for i := range cfg.Webhooks {
+		cfg.Webhooks[i].Secret = ""
+	}
+	folders := make([]config.FolderConfiguration, 0, len(cfg.Folders))
+	for _, folder := range cfg.Folders {
+		if p.hasFolder(folder.ID) {
+			folders = append(folders, visibleFolder(folder, p))
+		}
+	}
+	cfg.Folders = folders
+	cfg.Defaults.Folder = visibleFolder(cfg.Defaults.Folder, p)
+	return cfg
+}
+
+func visibleGUI(gui config.GUIConfiguration, p principal) config.GUIConfiguration {
+	if p.isAdmin() {
+		return gui
+	}
+	gui = gui.Copy()
+	gui.Password = ""
+	gui.APIKey = ""
+	for i := range gui.Users {
+		gui.Users[i].Password = ""
+	}
+	for i := range gui.ScopedAPIKeys {
+		gui.ScopedAPIKeys[i].Key = ""
+	}
+	return gui
+}
+
+func visibleFolder(folder config.FolderConfiguration, p principal) config.FolderConfiguration {
+	if p.isAdmin() {
+		return folder
+	}
+	folder = folder.Copy()
+	folder.Path = visiblePath(folder.FilesystemType, folder.Path)
+	for i := range folder.Devices {
+		folder.Devices[i].EncryptionPassword = ""
+	}
+	return folder
+}
+
+// These folder path parameters hold credentials. The filesystems refuse
+// them nowadays, but older configurations may still have them.
+var credentialParams = []string{"accesskey", "secretkey", "password"}
+
+// visiblePath returns the folder path without the credentials that remote
+// filesystems may have in them.
+func visiblePath(fsType config.FilesystemType, path string) string {
+	if fsType != config.FilesystemTypeS3 && fsType != config.FilesystemTypeSFTP {
+		return path
+	}
+
+	path, query, hasQuery := strings.Cut(path, "?")
+	if fsType == config.FilesystemTypeSFTP {
+		// [user[:password]@]host[:port]/path
+		host, rest, hasPath := strings.Cut(path, "/")
+		if at := strings.LastIndex(host, "@"); at >= 0 {
+			user, _, _ := strings.Cut(host[:at], ":")
+			host = user + host[at:]
+		}
+		path = host
+		if hasPath {
+			path += "/" + rest
+		}
+	}
+
+	if !hasQuery {
+		return path
+	}
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		// We can't tell what's in there.
+		return path
+	}
+	for _, key := range credentialParams {
+		if !params.Has(key) {
+			continue
+		}
+		params.Del(key)
+		query = params.Encode()
+	}
+	if query == "" {
+		return path
+	}
+	return path + "?" + query
+}
+
+// A principalSubscription hides the events about folders the principal
+// doesn't have access to, the actions of users, and the secrets in saved
+// configurations from non-admins.
+type principalSubscription struct {
+	events.BufferedSubscription
+	p principal
+}
+
+func principalEventSub(sub events.BufferedSubscription, p principal) events.BufferedSubscription {
+	if p.isAdmin() {
+		return sub
+	}
+	return principalSubscription{BufferedSubscription: sub, p: p}
+}
+
+func (s principalSubscription) Since(id int, into []events.Event, timeout time.Duration) []events.Event {
+	deadline := time.Now().Add(timeout)
+	n := len(into)
+	for {
+		into = s.BufferedSubscription.Since(id, into, max(time.Until(deadline), 0))
+		if len(into) == n {
+			return into
+		}
+		last := into[len(into)-1].SubscriptionID
+		into = into[:n+len(slices.DeleteFunc(into[n:], s.hidden))]
+		if len(into) > n || time.Until(deadline) <= 0 {
+			break
+		}
+		// Everything was hidden; wait for more after it rather than
+		// returning nothing, which would have the caller ask again
+		// right away.
+		id = last
+	}
+
+	for i := n; i < len(into); i++ {
+		if cfg, ok := into[i].Data.(config.Configuration); ok {
+			into[i].Data = visibleConfig(cfg, s.p)
+		}
+	}
+	return into
+}
+
+func (s principalSubscription) hidden(ev events.Event) bool {
+	if ev.Type == events.UserAction {
+		// These may concern any folder, or the secrets in the
+		// configuration.
+		return true
+	}
+	var folder string
+	switch data := ev.Data.(type) {
+	case map[string]interface{}:
+		folder, _ = data["folder"].(string)
+	case map[string]string:
+		folder = data["folder"]
+	}
+	return folder != "" && !s.p.hasFolder(folder)
+}
@@ -0,0 +1,194 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/internal/gen/apiproto"
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+)
+
+func TestOwnerPrincipal(t *testing.T) {
+	t.Parallel()
+
+	gui := config.GUIConfiguration{
+		User: "admin",
+		Users: []config.GUIUserConfiguration{
+			{Name: "ops", Role: config.GUIRoleOperator, Folders: []string{"a"}},
+			{Name: "boss", Role: config.GUIRoleAdmin, Folders: []string{"a"}},
+		},
+	}
+
+	cases := []struct {
+		owner   *apiproto.TokenOwner
+		ok      bool
+		role    config.GUIRole
+		folders []string
+	}{
+		{&apiproto.TokenOwner{Username: "admin"}, true, config.GUIRoleAdmin, nil},
+		{nil, true, config.GUIRoleAdmin, nil},
+		{&apiproto.TokenOwner{Username: "ops"}, true, config.GUIRoleOperator, []string{"a"}},
+		// Folders don't limit admins
+		{&apiproto.TokenOwner{Username: "boss"}, true, config.GUIRoleAdmin, nil},
+		{&apiproto.TokenOwner{Username: "removed"}, false, 0, nil},
+		{&apiproto.TokenOwner{}, false, 0, nil},
+	}
+	for _, tc := range cases {
+		p, ok := ownerPrincipal(tc.owner, gui, config.LDAPConfiguration{}, config.OIDCConfiguration{})
+		if ok != tc.ok || p.role != tc.role || !slices.Equal(p.folders, tc.folders) {
+			t.Errorf("owner %v: unexpected principal %v, %v", tc.owner, p, ok)
+		}
+	}
+}
+
+func TestLDAPPrincipal(t *testing.T) {
+	t.Parallel()
+
+	if p, ok := ldapPrincipal("user", nil, config.LDAPConfiguration{}); !ok || !p.isAdmin() {
+		t.Error("expected LDAP users to be admins without group roles, got", p, ok)
+	}
+
+	ldapCfg := config.LDAPConfiguration{
+		GroupRoles: []config.GroupRoleConfiguration{
+			{Group: "cn=monitors,dc=example,dc=com", Role: config.GUIRoleMonitor},
+			{Group: "cn=ops-a,dc=example,dc=com", Role: config.GUIRoleOperator, Folders: []string{"a"}},
+			{Group: "cn=ops-b,dc=example,dc=com", Role: config.GUIRoleOperator, Folders: []string{"b"}},
+		},
+	}
+
+	cases := []struct {
+		groups  []string
+		ok      bool
+		role    config.GUIRole
+		folders []string
+	}{
+		{[]string{"CN=Monitors,DC=example,DC=com"}, true, config.GUIRoleMonitor, nil},
+		{[]string{"cn=monitors,dc=example,dc=com", "cn=ops-a,dc=example,dc=com", "cn=ops-b,dc=example,dc=com"}, true, config.GUIRoleOperator, []string{"a", "b"}},
+		{[]string{"cn=others,dc=example,dc=com"}, false, 0, nil},
+		{nil, false, 0, nil},
+	}
+	for _, tc := range cases {
+		p, ok := ldapPrincipal("user", tc.groups, ldapCfg)
+		if ok != tc.ok || p.role != tc.role || !slices.Equal(p.folders, tc.folders) {
+			t.Errorf("groups %v: unexpected principal %v, %v", tc.groups, p, ok)
+		}
+	}
+}
+
+func TestVisibleConfig(t *testing.T) {
+	t.Parallel()
+
+	var cfg config.Configuration
+	cfg.GUI.APIKey = "secret"
+	cfg.GUI.ScopedAPIKeys = []config.GUIAPIKeyConfiguration{{Name: "key", Key: "secret"}}
+	cfg.Folders = []config.FolderConfiguration{
+		{ID: "a", Devices: []config.FolderDeviceConfiguration{{EncryptionPassword: "secret"}}},
+		{ID: "b"},
+	}
+
+	if res := visibleConfig(cfg, adminPrincipal); res.GUI.APIKey != "secret" || len(res.Folders) != 2 {
+		t.Error("expected admins to see everything")
+	}
+
+	res := visibleConfig(cfg, newPrincipal("ops", config.GUIRoleOperator, []string{"a"}))
+	if res.GUI.APIKey != "" || res.GUI.ScopedAPIKeys[0].Key != "" || res.Folders[0].Devices[0].EncryptionPassword != "" {
+		t.Error("expected secrets to be removed, got", res)
+	}
+	if len(res.Folders) != 1 || res.Folders[0].ID != "a" {
+		t.Error("expected only the folder in scope, got", res.Folders)
+	}
+	if cfg.GUI.ScopedAPIKeys[0].Key != "secret" || cfg.Folders[0].Devices[0].EncryptionPassword != "secret" {
+		t.Error("expected the original to be unchanged")
+	}
+}
+
+func TestVisibleConfigCredentials(t *testing.T) {
+	t.Parallel()
+
+	var cfg config.Configuration
+	cfg.Webhooks = []config.WebhookConfiguration{{ID: "hook", URL: "https://example.com/hook", Secret: "secret"}}
+	cfg.Folders = []config.FolderConfiguration{
+		{ID: "s3", FilesystemType: config.FilesystemTypeS3, Path: "bucket/prefix?region=eu-north-1&accesskey=key&secretkey=secret"},
+		{ID: "sftp", FilesystemType: config.FilesystemTypeSFTP, Path: "user:secret@host:2222/data?password=secret&hostkey=SHA256:abc"},
+		{ID: "basic", FilesystemType: config.FilesystemTypeBasic, Path: "/data/what?password=x"},
+	}
+
+	res := visibleConfig(cfg, newPrincipal("monitor", config.GUIRoleMonitor, nil))
+	if res.Webhooks[0].Secret != "" || res.Webhooks[0].URL != "https://example.com/hook" {
+		t.Error("expected the webhook secret to be removed, got", res.Webhooks[0])
+	}
+	expected := []string{
+		"bucket/prefix?region=eu-north-1",
+		"user@host:2222/data?hostkey=SHA256%3Aabc",
+		"/data/what?password=x",
+	}
+	for i, folder := range res.Folders {
+		if folder.Path != expected[i] {
+			t.Errorf("folder %s: expected path %q, got %q", folder.ID, expected[i], folder.Path)
+		}
+	}
+	if cfg.Webhooks[0].Secret != "secret" || cfg.Folders[0].Path != "bucket/prefix?region=eu-north-1&accesskey=key&secretkey=secret" {
+		t.Error("expected the original to be unchanged")
+	}
+
+	if res := visibleConfig(cfg, adminPrincipal); res.Webhooks[0].Secret != "secret" || res.Folders[1].Path != cfg.Folders[1].Path {
+		t.Error("expected admins to see the credentials")
+	}
+}
+
+func TestPrincipalEventSub(t *testing.T) {
+	t.Parallel()
+
+	evLogger := events.NewLogger()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go evLogger.Serve(ctx)
+	sub := events.NewBufferedSubscription(evLogger.Subscribe(events.AllEvents), 10)
+
+	evLogger.Log(events.StateChanged, map[string]interface{}{"folder": "b"})
+	evLogger.Log(events.StateChanged, map[string]interface{}{"folder": "a"})
+	evLogger.Log(events.Starting, map[string]string{"home": "/"})
+
+	for start := time.Now(); len(sub.Since(0, nil, time.Second)) < 3; {
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("timed out")
+		}
+	}
+
+	scoped := principalEventSub(sub, newPrincipal("ops", config.GUIRoleOperator, []string{"a"}))
+	evs := scoped.Since(0, nil, time.Second)
+	if len(evs) != 2 || evs[0].Type != events.StateChanged || evs[1].Type != events.Starting {
+		t.Fatal("expected only the events about folder a and others, got", evs)
+	}
+
+	// Hidden events are skipped over rather than returned as nothing.
+	evLogger.Log(events.StateChanged, map[string]interface{}{"folder": "b"})
+	evLogger.Log(events.StateChanged, map[string]interface{}{"folder": "a"})
+	evs = scoped.Since(evs[1].SubscriptionID, nil, time.Second)
+	if len(evs) != 1 || evs[0].Data.(map[string]interface{})["folder"] != "a" {
+		t.Error("expected the event about folder a, got", evs)
+	}
+
+	// Saved configurations are shown without secrets.
+	var cfg config.Configuration
+	cfg.Webhooks = []config.WebhookConfiguration{{ID: "hook", Secret: "secret"}}
+	cfg.Folders = []config.FolderConfiguration{{ID: "a", FilesystemType: config.FilesystemTypeS3, Path: "bucket?secretkey=secret"}}
+	evLogger.Log(events.ConfigSaved, cfg)
+	monitor := principalEventSub(sub, newPrincipal("monitor", config.GUIRoleMonitor, nil))
+	evs = monitor.Since(evs[0].SubscriptionID, nil, time.Second)
+	if len(evs) != 1 {
+		t.Fatal("expected the saved configuration, got", evs)
+	}
+	if saved := evs[0].Data.(config.Configuration); saved.Webhooks[0].Secret != "" || saved.Folders[0].Path != "bucket" {
+		t.Error("expected the secrets to be removed, got", saved)
+	}
+}
@@ -0,0 +1,643 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	_ "crypto/sha512" // for SHA-384 and SHA-512 signatures
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/syncthing/syncthing/internal/gen/apiproto"
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/rand"
+)
+
+const (
+	oidcLoginPath    = "/rest/noauth/auth/oidc/login"
+	oidcCallbackPath = "/rest/noauth/auth/oidc/callback"
+
+	// How long a user has to log in at the provider, and how many logins
+	// may be in progress at the same time.
+	oidcLoginTimeout    = 10 * time.Minute
+	maxPendingOIDCLogin = 25
+	// The allowed clock difference to the provider when checking tokens.
+	oidcClockLeeway = time.Minute
+	// How often we refetch the provider's keys when seeing one we don't
+	// know.
+	oidcKeysRefetchInterval = time.Minute
+)
+
+// The oidcAuthenticator implements the OpenID Connect authorization code
+// flow, with PKCE, logging users in with the same session cookies as
+// password logins.
+type oidcAuthenticator struct {
+	tokenCookieManager *tokenCookieManager
+	guiCfg             config.GUIConfiguration
+	cfg                config.OIDCConfiguration
+	evLogger           events.Logger
+	client             *http.Client
+	stateCookieName    string
+
+	mut           sync.Mutex
+	discovery     *oidcDiscovery
+	keys          map[string]crypto.PublicKey
+	keysFetched   time.Time
+	pendingLogins map[string]oidcPendingLogin // by state
+}
+
+// The parts of the provider's discovery document we use.
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcPendingLogin struct {
+	nonce        string
+	codeVerifier string
+	redirectURL  string
+	stayLoggedIn bool
+	expires      time.Time
+}
+
+func newOIDCAuthenticator(tokenCookieManager *tokenCookieManager, guiCfg config.GUIConfiguration, cfg config.OIDCConfiguration, evLogger events.Logger) *oidcAuthenticator {
+	return &oidcAuthenticator{
+		tokenCookieManager: tokenCookieManager,
+		guiCfg:             guiCfg,
+		cfg:                cfg,
+		evLogger:           evLogger,
+		client:             &http.Client{Timeout: 30 * time.Second},
+		stateCookieName:    "oidcstate-" + tokenCookieManager.shortID,
+		pendingLogins:      make(map[string]oidcPendingLogin),
+	}
+}
+
+// loginHandler sends the user to the provider to log in, which sends them
+// back to the callbackHandler.
+func (a *oidcAuthenticator) loginHandler(w http.ResponseWriter, r *http.Request) {
+	disc, err := a.getDiscovery(r.Context())
+	if err != nil {
+		l.Warnln("OpenID Connect provider discovery:", err)
+		http.Error(w, "OpenID Connect provider unavailable", http.StatusBadGateway)
+		return
+	}
+
+	state := rand.String(randomTokenLength)
+	pending := oidcPendingLogin{
+		nonce:        rand.String(randomTokenLength),
+		codeVerifier: rand.String(randomTokenLength),
+		redirectURL:  a.redirectURL(r),
+		stayLoggedIn: r.URL.Query().Has("stayLoggedIn"),
+		expires:      time.Now().Add(oidcLoginTimeout),
+	}
+	a.addPendingLogin(state, pending)
+
+	challenge := sha256.Sum256([]byte(pending.codeVerifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {a.cfg.ClientID},
+		"redirect_uri":          {pending.redirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, a.cfg.Scopes...), " ")},
+		"state":                 {state},
+		"nonce":                 {pending.nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	authURL := disc.AuthorizationEndpoint
+	if strings.Contains(authURL, "?") {
+		authURL += "&" + params.Encode()
+	} else {
+		authURL += "?" + params.Encode()
+	}
+
+	// The state is tied to the browser that started the login, so that
+	// nobody else can complete it.
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.stateCookieName,
+		Value:    state,
+		MaxAge:   int(oidcLoginTimeout.Seconds()),
+		Secure:   a.tokenCookieManager.useSecureCookie(r),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Path:     "/rest/noauth/auth/oidc",
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// callbackHandler completes the login with the code the provider gave the
+// user, creating a session if the user is allowed in.
+func (a *oidcAuthenticator) callbackHandler(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:   a.stateCookieName,
+		MaxAge: -1,
+		Path:   "/rest/noauth/auth/oidc",
+	})
+
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		l.Infof("OpenID Connect login failed at the provider: %s: %s", errCode, query.Get("error_description"))
+		forbidden(w)
+		return
+	}
+
+	state := query.Get("state")
+	cookie, err := r.Cookie(a.stateCookieName)
+	if state == "" || err != nil || cookie.Value != state {
+		l.Debugln("OpenID Connect callback without matching state")
+		forbidden(w)
+		return
+	}
+	pending, ok := a.takePendingLogin(state)
+	if !ok {
+		l.Debugln("OpenID Connect callback for unknown or expired login")
+		forbidden(w)
+		return
+	}
+
+	owner, err := a.exchange(r.Context(), query.Get("code"), pending)
+	if err != nil {
+		l.Infoln("OpenID Connect login:", err)
+		emitLoginAttempt(false, "", r, a.evLogger)
+		forbidden(w)
+		return
+	}
+	if _, ok := oidcPrincipal(owner.Username, owner.Groups, a.cfg); !ok {
+		l.Debugf("OpenID Connect user %q with groups %v is not allowed in", owner.Username, owner.Groups)
+		emitLoginAttempt(false, owner.Username, r, a.evLogger)
+		forbidden(w)
+		return
+	}
+
+	a.tokenCookieManager.createSession(owner, pending.stayLoggedIn, w, r)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// redirectURL returns the URL of the callback handler as the provider
+// should send the user to it.
+func (a *oidcAuthenticator) redirectURL(r *http.Request) string {
+	if a.cfg.RedirectURL != "" {
+		return a.cfg.RedirectURL
+	}
+	scheme := "http"
+	if a.tokenCookieManager.useSecureCookie(r) {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + oidcCallbackPath
+}
+
+func (a *oidcAuthenticator) addPendingLogin(state string, pending oidcPendingLogin) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	now := time.Now()
+	for s, p := range a.pendingLogins {
+		if now.After(p.expires) {
+			delete(a.pendingLogins, s)
+		}
+	}
+	for len(a.pendingLogins) >= maxPendingOIDCLogin {
+		// Drop the oldest one.
+		var oldest string
+		for s, p := range a.pendingLogins {
+			if oldest == "" || p.expires.Before(a.pendingLogins[oldest].expires) {
+				oldest = s
+			}
+		}
+		delete(a.pendingLogins, oldest)
+	}
+	a.pendingLogins[state] = pending
+}
+
+func (a *oidcAuthenticator) takePendingLogin(state string) (oidcPendingLogin, bool) {
+	a.mut.Lock()
+	defer a.mut.Unlock()
+
+	pending, ok := a.pendingLogins[state]
+	delete(a.pendingLogins, state)
+	if !ok || time.Now().After(pending.expires) {
+		return oidcPendingLogin{}, false
+	}
+	return pending, true
+}
+
+// exchange redeems the code for an ID token at the provider, and returns
+// the owner of the session for the user it identifies.
+func (a *oidcAuthenticator) exchange(ctx context.Context, code string, pending oidcPendingLogin) (*apiproto.TokenOwner, error) {
+	if code == "" {
+		return nil, errors.New("no code in callback")
+	}
+	disc, err := a.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {pending.redirectURL},
+		"code_verifier": {pending.codeVerifier},
+		"client_id":     {a.cfg.ClientID},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, disc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if a.cfg.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(a.cfg.ClientID), url.QueryEscape(a.cfg.ClientSecret))
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request: %w", err)
+	}
+	defer resp.Body.Close()
+	var res struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("token response: %s: %w", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK || res.Error != "" {
+		return nil, fmt.Errorf("token request: %s: %s: %s", resp.Status, res.Error, res.ErrorDescription)
+	}
+
+	claims, err := a.verifyIDToken(ctx, res.IDToken, pending.nonce)
+	if err != nil {
+		return nil, fmt.Errorf("ID token: %w", err)
+	}
+
+	username, err := oidcUsername(claims, a.cfg.UsernameClaim)
+	if err != nil {
+		return nil, fmt.Errorf("ID token: %w", err)
+	}
+	return &apiproto.TokenOwner{Username: username, Groups: claimStrings(claims[a.cfg.GroupsClaim])}, nil
+}
+
+// oidcVerifiedClaims are the claims that the provider may not have
+// verified, by the claim that says whether it has.
+var oidcVerifiedClaims = map[string]string{
+	"email":        "email_verified",
+	"phone_number": "phone_number_verified",
+}
+
+// oidcUsername returns the value of the username claim, provided the
+// provider has verified it, if that's something it does.
+func oidcUsername(claims map[string]any, claim string) (string, error) {
+	if claim == "" {
+		claim = "sub"
+	}
+	username, _ := claims[claim].(string)
+	if username == "" {
+		return "", fmt.Errorf("no %s claim", claim)
+	}
+	if verifiedClaim, ok := oidcVerifiedClaims[claim]; ok {
+		if verified, _ := claims[verifiedClaim].(bool); !verified {
+			return "", fmt.Errorf("%s %q is not verified", claim, username)
+		}
+	}
+	return username, nil
+}
+
+// verifyIDToken checks the signature and claims of the ID token, and
+// returns its claims.
+func (a *oidcAuthenticator) verifyIDToken(ctx context.Context, token, nonce string) (map[string]any, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("signature: %w", err)
+	}
+	key, err := a.getKey(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyJWTSignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]any
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("claims: %w", err)
+	}
+	disc, err := a.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if iss, _ := claims["iss"].(string); iss != disc.Issuer {
+		return nil, fmt.Errorf("unexpected issuer %q", iss)
+	}
+	if aud := claimStrings(claims["aud"]); !slices.Contains(aud, a.cfg.ClientID) {
+		return nil, fmt.Errorf("unexpected audience %v", aud)
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("no expiry")
+	}
+	if time.Now().Add(-oidcClockLeeway).After(time.Unix(int64(exp), 0)) {
+		return nil, errors.New("expired")
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return nil, errors.New("unexpected nonce")
+	}
+	return claims, nil
+}
+
+// getDiscovery returns the provider's discovery document, fetching it the
+// first time. The lock isn't held while fetching, so that a slow provider
+// doesn't hold up other logins.
+func (a *oidcAuthenticator) getDiscovery(ctx context.Context) (*oidcDiscovery, error) {
+	a.mut.Lock()
+	disc := a.discovery
+	a.mut.Unlock()
+	if disc != nil {
+		return disc, nil
+	}
+
+	disc, err := a.fetchDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	if a.discovery == nil {
+		a.discovery = disc
+	}
+	return a.discovery, nil
+}
+
+func (a *oidcAuthenticator) fetchDiscovery(ctx context.Context) (*oidcDiscovery, error) {
+	var disc oidcDiscovery
+	if err := a.getJSON(ctx, strings.TrimSuffix(a.cfg.Issuer, "/")+"/.well-known/openid-configuration", &disc); err != nil {
+		return nil, err
+	}
+	if disc.Issuer != a.cfg.Issuer {
+		return nil, fmt.Errorf("issuer %q doesn't match the configured %q", disc.Issuer, a.cfg.Issuer)
+	}
+	if disc.AuthorizationEndpoint == "" || disc.TokenEndpoint == "" || disc.JWKSURI == "" {
+		return nil, errors.New("incomplete discovery document")
+	}
+	return &disc, nil
+}
+
+// getKey returns the provider's signing key with the given ID, fetching
+// the keys if we don't know it, as the provider may have rotated them.
+func (a *oidcAuthenticator) getKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	disc, err := a.getDiscovery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mut.Lock()
+	key, ok := a.keys[kid]
+	prevFetched := a.keysFetched
+	refetch := !ok && time.Since(prevFetched) >= oidcKeysRefetchInterval
+	if refetch {
+		// Claim the fetch, so that concurrent logins don't all make one.
+		a.keysFetched = time.Now()
+	}
+	a.mut.Unlock()
+	if ok {
+		return key, nil
+	}
+	if !refetch {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+
+	keys, err := a.fetchKeys(ctx, disc.JWKSURI)
+	a.mut.Lock()
+	defer a.mut.Unlock()
+	if err != nil {
+		a.keysFetched = prevFetched
+		return nil, err
+	}
+	a.keys = keys
+	if key, ok := a.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// fetchKeys returns the provider's signing keys by ID.
+func (a *oidcAuthenticator) fetchKeys(ctx context.Context, url string) (map[string]crypto.PublicKey, error) {
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := a.getJSON(ctx, url, &jwks); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			l.Debugf("Skipping OpenID Connect provider key %q: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+func (a *oidcAuthenticator) getJSON(ctx context.Context, url string, into any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(into); err != nil {
+		return fmt.Errorf("%s: %w", url, err)
+	}
+	return nil
+}
+
+// A jsonWebKey is a public RSA or elliptic curve key as per RFC 7517.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeJWKInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJWKInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("bad exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeJWKInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJWKInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeJWKInt(s string) (*big.Int, error) {
+	bs, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(bs) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(bs), nil
+}
+
+func decodeJWTPart(s string, into any) error {
+	bs, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, into)
+}
+
+// The curves that go with the ECDSA algorithms of RFC 7518.
+var jwtCurves = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+	"ES512": elliptic.P521(),
+}
+
+// verifyJWTSignature checks the signature of a JWT made with one of the
+// asymmetric algorithms of RFC 7518. Others, "none" in particular, are
+// rejected.
+func verifyJWTSignature(alg string, key crypto.PublicKey, signed string, sig []byte) error {
+	var hash crypto.Hash
+	switch alg[min(len(alg), 2):] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	}
+	if hash == 0 {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			break
+		}
+		if err := rsa.VerifyPKCS1v15(key, hash, digest, sig); err != nil {
+			return errors.New("bad signature")
+		}
+		return nil
+
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if jwtCurves[alg] != key.Curve || len(sig) != 2*size {
+			break
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("bad signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("algorithm %q doesn't match the key", alg)
+}
+
+// claimStrings returns the values of a claim that is either a string or a
+// list of them.
+func claimStrings(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		res := make([]string, 0, len(v))
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	default:
+		return nil
+	}
+}
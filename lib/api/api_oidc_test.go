@@ -0,0 +1,344 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+)
+
+// A testOIDCIssuer is a minimal OpenID Connect provider that logs in the
+// given user right away.
+type testOIDCIssuer struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	user   string
+	groups []string
+
+	mut     sync.Mutex
+	codes   map[string]url.Values // authorization request by code
+	onFetch func()                // called when discovery or keys are fetched
+}
+
+func (iss *testOIDCIssuer) fetched() {
+	iss.mut.Lock()
+	onFetch := iss.onFetch
+	iss.mut.Unlock()
+	if onFetch != nil {
+		onFetch()
+	}
+}
+
+func newTestOIDCIssuer(t *testing.T, user string, groups ...string) *testOIDCIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss := &testOIDCIssuer{key: key, user: user, groups: groups, codes: make(map[string]url.Values)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		iss.fetched()
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 iss.URL,
+			"authorization_endpoint": iss.URL + "/authorize",
+			"token_endpoint":         iss.URL + "/token",
+			"jwks_uri":               iss.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, _ *http.Request) {
+		iss.fetched()
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		code := params.Get("nonce") + "-code"
+		iss.mut.Lock()
+		iss.codes[code] = params
+		iss.mut.Unlock()
+		http.Redirect(w, r, params.Get("redirect_uri")+"?"+url.Values{"code": {code}, "state": {params.Get("state")}}.Encode(), http.StatusFound)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		iss.mut.Lock()
+		params, ok := iss.codes[r.FormValue("code")]
+		delete(iss.codes, r.FormValue("code"))
+		iss.mut.Unlock()
+		challenge := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if !ok || params.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(challenge[:]) || params.Get("redirect_uri") != r.FormValue("redirect_uri") {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": iss.idToken(t, map[string]any{
+			"iss":    iss.URL,
+			"aud":    []string{params.Get("client_id")},
+			"exp":    time.Now().Add(time.Minute).Unix(),
+			"nonce":  params.Get("nonce"),
+			"sub":    iss.user,
+			"groups": iss.groups,
+		})})
+	})
+	iss.Server = httptest.NewServer(mux)
+	t.Cleanup(iss.Close)
+	return iss
+}
+
+func (iss *testOIDCIssuer) idToken(t *testing.T, claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, iss.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Error(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestOIDCLogin(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		user   string
+		groups []string
+		ok     bool
+	}{
+		{"alice", nil, true},
+		{"bob", []string{"ops"}, true},
+		{"mallory", []string{"others"}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.user, func(t *testing.T) {
+			t.Parallel()
+
+			iss := newTestOIDCIssuer(t, tc.user, tc.groups...)
+			cfg := newMockedConfig()
+			cfg.GUIReturns(config.GUIConfiguration{RawAddress: "127.0.0.1:0", AuthMode: config.AuthModeOIDC})
+			cfg.RawCopyReturns(config.Configuration{OIDC: config.OIDCConfiguration{
+				Issuer:       iss.URL,
+				ClientID:     "syncthing",
+				GroupsClaim:  "groups",
+				AllowedUsers: []string{"alice"},
+				GroupRoles:   []config.GroupRoleConfiguration{{Group: "ops", Role: config.GUIRoleOperator}},
+			}})
+			baseURL, cancel, err := startHTTP(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(cancel)
+
+			jar, _ := cookiejar.New(nil)
+			client := &http.Client{Jar: jar, Timeout: 15 * time.Second}
+
+			// Not logged in yet.
+			resp, err := client.Get(baseURL + "/meta.js")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusForbidden {
+				t.Fatal("expected forbidden before logging in, got", resp.Status)
+			}
+
+			// Loading the GUI goes through the provider and back.
+			resp, err = client.Get(baseURL + "/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if tc.ok != (resp.StatusCode == http.StatusOK) {
+				t.Fatal("unexpected login result", resp.Status)
+			}
+
+			resp, err = client.Get(baseURL + "/meta.js")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if tc.ok != (resp.StatusCode == http.StatusOK) {
+				t.Error("unexpected status with the session", resp.Status)
+			}
+		})
+	}
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	t.Parallel()
+
+	iss := newTestOIDCIssuer(t, "alice")
+	a := newOIDCAuthenticator(&tokenCookieManager{}, config.GUIConfiguration{}, config.OIDCConfiguration{Issuer: iss.URL, ClientID: "syncthing"}, nil)
+	valid := map[string]any{
+		"iss":   iss.URL,
+		"aud":   "syncthing",
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": "nonce",
+	}
+	ctx := context.Background()
+	if _, err := a.verifyIDToken(ctx, iss.idToken(t, valid), "nonce"); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, val := range map[string]any{
+		"iss":   "https://elsewhere.example.com",
+		"aud":   []string{"other"},
+		"exp":   time.Now().Add(-time.Hour).Unix(),
+		"nonce": "other",
+	} {
+		claims := make(map[string]any)
+		for k, v := range valid {
+			claims[k] = v
+		}
+		claims[key] = val
+		if _, err := a.verifyIDToken(ctx, iss.idToken(t, claims), "nonce"); err == nil {
+			t.Errorf("expected an error for %s %v", key, val)
+		}
+	}
+
+	// Tampering breaks the signature.
+	token := []byte(iss.idToken(t, valid))
+	token[len(token)-5] ^= 1
+	if _, err := a.verifyIDToken(ctx, string(token), "nonce"); err == nil {
+		t.Error("expected an error for a bad signature")
+	}
+}
+
+func TestOIDCFetchUnlocked(t *testing.T) {
+	t.Parallel()
+
+	// Other logins can proceed while the provider is being asked for its
+	// discovery document and keys.
+	iss := newTestOIDCIssuer(t, "alice")
+	a := newOIDCAuthenticator(&tokenCookieManager{}, config.GUIConfiguration{}, config.OIDCConfiguration{Issuer: iss.URL, ClientID: "syncthing"}, nil)
+	var fetches atomic.Int32
+	iss.mut.Lock()
+	iss.onFetch = func() {
+		fetches.Add(1)
+		done := make(chan struct{})
+		go func() {
+			a.takePendingLogin("state")
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Error("lock held while fetching from the provider")
+		}
+	}
+	iss.mut.Unlock()
+
+	if _, err := a.getKey(context.Background(), "test"); err != nil {
+		t.Fatal(err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Error("expected two fetches, got", n)
+	}
+}
+
+func TestVerifyJWTSignatureCurve(t *testing.T) {
+	t.Parallel()
+
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const signed = "header.payload"
+	sign := func(hash crypto.Hash) []byte {
+		h := hash.New()
+		h.Write([]byte(signed))
+		r, s, err := ecdsa.Sign(rand.Reader, key, h.Sum(nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig := make([]byte, 96)
+		r.FillBytes(sig[:48])
+		s.FillBytes(sig[48:])
+		return sig
+	}
+
+	if err := verifyJWTSignature("ES384", &key.PublicKey, signed, sign(crypto.SHA384)); err != nil {
+		t.Error(err)
+	}
+	// The hash must go with the curve of the key.
+	if err := verifyJWTSignature("ES256", &key.PublicKey, signed, sign(crypto.SHA256)); err == nil {
+		t.Error("expected ES256 with a P-384 key to be rejected")
+	}
+}
+
+func TestOIDCUsername(t *testing.T) {
+	t.Parallel()
+
+	claims := map[string]any{
+		"sub":                "1234",
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+	}
+	if name, err := oidcUsername(claims, ""); err != nil || name != "1234" {
+		t.Error("expected the subject by default, got", name, err)
+	}
+	if name, err := oidcUsername(claims, "preferred_username"); err != nil || name != "alice" {
+		t.Error("expected the configured claim, got", name, err)
+	}
+	if _, err := oidcUsername(claims, "name"); err == nil {
+		t.Error("expected an error for a missing claim")
+	}
+
+	// Email addresses must be verified by the provider.
+	if name, err := oidcUsername(claims, "email"); err == nil {
+		t.Error("expected an error for an unverified email address, got", name)
+	}
+	claims["email_verified"] = false
+	if name, err := oidcUsername(claims, "email"); err == nil {
+		t.Error("expected an error for an unverified email address, got", name)
+	}
+	claims["email_verified"] = true
+	if name, err := oidcUsername(claims, "email"); err != nil || name != "alice@example.com" {
+		t.Error("expected the verified email address, got", name, err)
+	}
+}
+
+func TestOIDCPrincipal(t *testing.T) {
+	t.Parallel()
+
+	oidcCfg := config.OIDCConfiguration{
+		AllowedUsers: []string{"alice"},
+		GroupRoles:   []config.GroupRoleConfiguration{{Group: "ops", Role: config.GUIRoleOperator, Folders: []string{"a"}}},
+	}
+	if p, ok := oidcPrincipal("alice", nil, oidcCfg); !ok || !p.isAdmin() {
+		t.Error("expected allowed users to be admins, got", p, ok)
+	}
+	if p, ok := oidcPrincipal("bob", []string{"ops"}, oidcCfg); !ok || p.role != config.GUIRoleOperator || !p.hasFolder("a") || p.hasFolder("b") {
+		t.Error("expected the role of the group, got", p, ok)
+	}
+	if p, ok := oidcPrincipal("mallory", []string{"others"}, oidcCfg); ok {
+		t.Error("expected others to be denied, got", p)
+	}
+}
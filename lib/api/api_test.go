@@ -7,10 +7,12 @@
 package api
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -26,6 +28,7 @@ import (
 
 	"github.com/d4l3k/messagediff"
 	"github.com/thejerf/suture/v4"
+	"golang.org/x/net/websocket"
 
 	"github.com/syncthing/syncthing/lib/assets"
 	"github.com/syncthing/syncthing/lib/build"
@@ -325,7 +328,7 @@ func TestAPIServiceRequests(t *testing.T) {
 			URL:    "/rest/system/connections",
 			Code:   200,
 			Type:   "application/json",
-			Prefix: "null",
+			Prefix: "{",
 		},
 		{
 			URL:    "/rest/system/discovery",
@@ -876,6 +879,81 @@ func TestHTTPLogin(t *testing.T) {
 	})
 }
 
+func TestHTTPRoles(t *testing.T) {
+	t.Parallel()
+
+	monitor := config.GUIUserConfiguration{Name: "monitor", Role: config.GUIRoleMonitor}
+	if err := monitor.SetPassword("pass"); err != nil {
+		t.Fatal(err)
+	}
+	cfg := newMockedConfig()
+	cfg.GUIReturns(config.GUIConfiguration{
+		User:       "admin",
+		Password:   "$2a$10$IdIZTxTg/dCNuNEGlmLynOjqg4B1FvDKuIV5e0BB3pnWVHNb8.GSq", // bcrypt of "räksmörgås" in UTF-8
+		RawAddress: "127.0.0.1:0",
+		APIKey:     testAPIKey,
+		Users:      []config.GUIUserConfiguration{monitor},
+		ScopedAPIKeys: []config.GUIAPIKeyConfiguration{
+			{Name: "monitoring", Key: "monitorkey", Role: config.GUIRoleMonitor},
+			{Name: "automation", Key: "operatorkey", Role: config.GUIRoleOperator, Folders: []string{"default"}},
+		},
+	})
+	sftpFolder := config.FolderConfiguration{ID: "default", FilesystemType: config.FilesystemTypeSFTP, Path: "user@host/data?password=sftpsecret&hostkey=SHA256:abc"}
+	cfg.RawCopyReturns(config.Configuration{
+		Webhooks: []config.WebhookConfiguration{{ID: "hook", Secret: "hooksecret"}},
+		Folders:  []config.FolderConfiguration{sftpFolder},
+	})
+	cfg.FolderReturns(sftpFolder, true)
+	baseURL, cancel, err := startHTTP(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cancel)
+
+	// Users log in like the main user.
+	if resp := httpGet(baseURL+"/meta.js", "monitor", "pass", "", "", nil, t); resp.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected non-200 return code %d for additional user", resp.StatusCode)
+	}
+
+	cases := []struct {
+		method string
+		path   string
+		apiKey string
+		status int
+	}{
+		{http.MethodGet, "/rest/system/version", "monitorkey", http.StatusOK},
+		{http.MethodGet, "/rest/system/paths", "monitorkey", http.StatusForbidden},
+		{http.MethodPost, "/rest/system/ping", "monitorkey", http.StatusForbidden},
+		{http.MethodPost, "/rest/system/ping", "operatorkey", http.StatusOK},
+		{http.MethodPost, "/rest/system/restart", "operatorkey", http.StatusForbidden},
+		{http.MethodGet, "/rest/db/status?folder=other", "operatorkey", http.StatusForbidden},
+		{http.MethodGet, "/rest/db/completion", "operatorkey", http.StatusForbidden},
+		{http.MethodGet, "/rest/system/paths", testAPIKey, http.StatusOK},
+	}
+	for _, tc := range cases {
+		resp := httpRequest(tc.method, baseURL+tc.path, nil, "", "", tc.apiKey, "", "", "", nil, t)
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s %s with key %q: expected status %d, got %d", tc.method, tc.path, tc.apiKey, tc.status, resp.StatusCode)
+		}
+	}
+
+	// Monitors see the configuration without its secrets.
+	for _, path := range []string{"/rest/config", "/rest/config/folders", "/rest/config/folders/default"} {
+		resp := httpRequest(http.MethodGet, baseURL+path, nil, "", "", "monitorkey", "", "", "", nil, t)
+		bs, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s: expected status 200, got %d", path, resp.StatusCode)
+		}
+		if bytes.Contains(bs, []byte("hooksecret")) || bytes.Contains(bs, []byte("sftpsecret")) {
+			t.Errorf("GET %s: expected no secrets, got %s", path, bs)
+		}
+	}
+}
+
 func TestHtmlFormLogin(t *testing.T) {
 	t.Parallel()
 
@@ -1661,6 +1739,198 @@ func TestEventMasks(t *testing.T) {
 	}
 }
 
+func TestFolderRestoreDryRun(t *testing.T) {
+	t.Parallel()
+
+	m := new(modelmocks.Model)
+	svc := &service{model: m}
+
+	cases := []struct {
+		query  string
+		status int
+		dryRun bool
+	}{
+		{"", http.StatusOK, false},
+		{"&dryrun=true", http.StatusOK, true},
+		{"&dryrun=1", http.StatusOK, true},
+		{"&dryrun=false", http.StatusOK, false},
+		{"&dryrun=0", http.StatusOK, false},
+		{"&dryrun=no", http.StatusBadRequest, false},
+	}
+	for _, tc := range cases {
+		calls := m.RestoreFolderVersionsAtCallCount()
+		req := httptest.NewRequest(http.MethodPost, "/rest/folder/restore?folder=default&time=2024-03-01T14:00:00Z"+tc.query, nil)
+		rec := httptest.NewRecorder()
+		svc.postFolderRestore(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("%q: expected status %d, got %d", tc.query, tc.status, rec.Code)
+			continue
+		}
+		if tc.status != http.StatusOK {
+			if m.RestoreFolderVersionsAtCallCount() != calls {
+				t.Errorf("%q: expected no restore", tc.query)
+			}
+			continue
+		}
+		if _, _, dryRun := m.RestoreFolderVersionsAtArgsForCall(calls); dryRun != tc.dryRun {
+			t.Errorf("%q: expected dry run %v, got %v", tc.query, tc.dryRun, dryRun)
+		}
+	}
+}
+
+func TestFolderConflictResolveErrors(t *testing.T) {
+	t.Parallel()
+
+	m := new(modelmocks.Model)
+	svc := &service{model: m}
+
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{nil, http.StatusOK},
+		{model.ErrFolderMissing, http.StatusNotFound},
+		{model.ErrConflictMissing, http.StatusNotFound},
+		{model.ErrNotConflictCopy, http.StatusBadRequest},
+		{model.ErrConflictsEncrypted, http.StatusBadRequest},
+		{fmt.Errorf("removing original: %w", errors.New("permission denied")), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		m.ResolveConflictReturns(tc.err)
+		req := httptest.NewRequest(http.MethodPost, "/rest/folder/conflicts?folder=default&file=a.txt&keep=copy", nil)
+		rec := httptest.NewRecorder()
+		svc.postFolderConflictResolve(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("%v: expected status %d, got %d", tc.err, tc.status, rec.Code)
+		}
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	t.Parallel()
+
+	evLogger := events.NewLogger()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go evLogger.Serve(ctx)
+	sub := events.NewBufferedSubscription(evLogger.Subscribe(events.Failure), 10)
+
+	svc := &service{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		svc.getEvents(w, r, sub)
+	}))
+	defer srv.Close()
+
+	for _, msg := range []string{"one", "two"} {
+		evLogger.Log(events.Failure, msg)
+	}
+	waitForEvent := func(id int) {
+		t.Helper()
+		if evs := sub.Since(id-1, nil, 10*time.Second); len(evs) == 0 {
+			t.Fatal("timed out waiting for event", id)
+		}
+	}
+	waitForEvent(2)
+
+	// Resume after the first event, then receive a new one as it happens.
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatal("Unexpected content type", ct)
+	}
+
+	lines := make(chan string)
+	go func() {
+		br := bufio.NewScanner(resp.Body)
+		for br.Scan() {
+			lines <- br.Text()
+		}
+		close(lines)
+	}()
+	readEvent := func() (string, events.Event) {
+		t.Helper()
+		var id string
+		var ev events.Event
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					t.Fatal("stream ended")
+				}
+				switch {
+				case line == "":
+					return id, ev
+				case strings.HasPrefix(line, "id: "):
+					id = line[4:]
+				case strings.HasPrefix(line, "data: "):
+					if err := json.Unmarshal([]byte(line[6:]), &ev); err != nil {
+						t.Fatal(err)
+					}
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("timed out reading stream")
+			}
+		}
+	}
+
+	if id, ev := readEvent(); id != "2" || ev.Data != "two" {
+		t.Errorf("Unexpected event %s: %+v", id, ev)
+	}
+	evLogger.Log(events.Failure, "three")
+	if id, ev := readEvent(); id != "3" || ev.Type != events.Failure || ev.Data != "three" {
+		t.Errorf("Unexpected event %s: %+v", id, ev)
+	}
+}
+
+func TestEventWebsocket(t *testing.T) {
+	t.Parallel()
+
+	evLogger := events.NewLogger()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go evLogger.Serve(ctx)
+	sub := events.NewBufferedSubscription(evLogger.Subscribe(events.Failure), 10)
+
+	svc := &service{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		svc.getEvents(w, r, sub)
+	}))
+	defer srv.Close()
+
+	evLogger.Log(events.Failure, "one")
+	if evs := sub.Since(0, nil, 10*time.Second); len(evs) == 0 {
+		t.Fatal("timed out waiting for event")
+	}
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"?since=0", "", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ws.Close()
+	_ = ws.SetDeadline(time.Now().Add(10 * time.Second))
+
+	var ev events.Event
+	if err := websocket.JSON.Receive(ws, &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.SubscriptionID != 1 || ev.Data != "one" {
+		t.Errorf("Unexpected event %+v", ev)
+	}
+	evLogger.Log(events.Failure, "two")
+	if err := websocket.JSON.Receive(ws, &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.SubscriptionID != 2 || ev.Data != "two" {
+		t.Errorf("Unexpected event %+v", ev)
+	}
+}
+
 func TestBrowse(t *testing.T) {
 	t.Parallel()
 
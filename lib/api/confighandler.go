@@ -14,19 +14,21 @@ import (
 	"github.com/julienschmidt/httprouter"
 
 	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
 	"github.com/syncthing/syncthing/lib/protocol"
 	"github.com/syncthing/syncthing/lib/structutil"
 )
 
 type configMuxBuilder struct {
 	*httprouter.Router
-	id  protocol.DeviceID
-	cfg config.Wrapper
+	id       protocol.DeviceID
+	cfg      config.Wrapper
+	evLogger events.Logger
 }
 
 func (c *configMuxBuilder) registerConfig(path string) {
-	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, _ *http.Request) {
-		sendJSON(w, c.cfg.RawCopy())
+	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, r *http.Request) {
+		sendJSON(w, visibleConfig(c.cfg.RawCopy(), requestPrincipal(r)))
 	})
 
 	c.HandlerFunc(http.MethodPut, path, func(w http.ResponseWriter, r *http.Request) {
@@ -35,8 +37,8 @@ func (c *configMuxBuilder) registerConfig(path string) {
 }
 
 func (c *configMuxBuilder) registerConfigDeprecated(path string) {
-	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, _ *http.Request) {
-		sendJSON(w, c.cfg.RawCopy())
+	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, r *http.Request) {
+		sendJSON(w, visibleConfig(c.cfg.RawCopy(), requestPrincipal(r)))
 	})
 
 	c.HandlerFunc(http.MethodPost, path, func(w http.ResponseWriter, r *http.Request) {
@@ -57,8 +59,8 @@ func (c *configMuxBuilder) registerConfigRequiresRestart(path string) {
 }
 
 func (c *configMuxBuilder) registerFolders(path string) {
-	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, _ *http.Request) {
-		sendJSON(w, c.cfg.FolderList())
+	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, r *http.Request) {
+		sendJSON(w, visibleConfig(c.cfg.RawCopy(), requestPrincipal(r)).Folders)
 	})
 
 	c.HandlerFunc(http.MethodPut, path, func(w http.ResponseWriter, r *http.Request) {
@@ -76,7 +78,7 @@ func (c *configMuxBuilder) registerFolders(path string) {
 				return
 			}
 		}
-		waiter, err := c.cfg.Modify(func(cfg *config.Configuration) {
+		waiter, err := c.modify(r, func(cfg *config.Configuration) {
 			cfg.SetFolders(folders)
 		})
 		if err != nil {
@@ -111,7 +113,7 @@ func (c *configMuxBuilder) registerDevices(path string) {
 				return
 			}
 		}
-		waiter, err := c.cfg.Modify(func(cfg *config.Configuration) {
+		waiter, err := c.modify(r, func(cfg *config.Configuration) {
 			cfg.SetDevices(devices)
 		})
 		if err != nil {
@@ -127,13 +129,14 @@ func (c *configMuxBuilder) registerDevices(path string) {
 }
 
 func (c *configMuxBuilder) registerFolder(path string) {
-	c.Handle(http.MethodGet, path, func(w http.ResponseWriter, _ *http.Request, p httprouter.Params) {
+	c.Handle(http.MethodGet, path, func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		principal := requestPrincipal(r)
 		folder, ok := c.cfg.Folder(p.ByName("id"))
-		if !ok {
+		if !ok || !principal.hasFolder(folder.ID) {
 			http.Error(w, "No folder with given ID", http.StatusNotFound)
 			return
 		}
-		sendJSON(w, folder)
+		sendJSON(w, visibleFolder(folder, principal))
 	})
 
 	c.Handle(http.MethodPut, path, func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
@@ -149,13 +152,15 @@ func (c *configMuxBuilder) registerFolder(path string) {
 		c.adjustFolder(w, r, folder, false)
 	})
 
-	c.Handle(http.MethodDelete, path, func(w http.ResponseWriter, _ *http.Request, p httprouter.Params) {
+	c.Handle(http.MethodDelete, path, func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		from := c.cfg.RawCopy()
 		waiter, err := c.cfg.RemoveFolder(p.ByName("id"))
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 		c.finish(w, waiter)
+		logConfigChanges(c.evLogger, r, "config", from, c.cfg.RawCopy())
 	})
 }
 
@@ -190,24 +195,94 @@ func (c *configMuxBuilder) registerDevice(path string) {
 		}
 	})
 
-	c.Handle(http.MethodDelete, path, func(w http.ResponseWriter, _ *http.Request, p httprouter.Params) {
+	c.Handle(http.MethodDelete, path, func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		id, err := protocol.DeviceIDFromString(p.ByName("id"))
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		from := c.cfg.RawCopy()
 		waiter, err := c.cfg.RemoveDevice(id)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 		c.finish(w, waiter)
+		logConfigChanges(c.evLogger, r, "config", from, c.cfg.RawCopy())
 	})
 }
 
-func (c *configMuxBuilder) registerDefaultFolder(path string) {
+func (c *configMuxBuilder) registerDeviceGroups(path string) {
 	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, _ *http.Request) {
-		sendJSON(w, c.cfg.DefaultFolder())
+		sendJSON(w, c.cfg.RawCopy().DeviceGroups)
+	})
+
+	c.HandlerFunc(http.MethodPut, path, func(w http.ResponseWriter, r *http.Request) {
+		var groups []config.DeviceGroupConfiguration
+		if err := unmarshalTo(r.Body, &groups); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		waiter, err := c.modify(r, func(cfg *config.Configuration) {
+			cfg.SetDeviceGroups(groups)
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		c.finish(w, waiter)
+	})
+
+	c.HandlerFunc(http.MethodPost, path, func(w http.ResponseWriter, r *http.Request) {
+		c.adjustDeviceGroup(w, r, config.DeviceGroupConfiguration{})
+	})
+}
+
+func (c *configMuxBuilder) registerDeviceGroup(path string) {
+	groupFromParams := func(w http.ResponseWriter, p httprouter.Params) (config.DeviceGroupConfiguration, bool) {
+		cfg := c.cfg.RawCopy()
+		group, _, ok := cfg.DeviceGroup(p.ByName("id"))
+		if !ok {
+			http.Error(w, "No device group with given ID", http.StatusNotFound)
+			return config.DeviceGroupConfiguration{}, false
+		}
+		return group, true
+	}
+
+	c.Handle(http.MethodGet, path, func(w http.ResponseWriter, _ *http.Request, p httprouter.Params) {
+		if group, ok := groupFromParams(w, p); ok {
+			sendJSON(w, group)
+		}
+	})
+
+	c.Handle(http.MethodPut, path, func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		c.adjustDeviceGroup(w, r, config.DeviceGroupConfiguration{ID: p.ByName("id")})
+	})
+
+	c.Handle(http.MethodPatch, path, func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		if group, ok := groupFromParams(w, p); ok {
+			c.adjustDeviceGroup(w, r, group)
+		}
+	})
+
+	c.Handle(http.MethodDelete, path, func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+		if _, ok := groupFromParams(w, p); !ok {
+			return
+		}
+		waiter, err := c.modify(r, func(cfg *config.Configuration) {
+			cfg.RemoveDeviceGroup(p.ByName("id"))
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		c.finish(w, waiter)
+	})
+}
+
+func (c *configMuxBuilder) registerDefaultFolder(path string) {
+	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, r *http.Request) {
+		sendJSON(w, visibleFolder(c.cfg.DefaultFolder(), requestPrincipal(r)))
 	})
 
 	c.HandlerFunc(http.MethodPut, path, func(w http.ResponseWriter, r *http.Request) {
@@ -248,7 +323,7 @@ func (c *configMuxBuilder) registerDefaultIgnores(path string) {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		waiter, err := c.cfg.Modify(func(cfg *config.Configuration) {
+		waiter, err := c.modify(r, func(cfg *config.Configuration) {
 			cfg.Defaults.Ignores = ignores
 		})
 		if err != nil {
@@ -292,8 +367,8 @@ func (c *configMuxBuilder) registerLDAP(path string) {
 }
 
 func (c *configMuxBuilder) registerGUI(path string) {
-	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, _ *http.Request) {
-		sendJSON(w, c.cfg.GUI())
+	c.HandlerFunc(http.MethodGet, path, func(w http.ResponseWriter, r *http.Request) {
+		sendJSON(w, visibleGUI(c.cfg.GUI(), requestPrincipal(r)))
 	})
 
 	c.HandlerFunc(http.MethodPut, path, func(w http.ResponseWriter, r *http.Request) {
@@ -317,7 +392,7 @@ func (c *configMuxBuilder) adjustConfig(w http.ResponseWriter, r *http.Request)
 	}
 	var errMsg string
 	var status int
-	waiter, err := c.cfg.Modify(func(cfg *config.Configuration) {
+	waiter, err := c.modify(r, func(cfg *config.Configuration) {
 		if err := c.postAdjustGui(&cfg.GUI, &to.GUI); err != nil {
 			errMsg = err.Error()
 			status = http.StatusInternalServerError
@@ -339,7 +414,7 @@ func (c *configMuxBuilder) adjustFolder(w http.ResponseWriter, r *http.Request,
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	waiter, err := c.cfg.Modify(func(cfg *config.Configuration) {
+	waiter, err := c.modify(r, func(cfg *config.Configuration) {
 		if defaults {
 			cfg.Defaults.Folder = folder
 		} else {
@@ -358,7 +433,7 @@ func (c *configMuxBuilder) adjustDevice(w http.ResponseWriter, r *http.Request,
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	waiter, err := c.cfg.Modify(func(cfg *config.Configuration) {
+	waiter, err := c.modify(r, func(cfg *config.Configuration) {
 		if defaults {
 			cfg.Defaults.Device = device
 		} else {
@@ -372,12 +447,31 @@ func (c *configMuxBuilder) adjustDevice(w http.ResponseWriter, r *http.Request,
 	c.finish(w, waiter)
 }
 
+func (c *configMuxBuilder) adjustDeviceGroup(w http.ResponseWriter, r *http.Request, group config.DeviceGroupConfiguration) {
+	if err := unmarshalTo(r.Body, &group); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if group.ID == "" {
+		http.Error(w, "Device group has empty ID", http.StatusBadRequest)
+		return
+	}
+	waiter, err := c.modify(r, func(cfg *config.Configuration) {
+		cfg.SetDeviceGroup(group)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	c.finish(w, waiter)
+}
+
 func (c *configMuxBuilder) adjustOptions(w http.ResponseWriter, r *http.Request, opts config.OptionsConfiguration) {
 	if err := unmarshalTo(r.Body, &opts); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	waiter, err := c.cfg.Modify(func(cfg *config.Configuration) {
+	waiter, err := c.modify(r, func(cfg *config.Configuration) {
 		cfg.Options = opts
 	})
 	if err != nil {
@@ -395,7 +489,7 @@ func (c *configMuxBuilder) adjustGUI(w http.ResponseWriter, r *http.Request, gui
 	}
 	var errMsg string
 	var status int
-	waiter, err := c.cfg.Modify(func(cfg *config.Configuration) {
+	waiter, err := c.modify(r, func(cfg *config.Configuration) {
 		if err := c.postAdjustGui(&cfg.GUI, &gui); err != nil {
 			errMsg = err.Error()
 			status = http.StatusInternalServerError
@@ -419,6 +513,15 @@ func (c *configMuxBuilder) postAdjustGui(from *config.GUIConfiguration, to *conf
 			return err
 		}
 	}
+	for i, user := range to.Users {
+		if fromUser, ok := from.GUIUser(user.Name); user.Password == "" || (ok && user.Password == fromUser.Password) {
+			continue
+		}
+		if err := to.Users[i].SetPassword(user.Password); err != nil {
+			l.Warnln("hashing password:", err)
+			return err
+		}
+	}
 	return nil
 }
 
@@ -427,7 +530,7 @@ func (c *configMuxBuilder) adjustLDAP(w http.ResponseWriter, r *http.Request, ld
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	waiter, err := c.cfg.Modify(func(cfg *config.Configuration) {
+	waiter, err := c.modify(r, func(cfg *config.Configuration) {
 		cfg.LDAP = ldap
 	})
 	if err != nil {
@@ -453,6 +556,12 @@ func unmarshalToRawMessages(body io.ReadCloser) ([]json.RawMessage, error) {
 	return data, err
 }
 
+// modify is like c.cfg.Modify, logging the changes as made by the
+// principal of the request.
+func (c *configMuxBuilder) modify(r *http.Request, fn config.ModifyFunction) (config.Waiter, error) {
+	return auditedModify(c.cfg, c.evLogger, r, "config", fn)
+}
+
 func (c *configMuxBuilder) finish(w http.ResponseWriter, waiter config.Waiter) {
 	waiter.Wait()
 	if err := c.cfg.Save(); err != nil {
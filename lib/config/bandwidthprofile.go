@@ -0,0 +1,69 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// A BandwidthProfile replaces the static rate limits while its schedule is
+// active. As for the static limits, rates are in KiB/s and zero or negative
+// means unlimited.
+type BandwidthProfile struct {
+	Name        string   `json:"name" xml:"name,attr"`
+	Schedule    Schedule `json:"schedule" xml:"schedule>window"`
+	MaxSendKbps int      `json:"maxSendKbps" xml:"maxSendKbps"`
+	MaxRecvKbps int      `json:"maxRecvKbps" xml:"maxRecvKbps"`
+}
+
+type BandwidthProfiles []BandwidthProfile
+
+func (ps BandwidthProfiles) Copy() BandwidthProfiles {
+	if ps == nil {
+		return nil
+	}
+	c := make(BandwidthProfiles, len(ps))
+	for i, p := range ps {
+		c[i] = p
+		c[i].Schedule = slices.Clone(p.Schedule)
+	}
+	return c
+}
+
+// Active returns the first profile with a schedule that is active at the
+// given time.
+func (ps BandwidthProfiles) Active(t time.Time) (BandwidthProfile, bool) {
+	for _, p := range ps {
+		if p.Schedule.Active(t) {
+			return p, true
+		}
+	}
+	return BandwidthProfile{}, false
+}
+
+// prepare drops invalid windows and profiles without any valid window, as
+// an empty schedule would make the profile apply at all times.
+func (ps BandwidthProfiles) prepare(what string) BandwidthProfiles {
+	if ps == nil {
+		return nil
+	}
+	res := make(BandwidthProfiles, 0, len(ps))
+	for i, p := range ps {
+		if p.Name == "" {
+			p.Name = fmt.Sprintf("profile %d", i+1)
+		}
+		p.Schedule = p.Schedule.validWindows(fmt.Sprintf("bandwidth profile %q of %s", p.Name, what))
+		if len(p.Schedule) == 0 {
+			l.Warnf("Ignoring bandwidth profile %q of %s without schedule", p.Name, what)
+			continue
+		}
+		res = append(res, p)
+	}
+	return res
+}
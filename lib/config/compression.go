@@ -56,3 +56,49 @@ func (c Compression) ToProtocol() protocol.Compression {
 		return protocol.CompressionMetadata
 	}
 }
+
+// CompressionAlgorithm is the algorithm we prefer for compressing messages
+// to a device. It's only used if the device supports it, otherwise we fall
+// back to LZ4.
+type CompressionAlgorithm int32
+
+const (
+	CompressionAlgorithmLZ4  CompressionAlgorithm = 0
+	CompressionAlgorithmZstd CompressionAlgorithm = 1
+)
+
+func (c CompressionAlgorithm) String() string {
+	switch c {
+	case CompressionAlgorithmLZ4:
+		return "lz4"
+	case CompressionAlgorithmZstd:
+		return "zstd"
+	default:
+		return "unknown"
+	}
+}
+
+func (c CompressionAlgorithm) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+func (c *CompressionAlgorithm) UnmarshalText(bs []byte) error {
+	switch string(bs) {
+	case "lz4":
+		*c = CompressionAlgorithmLZ4
+	case "zstd":
+		*c = CompressionAlgorithmZstd
+	default:
+		*c = CompressionAlgorithmLZ4
+	}
+	return nil
+}
+
+func (c CompressionAlgorithm) ToProtocol() protocol.MessageCompression {
+	switch c {
+	case CompressionAlgorithmZstd:
+		return protocol.MessageCompressionZstd
+	default:
+		return protocol.MessageCompressionLZ4
+	}
+}
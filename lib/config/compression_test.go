@@ -47,3 +47,26 @@ func TestCompressionMarshal(t *testing.T) {
 		}
 	}
 }
+
+func TestCompressionAlgorithmMarshal(t *testing.T) {
+	for _, tc := range []struct {
+		s string
+		c CompressionAlgorithm
+	}{
+		{"lz4", CompressionAlgorithmLZ4},
+		{"zstd", CompressionAlgorithmZstd},
+		{"whatever", CompressionAlgorithmLZ4},
+	} {
+		var c CompressionAlgorithm
+		if err := c.UnmarshalText([]byte(tc.s)); err != nil {
+			t.Fatal(err)
+		}
+		if c != tc.c {
+			t.Errorf("%q: got %v, expected %v", tc.s, c, tc.c)
+		}
+	}
+
+	if bs, _ := CompressionAlgorithmZstd.MarshalText(); string(bs) != "zstd" {
+		t.Errorf("unexpected marshalling %q", bs)
+	}
+}
@@ -93,15 +93,18 @@ var (
 )
 
 type Configuration struct {
-	Version                  int                   `json:"version" xml:"version,attr"`
-	Folders                  []FolderConfiguration `json:"folders" xml:"folder"`
-	Devices                  []DeviceConfiguration `json:"devices" xml:"device"`
-	GUI                      GUIConfiguration      `json:"gui" xml:"gui"`
-	LDAP                     LDAPConfiguration     `json:"ldap" xml:"ldap"`
-	Options                  OptionsConfiguration  `json:"options" xml:"options"`
-	IgnoredDevices           []ObservedDevice      `json:"remoteIgnoredDevices" xml:"remoteIgnoredDevice"`
-	DeprecatedPendingDevices []ObservedDevice      `json:"-" xml:"pendingDevice,omitempty"` // Deprecated: Do not use.
-	Defaults                 Defaults              `json:"defaults" xml:"defaults"`
+	Version                  int                        `json:"version" xml:"version,attr"`
+	Folders                  []FolderConfiguration      `json:"folders" xml:"folder"`
+	Devices                  []DeviceConfiguration      `json:"devices" xml:"device"`
+	GUI                      GUIConfiguration           `json:"gui" xml:"gui"`
+	LDAP                     LDAPConfiguration          `json:"ldap" xml:"ldap"`
+	OIDC                     OIDCConfiguration          `json:"oidc" xml:"oidc"`
+	Options                  OptionsConfiguration       `json:"options" xml:"options"`
+	IgnoredDevices           []ObservedDevice           `json:"remoteIgnoredDevices" xml:"remoteIgnoredDevice"`
+	DeprecatedPendingDevices []ObservedDevice           `json:"-" xml:"pendingDevice,omitempty"` // Deprecated: Do not use.
+	Defaults                 Defaults                   `json:"defaults" xml:"defaults"`
+	Webhooks                 []WebhookConfiguration     `json:"webhooks" xml:"webhook"`
+	DeviceGroups             []DeviceGroupConfiguration `json:"deviceGroups" xml:"deviceGroup"`
 }
 
 type Defaults struct {
@@ -249,11 +252,23 @@ func (cfg Configuration) Copy() Configuration {
 
 	newCfg.Options = cfg.Options.Copy()
 	newCfg.GUI = cfg.GUI.Copy()
+	newCfg.LDAP = cfg.LDAP.Copy()
+	newCfg.OIDC = cfg.OIDC.Copy()
 
 	// DeviceIDs are values
 	newCfg.IgnoredDevices = make([]ObservedDevice, len(cfg.IgnoredDevices))
 	copy(newCfg.IgnoredDevices, cfg.IgnoredDevices)
 
+	newCfg.Webhooks = make([]WebhookConfiguration, len(cfg.Webhooks))
+	for i := range newCfg.Webhooks {
+		newCfg.Webhooks[i] = cfg.Webhooks[i].Copy()
+	}
+
+	newCfg.DeviceGroups = make([]DeviceGroupConfiguration, len(cfg.DeviceGroups))
+	for i := range newCfg.DeviceGroups {
+		newCfg.DeviceGroups[i] = cfg.DeviceGroups[i].Copy()
+	}
+
 	return newCfg
 }
 
@@ -286,6 +301,8 @@ func (cfg *Configuration) prepare(myID protocol.DeviceID) error {
 
 	cfg.Defaults.prepare(myID, existingDevices)
 
+	cfg.prepareWebhooks()
+
 	cfg.removeDeprecatedProtocols()
 
 	structutil.FillNilExceptDeprecated(cfg)
@@ -316,7 +333,9 @@ func (cfg *Configuration) ensureMyDevice(myID protocol.DeviceID) {
 func (cfg *Configuration) prepareFoldersAndDevices(myID protocol.DeviceID) (map[protocol.DeviceID]*DeviceConfiguration, error) {
 	existingDevices := cfg.prepareDeviceList()
 
-	sharedFolders, err := cfg.prepareFolders(myID, existingDevices)
+	deviceGroups := cfg.prepareDeviceGroups(existingDevices)
+
+	sharedFolders, err := cfg.prepareFolders(myID, existingDevices, deviceGroups)
 	if err != nil {
 		return nil, err
 	}
@@ -345,7 +364,7 @@ func (cfg *Configuration) prepareDeviceList() map[protocol.DeviceID]*DeviceConfi
 	return existingDevices
 }
 
-func (cfg *Configuration) prepareFolders(myID protocol.DeviceID, existingDevices map[protocol.DeviceID]*DeviceConfiguration) (map[protocol.DeviceID][]string, error) {
+func (cfg *Configuration) prepareFolders(myID protocol.DeviceID, existingDevices map[protocol.DeviceID]*DeviceConfiguration, deviceGroups map[string][]protocol.DeviceID) (map[protocol.DeviceID][]string, error) {
 	// Prepare folders and check for duplicates. Duplicates are bad and
 	// dangerous, can't currently be resolved in the GUI, and shouldn't
 	// happen when configured by the GUI. We return with an error in that
@@ -367,6 +386,7 @@ func (cfg *Configuration) prepareFolders(myID protocol.DeviceID, existingDevices
 			return nil, fmt.Errorf("folder %q: %w", folder.ID, errFolderIDDuplicate)
 		}
 
+		folder.expandDeviceGroups(deviceGroups)
 		folder.prepare(myID, existingDevices)
 
 		existingFolders[folder.ID] = folder
@@ -62,6 +62,7 @@ func TestDefaultValues(t *testing.T) {
 			LocalAnnMCAddr:            "[ff12::8384]:21027",
 			MaxSendKbps:               0,
 			MaxRecvKbps:               0,
+			BandwidthProfiles:         BandwidthProfiles{},
 			ReconnectIntervalS:        60,
 			RelaysEnabled:             true,
 			RelayReconnectIntervalM:   10,
@@ -94,6 +95,8 @@ func TestDefaultValues(t *testing.T) {
 			FeatureFlags:              []string{},
 			AuditEnabled:              false,
 			AuditFile:                 "",
+			AuditMaxFiles:             3,
+			AuditEvents:               []string{},
 			ConnectionPriorityTCPLAN:  10,
 			ConnectionPriorityQUICLAN: 20,
 			ConnectionPriorityTCPWAN:  30,
@@ -126,18 +129,27 @@ func TestDefaultValues(t *testing.T) {
 					MaxSingleEntrySize: 1024,
 					MaxTotalSize:       4096,
 				},
+				Schedule:       Schedule{},
+				ConflictRules:  []ConflictRule{},
+				PullPriorities: []PullPriority{},
+				SelectedPaths:  []string{},
+				DeviceGroups:   []string{},
 			},
 			Device: DeviceConfiguration{
-				Addresses:       []string{"dynamic"},
-				AllowedNetworks: []string{},
-				Compression:     CompressionMetadata,
-				IgnoredFolders:  []ObservedFolder{},
+				Addresses:         []string{"dynamic"},
+				AllowedNetworks:   []string{},
+				Compression:       CompressionMetadata,
+				IgnoredFolders:    []ObservedFolder{},
+				Schedule:          Schedule{},
+				BandwidthProfiles: BandwidthProfiles{},
 			},
 			Ignores: Ignores{
 				Lines: []string{},
 			},
 		},
 		IgnoredDevices: []ObservedDevice{},
+		Webhooks:       []WebhookConfiguration{},
+		DeviceGroups:   []DeviceGroupConfiguration{},
 	}
 	expected.Devices = []DeviceConfiguration{expected.Defaults.Device.Copy()}
 	expected.Devices[0].DeviceID = device1
@@ -146,6 +158,7 @@ func TestDefaultValues(t *testing.T) {
 	cfg := New(device1)
 	cfg.GUI = GUIConfiguration{}
 	cfg.LDAP = LDAPConfiguration{}
+	cfg.OIDC = OIDCConfiguration{}
 
 	if diff, equal := messagediff.PrettyDiff(expected, cfg); !equal {
 		t.Errorf("Default config differs. Diff:\n%s", diff)
@@ -204,25 +217,34 @@ func TestDeviceConfig(t *testing.T) {
 					MaxTotalSize:       4096,
 					Entries:            []XattrFilterEntry{},
 				},
+				Schedule:       Schedule{},
+				ConflictRules:  []ConflictRule{},
+				PullPriorities: []PullPriority{},
+				SelectedPaths:  []string{},
+				DeviceGroups:   []string{},
 			},
 		}
 
 		expectedDevices := []DeviceConfiguration{
 			{
-				DeviceID:        device1,
-				Name:            "node one",
-				Addresses:       []string{"tcp://a"},
-				Compression:     CompressionMetadata,
-				AllowedNetworks: []string{},
-				IgnoredFolders:  []ObservedFolder{},
+				DeviceID:          device1,
+				Name:              "node one",
+				Addresses:         []string{"tcp://a"},
+				Compression:       CompressionMetadata,
+				AllowedNetworks:   []string{},
+				IgnoredFolders:    []ObservedFolder{},
+				Schedule:          Schedule{},
+				BandwidthProfiles: BandwidthProfiles{},
 			},
 			{
-				DeviceID:        device4,
-				Name:            "node two",
-				Addresses:       []string{"tcp://b"},
-				Compression:     CompressionMetadata,
-				AllowedNetworks: []string{},
-				IgnoredFolders:  []ObservedFolder{},
+				DeviceID:          device4,
+				Name:              "node two",
+				Addresses:         []string{"tcp://b"},
+				Compression:       CompressionMetadata,
+				AllowedNetworks:   []string{},
+				IgnoredFolders:    []ObservedFolder{},
+				Schedule:          Schedule{},
+				BandwidthProfiles: BandwidthProfiles{},
 			},
 		}
 		expectedDeviceIDs := []protocol.DeviceID{device1, device4}
@@ -266,6 +288,7 @@ func TestOverriddenValues(t *testing.T) {
 		LocalAnnMCAddr:            "quux:3232",
 		MaxSendKbps:               1234,
 		MaxRecvKbps:               2341,
+		BandwidthProfiles:         BandwidthProfiles{},
 		ReconnectIntervalS:        6000,
 		RelaysEnabled:             false,
 		RelayReconnectIntervalM:   20,
@@ -299,6 +322,9 @@ func TestOverriddenValues(t *testing.T) {
 		FeatureFlags:              []string{"feature"},
 		AuditEnabled:              true,
 		AuditFile:                 "nggyu",
+		AuditMaxSize:              1 << 20,
+		AuditMaxFiles:             5,
+		AuditEvents:               []string{"-ItemStarted", "-ItemFinished"},
 		ConnectionPriorityTCPLAN:  40,
 		ConnectionPriorityQUICLAN: 45,
 		ConnectionPriorityTCPWAN:  50,
@@ -332,30 +358,38 @@ func TestDeviceAddressesDynamic(t *testing.T) {
 	name, _ := os.Hostname()
 	expected := map[protocol.DeviceID]DeviceConfiguration{
 		device1: {
-			DeviceID:        device1,
-			Addresses:       []string{"dynamic"},
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device1,
+			Addresses:         []string{"dynamic"},
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			Schedule:          Schedule{},
+			BandwidthProfiles: BandwidthProfiles{},
 		},
 		device2: {
-			DeviceID:        device2,
-			Addresses:       []string{"dynamic"},
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device2,
+			Addresses:         []string{"dynamic"},
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			Schedule:          Schedule{},
+			BandwidthProfiles: BandwidthProfiles{},
 		},
 		device3: {
-			DeviceID:        device3,
-			Addresses:       []string{"dynamic"},
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device3,
+			Addresses:         []string{"dynamic"},
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			Schedule:          Schedule{},
+			BandwidthProfiles: BandwidthProfiles{},
 		},
 		device4: {
-			DeviceID:        device4,
-			Name:            name, // Set when auto created
-			Addresses:       []string{"dynamic"},
-			Compression:     CompressionMetadata,
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device4,
+			Name:              name, // Set when auto created
+			Addresses:         []string{"dynamic"},
+			Compression:       CompressionMetadata,
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			Schedule:          Schedule{},
+			BandwidthProfiles: BandwidthProfiles{},
 		},
 	}
 
@@ -375,33 +409,41 @@ func TestDeviceCompression(t *testing.T) {
 	name, _ := os.Hostname()
 	expected := map[protocol.DeviceID]DeviceConfiguration{
 		device1: {
-			DeviceID:        device1,
-			Addresses:       []string{"dynamic"},
-			Compression:     CompressionMetadata,
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device1,
+			Addresses:         []string{"dynamic"},
+			Compression:       CompressionMetadata,
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			Schedule:          Schedule{},
+			BandwidthProfiles: BandwidthProfiles{},
 		},
 		device2: {
-			DeviceID:        device2,
-			Addresses:       []string{"dynamic"},
-			Compression:     CompressionMetadata,
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device2,
+			Addresses:         []string{"dynamic"},
+			Compression:       CompressionMetadata,
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			Schedule:          Schedule{},
+			BandwidthProfiles: BandwidthProfiles{},
 		},
 		device3: {
-			DeviceID:        device3,
-			Addresses:       []string{"dynamic"},
-			Compression:     CompressionNever,
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device3,
+			Addresses:         []string{"dynamic"},
+			Compression:       CompressionNever,
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			Schedule:          Schedule{},
+			BandwidthProfiles: BandwidthProfiles{},
 		},
 		device4: {
-			DeviceID:        device4,
-			Name:            name, // Set when auto created
-			Addresses:       []string{"dynamic"},
-			Compression:     CompressionMetadata,
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device4,
+			Name:              name, // Set when auto created
+			Addresses:         []string{"dynamic"},
+			Compression:       CompressionMetadata,
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			Schedule:          Schedule{},
+			BandwidthProfiles: BandwidthProfiles{},
 		},
 	}
 
@@ -421,30 +463,38 @@ func TestDeviceAddressesStatic(t *testing.T) {
 	name, _ := os.Hostname()
 	expected := map[protocol.DeviceID]DeviceConfiguration{
 		device1: {
-			DeviceID:        device1,
-			Addresses:       []string{"tcp://192.0.2.1", "tcp://192.0.2.2"},
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device1,
+			Addresses:         []string{"tcp://192.0.2.1", "tcp://192.0.2.2"},
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			Schedule:          Schedule{},
+			BandwidthProfiles: BandwidthProfiles{},
 		},
 		device2: {
-			DeviceID:        device2,
-			Addresses:       []string{"tcp://192.0.2.3:6070", "tcp://[2001:db8::42]:4242"},
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device2,
+			Addresses:         []string{"tcp://192.0.2.3:6070", "tcp://[2001:db8::42]:4242"},
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			Schedule:          Schedule{},
+			BandwidthProfiles: BandwidthProfiles{},
 		},
 		device3: {
-			DeviceID:        device3,
-			Addresses:       []string{"tcp://[2001:db8::44]:4444", "tcp://192.0.2.4:6090"},
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device3,
+			Addresses:         []string{"tcp://[2001:db8::44]:4444", "tcp://192.0.2.4:6090"},
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			Schedule:          Schedule{},
+			BandwidthProfiles: BandwidthProfiles{},
 		},
 		device4: {
-			DeviceID:        device4,
-			Name:            name, // Set when auto created
-			Addresses:       []string{"dynamic"},
-			Compression:     CompressionMetadata,
-			AllowedNetworks: []string{},
-			IgnoredFolders:  []ObservedFolder{},
+			DeviceID:          device4,
+			Name:              name, // Set when auto created
+			Addresses:         []string{"dynamic"},
+			Compression:       CompressionMetadata,
+			AllowedNetworks:   []string{},
+			IgnoredFolders:    []ObservedFolder{},
+			Schedule:          Schedule{},
+			BandwidthProfiles: BandwidthProfiles{},
 		},
 	}
 
@@ -818,6 +868,43 @@ func TestGUIPasswordHash(t *testing.T) {
 	}
 }
 
+func TestGUIUsersAndScopedAPIKeys(t *testing.T) {
+	gui := GUIConfiguration{
+		User:   "admin",
+		APIKey: "main",
+		Users: []GUIUserConfiguration{
+			{Name: "ops", Role: GUIRoleOperator, Folders: []string{"a", "b"}},
+			{Name: "ops", Role: GUIRoleAdmin},
+			{Name: "admin", Role: GUIRoleMonitor},
+		},
+		ScopedAPIKeys: []GUIAPIKeyConfiguration{
+			{Name: "monitoring", Key: "key1"},
+			{Name: "copy", Key: "main", Role: GUIRoleAdmin},
+		},
+	}
+
+	bs, err := xml.Marshal(gui)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res GUIConfiguration
+	if err := xml.Unmarshal(bs, &res); err != nil {
+		t.Fatal(err)
+	}
+	res.prepare()
+
+	expUsers := []GUIUserConfiguration{{Name: "ops", Role: GUIRoleOperator, Folders: []string{"a", "b"}}}
+	if !reflect.DeepEqual(res.Users, expUsers) {
+		t.Errorf("unexpected users %v, expected %v", res.Users, expUsers)
+	}
+	if len(res.ScopedAPIKeys) != 1 || res.ScopedAPIKeys[0].Key != "key1" || res.ScopedAPIKeys[0].Role != GUIRoleMonitor {
+		t.Errorf("unexpected scoped API keys %v", res.ScopedAPIKeys)
+	}
+	if !res.IsValidAPIKey("key1") || res.IsValidAPIKey("key2") {
+		t.Error("expected scoped API keys to be valid API keys")
+	}
+}
+
 func TestDuplicateDevices(t *testing.T) {
 	// Duplicate devices should be removed
 
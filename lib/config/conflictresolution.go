@@ -0,0 +1,108 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+type ConflictStrategy int32
+
+const (
+	// The version with the newest modification time wins, the other one
+	// is renamed to a conflict copy.
+	ConflictStrategyNewest ConflictStrategy = 0
+	// The version last modified by the given device wins. If neither or
+	// both were, the newest wins.
+	ConflictStrategyPreferDevice ConflictStrategy = 1
+	// The larger version wins. If both are of the same size, the newest
+	// wins.
+	ConflictStrategyPreferLarger ConflictStrategy = 2
+	// The local file is never renamed, instead the incoming version is
+	// stored as a conflict copy next to it.
+	ConflictStrategyKeepBoth ConflictStrategy = 3
+	// An external command merges the incoming version into the local
+	// file. If the command fails, the newest wins.
+	ConflictStrategyMerge ConflictStrategy = 4
+)
+
+func (s ConflictStrategy) String() string {
+	switch s {
+	case ConflictStrategyNewest:
+		return "newest"
+	case ConflictStrategyPreferDevice:
+		return "preferDevice"
+	case ConflictStrategyPreferLarger:
+		return "preferLarger"
+	case ConflictStrategyKeepBoth:
+		return "keepBoth"
+	case ConflictStrategyMerge:
+		return "merge"
+	default:
+		return "unknown"
+	}
+}
+
+func (s ConflictStrategy) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *ConflictStrategy) UnmarshalText(bs []byte) error {
+	switch string(bs) {
+	case "newest":
+		*s = ConflictStrategyNewest
+	case "preferDevice":
+		*s = ConflictStrategyPreferDevice
+	case "preferLarger":
+		*s = ConflictStrategyPreferLarger
+	case "keepBoth":
+		*s = ConflictStrategyKeepBoth
+	case "merge":
+		*s = ConflictStrategyMerge
+	default:
+		*s = ConflictStrategyNewest
+	}
+	return nil
+}
+
+// A ConflictRule selects the strategy used to resolve conflicts on files
+// matching the pattern, which has the same syntax as the lines of an ignore
+// file. An empty pattern matches all files. The first matching rule of a
+// folder applies; without one conflicts are resolved by the newest version
+// winning.
+//
+// The merge command is split and expanded like the command of the external
+// versioner, with %FOLDER_PATH%, %FILE_PATH% (the local file) and
+// %REMOTE_PATH% (the incoming version) available. It should write the
+// merged result to the local file and exit with status zero.
+type ConflictRule struct {
+	Pattern      string            `json:"pattern" xml:"pattern,attr"`
+	Strategy     ConflictStrategy  `json:"strategy" xml:"strategy,attr"`
+	Device       protocol.DeviceID `json:"device" xml:"device,omitempty"`
+	MergeCommand string            `json:"mergeCommand" xml:"mergeCommand,omitempty"`
+}
+
+// prepareConflictRules drops rules lacking the parameters their strategy
+// requires.
+func (f *FolderConfiguration) prepareConflictRules() {
+	if f.ConflictRules == nil {
+		return
+	}
+	rules := f.ConflictRules[:0]
+	for _, r := range f.ConflictRules {
+		switch {
+		case r.Strategy == ConflictStrategyPreferDevice && r.Device == protocol.EmptyDeviceID:
+			l.Warnf("Ignoring conflict rule %q of folder %s without device", r.Pattern, f.Description())
+			continue
+		case r.Strategy == ConflictStrategyMerge && r.MergeCommand == "":
+			l.Warnf("Ignoring conflict rule %q of folder %s without merge command", r.Pattern, f.Description())
+			continue
+		}
+		rules = append(rules, r)
+	}
+	f.ConflictRules = rules
+}
@@ -0,0 +1,227 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// A DesiredState describes the configuration that we should have, for
+// managing it declaratively. It has the shape of Configuration, in JSON or
+// YAML, but only the folders, devices, options and GUI sections are
+// supported. Sections missing from the desired state are left alone. For
+// the others:
+//
+//   - The folders and devices are exactly those listed, apart from our own
+//     device which is always kept.
+//
+//   - Within each folder and device, and the options and GUI settings, the values
+//     that are given replace those in the configuration. Those that aren't
+//     given are left as they are, or take the default values for newly
+//     added folders and devices.
+//
+// Plain text GUI passwords are accepted, and considered to be in effect if
+// they match the stored hashes.
+type DesiredState struct {
+	Folders []json.RawMessage `json:"folders"`
+	Devices []json.RawMessage `json:"devices"`
+	Options json.RawMessage   `json:"options"`
+	GUI     json.RawMessage   `json:"gui"`
+}
+
+// ReadDesiredState reads a desired state from a JSON or YAML file.
+func ReadDesiredState(path string) (DesiredState, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return DesiredState{}, err
+	}
+	return ParseDesiredState(bs)
+}
+
+// ParseDesiredState parses a desired state in JSON or YAML format.
+func ParseDesiredState(bs []byte) (DesiredState, error) {
+	// JSON is valid YAML.
+	js, err := yaml.YAMLToJSON(bs)
+	if err != nil {
+		return DesiredState{}, err
+	}
+	var s DesiredState
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&s); err != nil {
+		return DesiredState{}, err
+	}
+	return s, nil
+}
+
+// Reconcile returns the configuration resulting from applying the desired
+// state to the given one, and the changes that makes.
+func (s DesiredState) Reconcile(cfg Configuration, myID protocol.DeviceID) (Configuration, []Change, error) {
+	to := cfg.Copy()
+	if err := s.Apply(&to, myID); err != nil {
+		return Configuration{}, nil, err
+	}
+	if err := to.prepare(myID); err != nil {
+		return Configuration{}, nil, err
+	}
+	return to, Diff(cfg, to), nil
+}
+
+// Apply modifies the configuration according to the desired state. The
+// result is to be prepared, e.g. by way of Wrapper.Modify.
+func (s DesiredState) Apply(cfg *Configuration, myID protocol.DeviceID) error {
+	if s.Devices != nil {
+		devices, err := s.devices(cfg, myID)
+		if err != nil {
+			return err
+		}
+		cfg.Devices = devices
+	}
+
+	if s.Folders != nil {
+		folders, err := s.folders(cfg)
+		if err != nil {
+			return err
+		}
+		cfg.Folders = folders
+	}
+
+	if s.Options != nil {
+		var opts OptionsConfiguration
+		if err := mergeJSON(cfg.Options, s.Options, &opts); err != nil {
+			return fmt.Errorf("options: %w", err)
+		}
+		cfg.Options = opts
+	}
+
+	if s.GUI != nil {
+		var gui GUIConfiguration
+		if err := mergeJSON(cfg.GUI, s.GUI, &gui); err != nil {
+			return fmt.Errorf("gui: %w", err)
+		}
+		if gui.Password != "" && gui.Password != cfg.GUI.Password {
+			if cfg.GUI.Password != "" && cfg.GUI.CompareHashedPassword(gui.Password) == nil {
+				gui.Password = cfg.GUI.Password
+			} else if err := gui.SetPassword(gui.Password); err != nil {
+				return fmt.Errorf("gui: %w", err)
+			}
+		}
+		for i, user := range gui.Users {
+			existing, ok := cfg.GUI.GUIUser(user.Name)
+			if user.Password == "" || (ok && user.Password == existing.Password) {
+				continue
+			}
+			if ok && existing.Password != "" && existing.CompareHashedPassword(user.Password) == nil {
+				gui.Users[i].Password = existing.Password
+			} else if err := gui.Users[i].SetPassword(user.Password); err != nil {
+				return fmt.Errorf("gui user %q: %w", user.Name, err)
+			}
+		}
+		cfg.GUI = gui
+	}
+
+	return nil
+}
+
+func (s DesiredState) devices(cfg *Configuration, myID protocol.DeviceID) ([]DeviceConfiguration, error) {
+	devices := make([]DeviceConfiguration, 0, len(s.Devices)+1)
+	seen := make(map[protocol.DeviceID]struct{}, len(s.Devices))
+	for _, bs := range s.Devices {
+		var key struct {
+			DeviceID protocol.DeviceID `json:"deviceID"`
+		}
+		if err := json.Unmarshal(bs, &key); err != nil {
+			return nil, fmt.Errorf("device: %w", err)
+		}
+		if key.DeviceID == protocol.EmptyDeviceID {
+			return nil, errors.New("device: missing device ID")
+		}
+		if _, ok := seen[key.DeviceID]; ok {
+			return nil, fmt.Errorf("device %s: duplicate device ID", key.DeviceID)
+		}
+		seen[key.DeviceID] = struct{}{}
+
+		base, _, ok := cfg.Device(key.DeviceID)
+		if !ok {
+			base = cfg.Defaults.Device
+		}
+		var dev DeviceConfiguration
+		if err := mergeJSON(base, bs, &dev); err != nil {
+			return nil, fmt.Errorf("device %s: %w", key.DeviceID, err)
+		}
+		devices = append(devices, dev)
+	}
+
+	if _, ok := seen[myID]; !ok {
+		if dev, _, ok := cfg.Device(myID); ok {
+			devices = append(devices, dev)
+		}
+	}
+	return devices, nil
+}
+
+func (s DesiredState) folders(cfg *Configuration) ([]FolderConfiguration, error) {
+	folders := make([]FolderConfiguration, 0, len(s.Folders))
+	seen := make(map[string]struct{}, len(s.Folders))
+	for _, bs := range s.Folders {
+		var key struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(bs, &key); err != nil {
+			return nil, fmt.Errorf("folder: %w", err)
+		}
+		if key.ID == "" {
+			return nil, errFolderIDEmpty
+		}
+		if _, ok := seen[key.ID]; ok {
+			return nil, fmt.Errorf("folder %q: %w", key.ID, errFolderIDDuplicate)
+		}
+		seen[key.ID] = struct{}{}
+
+		base, _, ok := cfg.Folder(key.ID)
+		if !ok {
+			base = cfg.Defaults.Folder
+		}
+		var folder FolderConfiguration
+		if err := mergeJSON(base, bs, &folder); err != nil {
+			return nil, fmt.Errorf("folder %q: %w", key.ID, err)
+		}
+		folders = append(folders, folder)
+	}
+	return folders, nil
+}
+
+// mergeJSON unmarshals base into to, with the top level values of patch
+// replacing those of base.
+func mergeJSON(base any, patch json.RawMessage, to any) error {
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &values); err != nil {
+		return err
+	}
+	bs, err := json.Marshal(base)
+	if err != nil {
+		return err
+	}
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(bs, &merged); err != nil {
+		return err
+	}
+	maps.Copy(merged, values)
+	if bs, err = json.Marshal(merged); err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, to)
+}
@@ -0,0 +1,143 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+const testDesiredState = `
+devices:
+  - deviceID: GYRZZQB-IRNPV4Z-T7TC52W-EQYJ3TT-FDQW6MW-DFLMU42-SSSU6EM-FBK2VAY
+    name: office
+folders:
+  - id: docs
+    path: /srv/docs
+    rescanIntervalS: 60
+    devices:
+      - deviceID: GYRZZQB-IRNPV4Z-T7TC52W-EQYJ3TT-FDQW6MW-DFLMU42-SSSU6EM-FBK2VAY
+options:
+  relaysEnabled: false
+gui:
+  user: admin
+  password: secret
+`
+
+func TestDesiredStateReconcile(t *testing.T) {
+	state, err := ParseDesiredState([]byte(testDesiredState))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := New(device1)
+	cfg.SetDevice(DeviceConfiguration{DeviceID: device3, Name: "old"})
+	cfg.SetFolder(FolderConfiguration{ID: "old", Path: "/srv/old"})
+	cfg.GUI.APIKey = "abc123"
+	if err := cfg.prepare(device1); err != nil {
+		t.Fatal(err)
+	}
+
+	to, changes, err := state.Reconcile(cfg, device1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(to.Devices) != 2 {
+		t.Error("expected our own and the listed device, got", to.Devices)
+	}
+	if dev, ok := to.DeviceMap()[device2]; !ok || dev.Name != "office" || !slices.Equal(dev.Addresses, []string{"dynamic"}) {
+		t.Error("expected the new device with defaults, got", dev)
+	}
+	if len(to.Folders) != 1 {
+		t.Fatal("expected only the listed folder, got", to.Folders)
+	}
+	folder := to.Folders[0]
+	if folder.RescanIntervalS != 60 || folder.FSWatcherDelayS != 10 {
+		t.Error("expected the given value and defaults, got", folder.RescanIntervalS, folder.FSWatcherDelayS)
+	}
+	if _, ok := folder.Device(device1); !ok {
+		t.Error("expected the folder to be shared with our own device")
+	}
+	if to.Options.RelaysEnabled || !to.Options.GlobalAnnEnabled {
+		t.Error("expected only the given option to change")
+	}
+	if to.GUI.CompareHashedPassword("secret") != nil || to.GUI.APIKey != "abc123" {
+		t.Error("expected a hashed password and unchanged API key, got", to.GUI)
+	}
+
+	paths := make([]string, len(changes))
+	for i, c := range changes {
+		paths[i] = c.Path
+		if c.Path == "gui.password" && c.To != diffRedacted {
+			t.Error("expected the password to be redacted, got", c)
+		}
+	}
+	for _, exp := range []string{"devices[" + device2.String() + "]", "devices[" + device3.String() + "]", "folders[docs]", "folders[old]", "gui.password", "gui.user", "options.relaysEnabled"} {
+		if !slices.Contains(paths, exp) {
+			t.Errorf("expected a change of %v, got %v", exp, paths)
+		}
+	}
+
+	// Reconciling again changes nothing, the password included.
+	if _, changes, err := state.Reconcile(to, device1); err != nil {
+		t.Fatal(err)
+	} else if len(changes) != 0 {
+		t.Error("expected no changes, got", changes)
+	}
+
+	// Drift is corrected.
+	to.Folders[0].RescanIntervalS = 3600
+	_, changes, err = state.Reconcile(to, device1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].String() != "folders[docs].rescanIntervalS: 3600 -> 60" {
+		t.Error("unexpected changes", changes)
+	}
+}
+
+func TestDesiredStateErrors(t *testing.T) {
+	cases := []string{
+		`{"folders": [], "ldap": {}}`,
+		`folders: [{path: /srv/docs}]`,
+		`folders: [{id: docs, path: a}, {id: docs, path: b}]`,
+		`devices: [{name: nameless}]`,
+	}
+	for _, c := range cases {
+		state, err := ParseDesiredState([]byte(c))
+		if err == nil {
+			cfg := New(device1)
+			err = state.Apply(&cfg, device1)
+		}
+		if err == nil {
+			t.Errorf("expected an error for %q", c)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	from := New(device1)
+	from.Folders = []FolderConfiguration{{ID: "a", Path: "a", Devices: []FolderDeviceConfiguration{{DeviceID: device1}}}}
+	to := from.Copy()
+	to.Folders[0].Devices = append(to.Folders[0].Devices, FolderDeviceConfiguration{DeviceID: device2, EncryptionPassword: "pw"})
+	to.Folders[0].Label = "A"
+	to.Options.RawListenAddresses = []string{"tcp://:22000"}
+
+	var res []string
+	for _, c := range Diff(from, to) {
+		res = append(res, c.String())
+	}
+	exp := []string{
+		`folders[a].devices[` + device2.String() + `]: added {"deviceGroup":"","deviceID":"` + device2.String() + `","encryptionPassword":"<redacted>","introducedBy":""}`,
+		`folders[a].label: "" -> "A"`,
+		`options.listenAddresses: ["default"] -> ["tcp://:22000"]`,
+	}
+	if !slices.Equal(res, exp) {
+		t.Errorf("unexpected diff:\n%v\nexpected:\n%v", res, exp)
+	}
+}
@@ -8,6 +8,7 @@ package config
 
 import (
 	"fmt"
+	"slices"
 	"sort"
 
 	"github.com/syncthing/syncthing/lib/protocol"
@@ -16,25 +17,29 @@ import (
 const defaultNumConnections = 1 // number of connections to use by default; may change in the future.
 
 type DeviceConfiguration struct {
-	DeviceID                 protocol.DeviceID `json:"deviceID" xml:"id,attr" nodefault:"true"`
-	Name                     string            `json:"name" xml:"name,attr,omitempty"`
-	Addresses                []string          `json:"addresses" xml:"address,omitempty"`
-	Compression              Compression       `json:"compression" xml:"compression,attr"`
-	CertName                 string            `json:"certName" xml:"certName,attr,omitempty"`
-	Introducer               bool              `json:"introducer" xml:"introducer,attr"`
-	SkipIntroductionRemovals bool              `json:"skipIntroductionRemovals" xml:"skipIntroductionRemovals,attr"`
-	IntroducedBy             protocol.DeviceID `json:"introducedBy" xml:"introducedBy,attr" nodefault:"true"`
-	Paused                   bool              `json:"paused" xml:"paused"`
-	AllowedNetworks          []string          `json:"allowedNetworks" xml:"allowedNetwork,omitempty"`
-	AutoAcceptFolders        bool              `json:"autoAcceptFolders" xml:"autoAcceptFolders"`
-	MaxSendKbps              int               `json:"maxSendKbps" xml:"maxSendKbps"`
-	MaxRecvKbps              int               `json:"maxRecvKbps" xml:"maxRecvKbps"`
-	IgnoredFolders           []ObservedFolder  `json:"ignoredFolders" xml:"ignoredFolder"`
-	DeprecatedPendingFolders []ObservedFolder  `json:"-" xml:"pendingFolder,omitempty"` // Deprecated: Do not use.
-	MaxRequestKiB            int               `json:"maxRequestKiB" xml:"maxRequestKiB"`
-	Untrusted                bool              `json:"untrusted" xml:"untrusted"`
-	RemoteGUIPort            int               `json:"remoteGUIPort" xml:"remoteGUIPort"`
-	RawNumConnections        int               `json:"numConnections" xml:"numConnections"`
+	DeviceID                 protocol.DeviceID    `json:"deviceID" xml:"id,attr" nodefault:"true"`
+	Name                     string               `json:"name" xml:"name,attr,omitempty"`
+	Addresses                []string             `json:"addresses" xml:"address,omitempty"`
+	Compression              Compression          `json:"compression" xml:"compression,attr"`
+	CompressionAlgorithm     CompressionAlgorithm `json:"compressionAlgorithm" xml:"compressionAlgorithm,attr"`
+	CompressionLevel         int                  `json:"compressionLevel" xml:"compressionLevel,attr,omitempty"`
+	CertName                 string               `json:"certName" xml:"certName,attr,omitempty"`
+	Introducer               bool                 `json:"introducer" xml:"introducer,attr"`
+	SkipIntroductionRemovals bool                 `json:"skipIntroductionRemovals" xml:"skipIntroductionRemovals,attr"`
+	IntroducedBy             protocol.DeviceID    `json:"introducedBy" xml:"introducedBy,attr" nodefault:"true"`
+	Paused                   bool                 `json:"paused" xml:"paused"`
+	AllowedNetworks          []string             `json:"allowedNetworks" xml:"allowedNetwork,omitempty"`
+	AutoAcceptFolders        bool                 `json:"autoAcceptFolders" xml:"autoAcceptFolders"`
+	MaxSendKbps              int                  `json:"maxSendKbps" xml:"maxSendKbps"`
+	MaxRecvKbps              int                  `json:"maxRecvKbps" xml:"maxRecvKbps"`
+	BandwidthProfiles        BandwidthProfiles    `json:"bandwidthProfiles" xml:"bandwidthProfile"`
+	IgnoredFolders           []ObservedFolder     `json:"ignoredFolders" xml:"ignoredFolder"`
+	DeprecatedPendingFolders []ObservedFolder     `json:"-" xml:"pendingFolder,omitempty"` // Deprecated: Do not use.
+	MaxRequestKiB            int                  `json:"maxRequestKiB" xml:"maxRequestKiB"`
+	Untrusted                bool                 `json:"untrusted" xml:"untrusted"`
+	RemoteGUIPort            int                  `json:"remoteGUIPort" xml:"remoteGUIPort"`
+	RawNumConnections        int                  `json:"numConnections" xml:"numConnections"`
+	Schedule                 Schedule             `json:"schedule" xml:"schedule>window"`
 }
 
 func (cfg DeviceConfiguration) Copy() DeviceConfiguration {
@@ -45,6 +50,8 @@ func (cfg DeviceConfiguration) Copy() DeviceConfiguration {
 	copy(c.AllowedNetworks, cfg.AllowedNetworks)
 	c.IgnoredFolders = make([]ObservedFolder, len(cfg.IgnoredFolders))
 	copy(c.IgnoredFolders, cfg.IgnoredFolders)
+	c.Schedule = slices.Clone(cfg.Schedule)
+	c.BandwidthProfiles = cfg.BandwidthProfiles.Copy()
 	return c
 }
 
@@ -61,6 +68,9 @@ func (cfg *DeviceConfiguration) prepare(sharedFolders []string) {
 
 	cfg.IgnoredFolders = sortedObservedFolderSlice(ignoredFolders)
 
+	cfg.Schedule = cfg.Schedule.validWindows(fmt.Sprintf("device %s (%s)", cfg.DeviceID.Short(), cfg.Name))
+	cfg.BandwidthProfiles = cfg.BandwidthProfiles.prepare(fmt.Sprintf("device %s (%s)", cfg.DeviceID.Short(), cfg.Name))
+
 	// A device cannot be simultaneously untrusted and an introducer, nor
 	// auto accept folders.
 	if cfg.Untrusted {
@@ -0,0 +1,138 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"slices"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// A DeviceGroupConfiguration is a named set of devices. Folders shared with
+// a group are shared with all its members, as they come and go.
+type DeviceGroupConfiguration struct {
+	ID      string              `json:"id" xml:"id,attr"`
+	Name    string              `json:"name" xml:"name,attr,omitempty"`
+	Devices []protocol.DeviceID `json:"devices" xml:"device"`
+}
+
+func (g DeviceGroupConfiguration) Copy() DeviceGroupConfiguration {
+	c := g
+	c.Devices = slices.Clone(g.Devices)
+	return c
+}
+
+// HasDevice returns whether the device is a member of the group.
+func (g DeviceGroupConfiguration) HasDevice(id protocol.DeviceID) bool {
+	return slices.Contains(g.Devices, id)
+}
+
+// prepareDeviceGroups removes groups with an empty or duplicate ID, and
+// members that are duplicates or not among the existing devices. It
+// returns the members of each group.
+func (cfg *Configuration) prepareDeviceGroups(existingDevices map[protocol.DeviceID]*DeviceConfiguration) map[string][]protocol.DeviceID {
+	groups := make(map[string][]protocol.DeviceID, len(cfg.DeviceGroups))
+	cfg.DeviceGroups = slices.DeleteFunc(cfg.DeviceGroups, func(g DeviceGroupConfiguration) bool {
+		if g.ID == "" {
+			l.Warnf("Removing device group with empty ID")
+			return true
+		}
+		if _, ok := groups[g.ID]; ok {
+			l.Warnf("Removing device group with duplicate ID %s", g.ID)
+			return true
+		}
+		groups[g.ID] = nil
+		return false
+	})
+	for i := range cfg.DeviceGroups {
+		g := &cfg.DeviceGroups[i]
+		seen := make(map[protocol.DeviceID]struct{}, len(g.Devices))
+		g.Devices = slices.DeleteFunc(g.Devices, func(id protocol.DeviceID) bool {
+			if _, ok := seen[id]; ok {
+				return true
+			}
+			seen[id] = struct{}{}
+			_, ok := existingDevices[id]
+			return !ok
+		})
+		groups[g.ID] = g.Devices
+	}
+	return groups
+}
+
+// expandDeviceGroups adds the members of the groups the folder is shared
+// with to its devices, marked with the group they came from. Devices that
+// were added for a group are removed when they are no longer a member, or
+// the folder is no longer shared with the group. Devices the folder is
+// shared with explicitly are left alone.
+func (f *FolderConfiguration) expandDeviceGroups(groups map[string][]protocol.DeviceID) {
+	f.DeviceGroups = slices.DeleteFunc(f.DeviceGroups, func(id string) bool {
+		if _, ok := groups[id]; !ok {
+			l.Warnf("Folder %s: ignoring unknown device group %s", f.Description(), id)
+			return true
+		}
+		return false
+	})
+
+	f.Devices = slices.DeleteFunc(f.Devices, func(dev FolderDeviceConfiguration) bool {
+		if dev.DeviceGroup == "" {
+			return false
+		}
+		return !slices.Contains(f.DeviceGroups, dev.DeviceGroup) || !slices.Contains(groups[dev.DeviceGroup], dev.DeviceID)
+	})
+
+	for _, id := range f.DeviceGroups {
+		for _, member := range groups[id] {
+			if _, ok := f.Device(member); !ok {
+				f.Devices = append(f.Devices, FolderDeviceConfiguration{
+					DeviceID:    member,
+					DeviceGroup: id,
+				})
+			}
+		}
+	}
+}
+
+func (cfg *Configuration) DeviceGroup(id string) (DeviceGroupConfiguration, int, bool) {
+	for i, g := range cfg.DeviceGroups {
+		if g.ID == id {
+			return g, i, true
+		}
+	}
+	return DeviceGroupConfiguration{}, 0, false
+}
+
+func (cfg *Configuration) SetDeviceGroup(group DeviceGroupConfiguration) {
+	cfg.SetDeviceGroups([]DeviceGroupConfiguration{group})
+}
+
+func (cfg *Configuration) SetDeviceGroups(groups []DeviceGroupConfiguration) {
+	inds := make(map[string]int, len(cfg.DeviceGroups))
+	for i, g := range cfg.DeviceGroups {
+		inds[g.ID] = i
+	}
+	filtered := groups[:0]
+	for _, g := range groups {
+		if i, ok := inds[g.ID]; ok {
+			cfg.DeviceGroups[i] = g
+		} else {
+			filtered = append(filtered, g)
+		}
+	}
+	cfg.DeviceGroups = append(cfg.DeviceGroups, filtered...)
+}
+
+// RemoveDeviceGroup removes the group, and thereby shares of folders with
+// its members that exist only due to the group.
+func (cfg *Configuration) RemoveDeviceGroup(id string) bool {
+	_, i, ok := cfg.DeviceGroup(id)
+	if !ok {
+		return false
+	}
+	cfg.DeviceGroups = slices.Delete(cfg.DeviceGroups, i, i+1)
+	return true
+}
@@ -0,0 +1,86 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestDeviceGroupExpansion(t *testing.T) {
+	cfg := New(device1)
+	cfg.SetDevices([]DeviceConfiguration{{DeviceID: device2}, {DeviceID: device3}, {DeviceID: device4}})
+	cfg.DeviceGroups = []DeviceGroupConfiguration{
+		{ID: "office", Devices: []protocol.DeviceID{device2, device3, device2, protocol.LocalDeviceID}},
+		{ID: "office"},
+		{ID: ""},
+	}
+	cfg.Folders = []FolderConfiguration{{
+		ID:           "docs",
+		Path:         "docs",
+		DeviceGroups: []string{"office", "unknown"},
+		Devices:      []FolderDeviceConfiguration{{DeviceID: device3, EncryptionPassword: "secret"}},
+	}}
+	if err := cfg.prepare(device1); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cfg.DeviceGroups) != 1 {
+		t.Fatal("expected the duplicate and empty groups to be removed, got", cfg.DeviceGroups)
+	}
+	if exp := []protocol.DeviceID{device2, device3}; !slices.Equal(cfg.DeviceGroups[0].Devices, exp) {
+		t.Errorf("expected members %v, got %v", exp, cfg.DeviceGroups[0].Devices)
+	}
+
+	folder := cfg.Folders[0]
+	if !slices.Equal(folder.DeviceGroups, []string{"office"}) {
+		t.Error("expected the unknown group to be removed, got", folder.DeviceGroups)
+	}
+	checkFolderDevices(t, folder, map[protocol.DeviceID]string{device1: "", device2: "office", device3: ""})
+	if dev, _ := folder.Device(device3); dev.EncryptionPassword != "secret" {
+		t.Error("explicitly shared device should be left alone")
+	}
+
+	// Adding a device to the group shares the folder with it.
+	_, i, _ := cfg.DeviceGroup("office")
+	cfg.DeviceGroups[i].Devices = append(cfg.DeviceGroups[i].Devices, device4)
+	if err := cfg.prepare(device1); err != nil {
+		t.Fatal(err)
+	}
+	checkFolderDevices(t, cfg.Folders[0], map[protocol.DeviceID]string{device1: "", device2: "office", device3: "", device4: "office"})
+
+	// Removing a device from the group unshares the folder.
+	cfg.DeviceGroups[i].Devices = []protocol.DeviceID{device3, device4}
+	if err := cfg.prepare(device1); err != nil {
+		t.Fatal(err)
+	}
+	checkFolderDevices(t, cfg.Folders[0], map[protocol.DeviceID]string{device1: "", device3: "", device4: "office"})
+
+	// As does removing the group.
+	cfg.RemoveDeviceGroup("office")
+	if err := cfg.prepare(device1); err != nil {
+		t.Fatal(err)
+	}
+	checkFolderDevices(t, cfg.Folders[0], map[protocol.DeviceID]string{device1: "", device3: ""})
+	if len(cfg.Folders[0].DeviceGroups) != 0 {
+		t.Error("expected the removed group to be unshared, got", cfg.Folders[0].DeviceGroups)
+	}
+}
+
+func checkFolderDevices(t *testing.T, folder FolderConfiguration, exp map[protocol.DeviceID]string) {
+	t.Helper()
+	if len(folder.Devices) != len(exp) {
+		t.Errorf("expected %d devices, got %v", len(exp), folder.Devices)
+	}
+	for _, dev := range folder.Devices {
+		if group, ok := exp[dev.DeviceID]; !ok || group != dev.DeviceGroup {
+			t.Errorf("unexpected device %v (group %q)", dev.DeviceID, dev.DeviceGroup)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// A Change is a difference between two configurations. The path is in
+// terms of the JSON representation, with list elements identified by their
+// ID where they have one, e.g. "folders[default].rescanIntervalS". From is
+// nil for added values and To is nil for removed ones.
+type Change struct {
+	Path string `json:"path"`
+	From any    `json:"from"`
+	To   any    `json:"to"`
+}
+
+func (c Change) String() string {
+	switch {
+	case c.From == nil:
+		return fmt.Sprintf("%s: added %s", c.Path, diffValueString(c.To))
+	case c.To == nil:
+		return fmt.Sprintf("%s: removed", c.Path)
+	default:
+		return fmt.Sprintf("%s: %s -> %s", c.Path, diffValueString(c.From), diffValueString(c.To))
+	}
+}
+
+func diffValueString(v any) string {
+	var buf strings.Builder
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// The values of these keys are replaced in changes, to avoid spreading
+// secrets to logs and the like.
+var diffRedactedKeys = []string{"password", "apiKey", "encryptionPassword", "secret", "clientSecret", "key"}
+
+const diffRedacted = "<redacted>"
+
+// The keys identifying elements of lists of objects, in order of
+// preference.
+var diffIDKeys = []string{"id", "deviceID"}
+
+// Diff returns the changes from one configuration to another, ordered by
+// path.
+func Diff(from, to Configuration) []Change {
+	var changes []Change
+	diffValues("", toDiffValue(from), toDiffValue(to), false, &changes)
+	return changes
+}
+
+func toDiffValue(cfg Configuration) any {
+	bs, err := json.Marshal(cfg)
+	if err != nil {
+		panic("bug: marshalling config: " + err.Error())
+	}
+	var v any
+	if err := json.Unmarshal(bs, &v); err != nil {
+		panic("bug: unmarshalling config: " + err.Error())
+	}
+	return v
+}
+
+func diffValues(path string, from, to any, redact bool, changes *[]Change) {
+	switch from := from.(type) {
+	case map[string]any:
+		if to, ok := to.(map[string]any); ok {
+			diffMaps(path, from, to, changes)
+			return
+		}
+	case []any:
+		if to, ok := to.([]any); ok {
+			if key, ok := diffIDKey(from, to); ok {
+				diffLists(path, key, from, to, changes)
+				return
+			}
+		}
+	}
+	if reflect.DeepEqual(from, to) || (isEmptyDiffValue(from) && isEmptyDiffValue(to)) {
+		return
+	}
+	if redact {
+		if from != nil && from != "" {
+			from = diffRedacted
+		}
+		if to != nil && to != "" {
+			to = diffRedacted
+		}
+	} else {
+		from, to = redactSecrets(from), redactSecrets(to)
+	}
+	*changes = append(*changes, Change{Path: path, From: from, To: to})
+}
+
+func diffMaps(path string, from, to map[string]any, changes *[]Change) {
+	keys := make([]string, 0, len(from)+len(to))
+	for k := range from {
+		keys = append(keys, k)
+	}
+	for k := range to {
+		if _, ok := from[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		sub := k
+		if path != "" {
+			sub = path + "." + k
+		}
+		diffValues(sub, from[k], to[k], slices.Contains(diffRedactedKeys, k), changes)
+	}
+}
+
+func diffLists(path, key string, from, to []any, changes *[]Change) {
+	fromByID := make(map[string]any, len(from))
+	for _, v := range from {
+		fromByID[v.(map[string]any)[key].(string)] = v
+	}
+	toByID := make(map[string]any, len(to))
+	ids := make([]string, 0, len(from)+len(to))
+	for _, v := range to {
+		id := v.(map[string]any)[key].(string)
+		toByID[id] = v
+		ids = append(ids, id)
+	}
+	for id := range fromByID {
+		if _, ok := toByID[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		diffValues(fmt.Sprintf("%s[%s]", path, id), fromByID[id], toByID[id], false, changes)
+	}
+}
+
+// isEmptyDiffValue returns whether the value is null or an empty list or
+// object, which are all the same to us.
+func isEmptyDiffValue(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case map[string]any:
+		return len(v) == 0
+	case []any:
+		return len(v) == 0
+	default:
+		return false
+	}
+}
+
+// redactSecrets returns a copy of the value with any secrets in it
+// replaced.
+func redactSecrets(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		res := make(map[string]any, len(v))
+		for k, sub := range v {
+			if slices.Contains(diffRedactedKeys, k) && sub != "" {
+				res[k] = diffRedacted
+			} else {
+				res[k] = redactSecrets(sub)
+			}
+		}
+		return res
+	case []any:
+		res := make([]any, len(v))
+		for i, sub := range v {
+			res[i] = redactSecrets(sub)
+		}
+		return res
+	default:
+		return v
+	}
+}
+
+// diffIDKey returns the key that uniquely identifies the elements of both
+// lists, if there is one.
+func diffIDKey(lists ...[]any) (string, bool) {
+	for _, key := range diffIDKeys {
+		if diffListHasIDKey(key, lists...) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+func diffListHasIDKey(key string, lists ...[]any) bool {
+	nonEmpty := false
+	for _, list := range lists {
+		seen := make(map[string]struct{}, len(list))
+		for _, v := range list {
+			m, ok := v.(map[string]any)
+			if !ok {
+				return false
+			}
+			id, ok := m[key].(string)
+			if !ok || strings.ContainsAny(id, "[]") {
+				return false
+			}
+			if _, ok := seen[id]; ok {
+				return false
+			}
+			seen[id] = struct{}{}
+			nonEmpty = true
+		}
+	}
+	return nonEmpty
+}
@@ -13,6 +13,8 @@ type FilesystemType string
 const (
 	FilesystemTypeBasic FilesystemType = "basic"
 	FilesystemTypeFake  FilesystemType = "fake"
+	FilesystemTypeS3    FilesystemType = "s3"
+	FilesystemTypeSFTP  FilesystemType = "sftp"
 )
 
 func (t FilesystemType) ToFS() fs.FilesystemType {
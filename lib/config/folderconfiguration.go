@@ -15,6 +15,7 @@ import (
 	"fmt"
 	"path"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -45,6 +46,7 @@ type FolderDeviceConfiguration struct {
 	DeviceID           protocol.DeviceID `json:"deviceID" xml:"id,attr"`
 	IntroducedBy       protocol.DeviceID `json:"introducedBy" xml:"introducedBy,attr"`
 	EncryptionPassword string            `json:"encryptionPassword" xml:"encryptionPassword"`
+	DeviceGroup        string            `json:"deviceGroup" xml:"deviceGroup,attr,omitempty"` // set when shared due to the group
 }
 
 type FolderConfiguration struct {
@@ -88,6 +90,16 @@ type FolderConfiguration struct {
 	SyncXattrs              bool                        `json:"syncXattrs" xml:"syncXattrs"`
 	SendXattrs              bool                        `json:"sendXattrs" xml:"sendXattrs"`
 	XattrFilter             XattrFilter                 `json:"xattrFilter" xml:"xattrFilter"`
+	Schedule                Schedule                    `json:"schedule" xml:"schedule>window" restart:"false"`
+	ConflictRules           []ConflictRule              `json:"conflictRules" xml:"conflictRule"`
+	PullPriorities          []PullPriority              `json:"pullPriorities" xml:"pullPriority"`
+	SelectedPaths           []string                    `json:"selectedPaths" xml:"selectedPath"`
+	ContentDefinedChunking  bool                        `json:"contentDefinedChunking" xml:"contentDefinedChunking"`
+	HashAlgorithm           HashAlgorithm               `json:"hashAlgorithm" xml:"hashAlgorithm"`
+	ScrubIntervalS          int                         `json:"scrubIntervalS" xml:"scrubIntervalS"`
+	ScrubMaxKiBps           int                         `json:"scrubMaxKiBps" xml:"scrubMaxKiBps"`
+	ScrubRepair             bool                        `json:"scrubRepair" xml:"scrubRepair"`
+	DeviceGroups            []string                    `json:"deviceGroups" xml:"deviceGroup"`
 	// Legacy deprecated
 	DeprecatedReadOnly       bool    `json:"-" xml:"ro,attr,omitempty"`        // Deprecated: Do not use.
 	DeprecatedMinDiskFreePct float64 `json:"-" xml:"minDiskFreePct,omitempty"` // Deprecated: Do not use.
@@ -117,6 +129,11 @@ func (f FolderConfiguration) Copy() FolderConfiguration {
 	c.Devices = make([]FolderDeviceConfiguration, len(f.Devices))
 	copy(c.Devices, f.Devices)
 	c.Versioning = f.Versioning.Copy()
+	c.Schedule = slices.Clone(f.Schedule)
+	c.ConflictRules = slices.Clone(f.ConflictRules)
+	c.PullPriorities = slices.Clone(f.PullPriorities)
+	c.SelectedPaths = slices.Clone(f.SelectedPaths)
+	c.DeviceGroups = slices.Clone(f.DeviceGroups)
 	return c
 }
 
@@ -314,6 +331,15 @@ func (f *FolderConfiguration) prepare(myID protocol.DeviceID, existingDevices ma
 		f.Versioning.CleanupIntervalS = 0
 	}
 
+	if f.ScrubIntervalS > MaxRescanIntervalS {
+		f.ScrubIntervalS = MaxRescanIntervalS
+	} else if f.ScrubIntervalS < 0 {
+		f.ScrubIntervalS = 0
+	}
+	if f.ScrubMaxKiBps < 0 {
+		f.ScrubMaxKiBps = 0
+	}
+
 	if f.WeakHashThresholdPct == 0 {
 		f.WeakHashThresholdPct = 25
 	}
@@ -332,6 +358,10 @@ func (f *FolderConfiguration) prepare(myID protocol.DeviceID, existingDevices ma
 		f.DisableTempIndexes = true
 		f.IgnorePerms = true
 	}
+
+	f.Schedule = f.Schedule.validWindows(fmt.Sprintf("folder %s", f.Description()))
+	f.prepareConflictRules()
+	f.prepareSelectedPaths()
 }
 
 // RequiresRestartOnly returns a copy with only the attributes that require
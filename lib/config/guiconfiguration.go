@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -19,26 +20,28 @@ import (
 )
 
 type GUIConfiguration struct {
-	Enabled                   bool     `json:"enabled" xml:"enabled,attr" default:"true"`
-	RawAddress                string   `json:"address" xml:"address" default:"127.0.0.1:8384"`
-	RawUnixSocketPermissions  string   `json:"unixSocketPermissions" xml:"unixSocketPermissions,omitempty"`
-	User                      string   `json:"user" xml:"user,omitempty"`
-	Password                  string   `json:"password" xml:"password,omitempty"`
-	AuthMode                  AuthMode `json:"authMode" xml:"authMode,omitempty"`
-	MetricsWithoutAuth        bool     `json:"metricsWithoutAuth" xml:"metricsWithoutAuth" default:"false"`
-	RawUseTLS                 bool     `json:"useTLS" xml:"tls,attr"`
-	APIKey                    string   `json:"apiKey" xml:"apikey,omitempty"`
-	InsecureAdminAccess       bool     `json:"insecureAdminAccess" xml:"insecureAdminAccess,omitempty"`
-	Theme                     string   `json:"theme" xml:"theme" default:"default"`
-	Debugging                 bool     `json:"debugging" xml:"debugging,attr"`
-	InsecureSkipHostCheck     bool     `json:"insecureSkipHostcheck" xml:"insecureSkipHostcheck,omitempty"`
-	InsecureAllowFrameLoading bool     `json:"insecureAllowFrameLoading" xml:"insecureAllowFrameLoading,omitempty"`
-	SendBasicAuthPrompt       bool     `json:"sendBasicAuthPrompt" xml:"sendBasicAuthPrompt,attr"`
+	Enabled                   bool                     `json:"enabled" xml:"enabled,attr" default:"true"`
+	RawAddress                string                   `json:"address" xml:"address" default:"127.0.0.1:8384"`
+	RawUnixSocketPermissions  string                   `json:"unixSocketPermissions" xml:"unixSocketPermissions,omitempty"`
+	User                      string                   `json:"user" xml:"user,omitempty"`
+	Password                  string                   `json:"password" xml:"password,omitempty"`
+	AuthMode                  AuthMode                 `json:"authMode" xml:"authMode,omitempty"`
+	MetricsWithoutAuth        bool                     `json:"metricsWithoutAuth" xml:"metricsWithoutAuth" default:"false"`
+	RawUseTLS                 bool                     `json:"useTLS" xml:"tls,attr"`
+	APIKey                    string                   `json:"apiKey" xml:"apikey,omitempty"`
+	InsecureAdminAccess       bool                     `json:"insecureAdminAccess" xml:"insecureAdminAccess,omitempty"`
+	Theme                     string                   `json:"theme" xml:"theme" default:"default"`
+	Debugging                 bool                     `json:"debugging" xml:"debugging,attr"`
+	InsecureSkipHostCheck     bool                     `json:"insecureSkipHostcheck" xml:"insecureSkipHostcheck,omitempty"`
+	InsecureAllowFrameLoading bool                     `json:"insecureAllowFrameLoading" xml:"insecureAllowFrameLoading,omitempty"`
+	SendBasicAuthPrompt       bool                     `json:"sendBasicAuthPrompt" xml:"sendBasicAuthPrompt,attr"`
+	Users                     []GUIUserConfiguration   `json:"users" xml:"users>user"`
+	ScopedAPIKeys             []GUIAPIKeyConfiguration `json:"scopedApiKeys" xml:"scopedApiKeys>apiKey"`
 }
 
 func (c GUIConfiguration) IsAuthEnabled() bool {
 	// This function should match isAuthEnabled() in syncthingController.js
-	return c.AuthMode == AuthModeLDAP || (len(c.User) > 0 && len(c.Password) > 0)
+	return c.AuthMode == AuthModeLDAP || c.AuthMode == AuthModeOIDC || (len(c.User) > 0 && len(c.Password) > 0) || len(c.Users) > 0
 }
 
 func (GUIConfiguration) IsOverridden() bool {
@@ -138,17 +141,24 @@ var bcryptExpr = regexp.MustCompile(`^\$2[aby]\$\d+\$.{50,}`)
 // Plaintext passwords are hashed. Returns an error if the password is not
 // valid.
 func (c *GUIConfiguration) SetPassword(password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	c.Password = hash
+	return nil
+}
+
+func hashPassword(password string) (string, error) {
 	if bcryptExpr.MatchString(password) {
 		// Already hashed
-		c.Password = password
-		return nil
+		return password, nil
 	}
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		return err
+		return "", err
 	}
-	c.Password = string(hash)
-	return nil
+	return string(hash), nil
 }
 
 // CompareHashedPassword returns nil when the given plaintext password matches the stored hash.
@@ -169,16 +179,71 @@ func (c GUIConfiguration) IsValidAPIKey(apiKey string) bool {
 		return true
 
 	default:
-		return false
+		_, ok := c.ScopedAPIKey(apiKey)
+		return ok
+	}
+}
+
+// ScopedAPIKey returns the scoped API key configuration for the given key,
+// if there is one.
+func (c GUIConfiguration) ScopedAPIKey(apiKey string) (GUIAPIKeyConfiguration, bool) {
+	if apiKey == "" {
+		return GUIAPIKeyConfiguration{}, false
+	}
+	for _, key := range c.ScopedAPIKeys {
+		if key.Key == apiKey {
+			return key, true
+		}
 	}
+	return GUIAPIKeyConfiguration{}, false
+}
+
+// GUIUser returns the additional user with the given name, if there is one.
+func (c GUIConfiguration) GUIUser(name string) (GUIUserConfiguration, bool) {
+	for _, user := range c.Users {
+		if user.Name == name {
+			return user, true
+		}
+	}
+	return GUIUserConfiguration{}, false
 }
 
 func (c *GUIConfiguration) prepare() {
 	if c.APIKey == "" {
 		c.APIKey = rand.String(32)
 	}
+
+	seenUsers := make(map[string]struct{}, len(c.Users))
+	c.Users = slices.DeleteFunc(c.Users, func(user GUIUserConfiguration) bool {
+		if _, ok := seenUsers[user.Name]; ok || user.Name == "" || user.Name == c.User {
+			l.Warnf("Dropping GUI user %q: name is empty, duplicate or the same as the main user", user.Name)
+			return true
+		}
+		seenUsers[user.Name] = struct{}{}
+		return false
+	})
+
+	seenKeys := make(map[string]struct{}, len(c.ScopedAPIKeys))
+	c.ScopedAPIKeys = slices.DeleteFunc(c.ScopedAPIKeys, func(key GUIAPIKeyConfiguration) bool {
+		if _, ok := seenKeys[key.Key]; ok || key.Key == "" || key.Key == c.APIKey {
+			l.Warnf("Dropping scoped API key %q: key is empty, duplicate or the same as the main API key", key.Name)
+			return true
+		}
+		seenKeys[key.Key] = struct{}{}
+		return false
+	})
 }
 
 func (c GUIConfiguration) Copy() GUIConfiguration {
+	users := c.Users
+	c.Users = make([]GUIUserConfiguration, len(users))
+	for i, user := range users {
+		c.Users[i] = user.Copy()
+	}
+	keys := c.ScopedAPIKeys
+	c.ScopedAPIKeys = make([]GUIAPIKeyConfiguration, len(keys))
+	for i, key := range keys {
+		c.ScopedAPIKeys[i] = key.Copy()
+	}
 	return c
 }
@@ -0,0 +1,52 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+// A GUIRole determines what a GUI user or API key is allowed to do. Each
+// role includes the permissions of the ones before it.
+type GUIRole int32
+
+const (
+	// Read only access to status, statistics and the configuration.
+	GUIRoleMonitor GUIRole = 0
+	// Additionally rescanning, overriding and reverting folders, and
+	// restoring versions.
+	GUIRoleOperator GUIRole = 1
+	// Full access.
+	GUIRoleAdmin GUIRole = 2
+)
+
+func (t GUIRole) String() string {
+	switch t {
+	case GUIRoleMonitor:
+		return "monitor"
+	case GUIRoleOperator:
+		return "operator"
+	case GUIRoleAdmin:
+		return "admin"
+	default:
+		return "unknown"
+	}
+}
+
+func (t GUIRole) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+func (t *GUIRole) UnmarshalText(bs []byte) error {
+	switch string(bs) {
+	case "admin":
+		*t = GUIRoleAdmin
+	case "operator":
+		*t = GUIRoleOperator
+	case "monitor":
+		*t = GUIRoleMonitor
+	default:
+		*t = GUIRoleMonitor
+	}
+	return nil
+}
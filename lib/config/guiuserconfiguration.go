@@ -0,0 +1,77 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"slices"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// A GUIUserConfiguration is an additional GUI user, with a role limiting
+// what they can do. The user configured in the GUIConfiguration itself is
+// always an admin.
+type GUIUserConfiguration struct {
+	Name     string  `json:"name" xml:"name,attr"`
+	Password string  `json:"password" xml:"password,omitempty"`
+	Role     GUIRole `json:"role" xml:"role,attr"`
+	// The folders the user has access to; all of them if empty.
+	Folders []string `json:"folders" xml:"folder"`
+}
+
+// SetPassword takes a bcrypt hash or a plaintext password and stores it.
+// Plaintext passwords are hashed.
+func (c *GUIUserConfiguration) SetPassword(password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	c.Password = hash
+	return nil
+}
+
+// CompareHashedPassword returns nil when the given plaintext password matches the stored hash.
+func (c GUIUserConfiguration) CompareHashedPassword(password string) error {
+	return bcrypt.CompareHashAndPassword([]byte(c.Password), []byte(password))
+}
+
+func (c GUIUserConfiguration) Copy() GUIUserConfiguration {
+	c.Folders = slices.Clone(c.Folders)
+	return c
+}
+
+// A GUIAPIKeyConfiguration is an additional API key, with a role limiting
+// what can be done with it. The API key configured in the GUIConfiguration
+// itself grants full access.
+type GUIAPIKeyConfiguration struct {
+	Name string  `json:"name" xml:"name,attr"`
+	Key  string  `json:"key" xml:"key,attr"`
+	Role GUIRole `json:"role" xml:"role,attr"`
+	// The folders the key grants access to; all of them if empty.
+	Folders []string `json:"folders" xml:"folder"`
+}
+
+func (c GUIAPIKeyConfiguration) Copy() GUIAPIKeyConfiguration {
+	c.Folders = slices.Clone(c.Folders)
+	return c
+}
+
+// A GroupRoleConfiguration grants a role to the LDAP or OpenID Connect
+// users that are members of the given group.
+type GroupRoleConfiguration struct {
+	// The group as listed in the group attribute or claim of the user,
+	// e.g. the distinguished name of an LDAP group.
+	Group string  `json:"group" xml:"group,attr"`
+	Role  GUIRole `json:"role" xml:"role,attr"`
+	// The folders the group has access to; all of them if empty.
+	Folders []string `json:"folders" xml:"folder"`
+}
+
+func (c GroupRoleConfiguration) Copy() GroupRoleConfiguration {
+	c.Folders = slices.Clone(c.Folders)
+	return c
+}
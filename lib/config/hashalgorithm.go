@@ -0,0 +1,52 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import "github.com/syncthing/syncthing/lib/protocol"
+
+type HashAlgorithm int32
+
+const (
+	HashAlgorithmSHA256  HashAlgorithm = 0
+	HashAlgorithmBLAKE2b HashAlgorithm = 1
+)
+
+func (h HashAlgorithm) String() string {
+	switch h {
+	case HashAlgorithmSHA256:
+		return "sha256"
+	case HashAlgorithmBLAKE2b:
+		return "blake2b"
+	default:
+		return "unknown"
+	}
+}
+
+func (h HashAlgorithm) MarshalText() ([]byte, error) {
+	return []byte(h.String()), nil
+}
+
+func (h *HashAlgorithm) UnmarshalText(bs []byte) error {
+	switch string(bs) {
+	case "sha256":
+		*h = HashAlgorithmSHA256
+	case "blake2b":
+		*h = HashAlgorithmBLAKE2b
+	default:
+		*h = HashAlgorithmSHA256
+	}
+	return nil
+}
+
+func (h HashAlgorithm) ToProtocol() protocol.HashAlgorithm {
+	switch h {
+	case HashAlgorithmBLAKE2b:
+		return protocol.HashAlgorithmBLAKE2b
+	default:
+		return protocol.HashAlgorithmSHA256
+	}
+}
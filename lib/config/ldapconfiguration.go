@@ -13,8 +13,18 @@ type LDAPConfiguration struct {
 	InsecureSkipVerify bool          `json:"insecureSkipVerify" xml:"insecureSkipVerify,omitempty" default:"false"`
 	SearchBaseDN       string        `json:"searchBaseDN" xml:"searchBaseDN,omitempty"`
 	SearchFilter       string        `json:"searchFilter" xml:"searchFilter,omitempty"`
+	// The attribute of the user entry listing the groups it's a member of.
+	GroupAttribute string `json:"groupAttribute" xml:"groupAttribute,omitempty" default:"memberOf"`
+	// If set, only members of these groups may log in, with the role of
+	// the group. Otherwise all LDAP users are admins.
+	GroupRoles []GroupRoleConfiguration `json:"groupRoles" xml:"groupRole"`
 }
 
 func (c LDAPConfiguration) Copy() LDAPConfiguration {
+	roles := c.GroupRoles
+	c.GroupRoles = make([]GroupRoleConfiguration, len(roles))
+	for i, role := range roles {
+		c.GroupRoles[i] = role.Copy()
+	}
 	return c
 }
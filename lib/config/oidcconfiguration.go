@@ -0,0 +1,48 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import "slices"
+
+// The OIDCConfiguration is used to log in to the GUI through an OpenID
+// Connect provider, when the GUI authentication mode is "oidc".
+type OIDCConfiguration struct {
+	// The issuer URL, where the provider's discovery document is found
+	// under /.well-known/openid-configuration.
+	Issuer       string `json:"issuer" xml:"issuer,omitempty"`
+	ClientID     string `json:"clientID" xml:"clientID,omitempty"`
+	ClientSecret string `json:"clientSecret" xml:"clientSecret,omitempty"`
+	// The URL the provider redirects back to after logging in, which must
+	// end in /rest/noauth/auth/oidc/callback. Derived from the address the
+	// GUI is accessed through if empty.
+	RedirectURL string `json:"redirectURL" xml:"redirectURL,omitempty"`
+	// The scopes to request in addition to "openid".
+	Scopes []string `json:"scopes" xml:"scope"`
+	// The claim identifying users. It must be unique and stable, as users
+	// are given access by it; claims like preferred_username can often be
+	// changed by the users themselves. An "email" claim is only accepted
+	// if the provider says the address is verified.
+	UsernameClaim string `json:"usernameClaim" xml:"usernameClaim,omitempty" default:"sub"`
+	GroupsClaim   string `json:"groupsClaim" xml:"groupsClaim,omitempty" default:"groups"`
+	// The users that are admins, by the value of their username claim.
+	AllowedUsers []string `json:"allowedUsers" xml:"allowedUser"`
+	// The roles of the members of groups, by the values of their groups
+	// claim. Users that are neither allowed nor members of any of these
+	// groups can't log in.
+	GroupRoles []GroupRoleConfiguration `json:"groupRoles" xml:"groupRole"`
+}
+
+func (c OIDCConfiguration) Copy() OIDCConfiguration {
+	c.Scopes = slices.Clone(c.Scopes)
+	c.AllowedUsers = slices.Clone(c.AllowedUsers)
+	roles := c.GroupRoles
+	c.GroupRoles = make([]GroupRoleConfiguration, len(roles))
+	for i, role := range roles {
+		c.GroupRoles[i] = role.Copy()
+	}
+	return c
+}
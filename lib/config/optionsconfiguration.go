@@ -20,56 +20,64 @@ import (
 )
 
 type OptionsConfiguration struct {
-	RawListenAddresses          []string `json:"listenAddresses" xml:"listenAddress" default:"default"`
-	RawGlobalAnnServers         []string `json:"globalAnnounceServers" xml:"globalAnnounceServer" default:"default"`
-	GlobalAnnEnabled            bool     `json:"globalAnnounceEnabled" xml:"globalAnnounceEnabled" default:"true"`
-	LocalAnnEnabled             bool     `json:"localAnnounceEnabled" xml:"localAnnounceEnabled" default:"true"`
-	LocalAnnPort                int      `json:"localAnnouncePort" xml:"localAnnouncePort" default:"21027"`
-	LocalAnnMCAddr              string   `json:"localAnnounceMCAddr" xml:"localAnnounceMCAddr" default:"[ff12::8384]:21027"`
-	MaxSendKbps                 int      `json:"maxSendKbps" xml:"maxSendKbps"`
-	MaxRecvKbps                 int      `json:"maxRecvKbps" xml:"maxRecvKbps"`
-	ReconnectIntervalS          int      `json:"reconnectionIntervalS" xml:"reconnectionIntervalS" default:"60"`
-	RelaysEnabled               bool     `json:"relaysEnabled" xml:"relaysEnabled" default:"true"`
-	RelayReconnectIntervalM     int      `json:"relayReconnectIntervalM" xml:"relayReconnectIntervalM" default:"10"`
-	StartBrowser                bool     `json:"startBrowser" xml:"startBrowser" default:"true"`
-	NATEnabled                  bool     `json:"natEnabled" xml:"natEnabled" default:"true"`
-	NATLeaseM                   int      `json:"natLeaseMinutes" xml:"natLeaseMinutes" default:"60"`
-	NATRenewalM                 int      `json:"natRenewalMinutes" xml:"natRenewalMinutes" default:"30"`
-	NATTimeoutS                 int      `json:"natTimeoutSeconds" xml:"natTimeoutSeconds" default:"10"`
-	URAccepted                  int      `json:"urAccepted" xml:"urAccepted"`
-	URSeen                      int      `json:"urSeen" xml:"urSeen"`
-	URUniqueID                  string   `json:"urUniqueId" xml:"urUniqueID"`
-	URURL                       string   `json:"urURL" xml:"urURL" default:"https://data.syncthing.net/newdata"`
-	URPostInsecurely            bool     `json:"urPostInsecurely" xml:"urPostInsecurely" default:"false"`
-	URInitialDelayS             int      `json:"urInitialDelayS" xml:"urInitialDelayS" default:"1800"`
-	AutoUpgradeIntervalH        int      `json:"autoUpgradeIntervalH" xml:"autoUpgradeIntervalH" default:"12"`
-	UpgradeToPreReleases        bool     `json:"upgradeToPreReleases" xml:"upgradeToPreReleases"`
-	KeepTemporariesH            int      `json:"keepTemporariesH" xml:"keepTemporariesH" default:"24"`
-	CacheIgnoredFiles           bool     `json:"cacheIgnoredFiles" xml:"cacheIgnoredFiles" default:"false"`
-	ProgressUpdateIntervalS     int      `json:"progressUpdateIntervalS" xml:"progressUpdateIntervalS" default:"5"`
-	LimitBandwidthInLan         bool     `json:"limitBandwidthInLan" xml:"limitBandwidthInLan" default:"false"`
-	MinHomeDiskFree             Size     `json:"minHomeDiskFree" xml:"minHomeDiskFree" default:"1 %"`
-	ReleasesURL                 string   `json:"releasesURL" xml:"releasesURL" default:"https://upgrades.syncthing.net/meta.json"`
-	AlwaysLocalNets             []string `json:"alwaysLocalNets" xml:"alwaysLocalNet"`
-	OverwriteRemoteDevNames     bool     `json:"overwriteRemoteDeviceNamesOnConnect" xml:"overwriteRemoteDeviceNamesOnConnect" default:"false"`
-	TempIndexMinBlocks          int      `json:"tempIndexMinBlocks" xml:"tempIndexMinBlocks" default:"10"`
-	UnackedNotificationIDs      []string `json:"unackedNotificationIDs" xml:"unackedNotificationID"`
-	TrafficClass                int      `json:"trafficClass" xml:"trafficClass"`
-	DeprecatedDefaultFolderPath string   `json:"-" xml:"defaultFolderPath,omitempty"` // Deprecated: Do not use.
-	SetLowPriority              bool     `json:"setLowPriority" xml:"setLowPriority" default:"true"`
-	RawMaxFolderConcurrency     int      `json:"maxFolderConcurrency" xml:"maxFolderConcurrency"`
-	CRURL                       string   `json:"crURL" xml:"crashReportingURL" default:"https://crash.syncthing.net/newcrash"`
-	CREnabled                   bool     `json:"crashReportingEnabled" xml:"crashReportingEnabled" default:"true"`
-	StunKeepaliveStartS         int      `json:"stunKeepaliveStartS" xml:"stunKeepaliveStartS" default:"180"`
-	StunKeepaliveMinS           int      `json:"stunKeepaliveMinS" xml:"stunKeepaliveMinS" default:"20"`
-	RawStunServers              []string `json:"stunServers" xml:"stunServer" default:"default"`
-	DatabaseTuning              Tuning   `json:"databaseTuning" xml:"databaseTuning" restart:"true"`
-	RawMaxCIRequestKiB          int      `json:"maxConcurrentIncomingRequestKiB" xml:"maxConcurrentIncomingRequestKiB"`
-	AnnounceLANAddresses        bool     `json:"announceLANAddresses" xml:"announceLANAddresses" default:"true"`
-	SendFullIndexOnUpgrade      bool     `json:"sendFullIndexOnUpgrade" xml:"sendFullIndexOnUpgrade"`
-	FeatureFlags                []string `json:"featureFlags" xml:"featureFlag"`
-	AuditEnabled                bool     `json:"auditEnabled" xml:"auditEnabled" default:"false"`
-	AuditFile                   string   `json:"auditFile" xml:"auditFile"`
+	RawListenAddresses          []string          `json:"listenAddresses" xml:"listenAddress" default:"default"`
+	RawGlobalAnnServers         []string          `json:"globalAnnounceServers" xml:"globalAnnounceServer" default:"default"`
+	GlobalAnnEnabled            bool              `json:"globalAnnounceEnabled" xml:"globalAnnounceEnabled" default:"true"`
+	LocalAnnEnabled             bool              `json:"localAnnounceEnabled" xml:"localAnnounceEnabled" default:"true"`
+	LocalAnnPort                int               `json:"localAnnouncePort" xml:"localAnnouncePort" default:"21027"`
+	LocalAnnMCAddr              string            `json:"localAnnounceMCAddr" xml:"localAnnounceMCAddr" default:"[ff12::8384]:21027"`
+	MaxSendKbps                 int               `json:"maxSendKbps" xml:"maxSendKbps"`
+	MaxRecvKbps                 int               `json:"maxRecvKbps" xml:"maxRecvKbps"`
+	BandwidthProfiles           BandwidthProfiles `json:"bandwidthProfiles" xml:"bandwidthProfile"`
+	ReconnectIntervalS          int               `json:"reconnectionIntervalS" xml:"reconnectionIntervalS" default:"60"`
+	RelaysEnabled               bool              `json:"relaysEnabled" xml:"relaysEnabled" default:"true"`
+	RelayReconnectIntervalM     int               `json:"relayReconnectIntervalM" xml:"relayReconnectIntervalM" default:"10"`
+	StartBrowser                bool              `json:"startBrowser" xml:"startBrowser" default:"true"`
+	NATEnabled                  bool              `json:"natEnabled" xml:"natEnabled" default:"true"`
+	NATLeaseM                   int               `json:"natLeaseMinutes" xml:"natLeaseMinutes" default:"60"`
+	NATRenewalM                 int               `json:"natRenewalMinutes" xml:"natRenewalMinutes" default:"30"`
+	NATTimeoutS                 int               `json:"natTimeoutSeconds" xml:"natTimeoutSeconds" default:"10"`
+	URAccepted                  int               `json:"urAccepted" xml:"urAccepted"`
+	URSeen                      int               `json:"urSeen" xml:"urSeen"`
+	URUniqueID                  string            `json:"urUniqueId" xml:"urUniqueID"`
+	URURL                       string            `json:"urURL" xml:"urURL" default:"https://data.syncthing.net/newdata"`
+	URPostInsecurely            bool              `json:"urPostInsecurely" xml:"urPostInsecurely" default:"false"`
+	URInitialDelayS             int               `json:"urInitialDelayS" xml:"urInitialDelayS" default:"1800"`
+	AutoUpgradeIntervalH        int               `json:"autoUpgradeIntervalH" xml:"autoUpgradeIntervalH" default:"12"`
+	UpgradeToPreReleases        bool              `json:"upgradeToPreReleases" xml:"upgradeToPreReleases"`
+	KeepTemporariesH            int               `json:"keepTemporariesH" xml:"keepTemporariesH" default:"24"`
+	CacheIgnoredFiles           bool              `json:"cacheIgnoredFiles" xml:"cacheIgnoredFiles" default:"false"`
+	ProgressUpdateIntervalS     int               `json:"progressUpdateIntervalS" xml:"progressUpdateIntervalS" default:"5"`
+	LimitBandwidthInLan         bool              `json:"limitBandwidthInLan" xml:"limitBandwidthInLan" default:"false"`
+	MinHomeDiskFree             Size              `json:"minHomeDiskFree" xml:"minHomeDiskFree" default:"1 %"`
+	ReleasesURL                 string            `json:"releasesURL" xml:"releasesURL" default:"https://upgrades.syncthing.net/meta.json"`
+	AlwaysLocalNets             []string          `json:"alwaysLocalNets" xml:"alwaysLocalNet"`
+	OverwriteRemoteDevNames     bool              `json:"overwriteRemoteDeviceNamesOnConnect" xml:"overwriteRemoteDeviceNamesOnConnect" default:"false"`
+	TempIndexMinBlocks          int               `json:"tempIndexMinBlocks" xml:"tempIndexMinBlocks" default:"10"`
+	UnackedNotificationIDs      []string          `json:"unackedNotificationIDs" xml:"unackedNotificationID"`
+	TrafficClass                int               `json:"trafficClass" xml:"trafficClass"`
+	DeprecatedDefaultFolderPath string            `json:"-" xml:"defaultFolderPath,omitempty"` // Deprecated: Do not use.
+	SetLowPriority              bool              `json:"setLowPriority" xml:"setLowPriority" default:"true"`
+	RawMaxFolderConcurrency     int               `json:"maxFolderConcurrency" xml:"maxFolderConcurrency"`
+	CRURL                       string            `json:"crURL" xml:"crashReportingURL" default:"https://crash.syncthing.net/newcrash"`
+	CREnabled                   bool              `json:"crashReportingEnabled" xml:"crashReportingEnabled" default:"true"`
+	StunKeepaliveStartS         int               `json:"stunKeepaliveStartS" xml:"stunKeepaliveStartS" default:"180"`
+	StunKeepaliveMinS           int               `json:"stunKeepaliveMinS" xml:"stunKeepaliveMinS" default:"20"`
+	RawStunServers              []string          `json:"stunServers" xml:"stunServer" default:"default"`
+	DatabaseTuning              Tuning            `json:"databaseTuning" xml:"databaseTuning" restart:"true"`
+	RawMaxCIRequestKiB          int               `json:"maxConcurrentIncomingRequestKiB" xml:"maxConcurrentIncomingRequestKiB"`
+	AnnounceLANAddresses        bool              `json:"announceLANAddresses" xml:"announceLANAddresses" default:"true"`
+	SendFullIndexOnUpgrade      bool              `json:"sendFullIndexOnUpgrade" xml:"sendFullIndexOnUpgrade"`
+	FeatureFlags                []string          `json:"featureFlags" xml:"featureFlag"`
+	AuditEnabled                bool              `json:"auditEnabled" xml:"auditEnabled" default:"false"`
+	AuditFile                   string            `json:"auditFile" xml:"auditFile"`
+	// The size at which the audit file is rotated, in bytes, zero meaning
+	// no rotation, and the number of rotated files to keep.
+	AuditMaxSize  int64 `json:"auditMaxSize" xml:"auditMaxSize"`
+	AuditMaxFiles int   `json:"auditMaxFiles" xml:"auditMaxFiles" default:"3"`
+	// The event types to write to the audit log, all of them if empty.
+	// Types prefixed with "-" are left out.
+	AuditEvents []string `json:"auditEvents" xml:"auditEvent"`
 	// The number of connections at which we stop trying to connect to more
 	// devices, zero meaning no limit. Does not affect incoming connections.
 	ConnectionLimitEnough int `json:"connectionLimitEnough" xml:"connectionLimitEnough"`
@@ -103,6 +111,9 @@ func (opts OptionsConfiguration) Copy() OptionsConfiguration {
 	copy(optsCopy.AlwaysLocalNets, opts.AlwaysLocalNets)
 	optsCopy.UnackedNotificationIDs = make([]string, len(opts.UnackedNotificationIDs))
 	copy(optsCopy.UnackedNotificationIDs, opts.UnackedNotificationIDs)
+	optsCopy.AuditEvents = make([]string, len(opts.AuditEvents))
+	copy(optsCopy.AuditEvents, opts.AuditEvents)
+	optsCopy.BandwidthProfiles = opts.BandwidthProfiles.Copy()
 	return optsCopy
 }
 
@@ -111,6 +122,7 @@ func (opts *OptionsConfiguration) prepare(guiPWIsSet bool) {
 
 	opts.RawListenAddresses = stringutil.UniqueTrimmedStrings(opts.RawListenAddresses)
 	opts.RawGlobalAnnServers = stringutil.UniqueTrimmedStrings(opts.RawGlobalAnnServers)
+	opts.BandwidthProfiles = opts.BandwidthProfiles.prepare("options")
 
 	// Very short reconnection intervals are annoying
 	if opts.ReconnectIntervalS < 5 {
@@ -59,3 +59,13 @@ func (o *PullOrder) UnmarshalText(bs []byte) error {
 	}
 	return nil
 }
+
+// A PullPriority puts files matching the pattern, which has the same syntax
+// as the lines of an ignore file, in a priority tier. Files in higher tiers
+// are pulled before files in lower tiers, with the pull order applying
+// within each tier. The first matching pattern applies; files matching none
+// are in tier zero, so negative priorities can be used to pull files last.
+type PullPriority struct {
+	Pattern  string `json:"pattern" xml:"pattern,attr"`
+	Priority int    `json:"priority" xml:"priority,attr"`
+}
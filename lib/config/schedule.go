@@ -0,0 +1,228 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A ScheduleWindow is a recurring window of time, such as 22:00 to 06:00 on
+// weekdays. Days is a comma separated list of days or ranges of days
+// ("mon-fri", "sat,sun"), where an empty value means every day. Start and
+// End are times of day in local time ("22:00"). An End before Start means
+// the window extends into the following day, and an End equal to Start
+// means the window spans a whole day. The window starts on the given days.
+type ScheduleWindow struct {
+	Days  string `json:"days" xml:"days,attr"`
+	Start string `json:"start" xml:"start,attr"`
+	End   string `json:"end" xml:"end,attr"`
+}
+
+// A Schedule is a set of possibly overlapping windows. An empty schedule
+// imposes no restrictions, i.e. it is always active.
+type Schedule []ScheduleWindow
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+type parsedScheduleWindow struct {
+	days                       [7]bool
+	startH, startM, endH, endM int
+	wraps                      bool
+}
+
+func (w ScheduleWindow) parse() (parsedScheduleWindow, error) {
+	var p parsedScheduleWindow
+	if strings.TrimSpace(w.Days) == "" || strings.TrimSpace(w.Days) == "*" {
+		for i := range p.days {
+			p.days[i] = true
+		}
+	} else {
+		for _, part := range strings.Split(w.Days, ",") {
+			first, last, isRange := strings.Cut(part, "-")
+			from, ok := weekdayNames[strings.ToLower(strings.TrimSpace(first))]
+			if !ok {
+				return p, fmt.Errorf("unknown day %q", first)
+			}
+			to := from
+			if isRange {
+				if to, ok = weekdayNames[strings.ToLower(strings.TrimSpace(last))]; !ok {
+					return p, fmt.Errorf("unknown day %q", last)
+				}
+			}
+			for d := from; ; d = (d + 1) % 7 {
+				p.days[d] = true
+				if d == to {
+					break
+				}
+			}
+		}
+	}
+
+	var err error
+	if p.startH, p.startM, err = parseTimeOfDay(w.Start); err != nil {
+		return p, err
+	}
+	if p.endH, p.endM, err = parseTimeOfDay(w.End); err != nil {
+		return p, err
+	}
+	p.wraps = p.endH*60+p.endM <= p.startH*60+p.startM
+	return p, nil
+}
+
+func parseTimeOfDay(s string) (int, int, error) {
+	hs, ms, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	h, err := strconv.Atoi(hs)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	m, err := strconv.Atoi(ms)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	if h < 0 || m < 0 || m > 59 || h > 24 || h == 24 && m != 0 {
+		return 0, 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return h, m, nil
+}
+
+// Validate returns an error if the window cannot be parsed.
+func (w ScheduleWindow) Validate() error {
+	_, err := w.parse()
+	return err
+}
+
+func (w ScheduleWindow) String() string {
+	days := w.Days
+	if days == "" {
+		days = "every day"
+	}
+	return fmt.Sprintf("%s %s-%s", days, w.Start, w.End)
+}
+
+// scheduleInterval is a concrete occurrence of a window.
+type scheduleInterval struct {
+	start, end time.Time
+}
+
+// intervals returns the occurrences of the windows that overlap the given
+// time range, sorted by start time. Invalid windows are skipped.
+func (s Schedule) intervals(from, to time.Time) []scheduleInterval {
+	var res []scheduleInterval
+	loc := from.Location()
+	// Windows started on the day before may extend into the range.
+	y, mo, d := from.AddDate(0, 0, -1).Date()
+	for day := time.Date(y, mo, d, 0, 0, 0, 0, loc); day.Before(to); day = day.AddDate(0, 0, 1) {
+		for _, w := range s {
+			p, err := w.parse()
+			if err != nil || !p.days[day.Weekday()] {
+				continue
+			}
+			start := time.Date(day.Year(), day.Month(), day.Day(), p.startH, p.startM, 0, 0, loc)
+			endDay := day.Day()
+			if p.wraps {
+				endDay++
+			}
+			end := time.Date(day.Year(), day.Month(), endDay, p.endH, p.endM, 0, 0, loc)
+			if end.After(from) && start.Before(to) {
+				res = append(res, scheduleInterval{start, end})
+			}
+		}
+	}
+	sort.Slice(res, func(a, b int) bool {
+		return res[a].start.Before(res[b].start)
+	})
+	return res
+}
+
+// Active returns whether the given time is within a window of the schedule,
+// or the schedule is empty.
+func (s Schedule) Active(t time.Time) bool {
+	if len(s) == 0 {
+		return true
+	}
+	for _, iv := range s.intervals(t, t.Add(time.Nanosecond)) {
+		if !t.Before(iv.start) && t.Before(iv.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// Window returns the window containing the given time or, if there is
+// none, the next window. Overlapping and adjacent windows are merged. A
+// zero end time means the window doesn't end within the next two weeks. The
+// boolean is false if the schedule is empty or has no windows at all.
+func (s Schedule) Window(t time.Time) (start, end time.Time, ok bool) {
+	if len(s) == 0 {
+		return time.Time{}, time.Time{}, false
+	}
+	// Look one week back and two weeks forward, which is enough to see
+	// the full current and next windows unless they never end.
+	from, to := t.AddDate(0, 0, -7), t.AddDate(0, 0, 14)
+	var cur *scheduleInterval
+	for _, iv := range s.intervals(from, to) {
+		if cur != nil && !iv.start.After(cur.end) {
+			if iv.end.After(cur.end) {
+				cur.end = iv.end
+			}
+			continue
+		}
+		if cur != nil && cur.end.After(t) {
+			break
+		}
+		cur = &scheduleInterval{iv.start, iv.end}
+	}
+	if cur == nil || !cur.end.After(t) {
+		return time.Time{}, time.Time{}, false
+	}
+	if !cur.end.Before(to) {
+		return cur.start, time.Time{}, true
+	}
+	return cur.start, cur.end, true
+}
+
+// Validate returns an error for the first invalid window of the schedule.
+func (s Schedule) Validate() error {
+	for _, w := range s {
+		if err := w.Validate(); err != nil {
+			return fmt.Errorf("schedule window %v: %w", w, err)
+		}
+	}
+	return nil
+}
+
+// validWindows returns the schedule without invalid windows, logging a
+// warning about each dropped window.
+func (s Schedule) validWindows(what string) Schedule {
+	if len(s) == 0 {
+		return s
+	}
+	res := make(Schedule, 0, len(s))
+	for _, w := range s {
+		if err := w.Validate(); err != nil {
+			l.Warnf("Ignoring invalid schedule window %v for %s: %v", w, what, err)
+			continue
+		}
+		res = append(res, w)
+	}
+	return res
+}
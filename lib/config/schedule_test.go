@@ -0,0 +1,120 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleActive(t *testing.T) {
+	// 2025-03-03 is a Monday
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2025, 3, day, hour, min, 0, 0, time.Local)
+	}
+
+	cases := []struct {
+		schedule Schedule
+		t        time.Time
+		active   bool
+	}{
+		{nil, at(3, 12, 0), true},
+		{Schedule{{Start: "09:00", End: "17:00"}}, at(3, 12, 0), true},
+		{Schedule{{Start: "09:00", End: "17:00"}}, at(3, 17, 0), false},
+		{Schedule{{Start: "09:00", End: "17:00"}}, at(3, 8, 59), false},
+		{Schedule{{Start: "09:00", End: "09:00"}}, at(3, 8, 59), true},
+		{Schedule{{Start: "00:00", End: "24:00"}}, at(3, 23, 59), true},
+		// Wrapping into the next day, including from Friday to Saturday
+		{Schedule{{Days: "mon-fri", Start: "22:00", End: "06:00"}}, at(3, 23, 0), true},
+		{Schedule{{Days: "mon-fri", Start: "22:00", End: "06:00"}}, at(4, 5, 59), true},
+		{Schedule{{Days: "mon-fri", Start: "22:00", End: "06:00"}}, at(3, 5, 59), false},
+		{Schedule{{Days: "mon-fri", Start: "22:00", End: "06:00"}}, at(8, 5, 0), true},
+		{Schedule{{Days: "mon-fri", Start: "22:00", End: "06:00"}}, at(8, 23, 0), false},
+		// Day ranges wrapping around the week
+		{Schedule{{Days: "Sat-Mon", Start: "10:00", End: "11:00"}}, at(3, 10, 30), true},
+		{Schedule{{Days: "sat-mon", Start: "10:00", End: "11:00"}}, at(4, 10, 30), false},
+		{Schedule{{Days: "tue, thu", Start: "10:00", End: "11:00"}}, at(6, 10, 30), true},
+		// Several windows
+		{Schedule{{Start: "01:00", End: "02:00"}, {Days: "wed", Start: "12:00", End: "13:00"}}, at(5, 12, 0), true},
+		{Schedule{{Start: "01:00", End: "02:00"}, {Days: "wed", Start: "12:00", End: "13:00"}}, at(4, 12, 0), false},
+		// Invalid windows never match
+		{Schedule{{Days: "someday", Start: "10:00", End: "11:00"}}, at(3, 10, 30), false},
+	}
+
+	for i, tc := range cases {
+		if active := tc.schedule.Active(tc.t); active != tc.active {
+			t.Errorf("%d: %v at %v: active is %v, expected %v", i, tc.schedule, tc.t, active, tc.active)
+		}
+	}
+}
+
+func TestScheduleWindow(t *testing.T) {
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2025, 3, day, hour, min, 0, 0, time.Local)
+	}
+
+	cases := []struct {
+		schedule   Schedule
+		t          time.Time
+		start, end time.Time
+		ok         bool
+	}{
+		{nil, at(3, 12, 0), time.Time{}, time.Time{}, false},
+		{Schedule{{Start: "09:00", End: "17:00"}}, at(3, 12, 0), at(3, 9, 0), at(3, 17, 0), true},
+		{Schedule{{Start: "09:00", End: "17:00"}}, at(3, 17, 0), at(4, 9, 0), at(4, 17, 0), true},
+		{Schedule{{Days: "mon-fri", Start: "22:00", End: "06:00"}}, at(8, 12, 0), at(10, 22, 0), at(11, 6, 0), true},
+		// Adjacent and overlapping windows are merged
+		{Schedule{{Start: "09:00", End: "12:00"}, {Start: "12:00", End: "14:00"}}, at(3, 10, 0), at(3, 9, 0), at(3, 14, 0), true},
+		{Schedule{{Start: "09:00", End: "12:00"}, {Start: "11:00", End: "02:00"}}, at(3, 10, 0), at(3, 9, 0), at(4, 2, 0), true},
+		// A window that never ends
+		{Schedule{{Start: "00:00", End: "00:00"}}, at(3, 10, 0), at(3, 0, 0).AddDate(0, 0, -7), time.Time{}, true},
+		{Schedule{{Days: "someday", Start: "10:00", End: "11:00"}}, at(3, 10, 30), time.Time{}, time.Time{}, false},
+	}
+
+	for i, tc := range cases {
+		start, end, ok := tc.schedule.Window(tc.t)
+		if ok != tc.ok || !start.Equal(tc.start) || !end.Equal(tc.end) {
+			t.Errorf("%d: %v at %v: got %v-%v %v, expected %v-%v %v", i, tc.schedule, tc.t, start, end, ok, tc.start, tc.end, tc.ok)
+		}
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	valid := []ScheduleWindow{
+		{Start: "22:00", End: "06:00"},
+		{Days: "*", Start: "0:00", End: "24:00"},
+		{Days: "Monday,wed-fri", Start: "08:30", End: "17:15"},
+	}
+	for _, w := range valid {
+		if err := w.Validate(); err != nil {
+			t.Errorf("%v: unexpected error: %v", w, err)
+		}
+	}
+
+	invalid := []ScheduleWindow{
+		{Start: "22:00"},
+		{Start: "22", End: "06:00"},
+		{Start: "24:01", End: "06:00"},
+		{Start: "12:60", End: "06:00"},
+		{Start: "-1:00", End: "06:00"},
+		{Days: "mon-", Start: "22:00", End: "06:00"},
+		{Days: "holidays", Start: "22:00", End: "06:00"},
+	}
+	for _, w := range invalid {
+		if err := w.Validate(); err == nil {
+			t.Errorf("%v: unexpected nil error", w)
+		}
+	}
+
+	s := Schedule{valid[0], invalid[0], valid[1]}
+	if err := s.Validate(); err == nil {
+		t.Error("expected error for schedule with invalid window")
+	}
+	if got := s.validWindows("test"); len(got) != 2 || got[0] != valid[0] || got[1] != valid[1] {
+		t.Error("unexpected valid windows:", got)
+	}
+}
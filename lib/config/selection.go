@@ -0,0 +1,49 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// prepareSelectedPaths cleans the selected paths of a selective folder into
+// slash separated paths relative to the folder root, dropping duplicates and
+// paths that don't select anything in particular.
+func (f *FolderConfiguration) prepareSelectedPaths() {
+	if f.SelectedPaths == nil {
+		return
+	}
+	paths := f.SelectedPaths[:0]
+	for _, p := range f.SelectedPaths {
+		p = strings.Trim(path.Clean("/"+filepath.ToSlash(p)), "/")
+		if p == "" || slices.Contains(paths, p) {
+			continue
+		}
+		paths = append(paths, p)
+	}
+	f.SelectedPaths = paths
+}
+
+// IsSelected returns whether the given item is materialised locally, which
+// for a selective folder is the case for the selected paths, everything
+// within them and their parent directories. All items are selected in other
+// types of folders.
+func (f FolderConfiguration) IsSelected(name string) bool {
+	if f.Type != FolderTypeSelective {
+		return true
+	}
+	name = filepath.ToSlash(name)
+	for _, p := range f.SelectedPaths {
+		if name == p || strings.HasPrefix(name, p+"/") || strings.HasPrefix(p, name+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestPrepareSelectedPaths(t *testing.T) {
+	f := FolderConfiguration{
+		SelectedPaths: []string{"/photos/2024/", "docs", "", "/", "docs/../docs", "a//b"},
+	}
+	f.prepareSelectedPaths()
+	if exp := []string{"photos/2024", "docs", "a/b"}; !slices.Equal(f.SelectedPaths, exp) {
+		t.Errorf("got %v, expected %v", f.SelectedPaths, exp)
+	}
+}
+
+func TestIsSelected(t *testing.T) {
+	f := FolderConfiguration{
+		Type:          FolderTypeSelective,
+		SelectedPaths: []string{"photos/2024", "notes.txt"},
+	}
+
+	cases := map[string]bool{
+		"photos":                             true,
+		filepath.Join("photos", "2024"):      true,
+		filepath.Join("photos", "2024", "a"): true,
+		filepath.Join("photos", "2023"):      false,
+		filepath.Join("photos", "2024b"):     false,
+		"notes.txt":                          true,
+		"notes.txt.bak":                      false,
+		"docs":                               false,
+	}
+	for name, exp := range cases {
+		if res := f.IsSelected(name); res != exp {
+			t.Errorf("%s: got %v, expected %v", name, res, exp)
+		}
+	}
+
+	f.Type = FolderTypeSendReceive
+	if !f.IsSelected("docs") {
+		t.Error("everything should be selected in a send-receive folder")
+	}
+}
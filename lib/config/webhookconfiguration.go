@@ -0,0 +1,111 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"slices"
+
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/rand"
+	"github.com/syncthing/syncthing/lib/structutil"
+)
+
+// A WebhookConfiguration describes an URL that events are POSTed to.
+// Events can be limited to certain types, and to those concerning certain
+// folders or devices. Empty lists mean no restriction, except that the
+// types default to those also returned by default from the events
+// endpoint. Payloads are signed using HMAC-SHA256 if a secret is set.
+type WebhookConfiguration struct {
+	ID           string              `json:"id" xml:"id,attr"`
+	URL          string              `json:"url" xml:"url"`
+	Enabled      bool                `json:"enabled" xml:"enabled,attr" default:"true"`
+	Events       []string            `json:"events" xml:"event"`
+	Folders      []string            `json:"folders" xml:"folder"`
+	Devices      []protocol.DeviceID `json:"devices" xml:"device"`
+	Secret       string              `json:"secret" xml:"secret"`
+	MaxBatchSize int                 `json:"maxBatchSize" xml:"maxBatchSize" default:"100"`
+	BatchDelayS  int                 `json:"batchDelayS" xml:"batchDelayS" default:"5"`
+}
+
+const defaultWebhookEventMask = events.AllEvents &^ events.LocalChangeDetected &^ events.RemoteChangeDetected
+
+func (w WebhookConfiguration) Copy() WebhookConfiguration {
+	c := w
+	c.Events = slices.Clone(w.Events)
+	c.Folders = slices.Clone(w.Folders)
+	c.Devices = slices.Clone(w.Devices)
+	return c
+}
+
+// EventMask returns the event types the webhook is interested in.
+func (w WebhookConfiguration) EventMask() events.EventType {
+	if len(w.Events) == 0 {
+		return defaultWebhookEventMask
+	}
+	var mask events.EventType
+	for _, name := range w.Events {
+		mask |= events.UnmarshalEventType(name)
+	}
+	return mask
+}
+
+func (w *WebhookConfiguration) prepare() {
+	if w.ID == "" {
+		w.ID = rand.String(8)
+	}
+	w.Events = slices.DeleteFunc(w.Events, func(name string) bool {
+		if events.UnmarshalEventType(name) == 0 {
+			l.Warnf("Ignoring unknown event type %q for webhook %s", name, w.ID)
+			return true
+		}
+		return false
+	})
+	if w.MaxBatchSize <= 0 {
+		w.MaxBatchSize = 100
+	}
+	if w.BatchDelayS < 0 {
+		w.BatchDelayS = 0
+	}
+}
+
+func (w *WebhookConfiguration) UnmarshalJSON(data []byte) error {
+	structutil.SetDefaults(w)
+
+	// avoid recursing into this method
+	type noCustomUnmarshal WebhookConfiguration
+	ptr := (*noCustomUnmarshal)(w)
+
+	return json.Unmarshal(data, ptr)
+}
+
+func (w *WebhookConfiguration) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	structutil.SetDefaults(w)
+
+	// avoid recursing into this method
+	type noCustomUnmarshal WebhookConfiguration
+	ptr := (*noCustomUnmarshal)(w)
+
+	return d.DecodeElement(ptr, &start)
+}
+
+func (cfg *Configuration) prepareWebhooks() {
+	seen := make(map[string]struct{}, len(cfg.Webhooks))
+	cfg.Webhooks = slices.DeleteFunc(cfg.Webhooks, func(w WebhookConfiguration) bool {
+		if _, ok := seen[w.ID]; ok && w.ID != "" {
+			l.Warnf("Removing webhook with duplicate ID %s", w.ID)
+			return true
+		}
+		seen[w.ID] = struct{}{}
+		return false
+	})
+	for i := range cfg.Webhooks {
+		cfg.Webhooks[i].prepare()
+	}
+}
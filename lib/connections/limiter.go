@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"io"
 	"sync/atomic"
+	"time"
 
 	"golang.org/x/time/rate"
 
@@ -20,7 +21,7 @@ import (
 )
 
 // limiter manages a read and write rate limit, reacting to config changes
-// as appropriate.
+// and switching bandwidth profiles as appropriate.
 type limiter struct {
 	myID                protocol.DeviceID
 	mu                  sync.Mutex
@@ -29,6 +30,25 @@ type limiter struct {
 	limitsLAN           atomic.Bool
 	deviceReadLimiters  map[protocol.DeviceID]*rate.Limiter
 	deviceWriteLimiters map[protocol.DeviceID]*rate.Limiter
+	// The latest configuration and the limits in effect, as bandwidth
+	// profiles may change the limits without a configuration change.
+	cfg          config.Configuration
+	globalLimits BandwidthLimits
+	deviceLimits map[protocol.DeviceID]BandwidthLimits
+	now          func() time.Time
+}
+
+// BandwidthLimits are the rate limits in effect, in KiB/s with zero meaning
+// unlimited, and the name of the bandwidth profile they come from, if any.
+type BandwidthLimits struct {
+	Profile     string `json:"profile"`
+	MaxSendKbps int    `json:"maxSendKbps"`
+	MaxRecvKbps int    `json:"maxRecvKbps"`
+}
+
+type BandwidthStatus struct {
+	Global  BandwidthLimits            `json:"global"`
+	Devices map[string]BandwidthLimits `json:"devices"`
 }
 
 type waiter interface {
@@ -49,6 +69,8 @@ func newLimiter(myId protocol.DeviceID, cfg config.Wrapper) *limiter {
 		mu:                  sync.NewMutex(),
 		deviceReadLimiters:  make(map[protocol.DeviceID]*rate.Limiter),
 		deviceWriteLimiters: make(map[protocol.DeviceID]*rate.Limiter),
+		deviceLimits:        make(map[protocol.DeviceID]BandwidthLimits),
+		now:                 time.Now,
 	}
 
 	cfg.Subscribe(l)
@@ -58,22 +80,72 @@ func newLimiter(myId protocol.DeviceID, cfg config.Wrapper) *limiter {
 	return l
 }
 
-// This function sets limiters according to corresponding DeviceConfiguration
-func (lim *limiter) setLimitsLocked(device config.DeviceConfiguration) bool {
-	readLimiter := lim.getReadLimiterLocked(device.DeviceID)
-	writeLimiter := lim.getWriteLimiterLocked(device.DeviceID)
+// serve re-evaluates the bandwidth profiles every minute, which is the
+// granularity of their schedules.
+func (lim *limiter) serve(ctx context.Context) error {
+	for {
+		now := lim.now()
+		timer := time.NewTimer(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		lim.mu.Lock()
+		lim.applyLocked(lim.cfg, lim.cfg, lim.now())
+		lim.mu.Unlock()
+	}
+}
+
+// effectiveLimits returns the limits of the active bandwidth profile, if
+// any, or otherwise the given static limits.
+func effectiveLimits(maxSendKbps, maxRecvKbps int, profiles config.BandwidthProfiles, now time.Time) BandwidthLimits {
+	var limits BandwidthLimits
+	if p, ok := profiles.Active(now); ok {
+		limits.Profile = p.Name
+		maxSendKbps, maxRecvKbps = p.MaxSendKbps, p.MaxRecvKbps
+	}
+	limits.MaxSendKbps = max(maxSendKbps, 0)
+	limits.MaxRecvKbps = max(maxRecvKbps, 0)
+	return limits
+}
+
+// The rate variables are in KiB/s in the config (despite the camel casing
+// of the name). We multiply by 1024 to get bytes/s.
+func kbpsToLimit(kbps int) rate.Limit {
+	if kbps <= 0 {
+		return rate.Inf
+	}
+	return 1024 * rate.Limit(kbps)
+}
+
+func (limits BandwidthLimits) String() string {
+	sendLimitStr := "is unlimited"
+	if limits.MaxSendKbps > 0 {
+		sendLimitStr = fmt.Sprintf("limit is %d KiB/s", limits.MaxSendKbps)
+	}
+	recvLimitStr := "is unlimited"
+	if limits.MaxRecvKbps > 0 {
+		recvLimitStr = fmt.Sprintf("limit is %d KiB/s", limits.MaxRecvKbps)
+	}
+	str := fmt.Sprintf("send rate %s, receive rate %s", sendLimitStr, recvLimitStr)
+	if limits.Profile != "" {
+		str += fmt.Sprintf(" (bandwidth profile %q)", limits.Profile)
+	}
+	return str
+}
+
+// This function sets limiters for a device according to the given limits
+func (lim *limiter) setLimitsLocked(deviceID protocol.DeviceID, limits BandwidthLimits) bool {
+	readLimiter := lim.getReadLimiterLocked(deviceID)
+	writeLimiter := lim.getWriteLimiterLocked(deviceID)
 
 	// limiters for this device are created so we can store previous rates for logging
 	previousReadLimit := readLimiter.Limit()
 	previousWriteLimit := writeLimiter.Limit()
-	currentReadLimit := rate.Limit(device.MaxRecvKbps) * 1024
-	currentWriteLimit := rate.Limit(device.MaxSendKbps) * 1024
-	if device.MaxSendKbps <= 0 {
-		currentWriteLimit = rate.Inf
-	}
-	if device.MaxRecvKbps <= 0 {
-		currentReadLimit = rate.Inf
-	}
+	currentReadLimit := kbpsToLimit(limits.MaxRecvKbps)
+	currentWriteLimit := kbpsToLimit(limits.MaxSendKbps)
 	// Nothing about this device has changed. Start processing next device
 	if previousWriteLimit == currentWriteLimit && previousReadLimit == currentReadLimit {
 		return false
@@ -86,7 +158,7 @@ func (lim *limiter) setLimitsLocked(device config.DeviceConfiguration) bool {
 }
 
 // This function handles removing, adding and updating of device limiters.
-func (lim *limiter) processDevicesConfigurationLocked(from, to config.Configuration) {
+func (lim *limiter) processDevicesConfigurationLocked(from, to config.Configuration, now time.Time) {
 	seen := make(map[protocol.DeviceID]struct{})
 
 	// Mark devices which should not be removed, create new limiters if needed and assign new limiter rate
@@ -97,18 +169,12 @@ func (lim *limiter) processDevicesConfigurationLocked(from, to config.Configurat
 		}
 		seen[dev.DeviceID] = struct{}{}
 
-		if lim.setLimitsLocked(dev) {
-			readLimitStr := "is unlimited"
-			if dev.MaxRecvKbps > 0 {
-				readLimitStr = fmt.Sprintf("limit is %d KiB/s", dev.MaxRecvKbps)
-			}
-			writeLimitStr := "is unlimited"
-			if dev.MaxSendKbps > 0 {
-				writeLimitStr = fmt.Sprintf("limit is %d KiB/s", dev.MaxSendKbps)
-			}
-
-			l.Infof("Device %s send rate %s, receive rate %s", dev.DeviceID, writeLimitStr, readLimitStr)
+		limits := effectiveLimits(dev.MaxSendKbps, dev.MaxRecvKbps, dev.BandwidthProfiles, now)
+		changed := lim.setLimitsLocked(dev.DeviceID, limits)
+		if prev, ok := lim.deviceLimits[dev.DeviceID]; changed || ok && prev.Profile != limits.Profile {
+			l.Infof("Device %s %s", dev.DeviceID, limits)
 		}
+		lim.deviceLimits[dev.DeviceID] = limits
 	}
 
 	// Delete remote devices which were removed in new configuration
@@ -118,6 +184,7 @@ func (lim *limiter) processDevicesConfigurationLocked(from, to config.Configurat
 
 			delete(lim.deviceWriteLimiters, dev.DeviceID)
 			delete(lim.deviceReadLimiters, dev.DeviceID)
+			delete(lim.deviceLimits, dev.DeviceID)
 		}
 	}
 }
@@ -127,50 +194,54 @@ func (lim *limiter) CommitConfiguration(from, to config.Configuration) bool {
 	lim.mu.Lock()
 	defer lim.mu.Unlock()
 
+	lim.cfg = to
+	lim.applyLocked(from, to, lim.now())
+
+	return true
+}
+
+// applyLocked sets the limits according to the configuration and the
+// bandwidth profiles active at the given time.
+func (lim *limiter) applyLocked(from, to config.Configuration, now time.Time) {
 	// Delete, add or update limiters for devices
-	lim.processDevicesConfigurationLocked(from, to)
+	lim.processDevicesConfigurationLocked(from, to, now)
 
-	if from.Options.MaxRecvKbps == to.Options.MaxRecvKbps &&
+	limits := effectiveLimits(to.Options.MaxSendKbps, to.Options.MaxRecvKbps, to.Options.BandwidthProfiles, now)
+	if limits == lim.globalLimits &&
+		from.Options.MaxRecvKbps == to.Options.MaxRecvKbps &&
 		from.Options.MaxSendKbps == to.Options.MaxSendKbps &&
 		from.Options.LimitBandwidthInLan == to.Options.LimitBandwidthInLan {
-		return true
-	}
-
-	limited := false
-	sendLimitStr := "is unlimited"
-	recvLimitStr := "is unlimited"
-
-	// The rate variables are in KiB/s in the config (despite the camel casing
-	// of the name). We multiply by 1024 to get bytes/s.
-	if to.Options.MaxRecvKbps <= 0 {
-		lim.read.SetLimit(rate.Inf)
-	} else {
-		lim.read.SetLimit(1024 * rate.Limit(to.Options.MaxRecvKbps))
-		recvLimitStr = fmt.Sprintf("limit is %d KiB/s", to.Options.MaxRecvKbps)
-		limited = true
-	}
-
-	if to.Options.MaxSendKbps <= 0 {
-		lim.write.SetLimit(rate.Inf)
-	} else {
-		lim.write.SetLimit(1024 * rate.Limit(to.Options.MaxSendKbps))
-		sendLimitStr = fmt.Sprintf("limit is %d KiB/s", to.Options.MaxSendKbps)
-		limited = true
+		return
 	}
+	lim.globalLimits = limits
 
+	lim.read.SetLimit(kbpsToLimit(limits.MaxRecvKbps))
+	lim.write.SetLimit(kbpsToLimit(limits.MaxSendKbps))
 	lim.limitsLAN.Store(to.Options.LimitBandwidthInLan)
 
-	l.Infof("Overall send rate %s, receive rate %s", sendLimitStr, recvLimitStr)
+	l.Infof("Overall %s", limits)
 
-	if limited {
+	if limits.MaxRecvKbps > 0 || limits.MaxSendKbps > 0 {
 		if to.Options.LimitBandwidthInLan {
 			l.Infoln("Rate limits apply to LAN connections")
 		} else {
 			l.Infoln("Rate limits do not apply to LAN connections")
 		}
 	}
+}
 
-	return true
+// status returns the limits currently in effect
+func (lim *limiter) status() BandwidthStatus {
+	lim.mu.Lock()
+	defer lim.mu.Unlock()
+	res := BandwidthStatus{
+		Global:  lim.globalLimits,
+		Devices: make(map[string]BandwidthLimits, len(lim.deviceLimits)),
+	}
+	for id, limits := range lim.deviceLimits {
+		res.Devices[id.String()] = limits
+	}
+	return res
 }
 
 func (*limiter) String() string {
@@ -14,6 +14,7 @@ import (
 	"math/rand"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"golang.org/x/time/rate"
 
@@ -217,6 +218,55 @@ func TestAddAndRemove(t *testing.T) {
 	checkActualAndExpected(t, actualR, actualW, expectedR, expectedW)
 }
 
+func TestBandwidthProfiles(t *testing.T) {
+	wrapper, wrapperCancel := initConfig()
+	defer wrapperCancel()
+
+	office := config.Schedule{{Days: "mon-fri", Start: "09:00", End: "17:00"}}
+	waiter, _ := wrapper.Modify(func(cfg *config.Configuration) {
+		cfg.Options.MaxSendKbps = 1000
+		cfg.Options.BandwidthProfiles = config.BandwidthProfiles{
+			{Name: "office", Schedule: office, MaxSendKbps: 100, MaxRecvKbps: 200},
+		}
+		dev3Conf.BandwidthProfiles = config.BandwidthProfiles{
+			{Name: "night", Schedule: config.Schedule{{Start: "22:00", End: "06:00"}}, MaxSendKbps: 10},
+		}
+		cfg.SetDevice(dev3Conf)
+	})
+	waiter.Wait()
+	lim := newLimiter(device1, wrapper)
+
+	check := func(at time.Time, limiter *rate.Limiter, expected rate.Limit) {
+		t.Helper()
+		lim.mu.Lock()
+		lim.applyLocked(lim.cfg, lim.cfg, at)
+		lim.mu.Unlock()
+		if limit := limiter.Limit(); limit != expected {
+			t.Errorf("At %v limit is %v, expected %v", at, limit, expected)
+		}
+	}
+
+	// 2025-03-03 is a Monday
+	check(time.Date(2025, 3, 3, 10, 0, 0, 0, time.Local), lim.write, 100*1024)
+	check(time.Date(2025, 3, 3, 10, 0, 0, 0, time.Local), lim.read, 200*1024)
+	if status := lim.status(); status.Global.Profile != "office" || status.Global.MaxSendKbps != 100 {
+		t.Error("Unexpected status", status)
+	}
+	check(time.Date(2025, 3, 3, 17, 0, 0, 0, time.Local), lim.write, 1000*1024)
+	check(time.Date(2025, 3, 3, 17, 0, 0, 0, time.Local), lim.read, rate.Inf)
+	check(time.Date(2025, 3, 8, 10, 0, 0, 0, time.Local), lim.write, 1000*1024)
+	if status := lim.status(); status.Global.Profile != "" || status.Global.MaxSendKbps != 1000 {
+		t.Error("Unexpected status", status)
+	}
+
+	check(time.Date(2025, 3, 3, 23, 0, 0, 0, time.Local), lim.deviceWriteLimiters[device3], 10*1024)
+	check(time.Date(2025, 3, 3, 23, 0, 0, 0, time.Local), lim.deviceWriteLimiters[device2], kbpsToLimit(dev2Conf.MaxSendKbps))
+	if status := lim.status(); status.Devices[device3.String()].Profile != "night" {
+		t.Error("Unexpected status", status)
+	}
+	check(time.Date(2025, 3, 4, 6, 0, 0, 0, time.Local), lim.deviceWriteLimiters[device3], rate.Inf)
+}
+
 func TestLimitedWriterWrite(t *testing.T) {
 	// Check that the limited writer writes the correct data in the correct manner.
 
@@ -19,6 +19,16 @@ type Service struct {
 	allAddressesReturnsOnCall map[int]struct {
 		result1 []string
 	}
+	BandwidthStatusStub        func() connections.BandwidthStatus
+	bandwidthStatusMutex       sync.RWMutex
+	bandwidthStatusArgsForCall []struct {
+	}
+	bandwidthStatusReturns struct {
+		result1 connections.BandwidthStatus
+	}
+	bandwidthStatusReturnsOnCall map[int]struct {
+		result1 connections.BandwidthStatus
+	}
 	ConnectionStatusStub        func() map[string]connections.ConnectionStatusEntry
 	connectionStatusMutex       sync.RWMutex
 	connectionStatusArgsForCall []struct {
@@ -127,6 +137,59 @@ func (fake *Service) AllAddressesReturnsOnCall(i int, result1 []string) {
 	}{result1}
 }
 
+func (fake *Service) BandwidthStatus() connections.BandwidthStatus {
+	fake.bandwidthStatusMutex.Lock()
+	ret, specificReturn := fake.bandwidthStatusReturnsOnCall[len(fake.bandwidthStatusArgsForCall)]
+	fake.bandwidthStatusArgsForCall = append(fake.bandwidthStatusArgsForCall, struct {
+	}{})
+	stub := fake.BandwidthStatusStub
+	fakeReturns := fake.bandwidthStatusReturns
+	fake.recordInvocation("BandwidthStatus", []interface{}{})
+	fake.bandwidthStatusMutex.Unlock()
+	if stub != nil {
+		return stub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *Service) BandwidthStatusCallCount() int {
+	fake.bandwidthStatusMutex.RLock()
+	defer fake.bandwidthStatusMutex.RUnlock()
+	return len(fake.bandwidthStatusArgsForCall)
+}
+
+func (fake *Service) BandwidthStatusCalls(stub func() connections.BandwidthStatus) {
+	fake.bandwidthStatusMutex.Lock()
+	defer fake.bandwidthStatusMutex.Unlock()
+	fake.BandwidthStatusStub = stub
+}
+
+func (fake *Service) BandwidthStatusReturns(result1 connections.BandwidthStatus) {
+	fake.bandwidthStatusMutex.Lock()
+	defer fake.bandwidthStatusMutex.Unlock()
+	fake.BandwidthStatusStub = nil
+	fake.bandwidthStatusReturns = struct {
+		result1 connections.BandwidthStatus
+	}{result1}
+}
+
+func (fake *Service) BandwidthStatusReturnsOnCall(i int, result1 connections.BandwidthStatus) {
+	fake.bandwidthStatusMutex.Lock()
+	defer fake.bandwidthStatusMutex.Unlock()
+	fake.BandwidthStatusStub = nil
+	if fake.bandwidthStatusReturnsOnCall == nil {
+		fake.bandwidthStatusReturnsOnCall = make(map[int]struct {
+			result1 connections.BandwidthStatus
+		})
+	}
+	fake.bandwidthStatusReturnsOnCall[i] = struct {
+		result1 connections.BandwidthStatus
+	}{result1}
+}
+
 func (fake *Service) ConnectionStatus() map[string]connections.ConnectionStatusEntry {
 	fake.connectionStatusMutex.Lock()
 	ret, specificReturn := fake.connectionStatusReturnsOnCall[len(fake.connectionStatusArgsForCall)]
@@ -401,6 +464,8 @@ func (fake *Service) ServeReturnsOnCall(i int, result1 error) {
 }
 
 func (fake *Service) Invocations() map[string][][]interface{} {
+	fake.bandwidthStatusMutex.RLock()
+	defer fake.bandwidthStatusMutex.RUnlock()
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
 	fake.allAddressesMutex.RLock()
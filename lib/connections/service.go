@@ -133,6 +133,7 @@ type Service interface {
 	discover.AddressLister
 	ListenerStatus() map[string]ListenerStatusEntry
 	ConnectionStatus() map[string]ConnectionStatusEntry
+	BandwidthStatus() BandwidthStatus
 	NATType() string
 }
 
@@ -232,6 +233,7 @@ func NewService(cfg config.Wrapper, myID protocol.DeviceID, mdl Model, tlsCfg *t
 	service.Add(svcutil.AsService(service.connect, fmt.Sprintf("%s/connect", service)))
 	service.Add(svcutil.AsService(service.handleConns, fmt.Sprintf("%s/handleConns", service)))
 	service.Add(svcutil.AsService(service.handleHellos, fmt.Sprintf("%s/handleHellos", service)))
+	service.Add(svcutil.AsService(service.limiter.serve, fmt.Sprintf("%s/limiter", service)))
 	service.Add(service.natService)
 
 	svcutil.OnSupervisorDone(service.Supervisor, func() {
@@ -313,6 +315,7 @@ func (s *service) helloForDevice(remoteID protocol.DeviceID) protocol.Hello {
 		ClientName:    "syncthing",
 		ClientVersion: build.Version,
 		Timestamp:     time.Now().UnixNano(),
+		Compressions:  protocol.SupportedCompressions,
 	}
 	if cfg, ok := s.cfg.Device(remoteID); ok {
 		hello.NumConnections = cfg.NumConnections()
@@ -445,7 +448,11 @@ func (s *service) handleHellos(ctx context.Context) error {
 		// connections are limited.
 		rd, wr := s.limiter.getLimiters(remoteID, c, c.IsLocal())
 
-		protoConn := protocol.NewConnection(remoteID, rd, wr, c, s.model, c, deviceCfg.Compression.ToProtocol(), s.cfg.FolderPasswords(remoteID), s.keyGen)
+		// Use the preferred message compression if the other side
+		// supports it, otherwise fall back to LZ4 which all devices
+		// understand.
+		msgCompression := protocol.NegotiateCompression(deviceCfg.CompressionAlgorithm.ToProtocol(), hello)
+		protoConn := protocol.NewConnection(remoteID, rd, wr, c, s.model, c, deviceCfg.Compression.ToProtocol(), msgCompression, deviceCfg.CompressionLevel, s.cfg.FolderPasswords(remoteID), s.keyGen)
 		s.accountAddedConnection(protoConn, hello, s.cfg.Options().ConnectionPriorityUpgradeThreshold)
 		go func() {
 			<-protoConn.Closed()
@@ -1051,6 +1058,12 @@ func (s *service) NATType() string {
 	return "unknown"
 }
 
+// BandwidthStatus returns the rate limits currently in effect, globally
+// and per device.
+func (s *service) BandwidthStatus() BandwidthStatus {
+	return s.limiter.status()
+}
+
 func getDialerFactory(cfg config.Configuration, uri *url.URL) (dialerFactory, error) {
 	dialerFactory, ok := dialers[uri.Scheme]
 	if !ok {
@@ -0,0 +1,385 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/build"
+	"github.com/syncthing/syncthing/lib/db/backend"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// An archive is a gzip compressed stream consisting of
+//
+//   - the magic string archiveMagic,
+//   - a length prefixed JSON ArchiveHeader,
+//   - any number of records, each a length prefixed key followed by a length
+//     prefixed value, in key order,
+//   - an empty key marking the end of the records,
+//   - a length prefixed JSON ArchiveTrailer.
+//
+// All lengths are unsigned varints. As the first byte of each key is its key
+// type, the records are grouped by the key types described in the header.
+const (
+	archiveMagic         = "SYNCTHING-DB-ARCHIVE\n"
+	archiveFormatVersion = 1
+	// Sanity limit for the JSON parts and for keys and values.
+	archiveMaxItemSize = 512 << 20
+)
+
+var (
+	errArchiveMagic        = errors.New("not a database archive")
+	errArchiveDBNotEmpty   = errors.New("database is not empty")
+	errArchiveItemTooLarge = errors.New("archive item too large")
+)
+
+// ArchiveHeader describes the contents of a database archive.
+type ArchiveHeader struct {
+	FormatVersion    int       `json:"formatVersion"`
+	Created          time.Time `json:"created"`
+	SyncthingVersion string    `json:"syncthingVersion"`
+	SchemaVersion    int64     `json:"schemaVersion"`
+	MigrationVersion int64     `json:"migrationVersion"`
+	// The folders and devices known to the database, in the order of
+	// their internal indexes.
+	Folders  []string         `json:"folders"`
+	Devices  []string         `json:"devices"`
+	KeyTypes []ArchiveKeyType `json:"keyTypes"`
+}
+
+// ArchiveKeyType describes the records of a given key type.
+type ArchiveKeyType struct {
+	Type        byte   `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ArchiveTrailer follows the records and allows verifying them.
+type ArchiveTrailer struct {
+	// Records is the number of records per key type name.
+	Records map[string]int `json:"records"`
+	// SHA256 is the hash over the header and all records as written,
+	// including the end marker.
+	SHA256 string `json:"sha256"`
+}
+
+var archiveKeyTypes = []ArchiveKeyType{
+	{KeyTypeDevice, "files", "<int32 folder idx> <int32 device idx> <file name> = FileInfo (local and remote file lists)"},
+	{KeyTypeGlobal, "global", "<int32 folder idx> <file name> = VersionList"},
+	{KeyTypeBlock, "blockMap", "<int32 folder idx> <32 bytes hash> <file name> = int32 (block index) [byte (hash algorithm)]"},
+	{KeyTypeDeviceStatistic, "deviceStatistics", "<device ID as string> <some string> = some value"},
+	{KeyTypeFolderStatistic, "folderStatistics", "<folder ID as string> <some string> = some value"},
+	{KeyTypeVirtualMtime, "virtualMtimes", "<int32 folder idx> <file name> = mtimeMapping"},
+	{KeyTypeFolderIdx, "folderIndex", "<int32 idx> = folder ID"},
+	{KeyTypeDeviceIdx, "deviceIndex", "<int32 idx> = device ID"},
+	{KeyTypeIndexID, "indexIDs", "<int32 device idx> <int32 folder idx> = protocol.IndexID"},
+	{KeyTypeFolderMeta, "folderMeta", "<int32 folder idx> = CountsSet"},
+	{KeyTypeMiscData, "misc", "<some string> = some value"},
+	{KeyTypeSequence, "sequences", "<int32 folder idx> <int64 sequence number> = files key"},
+	{KeyTypeNeed, "need", "<int32 folder idx> <file name> = <nothing>"},
+	{KeyTypeBlockList, "blockLists", "<block list hash> = BlockList"},
+	{KeyTypeBlockListMap, "blockListMap", "<int32 folder idx> <block list hash> <file name> = <nothing>"},
+	{KeyTypeVersion, "versions", "<version hash> = Vector"},
+	{KeyTypePendingFolder, "pendingFolders", "<int32 device idx> <folder ID as string> = ObservedFolder"},
+	{KeyTypePendingDevice, "pendingDevices", "<device ID in wire format> = ObservedDevice"},
+}
+
+func archiveKeyTypeName(keyType byte) string {
+	for _, kt := range archiveKeyTypes {
+		if kt.Type == keyType {
+			return kt.Name
+		}
+	}
+	return fmt.Sprintf("unknown%d", keyType)
+}
+
+// ExportArchive writes the full contents of the database to w. The
+// database should not be in use by a running Syncthing, as not all of its
+// updates are atomic.
+func ExportArchive(db backend.Backend, w io.Writer) (*ArchiveTrailer, error) {
+	snap, err := db.NewReadTransaction()
+	if err != nil {
+		return nil, err
+	}
+	defer snap.Release()
+
+	header := &ArchiveHeader{
+		FormatVersion:    archiveFormatVersion,
+		Created:          time.Now().Truncate(time.Second),
+		SyncthingVersion: build.Version,
+		KeyTypes:         archiveKeyTypes,
+	}
+	header.SchemaVersion, err = archiveMiscInt64(snap, "dbVersion")
+	if err != nil {
+		return nil, err
+	}
+	header.MigrationVersion, err = archiveMiscInt64(snap, "dbMigrationVersion")
+	if err != nil {
+		return nil, err
+	}
+	header.Folders, err = archiveIndexNames(snap, KeyTypeFolderIdx, func(bs []byte) string { return string(bs) })
+	if err != nil {
+		return nil, err
+	}
+	header.Devices, err = archiveIndexNames(snap, KeyTypeDeviceIdx, func(bs []byte) string {
+		if id, err := protocol.DeviceIDFromBytes(bs); err == nil {
+			return id.String()
+		}
+		return hex.EncodeToString(bs)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	gw := gzip.NewWriter(w)
+	bw := bufio.NewWriter(gw)
+	if _, err := bw.WriteString(archiveMagic); err != nil {
+		return nil, err
+	}
+	hw := &archiveHashWriter{w: bw, h: sha256.New()}
+	if err := writeArchiveJSON(hw, header); err != nil {
+		return nil, err
+	}
+
+	it, err := snap.NewPrefixIterator(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Release()
+	trailer := &ArchiveTrailer{Records: make(map[string]int)}
+	for it.Next() {
+		key := it.Key()
+		if len(key) == 0 {
+			continue
+		}
+		if err := writeArchiveBytes(hw, key); err != nil {
+			return nil, err
+		}
+		if err := writeArchiveBytes(hw, it.Value()); err != nil {
+			return nil, err
+		}
+		trailer.Records[archiveKeyTypeName(key[0])]++
+	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	it.Release()
+	if err := writeArchiveBytes(hw, nil); err != nil {
+		return nil, err
+	}
+
+	trailer.SHA256 = hex.EncodeToString(hw.h.Sum(nil))
+	if err := writeArchiveJSON(bw, trailer); err != nil {
+		return nil, err
+	}
+	if err := bw.Flush(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return trailer, nil
+}
+
+// ImportArchive reads an archive as written by ExportArchive into the
+// given, empty, database. If an error is returned the database may contain
+// partial data and should be discarded.
+func ImportArchive(db backend.Backend, r io.Reader) (*ArchiveHeader, error) {
+	it, err := db.NewPrefixIterator(nil)
+	if err != nil {
+		return nil, err
+	}
+	empty := !it.Next()
+	it.Release()
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
+	if !empty {
+		return nil, errArchiveDBNotEmpty
+	}
+
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errArchiveMagic, err)
+	}
+	br := bufio.NewReader(gr)
+	magic := make([]byte, len(archiveMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != archiveMagic {
+		return nil, errArchiveMagic
+	}
+
+	hr := &archiveHashReader{r: br, h: sha256.New()}
+	var header ArchiveHeader
+	if err := readArchiveJSON(hr, &header); err != nil {
+		return nil, fmt.Errorf("reading archive header: %w", err)
+	}
+	if header.FormatVersion > archiveFormatVersion {
+		return nil, fmt.Errorf("unsupported archive format version %d (written by Syncthing %s)", header.FormatVersion, header.SyncthingVersion)
+	}
+	if header.SchemaVersion > dbVersion {
+		// Older schemas are taken care of by the schema updater when the
+		// database is opened.
+		return nil, fmt.Errorf("archive has database schema version %d, newer than the supported %d (written by Syncthing %s)", header.SchemaVersion, dbVersion, header.SyncthingVersion)
+	}
+
+	t, err := db.NewWriteTransaction()
+	if err != nil {
+		return nil, err
+	}
+	defer t.Release()
+
+	records := make(map[string]int)
+	for {
+		key, err := readArchiveBytes(hr)
+		if err != nil {
+			return nil, fmt.Errorf("reading archive: %w", err)
+		}
+		if len(key) == 0 {
+			break
+		}
+		val, err := readArchiveBytes(hr)
+		if err != nil {
+			return nil, fmt.Errorf("reading archive: %w", err)
+		}
+		if err := t.Put(key, val); err != nil {
+			return nil, err
+		}
+		if err := t.Checkpoint(); err != nil {
+			return nil, err
+		}
+		records[archiveKeyTypeName(key[0])]++
+	}
+
+	var trailer ArchiveTrailer
+	if err := readArchiveJSON(br, &trailer); err != nil {
+		return nil, fmt.Errorf("reading archive trailer: %w", err)
+	}
+	if sum := hex.EncodeToString(hr.h.Sum(nil)); sum != trailer.SHA256 {
+		return nil, fmt.Errorf("archive checksum mismatch: %s != %s", sum, trailer.SHA256)
+	}
+	for name, n := range trailer.Records {
+		if records[name] != n {
+			return nil, fmt.Errorf("archive record count mismatch for %s: %d != %d", name, records[name], n)
+		}
+	}
+
+	if err := t.Commit(); err != nil {
+		return nil, err
+	}
+	return &header, nil
+}
+
+func archiveMiscInt64(r backend.Reader, key string) (int64, error) {
+	bs, err := r.Get(append([]byte{KeyTypeMiscData}, key...))
+	if backend.IsNotFound(err) || len(bs) != 8 {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return int64(binary.BigEndian.Uint64(bs)), nil
+}
+
+func archiveIndexNames(r backend.Reader, keyType byte, name func([]byte) string) ([]string, error) {
+	it, err := r.NewPrefixIterator([]byte{keyType})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Release()
+	var names []string
+	for it.Next() {
+		names = append(names, name(it.Value()))
+	}
+	return names, it.Error()
+}
+
+func writeArchiveJSON(w io.Writer, v interface{}) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return writeArchiveBytes(w, bs)
+}
+
+func readArchiveJSON(r archiveReader, v interface{}) error {
+	bs, err := readArchiveBytes(r)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, v)
+}
+
+func writeArchiveBytes(w io.Writer, bs []byte) error {
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], uint64(len(bs)))
+	if _, err := w.Write(lenBuf[:n]); err != nil {
+		return err
+	}
+	_, err := w.Write(bs)
+	return err
+}
+
+type archiveReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+func readArchiveBytes(r archiveReader) ([]byte, error) {
+	l, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if l > archiveMaxItemSize {
+		return nil, errArchiveItemTooLarge
+	}
+	bs := make([]byte, l)
+	if _, err := io.ReadFull(r, bs); err != nil {
+		return nil, err
+	}
+	return bs, nil
+}
+
+// archiveHashWriter hashes everything written through it.
+type archiveHashWriter struct {
+	w io.Writer
+	h hash.Hash
+}
+
+func (w *archiveHashWriter) Write(bs []byte) (int, error) {
+	w.h.Write(bs)
+	return w.w.Write(bs)
+}
+
+// archiveHashReader hashes everything read through it.
+type archiveHashReader struct {
+	r *bufio.Reader
+	h hash.Hash
+}
+
+func (r *archiveHashReader) Read(bs []byte) (int, error) {
+	n, err := r.r.Read(bs)
+	r.h.Write(bs[:n])
+	return n, err
+}
+
+func (r *archiveHashReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.h.Write([]byte{b})
+	}
+	return b, err
+}
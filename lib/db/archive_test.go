@@ -0,0 +1,158 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package db
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/db/backend"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestArchiveRoundtrip(t *testing.T) {
+	remote, _ := protocol.DeviceIDFromString("AIR6LPZ-7K4PTTV-UXQSMUU-CPQ5YWH-OEDFIIQ-JUG777G-2YQXXR5-YD6AWQR")
+
+	src := newLowlevelMemory(t)
+	defer src.Close()
+	if err := UpdateSchema(src); err != nil {
+		t.Fatal(err)
+	}
+	srcSet := newFileSet(t, "test", src)
+	srcSet.Update(protocol.LocalDeviceID, archiveTestFiles(1, "a", "b", "c"))
+	srcSet.Update(remote, archiveTestFiles(2, "a", "d"))
+	srcSet.SetIndexID(remote, 42)
+
+	var buf bytes.Buffer
+	trailer, err := ExportArchive(src, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trailer.Records["files"] != 5 {
+		t.Errorf("expected five file records, got %v", trailer.Records)
+	}
+	archive := buf.Bytes()
+
+	dst := backend.OpenMemory()
+	header, err := ImportArchive(dst, bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.SchemaVersion != dbVersion || header.MigrationVersion != dbMigrationVersion {
+		t.Errorf("unexpected schema version %d/%d", header.SchemaVersion, header.MigrationVersion)
+	}
+	if fmt.Sprint(header.Folders) != "[test]" {
+		t.Errorf("unexpected folders %v", header.Folders)
+	}
+	if !slices.Contains(header.Devices, remote.String()) || !slices.Contains(header.Devices, protocol.LocalDeviceID.String()) {
+		t.Errorf("unexpected devices %v", header.Devices)
+	}
+
+	dstLL := newLowlevel(t, dst)
+	defer dstLL.Close()
+	if err := UpdateSchema(dstLL); err != nil {
+		t.Fatal(err)
+	}
+	dstSet := newFileSet(t, "test", dstLL)
+	srcSnap := snapshot(t, srcSet)
+	defer srcSnap.Release()
+	dstSnap := snapshot(t, dstSet)
+	defer dstSnap.Release()
+
+	if dstSet.IndexID(protocol.LocalDeviceID) != srcSet.IndexID(protocol.LocalDeviceID) {
+		t.Error("local index ID differs")
+	}
+	if dstSet.IndexID(remote) != 42 {
+		t.Error("remote index ID differs")
+	}
+	for _, dev := range []protocol.DeviceID{protocol.LocalDeviceID, remote} {
+		if dstSnap.Sequence(dev) != srcSnap.Sequence(dev) {
+			t.Errorf("sequence for %v differs: %d != %d", dev, dstSnap.Sequence(dev), srcSnap.Sequence(dev))
+		}
+	}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		srcFile, _ := srcSnap.GetGlobal(name)
+		dstFile, ok := dstSnap.GetGlobal(name)
+		if !ok || !srcFile.IsEquivalent(dstFile, 0) || srcFile.Sequence != dstFile.Sequence {
+			t.Errorf("global file %s differs: %v != %v", name, dstFile, srcFile)
+		}
+	}
+	if dstSnap.GlobalSize() != srcSnap.GlobalSize() {
+		t.Errorf("global size differs: %v != %v", dstSnap.GlobalSize(), srcSnap.GlobalSize())
+	}
+	found := false
+	dstSnap.WithBlocksHash(archiveTestFiles(1, "a")[0].BlocksHash, func(protocol.FileInfo) bool {
+		found = true
+		return false
+	})
+	if !found {
+		t.Error("block list map not restored")
+	}
+
+	// Importing again into the now non-empty database fails.
+	if _, err := ImportArchive(dst, bytes.NewReader(archive)); !errors.Is(err, errArchiveDBNotEmpty) {
+		t.Error("expected error for non-empty database, got", err)
+	}
+}
+
+func TestArchiveCorrupt(t *testing.T) {
+	src := newLowlevelMemory(t)
+	defer src.Close()
+	newFileSet(t, "test", src).Update(protocol.LocalDeviceID, archiveTestFiles(1, "a", "b", "c"))
+
+	var buf bytes.Buffer
+	if _, err := ExportArchive(src, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip a bit in the middle of the uncompressed contents and compress
+	// again, so that gzip's own checksum doesn't catch it.
+	gr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := io.ReadAll(gr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[len(raw)/2] ^= 1
+	var corrupt bytes.Buffer
+	gw := gzip.NewWriter(&corrupt)
+	_, _ = gw.Write(raw)
+	_ = gw.Close()
+
+	dst := backend.OpenMemory()
+	defer dst.Close()
+	if _, err := ImportArchive(dst, &corrupt); err == nil {
+		t.Error("expected error importing corrupt archive")
+	}
+
+	dst = backend.OpenMemory()
+	defer dst.Close()
+	if _, err := ImportArchive(dst, bytes.NewReader([]byte("something else"))); !errors.Is(err, errArchiveMagic) {
+		t.Error("expected not an archive error, got", err)
+	}
+}
+
+func archiveTestFiles(counter uint64, names ...string) []protocol.FileInfo {
+	files := make([]protocol.FileInfo, len(names))
+	for i, name := range names {
+		files[i] = protocol.FileInfo{
+			Name:    name,
+			Size:    1,
+			Version: protocol.Vector{}.Update(protocol.ShortID(counter)),
+			Blocks:  []protocol.BlockInfo{{Size: 1, Hash: bytes.Repeat([]byte(name), 32)}},
+		}
+		files[i].BlocksHash = protocol.BlocksHash(files[i].Blocks)
+	}
+	return files
+}
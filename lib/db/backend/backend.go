@@ -133,6 +133,41 @@ func OpenMemory() Backend {
 	return OpenLevelDBMemory()
 }
 
+// Copy copies all keys and values from src to dst, e.g. to migrate a
+// database from one backend implementation to another. Existing keys in
+// dst are overwritten, other keys in dst are left alone.
+func Copy(dst, src Backend) error {
+	snap, err := src.NewReadTransaction()
+	if err != nil {
+		return err
+	}
+	defer snap.Release()
+	it, err := snap.NewPrefixIterator(nil)
+	if err != nil {
+		return err
+	}
+	defer it.Release()
+
+	tx, err := dst.NewWriteTransaction()
+	if err != nil {
+		return err
+	}
+	defer tx.Release()
+	for it.Next() {
+		if err := tx.Put(it.Key(), it.Value()); err != nil {
+			return err
+		}
+		if err := tx.Checkpoint(); err != nil {
+			return err
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	it.Release()
+	return tx.Commit()
+}
+
 var (
 	errClosed   = errors.New("database is closed")
 	errNotFound = errors.New("key not found")
@@ -0,0 +1,388 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package backend
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+)
+
+const (
+	sqliteGet    = `SELECT value FROM kv WHERE key = ?`
+	sqlitePut    = `INSERT OR REPLACE INTO kv (key, value) VALUES (?, ?)`
+	sqliteDelete = `DELETE FROM kv WHERE key = ?`
+
+	sqliteIterAll   = `SELECT key, value FROM kv ORDER BY key`
+	sqliteIterFrom  = `SELECT key, value FROM kv WHERE key >= ? ORDER BY key`
+	sqliteIterTo    = `SELECT key, value FROM kv WHERE key < ? ORDER BY key`
+	sqliteIterRange = `SELECT key, value FROM kv WHERE key >= ? AND key < ? ORDER BY key`
+)
+
+// sqliteBackend implements Backend on top of a single key/value table in
+// an SQLite database.
+type sqliteBackend struct {
+	db       *sql.DB
+	closeWG  *closeWaitGroup
+	location string
+	// SQLite allows only one writer at a time. Serializing writes here
+	// avoids spinning on the busy timeout between our own connections.
+	writeMut sync.Mutex
+	// Called after closing, e.g. to remove the files of a temporary
+	// database.
+	onClose func()
+}
+
+func newSQLiteBackend(db *sql.DB, location string) *sqliteBackend {
+	return &sqliteBackend{
+		db:       db,
+		closeWG:  &closeWaitGroup{},
+		location: location,
+	}
+}
+
+func (b *sqliteBackend) NewReadTransaction() (ReadTransaction, error) {
+	return b.newSnapshot()
+}
+
+func (b *sqliteBackend) newSnapshot() (*sqliteSnapshot, error) {
+	rel, err := newReleaser(b.closeWG)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := b.db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		rel.Release()
+		return nil, wrapSQLiteErr(err)
+	}
+	// A transaction in SQLite only takes its snapshot on the first read,
+	// so do one right away.
+	var key []byte
+	if err := tx.QueryRow(`SELECT key FROM kv LIMIT 1`).Scan(&key); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		_ = tx.Rollback()
+		rel.Release()
+		return nil, wrapSQLiteErr(err)
+	}
+	return &sqliteSnapshot{
+		tx:  tx,
+		rel: rel,
+	}, nil
+}
+
+func (b *sqliteBackend) NewWriteTransaction(hooks ...CommitHook) (WriteTransaction, error) {
+	rel, err := newReleaser(b.closeWG)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := b.newSnapshot()
+	if err != nil {
+		rel.Release()
+		return nil, err // already wrapped
+	}
+	return &sqliteTransaction{
+		sqliteSnapshot: snap,
+		backend:        b,
+		rel:            rel,
+		commitHooks:    hooks,
+	}, nil
+}
+
+func (b *sqliteBackend) Close() error {
+	b.closeWG.CloseWait()
+	err := wrapSQLiteErr(b.db.Close())
+	if b.onClose != nil {
+		b.onClose()
+	}
+	return err
+}
+
+func (b *sqliteBackend) Get(key []byte) ([]byte, error) {
+	var val []byte
+	err := b.db.QueryRow(sqliteGet, nonNil(key)).Scan(&val)
+	return val, wrapSQLiteErr(err)
+}
+
+func (b *sqliteBackend) NewPrefixIterator(prefix []byte) (Iterator, error) {
+	return b.NewRangeIterator(prefix, prefixLimit(prefix))
+}
+
+func (b *sqliteBackend) NewRangeIterator(first, last []byte) (Iterator, error) {
+	// Guard against creating iterators on a closed database, which would
+	// otherwise result in an error we can't recognize.
+	if err := b.closeWG.Add(1); err != nil {
+		return nil, err
+	}
+	defer b.closeWG.Done()
+	return newSQLiteIterator(b.db.Query, first, last)
+}
+
+func (b *sqliteBackend) Put(key, val []byte) error {
+	b.writeMut.Lock()
+	defer b.writeMut.Unlock()
+	_, err := b.db.Exec(sqlitePut, nonNil(key), nonNil(val))
+	return wrapSQLiteErr(err)
+}
+
+func (b *sqliteBackend) Delete(key []byte) error {
+	b.writeMut.Lock()
+	defer b.writeMut.Unlock()
+	_, err := b.db.Exec(sqliteDelete, nonNil(key))
+	return wrapSQLiteErr(err)
+}
+
+func (b *sqliteBackend) Compact() error {
+	err := b.closeWG.Add(1)
+	if err != nil {
+		return err
+	}
+	defer b.closeWG.Done()
+	// Return free pages to the filesystem and fold the write ahead log
+	// back into the main database file.
+	if _, err := b.db.Exec(`PRAGMA incremental_vacuum`); err != nil {
+		return wrapSQLiteErr(err)
+	}
+	_, err = b.db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`)
+	return wrapSQLiteErr(err)
+}
+
+func (b *sqliteBackend) Location() string {
+	return b.location
+}
+
+// write applies the given operations to the database in a single
+// transaction.
+func (b *sqliteBackend) write(ops []sqliteOp) error {
+	b.writeMut.Lock()
+	defer b.writeMut.Unlock()
+
+	tx, err := b.db.Begin()
+	if err != nil {
+		return wrapSQLiteErr(err)
+	}
+	defer tx.Rollback()
+
+	put, err := tx.Prepare(sqlitePut)
+	if err != nil {
+		return wrapSQLiteErr(err)
+	}
+	defer put.Close()
+	del, err := tx.Prepare(sqliteDelete)
+	if err != nil {
+		return wrapSQLiteErr(err)
+	}
+	defer del.Close()
+
+	for _, op := range ops {
+		if op.delete {
+			_, err = del.Exec(op.key)
+		} else {
+			_, err = put.Exec(op.key, op.val)
+		}
+		if err != nil {
+			return wrapSQLiteErr(err)
+		}
+	}
+	return wrapSQLiteErr(tx.Commit())
+}
+
+// sqliteSnapshot implements backend.ReadTransaction using a read only
+// SQLite transaction.
+type sqliteSnapshot struct {
+	tx  *sql.Tx
+	rel *releaser
+}
+
+func (s *sqliteSnapshot) Get(key []byte) ([]byte, error) {
+	var val []byte
+	err := s.tx.QueryRow(sqliteGet, nonNil(key)).Scan(&val)
+	return val, wrapSQLiteErr(err)
+}
+
+func (s *sqliteSnapshot) NewPrefixIterator(prefix []byte) (Iterator, error) {
+	return s.NewRangeIterator(prefix, prefixLimit(prefix))
+}
+
+func (s *sqliteSnapshot) NewRangeIterator(first, last []byte) (Iterator, error) {
+	return newSQLiteIterator(s.tx.Query, first, last)
+}
+
+func (s *sqliteSnapshot) Release() {
+	// Rolling back an already finished transaction is harmless, so
+	// there's no need to track whether we were released before.
+	_ = s.tx.Rollback()
+	s.rel.Release()
+}
+
+type sqliteOp struct {
+	key, val []byte
+	delete   bool
+}
+
+// sqliteTransaction implements backend.WriteTransaction by reading from a
+// snapshot and batching writes in memory, just like leveldbTransaction.
+type sqliteTransaction struct {
+	*sqliteSnapshot
+	backend     *sqliteBackend
+	ops         []sqliteOp
+	size        int
+	rel         *releaser
+	commitHooks []CommitHook
+	inFlush     bool
+}
+
+func (t *sqliteTransaction) Delete(key []byte) error {
+	t.ops = append(t.ops, sqliteOp{key: bytes.Clone(nonNil(key)), delete: true})
+	t.size += len(key)
+	return t.checkFlush(dbFlushBatchMax)
+}
+
+func (t *sqliteTransaction) Put(key, val []byte) error {
+	t.ops = append(t.ops, sqliteOp{key: bytes.Clone(nonNil(key)), val: bytes.Clone(nonNil(val))})
+	t.size += len(key) + len(val)
+	return t.checkFlush(dbFlushBatchMax)
+}
+
+func (t *sqliteTransaction) Checkpoint() error {
+	return t.checkFlush(dbFlushBatchMin)
+}
+
+func (t *sqliteTransaction) Commit() error {
+	err := t.flush()
+	t.sqliteSnapshot.Release()
+	t.rel.Release()
+	return err
+}
+
+func (t *sqliteTransaction) Release() {
+	t.sqliteSnapshot.Release()
+	t.rel.Release()
+}
+
+// checkFlush flushes and resets the batch if its size exceeds the given size.
+func (t *sqliteTransaction) checkFlush(size int) error {
+	// Hooks might put values in the database, which triggers a checkFlush which might trigger a flush,
+	// which might trigger the hooks.
+	// Don't recurse...
+	if t.inFlush || t.size < size {
+		return nil
+	}
+	return t.flush()
+}
+
+func (t *sqliteTransaction) flush() error {
+	t.inFlush = true
+	defer func() { t.inFlush = false }()
+
+	for _, hook := range t.commitHooks {
+		if err := hook(t); err != nil {
+			return err
+		}
+	}
+	if len(t.ops) == 0 {
+		return nil
+	}
+	if err := t.backend.write(t.ops); err != nil {
+		return err
+	}
+	t.ops = t.ops[:0]
+	t.size = 0
+	return nil
+}
+
+type sqliteIterator struct {
+	rows     *sql.Rows
+	key, val []byte
+	err      error
+}
+
+func newSQLiteIterator(query func(string, ...any) (*sql.Rows, error), first, last []byte) (*sqliteIterator, error) {
+	var rows *sql.Rows
+	var err error
+	switch {
+	case first == nil && last == nil:
+		rows, err = query(sqliteIterAll)
+	case last == nil:
+		rows, err = query(sqliteIterFrom, first)
+	case first == nil:
+		rows, err = query(sqliteIterTo, last)
+	default:
+		rows, err = query(sqliteIterRange, first, last)
+	}
+	if err != nil {
+		return nil, wrapSQLiteErr(err)
+	}
+	return &sqliteIterator{rows: rows}, nil
+}
+
+func (it *sqliteIterator) Next() bool {
+	if it.err != nil || !it.rows.Next() {
+		return false
+	}
+	it.key, it.val = nil, nil
+	if err := it.rows.Scan(&it.key, &it.val); err != nil {
+		it.err = err
+		return false
+	}
+	return true
+}
+
+func (it *sqliteIterator) Key() []byte {
+	return it.key
+}
+
+func (it *sqliteIterator) Value() []byte {
+	return it.val
+}
+
+func (it *sqliteIterator) Error() error {
+	if it.err != nil {
+		return wrapSQLiteErr(it.err)
+	}
+	return wrapSQLiteErr(it.rows.Err())
+}
+
+func (it *sqliteIterator) Release() {
+	_ = it.rows.Close()
+}
+
+// prefixLimit returns the smallest key that is larger than all keys with
+// the given prefix, or nil if there is no such key.
+func prefixLimit(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] < 0xff {
+			limit := bytes.Clone(prefix[:i+1])
+			limit[i]++
+			return limit
+		}
+	}
+	return nil
+}
+
+// nonNil returns an empty slice for a nil one, as the driver would
+// otherwise store NULL.
+func nonNil(bs []byte) []byte {
+	if bs == nil {
+		return []byte{}
+	}
+	return bs
+}
+
+// wrapSQLiteErr wraps errors so that the backend package can recognize them
+func wrapSQLiteErr(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, sql.ErrNoRows):
+		return errNotFound
+	case errors.Is(err, sql.ErrConnDone), strings.Contains(err.Error(), "sql: database is closed"):
+		return errClosed
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package backend
+
+import (
+	"database/sql"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	_ "modernc.org/sqlite" // register the "sqlite" driver
+)
+
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS kv (
+	key BLOB NOT NULL PRIMARY KEY,
+	value BLOB NOT NULL
+) WITHOUT ROWID`
+
+// OpenSQLite opens, or creates, the SQLite database at the given location.
+func OpenSQLite(location string, tuning Tuning) (Backend, error) {
+	large := false
+	switch tuning {
+	case TuningLarge:
+		large = true
+	case TuningAuto:
+		if info, err := os.Stat(location); err == nil {
+			large = info.Size() > dbLargeThreshold
+		}
+	}
+
+	pragmas := url.Values{
+		"_pragma": []string{
+			// Set per connection
+			"busy_timeout(30000)",
+			"synchronous(NORMAL)",
+		},
+		// Writes are serialized by us anyway, and taking the lock up front
+		// avoids deadlocks between a deferred transaction upgrading to a
+		// write and a concurrent writer.
+		"_txlock": []string{"immediate"},
+	}
+	if large {
+		l.Infoln("Using large-database tuning")
+		// Negative values are in KiB, i.e. 64 MiB.
+		pragmas["_pragma"] = append(pragmas["_pragma"], "cache_size(-65536)")
+	}
+
+	db, err := sql.Open("sqlite", location+"?"+pragmas.Encode())
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range []string{
+		// Must be set before the table is created to have any effect.
+		`PRAGMA auto_vacuum = INCREMENTAL`,
+		`PRAGMA journal_mode = WAL`,
+		sqliteSchema,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, &errorSuggestion{err, "is another instance of Syncthing running?"}
+		}
+	}
+	return newSQLiteBackend(db, location), nil
+}
+
+// OpenSQLiteMemory returns a new Backend referencing a temporary SQLite
+// database that is removed when the Backend is closed.
+func OpenSQLiteMemory() Backend {
+	// An actual in-memory database would need a shared cache to be
+	// accessible from multiple connections, which doesn't provide the
+	// snapshot isolation we need for read transactions. Use a temporary
+	// file instead.
+	dir, err := os.MkdirTemp("", "syncthing-sqlite-")
+	if err != nil {
+		panic(err)
+	}
+	db, err := OpenSQLite(filepath.Join(dir, "index.sqlite"), TuningSmall)
+	if err != nil {
+		os.RemoveAll(dir)
+		panic(err)
+	}
+	sb := db.(*sqliteBackend)
+	sb.location = ""
+	sb.onClose = func() { os.RemoveAll(dir) }
+	return sb
+}
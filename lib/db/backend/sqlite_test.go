@@ -0,0 +1,167 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package backend
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteBackendBehavior(t *testing.T) {
+	testBackendBehavior(t, OpenSQLiteMemory)
+}
+
+func TestSQLiteIterators(t *testing.T) {
+	db := OpenSQLiteMemory()
+	defer db.Close()
+
+	for _, k := range []string{"a", "ab", "abc", "b", "b\xff", "b\xff\xff", "c"} {
+		if err := db.Put([]byte(k), []byte(k)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		first, last []byte
+		prefix      bool
+		expected    string
+	}{
+		{nil, nil, true, "[a ab abc b b\xff b\xff\xff c]"},
+		{[]byte("a"), nil, true, "[a ab abc]"},
+		{[]byte("b\xff"), nil, true, "[b\xff b\xff\xff]"},
+		{[]byte("ab"), []byte("b\xff"), false, "[ab abc b]"},
+		{nil, []byte("ab"), false, "[a]"},
+		{[]byte("b"), nil, false, "[b b\xff b\xff\xff c]"},
+	}
+
+	snap, err := db.NewReadTransaction()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Release()
+
+	for _, r := range []Reader{db, snap} {
+		for _, tc := range cases {
+			var it Iterator
+			if tc.prefix {
+				it, err = r.NewPrefixIterator(tc.first)
+			} else {
+				it, err = r.NewRangeIterator(tc.first, tc.last)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var keys []string
+			for it.Next() {
+				keys = append(keys, string(it.Key()))
+			}
+			if err := it.Error(); err != nil {
+				t.Fatal(err)
+			}
+			it.Release()
+			if fmt.Sprint(keys) != tc.expected {
+				t.Errorf("%q-%q (prefix %v): got %q, expected %q", tc.first, tc.last, tc.prefix, keys, tc.expected)
+			}
+		}
+	}
+}
+
+func TestSQLiteTransactionFlush(t *testing.T) {
+	db := OpenSQLiteMemory()
+	defer db.Close()
+
+	hookCalls := 0
+	tx, err := db.NewWriteTransaction(func(WriteTransaction) error {
+		hookCalls++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Release()
+
+	// Write enough to force intermediate flushes.
+	val := make([]byte, 1<<KiB)
+	n := 2 * dbFlushBatchMax / len(val)
+	for i := 0; i < n; i++ {
+		if err := tx.Put([]byte(fmt.Sprintf("key%05d", i)), val); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Delete([]byte("key00000")); err != nil {
+		t.Fatal(err)
+	}
+	if hookCalls == 0 {
+		t.Error("expected the commit hook to be called on flush")
+	}
+	if _, err := tx.Get([]byte("key00001")); !IsNotFound(err) {
+		t.Error("expected the transaction to read from its snapshot, got", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Get([]byte("key00000")); !IsNotFound(err) {
+		t.Error("expected deleted key to be gone, got", err)
+	}
+	if v, err := db.Get([]byte(fmt.Sprintf("key%05d", n-1))); err != nil {
+		t.Fatal(err)
+	} else if len(v) != len(val) {
+		t.Errorf("unexpected value length %d", len(v))
+	}
+}
+
+func TestCopyLevelDBToSQLite(t *testing.T) {
+	dir := t.TempDir()
+
+	src, err := OpenLevelDB(filepath.Join(dir, "index.db"), TuningAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	for i := 0; i < 1000; i++ {
+		if err := src.Put([]byte(fmt.Sprintf("key%04d", i)), []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dst, err := OpenSQLite(filepath.Join(dir, "index.sqlite"), TuningAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Copy(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopen to verify the data was persisted.
+	dst, err = OpenSQLite(filepath.Join(dir, "index.sqlite"), TuningAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	it, err := dst.NewPrefixIterator([]byte("key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Release()
+	i := 0
+	for ; it.Next(); i++ {
+		if string(it.Key()) != fmt.Sprintf("key%04d", i) || string(it.Value()) != fmt.Sprint(i) {
+			t.Fatalf("unexpected entry %q: %q at %d", it.Key(), it.Value(), i)
+		}
+	}
+	if err := it.Error(); err != nil {
+		t.Fatal(err)
+	}
+	if i != 1000 {
+		t.Errorf("expected 1000 entries, got %d", i)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"fmt"
 
 	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
 )
 
 type BlockFinder struct {
@@ -28,11 +29,12 @@ func (f *BlockFinder) String() string {
 }
 
 // Iterate takes an iterator function which iterates over all matching blocks
-// for the given hash. The iterator function has to return either true (if
-// they are happy with the block) or false to continue iterating for whatever
-// reason. The iterator finally returns the result, whether or not a
-// satisfying block was eventually found.
-func (f *BlockFinder) Iterate(folders []string, hash []byte, iterFn func(string, string, int32) bool) bool {
+// for the given hash, computed using the given algorithm. The iterator
+// function has to return either true (if they are happy with the block) or
+// false to continue iterating for whatever reason. The iterator finally
+// returns the result, whether or not a satisfying block was eventually
+// found.
+func (f *BlockFinder) Iterate(folders []string, hash []byte, algo protocol.HashAlgorithm, iterFn func(string, string, int32) bool) bool {
 	t, err := f.db.newReadOnlyTransaction()
 	if err != nil {
 		return false
@@ -51,8 +53,11 @@ func (f *BlockFinder) Iterate(folders []string, hash []byte, iterFn func(string,
 		}
 
 		for iter.Next() && iter.Error() == nil {
+			index, blockAlgo := parseBlockMapValue(iter.Value())
+			if blockAlgo != algo {
+				continue
+			}
 			file := string(f.db.keyer.NameFromBlockMapKey(iter.Key()))
-			index := int32(binary.BigEndian.Uint32(iter.Value()))
 			if iterFn(folder, osutil.NativeFilename(file), index) {
 				iter.Release()
 				return true
@@ -62,3 +67,22 @@ func (f *BlockFinder) Iterate(folders []string, hash []byte, iterFn func(string,
 	}
 	return false
 }
+
+// blockMapValue returns the block map value for the block with the given
+// index, reusing buf. The value is the index, followed by the hash
+// algorithm unless it's the default SHA-256.
+func blockMapValue(buf []byte, index int, algo protocol.HashAlgorithm) []byte {
+	buf = binary.BigEndian.AppendUint32(buf[:0], uint32(index))
+	if algo != protocol.HashAlgorithmSHA256 {
+		buf = append(buf, byte(algo))
+	}
+	return buf
+}
+
+func parseBlockMapValue(val []byte) (int32, protocol.HashAlgorithm) {
+	index := int32(binary.BigEndian.Uint32(val))
+	if len(val) > 4 {
+		return index, protocol.HashAlgorithm(val[4])
+	}
+	return index, protocol.HashAlgorithmSHA256
+}
@@ -7,7 +7,6 @@
 package db
 
 import (
-	"encoding/binary"
 	"testing"
 
 	"github.com/syncthing/syncthing/lib/protocol"
@@ -59,13 +58,12 @@ func addToBlockMap(db *Lowlevel, folder []byte, fs []protocol.FileInfo) error {
 	}
 	defer t.close()
 
-	var keyBuf []byte
-	blockBuf := make([]byte, 4)
+	var keyBuf, blockBuf []byte
 	for _, f := range fs {
 		if !f.IsDirectory() && !f.IsDeleted() && !f.IsInvalid() {
 			name := []byte(f.Name)
 			for i, block := range f.Blocks {
-				binary.BigEndian.PutUint32(blockBuf, uint32(i))
+				blockBuf = blockMapValue(blockBuf, i, f.BlockHashAlgorithm)
 				keyBuf, err = t.keyer.GenerateBlockMapKey(keyBuf, folder, block.Hash, name)
 				if err != nil {
 					return err
@@ -120,21 +118,21 @@ func TestBlockMapAddUpdateWipe(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	f.Iterate(folders, f1.Blocks[0].Hash, func(folder, file string, index int32) bool {
+	f.Iterate(folders, f1.Blocks[0].Hash, protocol.HashAlgorithmSHA256, func(folder, file string, index int32) bool {
 		if folder != "folder1" || file != "f1" || index != 0 {
 			t.Fatal("Mismatch")
 		}
 		return true
 	})
 
-	f.Iterate(folders, f2.Blocks[0].Hash, func(folder, file string, index int32) bool {
+	f.Iterate(folders, f2.Blocks[0].Hash, protocol.HashAlgorithmSHA256, func(folder, file string, index int32) bool {
 		if folder != "folder1" || file != "f2" || index != 0 {
 			t.Fatal("Mismatch")
 		}
 		return true
 	})
 
-	f.Iterate(folders, f3.Blocks[0].Hash, func(folder, file string, index int32) bool {
+	f.Iterate(folders, f3.Blocks[0].Hash, protocol.HashAlgorithmSHA256, func(folder, file string, index int32) bool {
 		t.Fatal("Unexpected block")
 		return true
 	})
@@ -150,17 +148,17 @@ func TestBlockMapAddUpdateWipe(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	f.Iterate(folders, f1.Blocks[0].Hash, func(folder, file string, index int32) bool {
+	f.Iterate(folders, f1.Blocks[0].Hash, protocol.HashAlgorithmSHA256, func(folder, file string, index int32) bool {
 		t.Fatal("Unexpected block")
 		return false
 	})
 
-	f.Iterate(folders, f2.Blocks[0].Hash, func(folder, file string, index int32) bool {
+	f.Iterate(folders, f2.Blocks[0].Hash, protocol.HashAlgorithmSHA256, func(folder, file string, index int32) bool {
 		t.Fatal("Unexpected block")
 		return false
 	})
 
-	f.Iterate(folders, f3.Blocks[0].Hash, func(folder, file string, index int32) bool {
+	f.Iterate(folders, f3.Blocks[0].Hash, protocol.HashAlgorithmSHA256, func(folder, file string, index int32) bool {
 		if folder != "folder1" || file != "f3" || index != 0 {
 			t.Fatal("Mismatch")
 		}
@@ -207,7 +205,7 @@ func TestBlockFinderLookup(t *testing.T) {
 	}
 
 	counter := 0
-	f.Iterate(folders, f1.Blocks[0].Hash, func(folder, file string, index int32) bool {
+	f.Iterate(folders, f1.Blocks[0].Hash, protocol.HashAlgorithmSHA256, func(folder, file string, index int32) bool {
 		counter++
 		switch counter {
 		case 1:
@@ -239,7 +237,7 @@ func TestBlockFinderLookup(t *testing.T) {
 	}
 
 	counter = 0
-	f.Iterate(folders, f1.Blocks[0].Hash, func(folder, file string, index int32) bool {
+	f.Iterate(folders, f1.Blocks[0].Hash, protocol.HashAlgorithmSHA256, func(folder, file string, index int32) bool {
 		counter++
 		switch counter {
 		case 1:
@@ -258,3 +256,29 @@ func TestBlockFinderLookup(t *testing.T) {
 
 	f1.Deleted = false
 }
+
+func TestBlockFinderHashAlgorithm(t *testing.T) {
+	db, f := setup(t)
+	defer db.Close()
+
+	file := f1
+	file.BlockHashAlgorithm = protocol.HashAlgorithmBLAKE2b
+	if err := addToBlockMap(db, []byte("folder1"), []protocol.FileInfo{file}); err != nil {
+		t.Fatal(err)
+	}
+
+	if f.Iterate(folders, file.Blocks[0].Hash, protocol.HashAlgorithmSHA256, func(string, string, int32) bool {
+		return true
+	}) {
+		t.Error("Unexpected block hashed using a different algorithm")
+	}
+
+	if !f.Iterate(folders, file.Blocks[0].Hash, protocol.HashAlgorithmBLAKE2b, func(folder, file string, index int32) bool {
+		if folder != "folder1" || file != "f1" || index != 0 {
+			t.Fatal("Mismatch")
+		}
+		return true
+	}) {
+		t.Error("Block not found")
+	}
+}
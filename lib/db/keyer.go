@@ -27,7 +27,7 @@ const (
 	// KeyTypeGlobal <int32 folder ID> <file name> = VersionList
 	KeyTypeGlobal byte = 1
 
-	// KeyTypeBlock <int32 folder ID> <32 bytes hash> <§file name> = int32 (block index)
+	// KeyTypeBlock <int32 folder ID> <32 bytes hash> <§file name> = int32 (block index) [byte (hash algorithm)]
 	KeyTypeBlock byte = 2
 
 	// KeyTypeDeviceStatistic <device ID as string> <some string> = some value
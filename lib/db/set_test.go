@@ -526,7 +526,7 @@ func TestUpdateToInvalid(t *testing.T) {
 		t.Errorf("Have incorrect after invalidation;\n A: %v !=\n E: %v", have, localHave)
 	}
 
-	f.Iterate([]string{folder}, oldBlockHash, func(folder, file string, index int32) bool {
+	f.Iterate([]string{folder}, oldBlockHash, protocol.HashAlgorithmSHA256, func(folder, file string, index int32) bool {
 		if file == localHave[1].Name {
 			t.Errorf("Found unexpected block in blockmap for invalidated file")
 			return true
@@ -534,7 +534,7 @@ func TestUpdateToInvalid(t *testing.T) {
 		return false
 	})
 
-	if !f.Iterate([]string{folder}, localHave[4].Blocks[0].Hash, func(folder, file string, index int32) bool {
+	if !f.Iterate([]string{folder}, localHave[4].Blocks[0].Hash, protocol.HashAlgorithmSHA256, func(folder, file string, index int32) bool {
 		return file == localHave[4].Name
 	}) {
 		t.Errorf("First block of un-invalidated file is missing from blockmap")
@@ -58,6 +58,11 @@ const (
 	ListenAddressesChanged
 	LoginAttempt
 	Failure
+	ConflictResolved
+	FolderScrubMismatch
+	FolderScrubCompleted
+	ConfigDriftCorrected
+	UserAction
 
 	AllEvents = (1 << iota) - 1
 )
@@ -135,6 +140,16 @@ func (t EventType) String() string {
 		return "FolderWatchStateChanged"
 	case Failure:
 		return "Failure"
+	case ConflictResolved:
+		return "ConflictResolved"
+	case FolderScrubMismatch:
+		return "FolderScrubMismatch"
+	case FolderScrubCompleted:
+		return "FolderScrubCompleted"
+	case ConfigDriftCorrected:
+		return "ConfigDriftCorrected"
+	case UserAction:
+		return "UserAction"
 	default:
 		return "Unknown"
 	}
@@ -222,6 +237,16 @@ func UnmarshalEventType(s string) EventType {
 		return FolderWatchStateChanged
 	case "Failure":
 		return Failure
+	case "ConflictResolved":
+		return ConflictResolved
+	case "FolderScrubMismatch":
+		return FolderScrubMismatch
+	case "FolderScrubCompleted":
+		return FolderScrubCompleted
+	case "ConfigDriftCorrected":
+		return ConfigDriftCorrected
+	case "UserAction":
+		return UserAction
 	default:
 		return 0
 	}
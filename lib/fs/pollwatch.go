@@ -0,0 +1,139 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultPollInterval is how often a polling watcher rescans the tree when
+// no other interval is configured.
+const DefaultPollInterval = time.Minute
+
+// pollState is what we remember about each entry between two polls.
+type pollState struct {
+	size    int64
+	modTime time.Time
+	mode    FileMode
+	isDir   bool
+}
+
+// pollWatch implements Watch for filesystems that have no native change
+// notification. It walks the tree below name every interval and emits
+// events for whatever was added, changed or removed since the last walk.
+// The initial walk happens before returning, so that a broken filesystem
+// is reported to the caller straight away.
+func pollWatch(fs Filesystem, name string, ignore Matcher, ctx context.Context, ignorePerms bool, interval time.Duration) (<-chan Event, <-chan error, error) {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	prev, err := pollSnapshot(fs, name, ignore)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	outChan := make(chan Event)
+	errChan := make(chan error)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				l.Debugln(fs.Type(), fs.URI(), "Watch: Stopped")
+				return
+			}
+
+			cur, err := pollSnapshot(fs, name, ignore)
+			if err != nil {
+				// Remote filesystems come and go; the folder health
+				// check is responsible for reporting that. We just try
+				// again next time around.
+				l.Debugln(fs.Type(), fs.URI(), "Watch: Poll failed:", err)
+				continue
+			}
+
+			for _, ev := range pollDiff(prev, cur, ignorePerms) {
+				select {
+				case outChan <- ev:
+					l.Debugln(fs.Type(), fs.URI(), "Watch: Sending", ev.Name, ev.Type)
+				case <-ctx.Done():
+					l.Debugln(fs.Type(), fs.URI(), "Watch: Stopped")
+					return
+				}
+			}
+			prev = cur
+		}
+	}()
+
+	return outChan, errChan, nil
+}
+
+func pollSnapshot(fs Filesystem, name string, ignore Matcher) (map[string]pollState, error) {
+	snap := make(map[string]pollState)
+	err := NewWalkFilesystem(fs).Walk(name, func(path string, info FileInfo, err error) error {
+		if err != nil {
+			if path == name {
+				return err
+			}
+			// Something vanished while we were walking. It will be
+			// reported as removed if it's still gone next time.
+			return nil
+		}
+		if path == name {
+			return nil
+		}
+		if IsInternal(path) {
+			if info.IsDir() {
+				return SkipDir
+			}
+			return nil
+		}
+		if ignore != nil {
+			res := ignore.Match(path)
+			if res.CanSkipDir() && info.IsDir() {
+				return SkipDir
+			}
+			if res.IsIgnored() {
+				return nil
+			}
+		}
+		snap[path] = pollState{
+			size:    info.Size(),
+			modTime: info.ModTime(),
+			mode:    info.Mode(),
+			isDir:   info.IsDir(),
+		}
+		return nil
+	})
+	return snap, err
+}
+
+func pollDiff(prev, cur map[string]pollState, ignorePerms bool) []Event {
+	var evs []Event
+	for name, c := range cur {
+		p, ok := prev[name]
+		switch {
+		case !ok:
+		case p.isDir != c.isDir:
+		case !c.isDir && (p.size != c.size || !p.modTime.Equal(c.modTime)):
+		case !ignorePerms && p.mode&ModePerm != c.mode&ModePerm:
+		default:
+			continue
+		}
+		evs = append(evs, Event{Name: name, Type: NonRemove})
+	}
+	for name := range prev {
+		if _, ok := cur[name]; !ok {
+			evs = append(evs, Event{Name: name, Type: Remove})
+		}
+	}
+	return evs
+}
@@ -0,0 +1,1195 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+const FilesystemTypeS3 FilesystemType = "s3"
+
+func init() {
+	RegisterFilesystemType(FilesystemTypeS3, func(root string, opts ...Option) (Filesystem, error) {
+		return newS3Filesystem(root, opts...)
+	})
+}
+
+// Object metadata keys. The S3 API hands these back in canonical header
+// form, so that's how we write them as well.
+const (
+	s3MetaType   = "St-Type" // "symlink" for symlinks, absent otherwise
+	s3MetaMode   = "St-Mode"
+	s3MetaMtime  = "St-Mtime" // nanoseconds since the epoch
+	s3MetaUID    = "St-Uid"
+	s3MetaGID    = "St-Gid"
+	s3MetaXattrs = "St-Xattrs" // base64 encoded JSON list of protocol.Xattr
+
+	s3TypeSymlink = "symlink"
+)
+
+var errS3NotEmpty = errors.New("directory not empty")
+
+// s3FS is a filesystem stored in an S3 compatible object store. The folder
+// path is of the form
+//
+//	bucket[/prefix][?param=value&...]
+//
+// with the following parameters:
+//
+//	endpoint=url       the S3 endpoint, e.g. http://localhost:9000 for MinIO
+//	region=name        the region (default "us-east-1")
+//	credentials=file   shared credentials file to read the keys from (default ~/.aws/credentials)
+//	profile=name       profile in the shared credentials file (default "default")
+//	pollinterval=d     how often Watch looks for changes, in time.ParseDuration format
+//
+// The folder path is shown in the GUI, logs and elsewhere, so it can't
+// hold the keys themselves. Without credentials or profile the default AWS
+// credential chain is used: the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+// environment variables, the shared credentials file, or the instance role.
+//
+// Files are stored as objects named by their path below the prefix.
+// Directories are zero length objects with a trailing slash, though we
+// also accept directories that only exist implicitly as the common prefix
+// of other objects. Symlinks are objects holding the link target, marked as
+// such in their metadata. File modes, modification times, ownership and
+// extended attributes are kept in object metadata.
+//
+// Objects can't be modified in place, so files opened for writing are
+// staged in a local temporary file and uploaded when closed or synced.
+// Renames and metadata changes are implemented as server side copies,
+// which means they are not atomic and are limited to objects of at most
+// 5 GiB. There is no change notification in S3, so Watch falls back to
+// polling.
+type s3FS struct {
+	uri          string
+	bucket       string
+	prefix       string // without leading or trailing slash, may be empty
+	svc          *s3.S3
+	uploader     *s3manager.Uploader
+	pollInterval time.Duration
+	userCache    *userCache
+	groupCache   *groupCache
+}
+
+func newS3Filesystem(rootURI string, _ ...Option) (*s3FS, error) {
+	uri, err := url.Parse(rootURI)
+	if err != nil {
+		return nil, fmt.Errorf("parsing S3 folder path: %w", err)
+	}
+	params := uri.Query()
+
+	bucket, prefix, _ := strings.Cut(strings.Trim(uri.Path, "/"), "/")
+	if bucket == "" {
+		return nil, errors.New("S3 folder path must start with a bucket name")
+	}
+
+	region := params.Get("region")
+	if region == "" {
+		region = "us-east-1"
+	}
+	cfg := &aws.Config{
+		Region:           aws.String(region),
+		S3ForcePathStyle: aws.Bool(true),
+	}
+	if endpoint := params.Get("endpoint"); endpoint != "" {
+		cfg.Endpoint = aws.String(endpoint)
+	}
+	if params.Has("accesskey") || params.Has("secretkey") {
+		return nil, errors.New("S3 keys can't be given in the folder path; use a credentials file or the environment")
+	}
+	if file, profile := params.Get("credentials"), params.Get("profile"); file != "" || profile != "" {
+		file, _ = ExpandTilde(file)
+		cfg.Credentials = credentials.NewSharedCredentials(file, profile)
+	}
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating S3 session: %w", err)
+	}
+
+	pollInterval, _ := time.ParseDuration(params.Get("pollinterval"))
+
+	return &s3FS{
+		uri:          rootURI,
+		bucket:       bucket,
+		prefix:       strings.Trim(prefix, "/"),
+		svc:          s3.New(sess),
+		uploader:     s3manager.NewUploader(sess),
+		pollInterval: pollInterval,
+		userCache:    newValueCache(time.Hour, user.LookupId),
+		groupCache:   newValueCache(time.Hour, user.LookupGroupId),
+	}, nil
+}
+
+// key returns the object key for the given path. The folder root maps to
+// the prefix itself, which is the empty string for a bucket root.
+func (f *s3FS) key(name string) (string, error) {
+	name, err := Canonicalize(name)
+	if err != nil {
+		return "", err
+	}
+	if name == "." {
+		return f.prefix, nil
+	}
+	name = filepath.ToSlash(name)
+	if f.prefix == "" {
+		return name, nil
+	}
+	return f.prefix + "/" + name, nil
+}
+
+// dirPrefix returns the prefix under which the children of the directory
+// with the given key live.
+func dirPrefix(key string) string {
+	if key == "" {
+		return ""
+	}
+	return key + "/"
+}
+
+func (f *s3FS) copySource(key string) string {
+	segs := strings.Split(key, "/")
+	for i := range segs {
+		segs[i] = url.PathEscape(segs[i])
+	}
+	return f.bucket + "/" + strings.Join(segs, "/")
+}
+
+func (f *s3FS) Chmod(name string, mode FileMode) error {
+	return f.updateMeta("chmod", name, func(meta map[string]*string) {
+		meta[s3MetaMode] = aws.String(strconv.FormatUint(uint64(mode&ModePerm), 8))
+	})
+}
+
+func (f *s3FS) Lchown(name, uid, gid string) error {
+	return f.updateMeta("lchown", name, func(meta map[string]*string) {
+		meta[s3MetaUID] = aws.String(uid)
+		meta[s3MetaGID] = aws.String(gid)
+	})
+}
+
+func (f *s3FS) Chtimes(name string, _ time.Time, mtime time.Time) error {
+	return f.updateMeta("chtimes", name, func(meta map[string]*string) {
+		meta[s3MetaMtime] = aws.String(strconv.FormatInt(mtime.UnixNano(), 10))
+	})
+}
+
+// updateMeta replaces the metadata of the object representing name, by
+// copying the object onto itself.
+func (f *s3FS) updateMeta(op, name string, fn func(map[string]*string)) error {
+	info, err := f.lstat(name)
+	if err != nil {
+		return &os.PathError{Op: op, Path: name, Err: err}
+	}
+	meta := info.meta
+	if meta == nil {
+		meta = make(map[string]*string)
+	}
+	fn(meta)
+
+	if info.implicit {
+		// There is no object to copy, so create the directory marker.
+		_, err = f.svc.PutObject(&s3.PutObjectInput{
+			Bucket:   aws.String(f.bucket),
+			Key:      aws.String(info.objKey),
+			Body:     strings.NewReader(""),
+			Metadata: meta,
+		})
+	} else {
+		_, err = f.svc.CopyObject(&s3.CopyObjectInput{
+			Bucket:            aws.String(f.bucket),
+			Key:               aws.String(info.objKey),
+			CopySource:        aws.String(f.copySource(info.objKey)),
+			Metadata:          meta,
+			MetadataDirective: aws.String(s3.MetadataDirectiveReplace),
+		})
+	}
+	if err != nil {
+		return &os.PathError{Op: op, Path: name, Err: s3Error(err)}
+	}
+	return nil
+}
+
+func (f *s3FS) Create(name string) (File, error) {
+	return f.OpenFile(name, OptReadWrite|OptCreate|OptTruncate, 0o666)
+}
+
+func (f *s3FS) CreateSymlink(target, name string) error {
+	key, err := f.key(name)
+	if err != nil {
+		return &os.PathError{Op: "symlink", Path: name, Err: err}
+	}
+	if _, err := f.lstat(name); err == nil {
+		return &os.PathError{Op: "symlink", Path: name, Err: ErrExist}
+	} else if !IsNotExist(err) {
+		return &os.PathError{Op: "symlink", Path: name, Err: err}
+	}
+	_, err = f.svc.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(f.bucket),
+		Key:    aws.String(key),
+		Body:   strings.NewReader(target),
+		Metadata: map[string]*string{
+			s3MetaType:  aws.String(s3TypeSymlink),
+			s3MetaMode:  aws.String("777"),
+			s3MetaMtime: aws.String(strconv.FormatInt(time.Now().UnixNano(), 10)),
+		},
+	})
+	if err != nil {
+		return &os.PathError{Op: "symlink", Path: name, Err: s3Error(err)}
+	}
+	return nil
+}
+
+func (f *s3FS) DirNames(name string) ([]string, error) {
+	key, err := f.key(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: err}
+	}
+	pfx := dirPrefix(key)
+
+	seen := make(map[string]struct{})
+	var names []string
+	err = f.svc.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket:    aws.String(f.bucket),
+		Prefix:    aws.String(pfx),
+		Delimiter: aws.String("/"),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		add := func(child string) {
+			child = strings.TrimSuffix(strings.TrimPrefix(child, pfx), "/")
+			if child == "" {
+				// The directory marker itself
+				return
+			}
+			if _, ok := seen[child]; !ok {
+				seen[child] = struct{}{}
+				names = append(names, child)
+			}
+		}
+		for _, obj := range page.Contents {
+			add(aws.StringValue(obj.Key))
+		}
+		for _, cp := range page.CommonPrefixes {
+			add(aws.StringValue(cp.Prefix))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: s3Error(err)}
+	}
+
+	if len(names) == 0 {
+		// Either an empty directory or no directory at all.
+		info, err := f.lstat(name)
+		if err != nil {
+			return nil, &os.PathError{Op: "readdir", Path: name, Err: err}
+		}
+		if !info.IsDir() {
+			return nil, &os.PathError{Op: "readdir", Path: name, Err: errNotADirectory}
+		}
+	}
+
+	return names, nil
+}
+
+var errNotADirectory = errors.New("not a directory")
+
+func (f *s3FS) Lstat(name string) (FileInfo, error) {
+	info, err := f.lstat(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "lstat", Path: name, Err: err}
+	}
+	return info, nil
+}
+
+// lstat looks for name as a file or symlink, a directory marker and an
+// implicit directory, in that order.
+func (f *s3FS) lstat(name string) (*s3FileInfo, error) {
+	key, err := f.key(name)
+	if err != nil {
+		return nil, err
+	}
+	base := filepath.Base(name)
+
+	if key == f.prefix {
+		// The root always is a directory, as long as the bucket exists.
+		// There need not be any objects below the prefix.
+		if _, err := f.svc.HeadBucket(&s3.HeadBucketInput{Bucket: aws.String(f.bucket)}); err != nil {
+			return nil, s3Error(err)
+		}
+		return &s3FileInfo{name: base, mode: FileMode(os.ModeDir | 0o777), implicit: true}, nil
+	}
+
+	out, err := f.svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(f.bucket),
+		Key:    aws.String(key),
+	})
+	if err == nil {
+		return newS3FileInfo(base, key, aws.Int64Value(out.ContentLength), aws.TimeValue(out.LastModified), out.Metadata, false), nil
+	} else if err = s3Error(err); !IsNotExist(err) {
+		return nil, err
+	}
+
+	out, err = f.svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(f.bucket),
+		Key:    aws.String(key + "/"),
+	})
+	if err == nil {
+		return newS3FileInfo(base, key+"/", 0, aws.TimeValue(out.LastModified), out.Metadata, true), nil
+	} else if err = s3Error(err); !IsNotExist(err) {
+		return nil, err
+	}
+
+	list, err := f.svc.ListObjectsV2(&s3.ListObjectsV2Input{
+		Bucket:  aws.String(f.bucket),
+		Prefix:  aws.String(key + "/"),
+		MaxKeys: aws.Int64(1),
+	})
+	if err != nil {
+		return nil, s3Error(err)
+	}
+	if len(list.Contents) > 0 {
+		return &s3FileInfo{name: base, objKey: key + "/", mode: FileMode(os.ModeDir | 0o755), implicit: true}, nil
+	}
+
+	return nil, ErrNotExist
+}
+
+func (f *s3FS) Mkdir(name string, perm FileMode) error {
+	if _, err := f.lstat(name); err == nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: ErrExist}
+	} else if !IsNotExist(err) {
+		return &os.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	if parent := filepath.Dir(name); parent != "." {
+		info, err := f.lstat(parent)
+		if err != nil {
+			return &os.PathError{Op: "mkdir", Path: name, Err: err}
+		}
+		if !info.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: name, Err: errNotADirectory}
+		}
+	}
+	return f.mkdir(name, perm)
+}
+
+func (f *s3FS) mkdir(name string, perm FileMode) error {
+	key, err := f.key(name)
+	if err != nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	if key == "" {
+		// The bucket root needs no marker.
+		return nil
+	}
+	_, err = f.svc.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(f.bucket),
+		Key:    aws.String(key + "/"),
+		Body:   strings.NewReader(""),
+		Metadata: map[string]*string{
+			s3MetaMode:  aws.String(strconv.FormatUint(uint64(perm&ModePerm), 8)),
+			s3MetaMtime: aws.String(strconv.FormatInt(time.Now().UnixNano(), 10)),
+		},
+	})
+	if err != nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: s3Error(err)}
+	}
+	return nil
+}
+
+func (f *s3FS) MkdirAll(name string, perm FileMode) error {
+	name, err := Canonicalize(name)
+	if err != nil {
+		return &os.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	if name == "." {
+		if _, err := f.lstat(name); err == nil {
+			return nil
+		}
+		return f.mkdir(name, perm)
+	}
+
+	cur := ""
+	for _, comp := range strings.Split(filepath.ToSlash(name), "/") {
+		cur = filepath.Join(cur, comp)
+		info, err := f.lstat(cur)
+		if err == nil {
+			if !info.IsDir() {
+				return &os.PathError{Op: "mkdir", Path: cur, Err: errNotADirectory}
+			}
+			continue
+		} else if !IsNotExist(err) {
+			return &os.PathError{Op: "mkdir", Path: cur, Err: err}
+		}
+		if err := f.mkdir(cur, perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *s3FS) Open(name string) (File, error) {
+	return f.OpenFile(name, OptReadOnly, 0)
+}
+
+func (f *s3FS) OpenFile(name string, flags int, mode FileMode) (File, error) {
+	key, err := f.key(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	info, err := f.lstat(name)
+	exists := err == nil
+	if err != nil && !IsNotExist(err) {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	switch {
+	case !exists && flags&OptCreate == 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: ErrNotExist}
+	case exists && flags&OptCreate != 0 && flags&OptExclusive != 0:
+		return nil, &os.PathError{Op: "open", Path: name, Err: ErrExist}
+	case exists && info.IsSymlink():
+		return nil, &os.PathError{Op: "open", Path: name, Err: errors.New("following symlink not supported")}
+	}
+
+	file := &s3File{
+		fs:   f,
+		name: name,
+		key:  key,
+	}
+	if exists {
+		file.info = *info
+	} else {
+		if parent := filepath.Dir(name); parent != "." {
+			if pinfo, err := f.lstat(parent); err != nil {
+				return nil, &os.PathError{Op: "open", Path: name, Err: err}
+			} else if !pinfo.IsDir() {
+				return nil, &os.PathError{Op: "open", Path: name, Err: errNotADirectory}
+			}
+		}
+		file.info = s3FileInfo{
+			name:  filepath.Base(name),
+			mode:  mode & ModePerm,
+			mtime: time.Now(),
+			meta: map[string]*string{
+				s3MetaMode: aws.String(strconv.FormatUint(uint64(mode&ModePerm), 8)),
+			},
+		}
+	}
+
+	if exists && info.IsDir() {
+		if flags&(OptWriteOnly|OptReadWrite) != 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+		}
+		// Opening a directory is allowed for the benefit of Sync() on
+		// it, but there's nothing to read.
+		return file, nil
+	}
+
+	if flags&(OptWriteOnly|OptReadWrite) == 0 && flags&OptCreate == 0 {
+		return file, nil
+	}
+
+	// Writable files are staged locally and uploaded on Close/Sync.
+	tmp, err := os.CreateTemp("", "syncthing-s3-*")
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	os.Remove(tmp.Name()) // Will disappear once closed, where supported
+	file.tmp = tmp
+	file.dirty = !exists
+
+	if exists && flags&OptTruncate == 0 && info.size > 0 {
+		out, err := f.svc.GetObject(&s3.GetObjectInput{
+			Bucket: aws.String(f.bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			tmp.Close()
+			return nil, &os.PathError{Op: "open", Path: name, Err: s3Error(err)}
+		}
+		_, err = io.Copy(tmp, out.Body)
+		out.Body.Close()
+		if err != nil {
+			tmp.Close()
+			return nil, &os.PathError{Op: "open", Path: name, Err: err}
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			tmp.Close()
+			return nil, &os.PathError{Op: "open", Path: name, Err: err}
+		}
+	} else if exists && info.size > 0 {
+		// Truncated
+		file.dirty = true
+	}
+	if flags&OptAppend != 0 {
+		if _, err := tmp.Seek(0, io.SeekEnd); err != nil {
+			tmp.Close()
+			return nil, &os.PathError{Op: "open", Path: name, Err: err}
+		}
+	}
+
+	return file, nil
+}
+
+func (f *s3FS) ReadSymlink(name string) (string, error) {
+	info, err := f.lstat(name)
+	if err != nil {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	if !info.IsSymlink() {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: errors.New("not a symlink")}
+	}
+	out, err := f.svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(f.bucket),
+		Key:    aws.String(info.objKey),
+	})
+	if err != nil {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: s3Error(err)}
+	}
+	defer out.Body.Close()
+	target, err := io.ReadAll(out.Body)
+	if err != nil {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	return string(target), nil
+}
+
+func (f *s3FS) Remove(name string) error {
+	info, err := f.lstat(name)
+	if err != nil {
+		return &os.PathError{Op: "remove", Path: name, Err: err}
+	}
+	if info.IsDir() {
+		children, err := f.DirNames(name)
+		if err != nil {
+			return err
+		}
+		if len(children) > 0 {
+			return &os.PathError{Op: "remove", Path: name, Err: errS3NotEmpty}
+		}
+		if info.implicit {
+			return nil
+		}
+	}
+	if err := f.deleteObject(info.objKey); err != nil {
+		return &os.PathError{Op: "remove", Path: name, Err: err}
+	}
+	return nil
+}
+
+func (f *s3FS) RemoveAll(name string) error {
+	key, err := f.key(name)
+	if err != nil {
+		return &os.PathError{Op: "removeall", Path: name, Err: err}
+	}
+	if key != "" {
+		if err := f.deleteObject(key); err != nil && !IsNotExist(err) {
+			return &os.PathError{Op: "removeall", Path: name, Err: err}
+		}
+	}
+	var keys []string
+	err = f.svc.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(f.bucket),
+		Prefix: aws.String(dirPrefix(key)),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.StringValue(obj.Key))
+		}
+		return true
+	})
+	if err != nil {
+		return &os.PathError{Op: "removeall", Path: name, Err: s3Error(err)}
+	}
+	for _, key := range keys {
+		if err := f.deleteObject(key); err != nil && !IsNotExist(err) {
+			return &os.PathError{Op: "removeall", Path: name, Err: err}
+		}
+	}
+	return nil
+}
+
+func (f *s3FS) deleteObject(key string) error {
+	_, err := f.svc.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(f.bucket),
+		Key:    aws.String(key),
+	})
+	return s3Error(err)
+}
+
+func (f *s3FS) copyObject(src, dst string) error {
+	_, err := f.svc.CopyObject(&s3.CopyObjectInput{
+		Bucket:            aws.String(f.bucket),
+		Key:               aws.String(dst),
+		CopySource:        aws.String(f.copySource(src)),
+		MetadataDirective: aws.String(s3.MetadataDirectiveCopy),
+	})
+	return s3Error(err)
+}
+
+func (f *s3FS) Rename(oldname, newname string) error {
+	oldInfo, err := f.lstat(oldname)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	newKey, err := f.key(newname)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	if newInfo, err := f.lstat(newname); err == nil && newInfo.IsDir() {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: errors.New("is a directory")}
+	}
+
+	if !oldInfo.IsDir() {
+		if err := f.copyObject(oldInfo.objKey, newKey); err != nil {
+			return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+		}
+		if err := f.deleteObject(oldInfo.objKey); err != nil {
+			return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+		}
+		return nil
+	}
+
+	// Moving a directory means moving everything below it, one object at
+	// a time.
+	oldPfx := oldInfo.objKey
+	newPfx := newKey + "/"
+	var keys []string
+	err = f.svc.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(f.bucket),
+		Prefix: aws.String(oldPfx),
+	}, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, obj := range page.Contents {
+			keys = append(keys, aws.StringValue(obj.Key))
+		}
+		return true
+	})
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: s3Error(err)}
+	}
+	for _, key := range keys {
+		if err := f.copyObject(key, newPfx+strings.TrimPrefix(key, oldPfx)); err != nil {
+			return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+		}
+	}
+	for _, key := range keys {
+		if err := f.deleteObject(key); err != nil {
+			return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+		}
+	}
+	return nil
+}
+
+func (f *s3FS) Stat(name string) (FileInfo, error) {
+	info, err := f.Lstat(name)
+	if err != nil || !info.IsSymlink() {
+		return info, err
+	}
+	target, err := f.ReadSymlink(name)
+	if err != nil {
+		return nil, err
+	}
+	if path.IsAbs(filepath.ToSlash(target)) {
+		// We can't resolve absolute targets, there's nothing outside the
+		// folder.
+		return nil, &os.PathError{Op: "stat", Path: name, Err: ErrNotExist}
+	}
+	return f.Lstat(filepath.Join(filepath.Dir(name), target))
+}
+
+func (*s3FS) SymlinksSupported() bool {
+	return true
+}
+
+func (*s3FS) Walk(_ string, _ WalkFunc) error {
+	return errors.New("not implemented")
+}
+
+func (f *s3FS) Watch(name string, ignore Matcher, ctx context.Context, ignorePerms bool) (<-chan Event, <-chan error, error) {
+	return pollWatch(f, name, ignore, ctx, ignorePerms, f.pollInterval)
+}
+
+func (*s3FS) Hide(_ string) error {
+	return nil
+}
+
+func (*s3FS) Unhide(_ string) error {
+	return nil
+}
+
+func (f *s3FS) Glob(pattern string) ([]string, error) {
+	dir := filepath.Dir(pattern)
+	file := filepath.Base(pattern)
+	names, err := f.DirNames(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, n := range names {
+		matched, err := filepath.Match(file, n)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			matches = append(matches, filepath.Join(dir, n))
+		}
+	}
+	return matches, nil
+}
+
+func (*s3FS) Roots() ([]string, error) {
+	return []string{"/"}, nil
+}
+
+func (*s3FS) Usage(_ string) (Usage, error) {
+	return Usage{}, errors.New("usage is not available for S3")
+}
+
+func (*s3FS) Type() FilesystemType {
+	return FilesystemTypeS3
+}
+
+func (f *s3FS) URI() string {
+	return f.uri
+}
+
+func (*s3FS) Options() []Option {
+	return nil
+}
+
+func (*s3FS) SameFile(fi1, fi2 FileInfo) bool {
+	return fi1.Name() == fi2.Name() && fi1.Size() == fi2.Size() && fi1.ModTime().Equal(fi2.ModTime()) && fi1.Mode() == fi2.Mode()
+}
+
+func (f *s3FS) PlatformData(name string, scanOwnership, scanXattrs bool, xattrFilter XattrFilter) (protocol.PlatformData, error) {
+	return unixPlatformData(f, name, f.userCache, f.groupCache, scanOwnership, scanXattrs, xattrFilter)
+}
+
+func (f *s3FS) GetXattr(name string, xattrFilter XattrFilter) ([]protocol.Xattr, error) {
+	info, err := f.lstat(name)
+	if err != nil {
+		return nil, fmt.Errorf("get xattr %s: %w", name, err)
+	}
+	attrs, err := decodeS3Xattrs(info.meta)
+	if err != nil {
+		return nil, fmt.Errorf("get xattr %s: %w", name, err)
+	}
+
+	res := make([]protocol.Xattr, 0, len(attrs))
+	var totSize int
+	for _, attr := range attrs {
+		if !xattrFilter.Permit(attr.Name) {
+			continue
+		}
+		if max := xattrFilter.GetMaxSingleEntrySize(); max > 0 && len(attr.Name)+len(attr.Value) > max {
+			continue
+		}
+		totSize += len(attr.Name) + len(attr.Value)
+		if max := xattrFilter.GetMaxTotalSize(); max > 0 && totSize > max {
+			continue
+		}
+		res = append(res, attr)
+	}
+	return res, nil
+}
+
+func (f *s3FS) SetXattr(name string, xattrs []protocol.Xattr, xattrFilter XattrFilter) error {
+	permitted := make([]protocol.Xattr, 0, len(xattrs))
+	for _, xa := range xattrs {
+		if xattrFilter.Permit(xa.Name) {
+			permitted = append(permitted, xa)
+		}
+	}
+	return f.updateMeta("set xattrs", name, func(meta map[string]*string) {
+		if len(permitted) == 0 {
+			delete(meta, s3MetaXattrs)
+			return
+		}
+		bs, _ := json.Marshal(permitted)
+		meta[s3MetaXattrs] = aws.String(base64.StdEncoding.EncodeToString(bs))
+	})
+}
+
+func decodeS3Xattrs(meta map[string]*string) ([]protocol.Xattr, error) {
+	val := s3MetaValue(meta, s3MetaXattrs)
+	if val == "" {
+		return nil, nil
+	}
+	bs, err := base64.StdEncoding.DecodeString(val)
+	if err != nil {
+		return nil, err
+	}
+	var attrs []protocol.Xattr
+	if err := json.Unmarshal(bs, &attrs); err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+func (*s3FS) underlying() (Filesystem, bool) {
+	return nil, false
+}
+
+// s3Error translates errors from the S3 API into their fs equivalents
+// where there is one.
+func s3Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) {
+		switch reqErr.StatusCode() {
+		case http.StatusNotFound:
+			return fmt.Errorf("%w (%s)", ErrNotExist, reqErr.Code())
+		case http.StatusForbidden:
+			return fmt.Errorf("%w (%s)", os.ErrPermission, reqErr.Code())
+		}
+	}
+	return err
+}
+
+// s3MetaValue returns the metadata value for key, tolerating servers that
+// don't canonicalise the case of metadata keys.
+func s3MetaValue(meta map[string]*string, key string) string {
+	if v, ok := meta[key]; ok {
+		return aws.StringValue(v)
+	}
+	for k, v := range meta {
+		if strings.EqualFold(k, key) {
+			return aws.StringValue(v)
+		}
+	}
+	return ""
+}
+
+// s3FileInfo is the result of a stat. For directories objKey is the key
+// of the directory marker (with a trailing slash), whether or not that
+// exists.
+type s3FileInfo struct {
+	name     string
+	objKey   string
+	size     int64
+	mode     FileMode
+	mtime    time.Time
+	uid      int
+	gid      int
+	meta     map[string]*string
+	implicit bool // directory without a marker object
+}
+
+func newS3FileInfo(name, key string, size int64, lastModified time.Time, meta map[string]*string, isDir bool) *s3FileInfo {
+	info := &s3FileInfo{
+		name:   name,
+		objKey: key,
+		size:   size,
+		mtime:  lastModified,
+		mode:   0o644,
+		meta:   make(map[string]*string, len(meta)),
+	}
+	for k, v := range meta {
+		// Normalise the keys so that we write back what we expect.
+		info.meta[http.CanonicalHeaderKey(k)] = v
+	}
+
+	if isDir {
+		info.mode = 0o755
+	}
+	if v := s3MetaValue(meta, s3MetaMode); v != "" {
+		if mode, err := strconv.ParseUint(v, 8, 32); err == nil {
+			info.mode = FileMode(mode) & ModePerm
+		}
+	}
+	if v := s3MetaValue(meta, s3MetaMtime); v != "" {
+		if ns, err := strconv.ParseInt(v, 10, 64); err == nil {
+			info.mtime = time.Unix(0, ns)
+		}
+	}
+	info.uid, _ = strconv.Atoi(s3MetaValue(meta, s3MetaUID))
+	info.gid, _ = strconv.Atoi(s3MetaValue(meta, s3MetaGID))
+
+	switch {
+	case isDir:
+		info.mode |= FileMode(os.ModeDir)
+		info.size = 0
+	case s3MetaValue(meta, s3MetaType) == s3TypeSymlink:
+		info.mode |= ModeSymlink
+	}
+	return info
+}
+
+func (f *s3FileInfo) Name() string {
+	return f.name
+}
+
+func (f *s3FileInfo) Mode() FileMode {
+	return f.mode
+}
+
+func (f *s3FileInfo) Size() int64 {
+	return f.size
+}
+
+func (f *s3FileInfo) ModTime() time.Time {
+	return f.mtime
+}
+
+func (f *s3FileInfo) IsDir() bool {
+	return f.mode&FileMode(os.ModeDir) != 0
+}
+
+func (f *s3FileInfo) IsRegular() bool {
+	return f.mode&ModeType == 0
+}
+
+func (f *s3FileInfo) IsSymlink() bool {
+	return f.mode&ModeSymlink != 0
+}
+
+func (f *s3FileInfo) Owner() int {
+	return f.uid
+}
+
+func (f *s3FileInfo) Group() int {
+	return f.gid
+}
+
+func (*s3FileInfo) Sys() interface{} {
+	return nil
+}
+
+func (*s3FileInfo) InodeChangeTime() time.Time {
+	return time.Time{}
+}
+
+// s3File is an open file. Files opened read only are read straight from
+// the object store using range requests; writable files have a local
+// temporary copy in tmp which replaces the object when the file is synced
+// or closed.
+type s3File struct {
+	fs   *s3FS
+	name string
+	key  string
+
+	mut    sync.Mutex
+	info   s3FileInfo
+	offset int64
+	tmp    *os.File
+	dirty  bool
+	closed bool
+}
+
+func (f *s3File) Close() error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	f.closed = true
+	if f.tmp == nil {
+		return nil
+	}
+	err := f.uploadLocked()
+	if cerr := f.tmp.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (f *s3File) Read(p []byte) (int, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if f.tmp != nil {
+		return f.tmp.Read(p)
+	}
+	n, err := f.readAtLocked(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *s3File) ReadAt(p []byte, offs int64) (int, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if f.tmp != nil {
+		return f.tmp.ReadAt(p, offs)
+	}
+	return f.readAtLocked(p, offs)
+}
+
+func (f *s3File) readAtLocked(p []byte, offs int64) (int, error) {
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	if f.info.IsDir() {
+		return 0, errors.New("is a directory")
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if offs >= f.info.size {
+		return 0, io.EOF
+	}
+	end := offs + int64(len(p)) - 1
+	if end >= f.info.size {
+		end = f.info.size - 1
+	}
+
+	out, err := f.fs.svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(f.fs.bucket),
+		Key:    aws.String(f.key),
+		Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offs, end)),
+	})
+	if err != nil {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: s3Error(err)}
+	}
+	defer out.Body.Close()
+
+	n, err := io.ReadFull(out.Body, p[:end-offs+1])
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *s3File) Seek(offset int64, whence int) (int64, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if f.tmp != nil {
+		return f.tmp.Seek(offset, whence)
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.size
+	default:
+		return f.offset, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return f.offset, errors.New("seek before start")
+	}
+	f.offset = offset
+	return f.offset, nil
+}
+
+func (f *s3File) Write(p []byte) (int, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if f.tmp == nil {
+		return 0, &os.PathError{Op: "write", Path: f.name, Err: os.ErrPermission}
+	}
+	f.dirty = true
+	return f.tmp.Write(p)
+}
+
+func (f *s3File) WriteAt(p []byte, off int64) (int, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if f.tmp == nil {
+		return 0, &os.PathError{Op: "write", Path: f.name, Err: os.ErrPermission}
+	}
+	f.dirty = true
+	return f.tmp.WriteAt(p, off)
+}
+
+func (f *s3File) Name() string {
+	return f.name
+}
+
+func (f *s3File) Truncate(size int64) error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if f.tmp == nil {
+		return &os.PathError{Op: "truncate", Path: f.name, Err: os.ErrPermission}
+	}
+	f.dirty = true
+	return f.tmp.Truncate(size)
+}
+
+func (f *s3File) Stat() (FileInfo, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	info := f.info
+	if f.tmp != nil {
+		st, err := f.tmp.Stat()
+		if err != nil {
+			return nil, err
+		}
+		info.size = st.Size()
+	}
+	return &info, nil
+}
+
+func (f *s3File) Sync() error {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if f.tmp == nil {
+		return nil
+	}
+	return f.uploadLocked()
+}
+
+// uploadLocked replaces the object with the contents of the local copy,
+// if it has changed. The modification time is bumped, as on a local
+// filesystem; other metadata is kept.
+func (f *s3File) uploadLocked() error {
+	if !f.dirty {
+		return nil
+	}
+
+	meta := make(map[string]*string, len(f.info.meta)+1)
+	for k, v := range f.info.meta {
+		meta[k] = v
+	}
+	now := time.Now()
+	meta[s3MetaMtime] = aws.String(strconv.FormatInt(now.UnixNano(), 10))
+
+	// The uploader reads through the file from its current position, and
+	// we don't want to disturb the position of the caller.
+	st, err := f.tmp.Stat()
+	if err != nil {
+		return &os.PathError{Op: "sync", Path: f.name, Err: err}
+	}
+	sr := io.NewSectionReader(f.tmp, 0, st.Size())
+	_, err = f.fs.uploader.Upload(&s3manager.UploadInput{
+		Bucket:   aws.String(f.fs.bucket),
+		Key:      aws.String(f.key),
+		Body:     sr,
+		Metadata: meta,
+	})
+	if err != nil {
+		return &os.PathError{Op: "sync", Path: f.name, Err: s3Error(err)}
+	}
+	f.info.meta = meta
+	f.info.mtime = now
+	f.dirty = false
+	return nil
+}
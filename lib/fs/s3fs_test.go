@@ -0,0 +1,496 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestS3FS(t *testing.T) {
+	fs := newS3TestFilesystem(t)
+
+	if err := fs.MkdirAll("dira/dirb", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := fs.Stat("dira/dirb"); err != nil {
+		t.Fatal(err)
+	} else if !info.IsDir() {
+		t.Fatal("expected a directory")
+	}
+	if err := fs.Mkdir("dira/dirb", 0o755); !IsExist(err) {
+		t.Fatal("expected exists error, got", err)
+	}
+	if err := fs.Mkdir("nonexistent/dir", 0o755); !IsNotExist(err) {
+		t.Fatal("expected not exists error, got", err)
+	}
+
+	// Write a file and read it back
+
+	fd, err := fs.Create("dira/dirb/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.WriteAt([]byte(", world"), 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := fd.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := fs.Lstat("dira/dirb/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name() != "test" || info.Size() != 12 || !info.IsRegular() {
+		t.Errorf("unexpected file info: %v %v %v", info.Name(), info.Size(), info.Mode())
+	}
+
+	fd, err = fs.Open("dira/dirb/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if n, err := fd.ReadAt(buf, 7); err != nil || string(buf[:n]) != "world" {
+		t.Errorf("ReadAt: %q, %v", buf[:n], err)
+	}
+	if n, err := fd.ReadAt(buf, 10); err != io.EOF || string(buf[:n]) != "ld" {
+		t.Errorf("short ReadAt: %q, %v", buf[:n], err)
+	}
+	all, err := io.ReadAll(fd)
+	if err != nil || string(all) != "hello, world" {
+		t.Errorf("ReadAll: %q, %v", all, err)
+	}
+	fd.Close()
+
+	// Modify in place, keeping existing contents
+
+	fd, err = fs.OpenFile("dira/dirb/test", OptReadWrite, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.WriteAt([]byte("J"), 0); err != nil {
+		t.Fatal(err)
+	}
+	fd.Close()
+	if all, err := readS3File(fs, "dira/dirb/test"); err != nil || string(all) != "Jello, world" {
+		t.Errorf("after update: %q, %v", all, err)
+	}
+
+	if _, err := fs.OpenFile("dira/dirb/test", OptCreate|OptExclusive|OptWriteOnly, 0o644); !IsExist(err) {
+		t.Error("expected exists error, got", err)
+	}
+
+	// Metadata
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	if err := fs.Chtimes("dira/dirb/test", mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Chmod("dira/dirb/test", 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Lchown("dira/dirb/test", "1000", "1001"); err != nil {
+		t.Fatal(err)
+	}
+	info, err = fs.Lstat("dira/dirb/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("wrong mtime %v", info.ModTime())
+	}
+	if info.Mode()&ModePerm != 0o600 {
+		t.Errorf("wrong mode %v", info.Mode())
+	}
+	if info.Owner() != 1000 || info.Group() != 1001 {
+		t.Errorf("wrong ownership %d:%d", info.Owner(), info.Group())
+	}
+	if info.Size() != 12 {
+		t.Errorf("metadata change affected size: %d", info.Size())
+	}
+
+	xattrs := []protocol.Xattr{{Name: "user.foo", Value: []byte("bar")}, {Name: "user.baz", Value: []byte{0, 1, 2}}}
+	if err := fs.SetXattr("dira/dirb/test", xattrs, noopXattrFilter{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := fs.GetXattr("dira/dirb/test", noopXattrFilter{}); err != nil {
+		t.Fatal(err)
+	} else if len(got) != 2 || got[0].Name != "user.foo" || !bytes.Equal(got[1].Value, []byte{0, 1, 2}) {
+		t.Errorf("unexpected xattrs %v", got)
+	}
+
+	// Symlinks
+
+	if err := fs.CreateSymlink("dirb/test", "dira/link"); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := fs.Lstat("dira/link"); err != nil || !info.IsSymlink() {
+		t.Errorf("expected symlink: %v, %v", info, err)
+	}
+	if target, err := fs.ReadSymlink("dira/link"); err != nil || target != "dirb/test" {
+		t.Errorf("ReadSymlink: %q, %v", target, err)
+	}
+	if info, err := fs.Stat("dira/link"); err != nil || !info.IsRegular() || info.Size() != 12 {
+		t.Errorf("Stat through symlink: %v, %v", info, err)
+	}
+
+	// Listing
+
+	names, err := fs.DirNames("dira")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "dirb,link" {
+		t.Errorf("unexpected names %v", names)
+	}
+	if names, err := fs.DirNames("."); err != nil || strings.Join(names, ",") != "dira" {
+		t.Errorf("unexpected root names %v, %v", names, err)
+	}
+	if _, err := fs.DirNames("nonexistent"); !IsNotExist(err) {
+		t.Error("expected not exists error, got", err)
+	}
+
+	// Renames
+
+	if err := fs.Rename("dira/dirb/test", "dira/dirb/test2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Lstat("dira/dirb/test"); !IsNotExist(err) {
+		t.Error("expected old name to be gone, got", err)
+	}
+	if info, err := fs.Lstat("dira/dirb/test2"); err != nil || !info.ModTime().Equal(mtime) {
+		t.Errorf("rename lost metadata: %v, %v", info, err)
+	}
+
+	if err := fs.Rename("dira", "dirc"); err != nil {
+		t.Fatal(err)
+	}
+	if all, err := readS3File(fs, "dirc/dirb/test2"); err != nil || string(all) != "Jello, world" {
+		t.Errorf("after dir rename: %q, %v", all, err)
+	}
+	if _, err := fs.Lstat("dira"); !IsNotExist(err) {
+		t.Error("expected old dir to be gone, got", err)
+	}
+
+	// Removal
+
+	if err := fs.Remove("dirc/dirb"); err == nil {
+		t.Error("expected error removing non-empty directory")
+	}
+	if err := fs.Remove("dirc/dirb/test2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Remove("dirc/dirb"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.RemoveAll("dirc"); err != nil {
+		t.Fatal(err)
+	}
+	if names, err := fs.DirNames("."); err != nil || len(names) != 0 {
+		t.Errorf("expected empty root, got %v, %v", names, err)
+	}
+	if err := fs.RemoveAll("nonexistent"); err != nil {
+		t.Error("RemoveAll of nonexistent:", err)
+	}
+}
+
+func TestS3FSImplicitDirectories(t *testing.T) {
+	// Objects uploaded by other tools don't come with directory markers.
+
+	srv := newFakeS3Server()
+	srv.put("bucket/prefix/a/b/file", []byte("data"), nil)
+	fs := newS3TestFilesystemOnServer(t, srv)
+
+	info, err := fs.Lstat("a/b")
+	if err != nil || !info.IsDir() {
+		t.Fatalf("expected implicit directory: %v, %v", info, err)
+	}
+	if err := fs.Chmod("a/b", 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := fs.Lstat("a/b"); err != nil || info.Mode()&ModePerm != 0o700 {
+		t.Errorf("chmod on implicit dir: %v, %v", info, err)
+	}
+	if names, err := fs.DirNames("a"); err != nil || strings.Join(names, ",") != "b" {
+		t.Errorf("unexpected names %v, %v", names, err)
+	}
+}
+
+func TestS3FSWatch(t *testing.T) {
+	srv := newFakeS3Server()
+	fs := newS3TestFilesystemOnServer(t, srv)
+	fs.pollInterval = 10 * time.Millisecond
+
+	if err := WriteFile(fs, "existing", []byte("foo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	evs, _, err := fs.Watch(".", nil, ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteFile(fs, "new", []byte("bar"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Remove("existing"); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make(map[string]EventType)
+	timeout := time.After(10 * time.Second)
+	for len(got) < 2 {
+		select {
+		case ev := <-evs:
+			got[ev.Name] = ev.Type
+		case <-timeout:
+			t.Fatal("timed out waiting for events, got", got)
+		}
+	}
+	if got["new"] != NonRemove || got["existing"] != Remove {
+		t.Error("unexpected events", got)
+	}
+}
+
+func TestS3FSKeysInPath(t *testing.T) {
+	// Keys in the folder path would end up in the GUI and the logs.
+	for _, uri := range []string{"bucket?accesskey=key&secretkey=secret", "bucket?secretkey=secret"} {
+		if _, err := newS3Filesystem(uri); err == nil {
+			t.Errorf("expected an error for %s", uri)
+		}
+	}
+}
+
+func readS3File(fs Filesystem, name string) ([]byte, error) {
+	fd, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	return io.ReadAll(fd)
+}
+
+func newS3TestFilesystem(t *testing.T) *s3FS {
+	t.Helper()
+	return newS3TestFilesystemOnServer(t, newFakeS3Server())
+}
+
+func newS3TestFilesystemOnServer(t *testing.T, srv *fakeS3Server) *s3FS {
+	t.Helper()
+	hs := httptest.NewServer(srv)
+	t.Cleanup(hs.Close)
+	creds := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(creds, []byte("[syncthing]\naws_access_key_id = key\naws_secret_access_key = secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fs, err := newS3Filesystem("bucket/prefix?endpoint=" + url.QueryEscape(hs.URL) + "&credentials=" + url.QueryEscape(creds) + "&profile=syncthing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+type noopXattrFilter struct{}
+
+func (noopXattrFilter) Permit(string) bool         { return true }
+func (noopXattrFilter) GetMaxSingleEntrySize() int { return 0 }
+func (noopXattrFilter) GetMaxTotalSize() int       { return 0 }
+
+// fakeS3Server implements just enough of the S3 API, path style, for the
+// S3 filesystem. There is a single bucket called "bucket".
+type fakeS3Server struct {
+	mut     sync.Mutex
+	objects map[string]fakeS3Object // "bucket/key" -> object
+}
+
+type fakeS3Object struct {
+	data     []byte
+	meta     map[string]string
+	modified time.Time
+}
+
+func newFakeS3Server() *fakeS3Server {
+	return &fakeS3Server{objects: make(map[string]fakeS3Object)}
+}
+
+func (s *fakeS3Server) put(path string, data []byte, meta map[string]string) {
+	s.mut.Lock()
+	s.objects[path] = fakeS3Object{data: data, meta: meta, modified: time.Now()}
+	s.mut.Unlock()
+}
+
+func (s *fakeS3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket != "bucket" {
+		fakeS3Error(w, r, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+	path := bucket + "/" + key
+
+	switch {
+	case key == "" && r.Method == http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+
+	case key == "" && r.Method == http.MethodGet:
+		s.list(w, r)
+
+	case r.Method == http.MethodHead, r.Method == http.MethodGet:
+		obj, ok := s.objects[path]
+		if !ok {
+			fakeS3Error(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		for k, v := range obj.meta {
+			w.Header().Set("X-Amz-Meta-"+k, v)
+		}
+		w.Header().Set("Last-Modified", obj.modified.UTC().Format(http.TimeFormat))
+		data := obj.data
+		status := http.StatusOK
+		if rng := r.Header.Get("Range"); rng != "" {
+			var start, end int
+			fmt.Sscanf(rng, "bytes=%d-%d", &start, &end)
+			if end >= len(data) {
+				end = len(data) - 1
+			}
+			data = data[start : end+1]
+			status = http.StatusPartialContent
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(status)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+
+	case r.Method == http.MethodPut:
+		obj := fakeS3Object{meta: make(map[string]string), modified: time.Now()}
+		if src := r.Header.Get("X-Amz-Copy-Source"); src != "" {
+			src, _ = url.PathUnescape(src)
+			orig, ok := s.objects[strings.TrimPrefix(src, "/")]
+			if !ok {
+				fakeS3Error(w, r, http.StatusNotFound, "NoSuchKey")
+				return
+			}
+			obj.data = orig.data
+			obj.meta = orig.meta
+			if r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" {
+				obj.meta = fakeS3Meta(r.Header)
+			}
+			s.objects[path] = obj
+			fmt.Fprintf(w, "<CopyObjectResult><ETag>\"x\"</ETag></CopyObjectResult>")
+			return
+		}
+		obj.data, _ = io.ReadAll(r.Body)
+		obj.meta = fakeS3Meta(r.Header)
+		s.objects[path] = obj
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == http.MethodDelete:
+		delete(s.objects, path)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		fakeS3Error(w, r, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (s *fakeS3Server) list(w http.ResponseWriter, r *http.Request) {
+	type content struct {
+		Key  string
+		Size int
+	}
+	type commonPrefix struct {
+		Prefix string
+	}
+	var res struct {
+		XMLName        xml.Name `xml:"ListBucketResult"`
+		Contents       []content
+		CommonPrefixes []commonPrefix
+		IsTruncated    bool
+		KeyCount       int
+	}
+
+	q := r.URL.Query()
+	prefix := q.Get("prefix")
+	delim := q.Get("delimiter")
+	maxKeys, _ := strconv.Atoi(q.Get("max-keys"))
+
+	var keys []string
+	for path := range s.objects {
+		keys = append(keys, strings.TrimPrefix(path, "bucket/"))
+	}
+	sort.Strings(keys)
+
+	seenPrefixes := make(map[string]bool)
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if maxKeys > 0 && res.KeyCount >= maxKeys {
+			break
+		}
+		if delim != "" {
+			if i := strings.Index(key[len(prefix):], delim); i >= 0 {
+				cp := key[:len(prefix)+i+len(delim)]
+				if !seenPrefixes[cp] {
+					seenPrefixes[cp] = true
+					res.CommonPrefixes = append(res.CommonPrefixes, commonPrefix{cp})
+					res.KeyCount++
+				}
+				continue
+			}
+		}
+		res.Contents = append(res.Contents, content{key, len(s.objects["bucket/"+key].data)})
+		res.KeyCount++
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(res)
+}
+
+func fakeS3Meta(h http.Header) map[string]string {
+	meta := make(map[string]string)
+	for k := range h {
+		if strings.HasPrefix(k, "X-Amz-Meta-") {
+			meta[strings.TrimPrefix(k, "X-Amz-Meta-")] = h.Get(k)
+		}
+	}
+	return meta
+}
+
+func fakeS3Error(w http.ResponseWriter, r *http.Request, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, code)
+	}
+}
@@ -0,0 +1,595 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+const FilesystemTypeSFTP FilesystemType = "sftp"
+
+func init() {
+	RegisterFilesystemType(FilesystemTypeSFTP, func(root string, opts ...Option) (Filesystem, error) {
+		return newSFTPFilesystem(root, opts...)
+	})
+}
+
+const sftpDialTimeout = 30 * time.Second
+
+// sftpFS is a filesystem on a remote host, reached over SSH. The folder
+// path is of the form
+//
+//	[user@]host[:port]/path[?param=value&...]
+//
+// where path is relative to the login directory unless it starts with a
+// second slash (host//srv/data). The following parameters are recognised:
+//
+//	identity=file      private key file for public key authentication
+//	passwordfile=file  file holding the password, for password authentication
+//	knownhosts=file    known_hosts file to verify the server against (default ~/.ssh/known_hosts)
+//	hostkey=fp         expected host key fingerprint (SHA256:...), instead of a known_hosts file
+//	pollinterval=d     how often Watch looks for changes, in time.ParseDuration format
+//
+// The folder path is shown in the GUI, logs and elsewhere, so it can't
+// hold the password itself.
+//
+// The connection is established on first use and re-established if it's
+// lost. What is supported (symlinks, atomic renames, disk usage) depends
+// on the server; OpenSSH supports all of it. There is no change
+// notification over SFTP, so Watch falls back to polling.
+type sftpFS struct {
+	uri          string
+	root         string
+	addr         string
+	config       *ssh.ClientConfig
+	pollInterval time.Duration
+	userCache    *userCache
+	groupCache   *groupCache
+
+	mut    sync.Mutex
+	conn   *ssh.Client
+	client *sftp.Client
+}
+
+func newSFTPFilesystem(rootURI string, _ ...Option) (*sftpFS, error) {
+	uri, err := url.Parse("sftp://" + rootURI)
+	if err != nil {
+		return nil, fmt.Errorf("parsing SFTP folder path: %w", err)
+	}
+	params := uri.Query()
+
+	if uri.Hostname() == "" {
+		return nil, errors.New("SFTP folder path must start with a host name")
+	}
+	port := uri.Port()
+	if port == "" {
+		port = "22"
+	}
+
+	username := uri.User.Username()
+	if username == "" {
+		if cur, err := user.Current(); err == nil {
+			username = cur.Username
+		}
+	}
+
+	if params.Has("password") {
+		return nil, errors.New("SFTP password can't be given in the folder path; use an identity or password file")
+	}
+	var auths []ssh.AuthMethod
+	if identity := params.Get("identity"); identity != "" {
+		identity, _ = ExpandTilde(identity)
+		bs, err := os.ReadFile(identity)
+		if err != nil {
+			return nil, fmt.Errorf("reading SFTP identity: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(bs)
+		if err != nil {
+			return nil, fmt.Errorf("parsing SFTP identity: %w", err)
+		}
+		auths = append(auths, ssh.PublicKeys(signer))
+	}
+	if pwFile := params.Get("passwordfile"); pwFile != "" {
+		pwFile, _ = ExpandTilde(pwFile)
+		bs, err := os.ReadFile(pwFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading SFTP password: %w", err)
+		}
+		auths = append(auths, ssh.Password(strings.TrimRight(string(bs), "\r\n")))
+	}
+	if len(auths) == 0 {
+		return nil, errors.New("SFTP folder path must specify an identity or password file")
+	}
+
+	hostKeyCallback, err := sftpHostKeyCallback(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// "host/path" is relative to the login directory, "host//path" is
+	// absolute.
+	root := strings.TrimPrefix(uri.Path, "/")
+	if root == "" {
+		root = "."
+	}
+	root = path.Clean(root)
+
+	pollInterval, _ := time.ParseDuration(params.Get("pollinterval"))
+
+	return &sftpFS{
+		uri:  rootURI,
+		root: root,
+		addr: net.JoinHostPort(uri.Hostname(), port),
+		config: &ssh.ClientConfig{
+			User:            username,
+			Auth:            auths,
+			HostKeyCallback: hostKeyCallback,
+			Timeout:         sftpDialTimeout,
+		},
+		pollInterval: pollInterval,
+		userCache:    newValueCache(time.Hour, user.LookupId),
+		groupCache:   newValueCache(time.Hour, user.LookupGroupId),
+	}, nil
+}
+
+func sftpHostKeyCallback(params url.Values) (ssh.HostKeyCallback, error) {
+	if fp := params.Get("hostkey"); fp != "" {
+		// The base64 fingerprint may contain plus signs, which end up as
+		// spaces unless the user remembered to escape them.
+		fp = strings.ReplaceAll(fp, " ", "+")
+		return func(_ string, _ net.Addr, key ssh.PublicKey) error {
+			if got := ssh.FingerprintSHA256(key); got != fp {
+				return fmt.Errorf("host key mismatch: got %s, expected %s", got, fp)
+			}
+			return nil
+		}, nil
+	}
+
+	file := params.Get("knownhosts")
+	if file == "" {
+		file = "~/.ssh/known_hosts"
+	}
+	file, _ = ExpandTilde(file)
+	cb, err := knownhosts.New(file)
+	if err != nil {
+		return nil, fmt.Errorf("loading SFTP known hosts: %w", err)
+	}
+	return cb, nil
+}
+
+// sftp returns a connected client, connecting if necessary.
+func (f *sftpFS) sftp() (*sftp.Client, error) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	if f.client != nil {
+		return f.client, nil
+	}
+
+	conn, err := ssh.Dial("tcp", f.addr, f.config)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", f.addr, err)
+	}
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("starting SFTP session on %s: %w", f.addr, err)
+	}
+	l.Debugln(f.Type(), f.URI(), "connected to", f.addr)
+
+	f.conn = conn
+	f.client = client
+	go func() {
+		// Forget the connection once it's gone, so that the next
+		// operation reconnects.
+		err := client.Wait()
+		l.Debugln(f.Type(), f.URI(), "disconnected:", err)
+		f.mut.Lock()
+		if f.client == client {
+			f.client = nil
+			f.conn = nil
+		}
+		f.mut.Unlock()
+		conn.Close()
+	}()
+	return client, nil
+}
+
+// rooted returns the remote path for the given relative path, making sure
+// it doesn't escape the root.
+func (f *sftpFS) rooted(name string) (string, error) {
+	name, err := Canonicalize(name)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(f.root, filepath.ToSlash(name)), nil
+}
+
+func (f *sftpFS) unrooted(remote string) string {
+	rel := strings.TrimPrefix(strings.TrimPrefix(remote, f.root), "/")
+	if rel == "" {
+		return "."
+	}
+	return filepath.FromSlash(rel)
+}
+
+// do runs fn with a connected client and the rooted name.
+func (f *sftpFS) do(name string, fn func(*sftp.Client, string) error) error {
+	remote, err := f.rooted(name)
+	if err != nil {
+		return err
+	}
+	client, err := f.sftp()
+	if err != nil {
+		return err
+	}
+	return sftpError(fn(client, remote))
+}
+
+func (f *sftpFS) Chmod(name string, mode FileMode) error {
+	return f.do(name, func(c *sftp.Client, p string) error {
+		return c.Chmod(p, os.FileMode(mode))
+	})
+}
+
+func (f *sftpFS) Lchown(name, uid, gid string) error {
+	nuid, err := strconv.Atoi(uid)
+	if err != nil {
+		return err
+	}
+	ngid, err := strconv.Atoi(gid)
+	if err != nil {
+		return err
+	}
+	return f.do(name, func(c *sftp.Client, p string) error {
+		// SFTP has no lchown; changing the owner of a symlink would
+		// change the owner of its target instead.
+		if info, err := c.Lstat(p); err != nil {
+			return err
+		} else if info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+		return c.Chown(p, nuid, ngid)
+	})
+}
+
+func (f *sftpFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return f.do(name, func(c *sftp.Client, p string) error {
+		return c.Chtimes(p, atime, mtime)
+	})
+}
+
+func (f *sftpFS) Create(name string) (File, error) {
+	return f.OpenFile(name, OptReadWrite|OptCreate|OptTruncate, 0o666)
+}
+
+func (f *sftpFS) CreateSymlink(target, name string) error {
+	return f.do(name, func(c *sftp.Client, p string) error {
+		return c.Symlink(filepath.ToSlash(target), p)
+	})
+}
+
+func (f *sftpFS) DirNames(name string) ([]string, error) {
+	var names []string
+	err := f.do(name, func(c *sftp.Client, p string) error {
+		infos, err := c.ReadDir(p)
+		if err != nil {
+			return err
+		}
+		names = make([]string, len(infos))
+		for i, info := range infos {
+			names[i] = info.Name()
+		}
+		return nil
+	})
+	return names, err
+}
+
+func (f *sftpFS) Lstat(name string) (FileInfo, error) {
+	var info FileInfo
+	err := f.do(name, func(c *sftp.Client, p string) error {
+		fi, err := c.Lstat(p)
+		if err != nil {
+			return err
+		}
+		info = sftpFileInfo{fi}
+		return nil
+	})
+	return info, err
+}
+
+func (f *sftpFS) Mkdir(name string, perm FileMode) error {
+	return f.do(name, func(c *sftp.Client, p string) error {
+		if err := c.Mkdir(p); err != nil {
+			return err
+		}
+		return c.Chmod(p, os.FileMode(perm))
+	})
+}
+
+func (f *sftpFS) MkdirAll(name string, perm FileMode) error {
+	return f.do(name, func(c *sftp.Client, p string) error {
+		if err := c.MkdirAll(p); err != nil {
+			return err
+		}
+		return c.Chmod(p, os.FileMode(perm))
+	})
+}
+
+func (f *sftpFS) Open(name string) (File, error) {
+	return f.OpenFile(name, OptReadOnly, 0)
+}
+
+func (f *sftpFS) OpenFile(name string, flags int, mode FileMode) (File, error) {
+	var file File
+	err := f.do(name, func(c *sftp.Client, p string) error {
+		fd, err := c.OpenFile(p, flags)
+		if err != nil {
+			return err
+		}
+		if flags&OptCreate != 0 {
+			// There's no way to pass the mode when creating the file, so
+			// set it afterwards. Errors are ignored as in os.OpenFile the
+			// mode is subject to the umask anyway.
+			_ = fd.Chmod(os.FileMode(mode))
+		}
+		file = &sftpFile{File: fd, name: name}
+		return nil
+	})
+	return file, err
+}
+
+func (f *sftpFS) ReadSymlink(name string) (string, error) {
+	var target string
+	err := f.do(name, func(c *sftp.Client, p string) error {
+		var err error
+		target, err = c.ReadLink(p)
+		return err
+	})
+	return filepath.FromSlash(target), err
+}
+
+func (f *sftpFS) Remove(name string) error {
+	return f.do(name, func(c *sftp.Client, p string) error {
+		return c.Remove(p)
+	})
+}
+
+func (f *sftpFS) RemoveAll(name string) error {
+	return f.do(name, func(c *sftp.Client, p string) error {
+		if _, err := c.Lstat(p); errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return c.RemoveAll(p)
+	})
+}
+
+func (f *sftpFS) Rename(oldname, newname string) error {
+	newpath, err := f.rooted(newname)
+	if err != nil {
+		return err
+	}
+	return f.do(oldname, func(c *sftp.Client, oldpath string) error {
+		if _, ok := c.HasExtension("posix-rename@openssh.com"); ok {
+			return c.PosixRename(oldpath, newpath)
+		}
+		// Plain SFTP rename refuses to overwrite the target, so we have
+		// to remove it first, losing atomicity.
+		if info, err := c.Lstat(newpath); err == nil && !info.IsDir() {
+			if err := c.Remove(newpath); err != nil {
+				return err
+			}
+		}
+		return c.Rename(oldpath, newpath)
+	})
+}
+
+func (f *sftpFS) Stat(name string) (FileInfo, error) {
+	var info FileInfo
+	err := f.do(name, func(c *sftp.Client, p string) error {
+		fi, err := c.Stat(p)
+		if err != nil {
+			return err
+		}
+		info = sftpFileInfo{fi}
+		return nil
+	})
+	return info, err
+}
+
+func (*sftpFS) SymlinksSupported() bool {
+	return true
+}
+
+func (*sftpFS) Walk(_ string, _ WalkFunc) error {
+	return errors.New("not implemented")
+}
+
+func (f *sftpFS) Watch(name string, ignore Matcher, ctx context.Context, ignorePerms bool) (<-chan Event, <-chan error, error) {
+	return pollWatch(f, name, ignore, ctx, ignorePerms, f.pollInterval)
+}
+
+func (*sftpFS) Hide(_ string) error {
+	return nil
+}
+
+func (*sftpFS) Unhide(_ string) error {
+	return nil
+}
+
+func (f *sftpFS) Glob(pattern string) ([]string, error) {
+	var matches []string
+	err := f.do(pattern, func(c *sftp.Client, p string) error {
+		remote, err := c.Glob(p)
+		if err != nil {
+			return err
+		}
+		matches = make([]string, len(remote))
+		for i, m := range remote {
+			matches[i] = f.unrooted(m)
+		}
+		return nil
+	})
+	return matches, err
+}
+
+func (*sftpFS) Roots() ([]string, error) {
+	return []string{"/"}, nil
+}
+
+func (f *sftpFS) Usage(name string) (Usage, error) {
+	var usage Usage
+	err := f.do(name, func(c *sftp.Client, p string) error {
+		if _, ok := c.HasExtension("statvfs@openssh.com"); !ok {
+			return errors.New("usage is not supported by the SFTP server")
+		}
+		st, err := c.StatVFS(p)
+		if err != nil {
+			return err
+		}
+		usage = Usage{
+			Free:  st.FreeSpace(),
+			Total: st.TotalSpace(),
+		}
+		return nil
+	})
+	return usage, err
+}
+
+func (*sftpFS) Type() FilesystemType {
+	return FilesystemTypeSFTP
+}
+
+func (f *sftpFS) URI() string {
+	return f.uri
+}
+
+func (*sftpFS) Options() []Option {
+	return nil
+}
+
+func (*sftpFS) SameFile(fi1, fi2 FileInfo) bool {
+	// SFTP doesn't expose inode numbers, so this is a best effort.
+	return fi1.Name() == fi2.Name() && fi1.Size() == fi2.Size() && fi1.ModTime().Equal(fi2.ModTime()) && fi1.Mode() == fi2.Mode()
+}
+
+func (f *sftpFS) PlatformData(name string, scanOwnership, scanXattrs bool, xattrFilter XattrFilter) (protocol.PlatformData, error) {
+	// Extended attributes aren't available over SFTP.
+	return unixPlatformData(f, name, f.userCache, f.groupCache, scanOwnership, false, xattrFilter)
+}
+
+func (*sftpFS) GetXattr(_ string, _ XattrFilter) ([]protocol.Xattr, error) {
+	return nil, ErrXattrsNotSupported
+}
+
+func (*sftpFS) SetXattr(_ string, _ []protocol.Xattr, _ XattrFilter) error {
+	return ErrXattrsNotSupported
+}
+
+func (*sftpFS) underlying() (Filesystem, bool) {
+	return nil, false
+}
+
+// sftpError translates SFTP status errors into their os equivalents, so
+// that IsNotExist and friends work.
+func sftpError(err error) error {
+	var st *sftp.StatusError
+	if !errors.As(err, &st) {
+		return err
+	}
+	switch st.FxCode() {
+	case sftp.ErrSSHFxNoSuchFile:
+		return fmt.Errorf("%w: %w", ErrNotExist, err)
+	case sftp.ErrSSHFxPermissionDenied:
+		return fmt.Errorf("%w: %w", os.ErrPermission, err)
+	}
+	return err
+}
+
+// sftpFileInfo implements FileInfo on top of the os.FileInfo returned by
+// the SFTP client.
+type sftpFileInfo struct {
+	os.FileInfo
+}
+
+func (e sftpFileInfo) Mode() FileMode {
+	return FileMode(e.FileInfo.Mode())
+}
+
+func (e sftpFileInfo) IsRegular() bool {
+	return e.FileInfo.Mode()&os.ModeType == 0
+}
+
+func (e sftpFileInfo) IsSymlink() bool {
+	return e.FileInfo.Mode()&os.ModeSymlink != 0
+}
+
+func (e sftpFileInfo) Owner() int {
+	if st, ok := e.Sys().(*sftp.FileStat); ok {
+		return int(st.UID)
+	}
+	return -1
+}
+
+func (e sftpFileInfo) Group() int {
+	if st, ok := e.Sys().(*sftp.FileStat); ok {
+		return int(st.GID)
+	}
+	return -1
+}
+
+func (sftpFileInfo) InodeChangeTime() time.Time {
+	return time.Time{}
+}
+
+// sftpFile wraps a remote file, presenting the name relative to the folder
+// root like the other filesystems do.
+type sftpFile struct {
+	*sftp.File
+	name string
+}
+
+func (f *sftpFile) Name() string {
+	return f.name
+}
+
+func (f *sftpFile) Stat() (FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, sftpError(err)
+	}
+	return sftpFileInfo{info}, nil
+}
+
+func (f *sftpFile) Sync() error {
+	// Not every server supports fsync@openssh.com, in which case there is
+	// nothing more we can do.
+	var st *sftp.StatusError
+	if err := f.File.Sync(); errors.As(err, &st) && st.FxCode() == sftp.ErrSSHFxOpUnsupported {
+		return nil
+	} else if err != nil {
+		return sftpError(err)
+	}
+	return nil
+}
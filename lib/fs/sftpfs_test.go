@@ -0,0 +1,361 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSFTPFS(t *testing.T) {
+	fs, dir := newSFTPTestFilesystem(t, "")
+
+	if err := fs.MkdirAll("dira/dirb", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "dira", "dirb")); err != nil {
+		t.Fatal(err)
+	} else if !info.IsDir() {
+		t.Fatal("expected a directory")
+	}
+	if _, err := fs.Lstat("nonexistent"); !IsNotExist(err) {
+		t.Fatal("expected not exists error, got", err)
+	}
+
+	// Write a file and read it back
+
+	fd, err := fs.Create("dira/dirb/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.WriteAt([]byte(", world"), 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := fd.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if err := fd.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if fd.Name() != filepath.Join("dira", "dirb", "test") {
+		t.Error("unexpected file name", fd.Name())
+	}
+	if bs, err := os.ReadFile(filepath.Join(dir, "dira", "dirb", "test")); err != nil {
+		t.Fatal(err)
+	} else if string(bs) != "hello, world" {
+		t.Errorf("unexpected contents %q", bs)
+	}
+
+	fd, err = fs.Open("dira/dirb/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if _, err := fd.ReadAt(buf, 7); err != nil {
+		t.Fatal(err)
+	} else if string(buf) != "world" {
+		t.Errorf("unexpected contents %q", buf)
+	}
+	if info, err := fd.Stat(); err != nil {
+		t.Fatal(err)
+	} else if info.Size() != 12 || !info.IsRegular() {
+		t.Errorf("unexpected stat %v, %v", info.Size(), info.Mode())
+	}
+	fd.Close()
+
+	// Metadata
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := fs.Chtimes("dira/dirb/test", mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Chmod("dira/dirb/test", 0o600); err != nil {
+		t.Fatal(err)
+	}
+	info, err := fs.Lstat("dira/dirb/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("unexpected mtime %v", info.ModTime())
+	}
+	if info.Mode()&ModePerm != 0o600 {
+		t.Errorf("unexpected mode %v", info.Mode())
+	}
+	if info.Owner() != os.Getuid() {
+		t.Errorf("unexpected owner %d", info.Owner())
+	}
+
+	// Symlinks
+
+	if err := fs.CreateSymlink("dirb/test", "dira/link"); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := fs.ReadSymlink("dira/link"); err != nil {
+		t.Fatal(err)
+	} else if target != filepath.FromSlash("dirb/test") {
+		t.Errorf("unexpected symlink target %q", target)
+	}
+	if info, err := fs.Lstat("dira/link"); err != nil {
+		t.Fatal(err)
+	} else if !info.IsSymlink() {
+		t.Error("expected a symlink")
+	}
+
+	// Listing
+
+	names, err := fs.DirNames("dira")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	if fmt.Sprint(names) != "[dirb link]" {
+		t.Errorf("unexpected names %v", names)
+	}
+	if matches, err := fs.Glob("dira/dirb/t*"); err != nil {
+		t.Fatal(err)
+	} else if len(matches) != 1 || matches[0] != filepath.Join("dira", "dirb", "test") {
+		t.Errorf("unexpected glob matches %v", matches)
+	}
+
+	// Renaming, including over an existing file
+
+	if err := WriteFile(fs, "dira/other", []byte("other"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Rename("dira/other", "dira/dirb/test"); err != nil {
+		t.Fatal(err)
+	}
+	if bs, err := os.ReadFile(filepath.Join(dir, "dira", "dirb", "test")); err != nil {
+		t.Fatal(err)
+	} else if string(bs) != "other" {
+		t.Errorf("unexpected contents after rename %q", bs)
+	}
+
+	// Usage
+
+	if usage, err := fs.Usage("."); err != nil {
+		t.Fatal(err)
+	} else if usage.Total == 0 {
+		t.Error("expected nonzero total space")
+	}
+
+	// Removal
+
+	if err := fs.Remove("dira/dirb"); err == nil {
+		t.Error("expected error removing non-empty directory")
+	}
+	if err := fs.RemoveAll("dira"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "dira")); !os.IsNotExist(err) {
+		t.Error("expected directory to be gone, got", err)
+	}
+	if err := fs.RemoveAll("nonexistent"); err != nil {
+		t.Error("RemoveAll of nonexistent:", err)
+	}
+
+	// Escaping the root
+
+	if _, err := fs.Lstat("../outside"); err == nil {
+		t.Error("expected error for path outside of root")
+	}
+}
+
+func TestSFTPFSReconnect(t *testing.T) {
+	fs, _ := newSFTPTestFilesystem(t, "")
+	sfs := unwrapSFTP(t, fs)
+
+	if err := WriteFile(fs, "test", []byte("test"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Drop the connection and wait for the client to notice.
+	sfs.mut.Lock()
+	sfs.conn.Close()
+	sfs.mut.Unlock()
+	for i := 0; ; i++ {
+		sfs.mut.Lock()
+		gone := sfs.client == nil
+		sfs.mut.Unlock()
+		if gone {
+			break
+		}
+		if i == 100 {
+			t.Fatal("client never noticed the lost connection")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := fs.Lstat("test"); err != nil {
+		t.Fatal("expected reconnect, got", err)
+	}
+}
+
+func TestSFTPFSHostKeyMismatch(t *testing.T) {
+	fs, _ := newSFTPTestFilesystem(t, "SHA256:wrong")
+	if _, err := fs.Lstat("."); err == nil {
+		t.Fatal("expected host key verification to fail")
+	}
+}
+
+func TestSFTPFSPasswordInPath(t *testing.T) {
+	// The password in the folder path would end up in the GUI and the
+	// logs.
+	if _, err := newSFTPFilesystem("user@localhost/data?password=secret&hostkey=SHA256:x"); err == nil {
+		t.Fatal("expected an error for a password in the folder path")
+	}
+}
+
+func TestSFTPFSWatch(t *testing.T) {
+	fs, dir := newSFTPTestFilesystem(t, "")
+	unwrapSFTP(t, fs).pollInterval = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, _, err := fs.Watch(".", nil, ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "test"), []byte("test"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev := <-events:
+		if ev.Name != "test" || ev.Type != NonRemove {
+			t.Errorf("unexpected event %v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+}
+
+// newSFTPTestFilesystem starts an SFTP server serving a temporary directory
+// and returns a filesystem connected to it. If hostKey is empty, the
+// server's actual host key fingerprint is used.
+func newSFTPTestFilesystem(t *testing.T, hostKey string) (Filesystem, string) {
+	t.Helper()
+
+	root := t.TempDir()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hostKey == "" {
+		hostKey = ssh.FingerprintSHA256(signer.PublicKey())
+	}
+
+	cfg := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, pw []byte) (*ssh.Permissions, error) {
+			if conn.User() == "user" && string(pw) == "secret" {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %q", conn.User())
+		},
+	}
+	cfg.AddHostKey(signer)
+
+	lst, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lst.Close() })
+	go func() {
+		for {
+			conn, err := lst.Accept()
+			if err != nil {
+				return
+			}
+			go serveSFTPTestConn(conn, cfg)
+		}
+	}()
+
+	// The double slash makes the path absolute.
+	pwFile := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(pwFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	uri := fmt.Sprintf("user@%s/%s?passwordfile=%s&hostkey=%s", lst.Addr(), filepath.ToSlash(root), url.QueryEscape(pwFile), hostKey)
+	fs := NewFilesystem(FilesystemTypeSFTP, uri)
+	if errFs, ok := fs.(*errorFilesystem); ok {
+		t.Fatal(errFs.err)
+	}
+	sfs := unwrapSFTP(t, fs)
+	t.Cleanup(func() {
+		sfs.mut.Lock()
+		if sfs.conn != nil {
+			sfs.conn.Close()
+		}
+		sfs.mut.Unlock()
+	})
+	return fs, root
+}
+
+func serveSFTPTestConn(conn net.Conn, cfg *ssh.ServerConfig) {
+	defer conn.Close()
+	_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			_ = newCh.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		ch, reqs, err := newCh.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range reqs {
+				// The payload is the length prefixed subsystem name.
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				_ = req.Reply(ok, nil)
+			}
+		}()
+		go func() {
+			defer ch.Close()
+			srv, err := sftp.NewServer(ch)
+			if err != nil {
+				return
+			}
+			_ = srv.Serve()
+		}()
+	}
+}
+
+func unwrapSFTP(t *testing.T, fs Filesystem) *sftpFS {
+	t.Helper()
+	sfs, ok := unwrapFilesystem[*sftpFS](fs)
+	if !ok {
+		t.Fatal("not an SFTP filesystem")
+	}
+	return sfs
+}
@@ -0,0 +1,151 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"context"
+	"path/filepath"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// A subFilesystem is a directory of another filesystem. Unlike a filesystem
+// created for the joined URI, it works for any filesystem type, regardless
+// of the format of its URIs.
+type subFilesystem struct {
+	Filesystem
+	dir string
+}
+
+// NewSubFilesystem returns a filesystem rooted at the given directory of
+// the parent filesystem. The directory need not exist.
+func NewSubFilesystem(parent Filesystem, dir string) Filesystem {
+	return NewWalkFilesystem(&subFilesystem{
+		Filesystem: parent,
+		dir:        filepath.Clean(dir),
+	})
+}
+
+func (f *subFilesystem) path(name string) string {
+	return filepath.Join(f.dir, name)
+}
+
+func (f *subFilesystem) Chmod(name string, mode FileMode) error {
+	return f.Filesystem.Chmod(f.path(name), mode)
+}
+
+func (f *subFilesystem) Lchown(name, uid, gid string) error {
+	return f.Filesystem.Lchown(f.path(name), uid, gid)
+}
+
+func (f *subFilesystem) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return f.Filesystem.Chtimes(f.path(name), atime, mtime)
+}
+
+func (f *subFilesystem) Create(name string) (File, error) {
+	return f.Filesystem.Create(f.path(name))
+}
+
+func (f *subFilesystem) CreateSymlink(target, name string) error {
+	return f.Filesystem.CreateSymlink(target, f.path(name))
+}
+
+func (f *subFilesystem) DirNames(name string) ([]string, error) {
+	return f.Filesystem.DirNames(f.path(name))
+}
+
+func (f *subFilesystem) Lstat(name string) (FileInfo, error) {
+	return f.Filesystem.Lstat(f.path(name))
+}
+
+func (f *subFilesystem) Mkdir(name string, perm FileMode) error {
+	return f.Filesystem.Mkdir(f.path(name), perm)
+}
+
+func (f *subFilesystem) MkdirAll(name string, perm FileMode) error {
+	return f.Filesystem.MkdirAll(f.path(name), perm)
+}
+
+func (f *subFilesystem) Open(name string) (File, error) {
+	return f.Filesystem.Open(f.path(name))
+}
+
+func (f *subFilesystem) OpenFile(name string, flags int, mode FileMode) (File, error) {
+	return f.Filesystem.OpenFile(f.path(name), flags, mode)
+}
+
+func (f *subFilesystem) ReadSymlink(name string) (string, error) {
+	return f.Filesystem.ReadSymlink(f.path(name))
+}
+
+func (f *subFilesystem) Remove(name string) error {
+	return f.Filesystem.Remove(f.path(name))
+}
+
+func (f *subFilesystem) RemoveAll(name string) error {
+	return f.Filesystem.RemoveAll(f.path(name))
+}
+
+func (f *subFilesystem) Rename(oldname, newname string) error {
+	return f.Filesystem.Rename(f.path(oldname), f.path(newname))
+}
+
+func (f *subFilesystem) Stat(name string) (FileInfo, error) {
+	return f.Filesystem.Stat(f.path(name))
+}
+
+func (*subFilesystem) Watch(_ string, _ Matcher, _ context.Context, _ bool) (<-chan Event, <-chan error, error) {
+	return nil, nil, ErrWatchNotSupported
+}
+
+func (f *subFilesystem) Hide(name string) error {
+	return f.Filesystem.Hide(f.path(name))
+}
+
+func (f *subFilesystem) Unhide(name string) error {
+	return f.Filesystem.Unhide(f.path(name))
+}
+
+func (f *subFilesystem) Glob(pattern string) ([]string, error) {
+	matches, err := f.Filesystem.Glob(filepath.Join(f.dir, pattern))
+	if err != nil {
+		return nil, err
+	}
+	for i, match := range matches {
+		if matches[i], err = filepath.Rel(f.dir, match); err != nil {
+			return nil, err
+		}
+	}
+	return matches, nil
+}
+
+func (f *subFilesystem) Usage(name string) (Usage, error) {
+	return f.Filesystem.Usage(f.path(name))
+}
+
+// URI returns a path for basic filesystems, so that it can be compared and
+// combined with those of other basic filesystems. For other types it only
+// identifies the directory.
+func (f *subFilesystem) URI() string {
+	if f.Type() == FilesystemTypeBasic {
+		return filepath.Join(f.Filesystem.URI(), f.dir)
+	}
+	return f.Filesystem.URI() + "#" + filepath.ToSlash(f.dir)
+}
+
+func (f *subFilesystem) PlatformData(name string, withOwnership, withXattrs bool, xattrFilter XattrFilter) (protocol.PlatformData, error) {
+	return f.Filesystem.PlatformData(f.path(name), withOwnership, withXattrs, xattrFilter)
+}
+
+func (f *subFilesystem) GetXattr(name string, xattrFilter XattrFilter) ([]protocol.Xattr, error) {
+	return f.Filesystem.GetXattr(f.path(name), xattrFilter)
+}
+
+func (f *subFilesystem) SetXattr(name string, xattrs []protocol.Xattr, xattrFilter XattrFilter) error {
+	return f.Filesystem.SetXattr(f.path(name), xattrs, xattrFilter)
+}
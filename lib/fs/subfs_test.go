@@ -0,0 +1,48 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package fs
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestSubFilesystem(t *testing.T) {
+	parent := NewFilesystem(FilesystemTypeFake, t.Name()+"?content=true")
+	sub := NewSubFilesystem(parent, filepath.Join("a", "b"))
+
+	if err := sub.MkdirAll("dir", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(sub, filepath.Join("dir", "file"), []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.Rename(filepath.Join("dir", "file"), "file"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parent.Lstat(filepath.Join("a", "b", "file")); err != nil {
+		t.Error("expected the file in the directory of the parent:", err)
+	}
+
+	var walked []string
+	err := sub.Walk(".", func(path string, _ FileInfo, err error) error {
+		walked = append(walked, path)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(walked)
+	if exp := []string{".", "dir", "file"}; !slices.Equal(walked, exp) {
+		t.Errorf("walked %v, expected %v", walked, exp)
+	}
+
+	if matches, err := sub.Glob("f*"); err != nil || !slices.Equal(matches, []string{"file"}) {
+		t.Errorf("glob matched %v, %v", matches, err)
+	}
+}
@@ -115,6 +115,7 @@ type Matcher struct {
 	curHash        string
 	stop           chan struct{}
 	changeDetector ChangeDetector
+	selected       func(file string) bool
 	mut            sync.Mutex
 }
 
@@ -136,6 +137,16 @@ func WithChangeDetector(cd ChangeDetector) Option {
 	}
 }
 
+// WithSelection sets a function that returns whether a file is selected,
+// in addition to the patterns. Files that aren't selected are ignored
+// regardless of the patterns, and directories that aren't selected are
+// skipped.
+func WithSelection(selected func(file string) bool) Option {
+	return func(m *Matcher) {
+		m.selected = selected
+	}
+}
+
 func New(fs fs.Filesystem, opts ...Option) *Matcher {
 	m := &Matcher{
 		fs:   fs,
@@ -229,6 +240,9 @@ func (m *Matcher) Match(file string) (result ignoreresult.R) {
 
 	case file == ".":
 		return ignoreresult.NotIgnored
+
+	case m.selected != nil && !m.selected(file):
+		return ignoreresult.IgnoreAndSkip
 	}
 
 	m.mut.Lock()
@@ -1345,3 +1345,28 @@ func TestWindowsLineEndings(t *testing.T) {
 		t.Error("expected there to be a non-zero number of Windows line endings")
 	}
 }
+
+func TestWithSelection(t *testing.T) {
+	testFs := newTestFS()
+
+	selected := func(file string) bool {
+		return file == "sel" || strings.HasPrefix(file, "sel"+string(filepath.Separator))
+	}
+	m := New(testFs, WithSelection(selected))
+	if err := m.Parse(strings.NewReader("*.tmp\n"), ".stignore"); err != nil {
+		t.Fatal(err)
+	}
+
+	if res := m.Match("other"); !res.IsIgnored() || !res.CanSkipDir() {
+		t.Error("unselected directory should be ignored and skipped, got", res)
+	}
+	if m.Match(filepath.Join("sel", "file")).IsIgnored() {
+		t.Error("selected file should not be ignored")
+	}
+	if !m.Match(filepath.Join("sel", "file.tmp")).IsIgnored() {
+		t.Error("ignore patterns should still apply to selected files")
+	}
+	if m.Match(".").IsIgnored() {
+		t.Error("the root should never be ignored")
+	}
+}
@@ -28,6 +28,7 @@ const (
 	HTTPSCertFile LocationEnum = "httpsCertFile"
 	HTTPSKeyFile  LocationEnum = "httpsKeyFile"
 	Database      LocationEnum = "database"
+	SQLiteDB      LocationEnum = "sqliteDatabase"
 	LogFile       LocationEnum = "logFile"
 	PanicLog      LocationEnum = "panicLog"
 	AuditLog      LocationEnum = "auditLog"
@@ -48,6 +49,7 @@ const (
 	UserHomeBaseDir BaseDirEnum = "userHome"
 
 	LevelDBDir          = "index-v0.14.0.db"
+	SQLiteDBFile        = "index-v0.14.0.sqlite"
 	configFileName      = "config.xml"
 	defaultStateDir     = ".local/state/syncthing"
 	oldDefaultConfigDir = ".config/syncthing"
@@ -120,6 +122,7 @@ var locationTemplates = map[LocationEnum]string{
 	HTTPSCertFile: "${config}/https-cert.pem",
 	HTTPSKeyFile:  "${config}/https-key.pem",
 	Database:      "${data}/" + LevelDBDir,
+	SQLiteDB:      "${data}/" + SQLiteDBFile,
 	LogFile:       "${data}/syncthing.log", // --logfile on Windows
 	PanicLog:      "${data}/panic-%{timestamp}.log",
 	AuditLog:      "${data}/audit-%{timestamp}.log",
@@ -168,6 +171,7 @@ func PrettyPaths() string {
 	fmt.Fprintf(&b, "Device private key & certificate files:\n\t%s\n\t%s\n\n", Get(KeyFile), Get(CertFile))
 	fmt.Fprintf(&b, "GUI / API HTTPS private key & certificate files:\n\t%s\n\t%s\n\n", Get(HTTPSKeyFile), Get(HTTPSCertFile))
 	fmt.Fprintf(&b, "Database location:\n\t%s\n\n", Get(Database))
+	fmt.Fprintf(&b, "SQLite database location:\n\t%s\n\n", Get(SQLiteDB))
 	fmt.Fprintf(&b, "Log file:\n\t%s\n\n", Get(LogFile))
 	fmt.Fprintf(&b, "GUI override directory:\n\t%s\n\n", Get(GUIAssets))
 	fmt.Fprintf(&b, "Default sync folder directory:\n\t%s\n\n", Get(DefFolder))
@@ -0,0 +1,269 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/kballard/go-shellquote"
+
+	"github.com/syncthing/syncthing/lib/build"
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/scanner"
+)
+
+// mergeTimeout is how long a merge command may run before it is killed.
+var mergeTimeout = time.Minute
+
+// How a conflict was resolved, as reported in the ConflictResolved event.
+const (
+	conflictKeptLocal    = "local"
+	conflictKeptIncoming = "incoming"
+	conflictMerged       = "merged"
+)
+
+// A conflictResolver picks the rule for resolving a conflict on a given
+// file from the conflict rules of a folder.
+type conflictResolver struct {
+	rules patternRules[config.ConflictRule]
+}
+
+func newConflictResolver(cfg config.FolderConfiguration, filesystem fs.Filesystem) *conflictResolver {
+	return &conflictResolver{
+		rules: newPatternRules(cfg, filesystem, "conflict rule", cfg.ConflictRules, func(r config.ConflictRule) string { return r.Pattern }),
+	}
+}
+
+// rule returns the first rule matching the given file, or one with the
+// default strategy if none does.
+func (r *conflictResolver) rule(name string) config.ConflictRule {
+	if rule, ok := r.rules.match(name); ok {
+		return rule
+	}
+	return config.ConflictRule{Strategy: config.ConflictStrategyNewest}
+}
+
+// keepLocal returns whether the rule favours the local version of a file
+// over the incoming one. The incoming version is the global one, i.e. it
+// has won by being the newest, so that is never a reason to keep the local
+// one. The decision depends only on the two versions and not on which of
+// them is local, so that devices with the same rules agree on the outcome.
+func keepLocal(rule config.ConflictRule, cur, file protocol.FileInfo) bool {
+	switch rule.Strategy {
+	case config.ConflictStrategyPreferDevice:
+		short := rule.Device.Short()
+		return cur.ModifiedBy == short && file.ModifiedBy != short
+	case config.ConflictStrategyPreferLarger:
+		return cur.Size > file.Size
+	case config.ConflictStrategyKeepBoth:
+		return true
+	default:
+		return false
+	}
+}
+
+// resolveConflict handles a conflict between the local file and the
+// incoming version of it, which has been written to tempName. It returns
+// true if the local file was kept, in which case the incoming version has
+// been taken care of and the local file has been given a version that
+// supersedes both. Otherwise the local file has been moved out of the way
+// of the incoming version.
+func (f *sendReceiveFolder) resolveConflict(file, cur protocol.FileInfo, tempName string, dbUpdateChan chan<- dbUpdateJob, scanChan chan<- string) (bool, error) {
+	rule := config.ConflictRule{Strategy: config.ConflictStrategyNewest}
+	// Data of encrypted folders can't be merged or compared meaningfully,
+	// and conflicts on conflict copies just replace them.
+	if f.Type != config.FolderTypeReceiveEncrypted && !isConflict(file.Name) {
+		rule = f.conflictResolver.rule(file.Name)
+	}
+
+	switch {
+	case rule.Strategy == config.ConflictStrategyMerge:
+		if err := f.runMergeCommand(rule.MergeCommand, file.Name, tempName); err != nil {
+			l.Infof("Merging conflicting versions of %s in folder %s failed, keeping the newest: %v", file.Name, f.Description(), err)
+			break
+		}
+		if err := f.mtimefs.Remove(tempName); err != nil {
+			l.Debugln(f, "removing merged temp file", err)
+		}
+		merged, err := f.scanMerged(cur, file)
+		if err != nil {
+			return false, fmt.Errorf("scanning merged file: %w", err)
+		}
+		dbUpdateChan <- dbUpdateJob{merged, dbUpdateHandleFile}
+		f.logConflictResolved(rule, file, cur, conflictMerged, "")
+		return true, nil
+
+	case keepLocal(rule, cur, file):
+		copyName, err := f.keepIncomingAsConflict(rule, file, tempName, scanChan)
+		if err != nil {
+			return false, err
+		}
+		// Just like when a deletion conflicts with a change, the merged
+		// version vector makes the local file win everywhere.
+		cur.Version = cur.Version.Merge(file.Version)
+		dbUpdateChan <- dbUpdateJob{cur, dbUpdateHandleFile}
+		f.logConflictResolved(rule, file, cur, conflictKeptLocal, copyName)
+		return true, nil
+	}
+
+	return false, f.moveConflictingOutOfTheWay(rule, file, cur, scanChan)
+}
+
+// scanMerged returns the local file as it is after merging the incoming
+// version into it. It's a change on top of both versions, so that it
+// supersedes them everywhere, and is hashed like the scanner would so that
+// it can go in the database right away.
+func (f *sendReceiveFolder) scanMerged(cur, file protocol.FileInfo) (protocol.FileInfo, error) {
+	info, err := f.mtimefs.Lstat(cur.Name)
+	if err != nil {
+		return protocol.FileInfo{}, err
+	}
+	merged, err := scanner.CreateFileInfo(info, cur.Name, f.mtimefs, f.SendOwnership || f.SyncOwnership, f.SendXattrs || f.SyncXattrs, f.XattrFilter)
+	if err != nil {
+		return protocol.FileInfo{}, err
+	}
+	if build.IsWindows {
+		merged.Permissions |= cur.Permissions & 0o111
+	}
+	merged.Version = cur.Version.Merge(file.Version).Update(f.shortID)
+	merged.ModifiedBy = f.shortID
+	merged.LocalFlags = f.localFlags
+	merged.Platform.MergeWith(&cur.Platform)
+	merged.NoPermissions = f.IgnorePerms
+	merged.RawBlockSize = int32(protocol.BlockSize(merged.Size))
+
+	algo, _ := f.model.blockHashAlgorithm(f.FolderConfiguration)
+	var blocks []protocol.BlockInfo
+	if f.model.contentDefinedChunking(f.FolderConfiguration) {
+		blocks, err = scanner.HashFileChunked(f.ctx, f.ID, f.mtimefs, merged.Name, algo, merged.BlockSize(), nil)
+	} else {
+		blocks, err = scanner.HashFile(f.ctx, f.ID, f.mtimefs, merged.Name, algo, merged.BlockSize(), nil, true)
+	}
+	if err != nil {
+		return protocol.FileInfo{}, err
+	}
+	merged.Blocks = blocks
+	merged.BlocksHash = protocol.BlocksHash(blocks)
+	merged.BlockHashAlgorithm = algo
+	merged.Size = 0
+	for _, b := range blocks {
+		merged.Size += int64(b.Size)
+	}
+	return merged, nil
+}
+
+// moveConflictingOutOfTheWay moves the local file away for the incoming
+// version to take its place, keeping it as a conflict copy.
+func (f *sendReceiveFolder) moveConflictingOutOfTheWay(rule config.ConflictRule, file, cur protocol.FileInfo, scanChan chan<- string) error {
+	var copyName string
+	err := f.inWritableDir(func(name string) error {
+		var err error
+		copyName, err = f.moveForConflict(name, file.ModifiedBy.String(), scanChan)
+		return err
+	}, cur.Name)
+	if err != nil {
+		return err
+	}
+	f.logConflictResolved(rule, file, cur, conflictKeptIncoming, copyName)
+	return nil
+}
+
+// keepIncomingAsConflict stores the incoming version in tempName as a
+// conflict copy, or removes it if conflict copies are disabled. The keep
+// both strategy always stores it. It returns the name of the copy, if any.
+func (f *sendReceiveFolder) keepIncomingAsConflict(rule config.ConflictRule, file protocol.FileInfo, tempName string, scanChan chan<- string) (string, error) {
+	if f.MaxConflicts == 0 && rule.Strategy != config.ConflictStrategyKeepBoth {
+		if err := f.mtimefs.Remove(tempName); err != nil && !fs.IsNotExist(err) {
+			return "", err
+		}
+		return "", nil
+	}
+
+	metricFolderConflictsTotal.WithLabelValues(f.ID).Inc()
+	copyName := conflictName(file.Name, file.ModifiedBy.String())
+	if err := osutil.RenameOrCopy(f.CopyRangeMethod.ToFS(), f.mtimefs, f.mtimefs, tempName, copyName); err != nil {
+		return "", fmt.Errorf("keeping conflict copy: %w", err)
+	}
+	f.mtimefs.Chtimes(copyName, file.ModTime(), file.ModTime()) // never fails
+	f.removeExtraConflicts(file.Name)
+	scanChan <- copyName
+	return copyName, nil
+}
+
+// runMergeCommand runs the given merge command, which should merge the
+// incoming version in tempName into the local file.
+func (f *sendReceiveFolder) runMergeCommand(command, name, tempName string) error {
+	if f.mtimefs.Type() != fs.FilesystemTypeBasic {
+		return errors.New("merging is only supported on regular filesystems")
+	}
+
+	if build.IsWindows {
+		command = strings.ReplaceAll(command, `\`, `\\`)
+	}
+	words, err := shellquote.Split(command)
+	if err != nil {
+		return fmt.Errorf("command is invalid: %w", err)
+	}
+	if len(words) == 0 {
+		return errors.New("command is empty")
+	}
+	placeholders := map[string]string{
+		"%FOLDER_PATH%": f.mtimefs.URI(),
+		"%FILE_PATH%":   name,
+		"%REMOTE_PATH%": tempName,
+	}
+	for i, word := range words {
+		for key, val := range placeholders {
+			word = strings.ReplaceAll(word, key, val)
+		}
+		words[i] = word
+	}
+
+	ctx, cancel := context.WithTimeout(f.ctx, mergeTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, words[0], words[1:]...)
+	// The file paths are relative to the folder.
+	cmd.Dir = f.mtimefs.URI()
+	for _, x := range os.Environ() {
+		// Don't leak our credentials.
+		if !strings.HasPrefix(x, "STGUIAUTH=") && !strings.HasPrefix(x, "STGUIAPIKEY=") {
+			cmd.Env = append(cmd.Env, x)
+		}
+	}
+	out, err := cmd.CombinedOutput()
+	l.Debugln(f, "merge command output:", string(out))
+	if err != nil {
+		if len(out) > 0 {
+			return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+		}
+		return err
+	}
+	return nil
+}
+
+func (f *sendReceiveFolder) logConflictResolved(rule config.ConflictRule, file, cur protocol.FileInfo, resolution, copyName string) {
+	l.Debugf("%v conflict on %s resolved (%v): %s, copy %q", f, file.Name, rule.Strategy, resolution, copyName)
+	f.evLogger.Log(events.ConflictResolved, map[string]string{
+		"folder":             f.folderID,
+		"item":               file.Name,
+		"strategy":           rule.Strategy.String(),
+		"resolution":         resolution,
+		"localModifiedBy":    cur.ModifiedBy.String(),
+		"incomingModifiedBy": file.ModifiedBy.String(),
+		"conflictCopy":       copyName,
+	})
+}
@@ -0,0 +1,249 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/build"
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/scanner"
+)
+
+func TestConflictResolverRule(t *testing.T) {
+	ffs := fs.NewFilesystem(fs.FilesystemTypeFake, "conflictresolverrule")
+	r := newConflictResolver(config.FolderConfiguration{
+		ConflictRules: []config.ConflictRule{
+			{Pattern: "*.txt", Strategy: config.ConflictStrategyMerge, MergeCommand: "merge"},
+			{Pattern: "/docs", Strategy: config.ConflictStrategyPreferDevice, Device: device1},
+			{Pattern: "", Strategy: config.ConflictStrategyPreferLarger},
+		},
+	}, ffs)
+
+	cases := map[string]config.ConflictStrategy{
+		"notes.txt":       config.ConflictStrategyMerge,
+		"docs/notes.txt":  config.ConflictStrategyMerge,
+		"docs/report.pdf": config.ConflictStrategyPreferDevice,
+		"sub/docs/a.pdf":  config.ConflictStrategyPreferLarger,
+		"image.png":       config.ConflictStrategyPreferLarger,
+	}
+	for name, exp := range cases {
+		if s := r.rule(name).Strategy; s != exp {
+			t.Errorf("%s: got strategy %v, expected %v", name, s, exp)
+		}
+	}
+
+	if s := newConflictResolver(config.FolderConfiguration{}, ffs).rule("foo").Strategy; s != config.ConflictStrategyNewest {
+		t.Errorf("got strategy %v without rules, expected newest", s)
+	}
+}
+
+func TestKeepLocal(t *testing.T) {
+	local := protocol.FileInfo{Size: 10, ModifiedBy: myID.Short()}
+	remote := protocol.FileInfo{Size: 5, ModifiedBy: device1.Short()}
+
+	cases := []struct {
+		rule config.ConflictRule
+		exp  bool
+	}{
+		{config.ConflictRule{Strategy: config.ConflictStrategyNewest}, false},
+		{config.ConflictRule{Strategy: config.ConflictStrategyPreferDevice, Device: myID}, true},
+		{config.ConflictRule{Strategy: config.ConflictStrategyPreferDevice, Device: device1}, false},
+		{config.ConflictRule{Strategy: config.ConflictStrategyPreferDevice, Device: device2}, false},
+		{config.ConflictRule{Strategy: config.ConflictStrategyPreferLarger}, true},
+		{config.ConflictRule{Strategy: config.ConflictStrategyKeepBoth}, true},
+	}
+	for _, tc := range cases {
+		if res := keepLocal(tc.rule, local, remote); res != tc.exp {
+			t.Errorf("%v %v: got %v, expected %v", tc.rule.Strategy, tc.rule.Device.Short(), res, tc.exp)
+		}
+	}
+
+	// Preferring the larger one only favours the local file if it is larger.
+	if keepLocal(config.ConflictRule{Strategy: config.ConflictStrategyPreferLarger}, remote, local) {
+		t.Error("smaller local file should not be kept")
+	}
+}
+
+func TestResolveConflictStrategies(t *testing.T) {
+	cases := []struct {
+		strategy     config.ConflictStrategy
+		maxConflicts int
+		keptLocal    bool
+		copyContent  string // empty if no conflict copy is expected
+	}{
+		{config.ConflictStrategyNewest, 10, false, "local data"},
+		{config.ConflictStrategyNewest, 0, false, ""},
+		{config.ConflictStrategyPreferLarger, 10, true, "remote"},
+		{config.ConflictStrategyPreferLarger, 0, true, ""},
+		{config.ConflictStrategyKeepBoth, 0, true, "remote"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.strategy.String(), func(t *testing.T) {
+			_, f, wcfgCancel := setupSendReceiveFolder(t)
+			defer wcfgCancel()
+			ffs := f.Filesystem(nil)
+			f.MaxConflicts = tc.maxConflicts
+			f.conflictResolver = newConflictResolver(config.FolderConfiguration{
+				ConflictRules: []config.ConflictRule{{Strategy: tc.strategy}},
+			}, ffs)
+
+			name := "foo"
+			writeFile(t, ffs, name, []byte("local data"))
+			info, err := ffs.Stat(name)
+			must(t, err)
+			cur, err := scanner.CreateFileInfo(info, name, ffs, false, false, config.XattrFilter{})
+			must(t, err)
+			cur.Version = protocol.Vector{}.Update(myID.Short())
+			cur.ModifiedBy = myID.Short()
+			f.updateLocalsFromScanning([]protocol.FileInfo{cur})
+
+			// The incoming, conflicting version is smaller.
+			tempName := fs.TempName(name)
+			writeFile(t, ffs, tempName, []byte("remote"))
+			file := cur
+			file.Size = 6
+			file.Version = protocol.Vector{}.Update(device1.Short())
+			file.ModifiedBy = device1.Short()
+
+			dbUpdateChan := make(chan dbUpdateJob, 1)
+			scanChan := make(chan string, 1)
+			must(t, f.performFinish(file, cur, true, tempName, fsetSnapshot(t, f.fset), dbUpdateChan, scanChan))
+
+			expContent := "remote"
+			if tc.keptLocal {
+				expContent = "local data"
+			}
+			if content := readContent(t, ffs, name); content != expContent {
+				t.Errorf("got content %q, expected %q", content, expContent)
+			}
+
+			job := <-dbUpdateChan
+			if tc.keptLocal {
+				if !job.file.Version.GreaterEqual(cur.Version) || !job.file.Version.GreaterEqual(file.Version) {
+					t.Errorf("kept local file should supersede both versions, got %v", job.file.Version)
+				}
+			} else if !job.file.Version.Equal(file.Version) {
+				t.Errorf("expected incoming version in db, got %v", job.file.Version)
+			}
+
+			confls := existingConflicts(name, ffs)
+			if tc.copyContent == "" {
+				if len(confls) != 0 {
+					t.Fatal("expected no conflict copy, got", confls)
+				}
+				return
+			}
+			if len(confls) != 1 {
+				t.Fatal("expected one conflict copy, got", confls)
+			}
+			if content := readContent(t, ffs, confls[0]); content != tc.copyContent {
+				t.Errorf("got conflict copy content %q, expected %q", content, tc.copyContent)
+			}
+			if scan := <-scanChan; scan != confls[0] {
+				t.Errorf("expected request to scan %v, got %v", confls[0], scan)
+			}
+		})
+	}
+}
+
+func TestResolveConflictMerge(t *testing.T) {
+	if build.IsWindows {
+		t.Skip("uses sh")
+	}
+
+	_, f, wcfgCancel := setupSendReceiveFolder(t)
+	defer wcfgCancel()
+	f.mtimefs = fs.NewFilesystem(fs.FilesystemTypeBasic, t.TempDir())
+	f.conflictResolver = newConflictResolver(config.FolderConfiguration{
+		ConflictRules: []config.ConflictRule{{Strategy: config.ConflictStrategyMerge, MergeCommand: `sh -c 'cat "$1" >> "$0"' %FILE_PATH% %REMOTE_PATH%`}},
+	}, f.mtimefs)
+
+	name := "foo"
+	writeFile(t, f.mtimefs, name, []byte("local\n"))
+	info, err := f.mtimefs.Stat(name)
+	must(t, err)
+	cur, err := scanner.CreateFileInfo(info, name, f.mtimefs, false, false, config.XattrFilter{})
+	must(t, err)
+	cur.Version = protocol.Vector{}.Update(myID.Short())
+	cur.ModifiedBy = myID.Short()
+
+	tempName := fs.TempName(name)
+	writeFile(t, f.mtimefs, tempName, []byte("remote\n"))
+	file := cur
+	file.Version = protocol.Vector{}.Update(device1.Short())
+	file.ModifiedBy = device1.Short()
+
+	dbUpdateChan := make(chan dbUpdateJob, 1)
+	scanChan := make(chan string, 1)
+	keptLocal, err := f.resolveConflict(file, cur, tempName, dbUpdateChan, scanChan)
+	must(t, err)
+	if !keptLocal {
+		t.Fatal("expected the merged local file to be kept")
+	}
+
+	// The database gets the merged file as scanned, in a version that
+	// supersedes both.
+	job := <-dbUpdateChan
+	content := "local\nremote\n"
+	if job.file.Size != int64(len(content)) || len(job.file.Blocks) != 1 {
+		t.Fatalf("expected the merged file's size and blocks, got %v", job.file)
+	}
+	blocks, err := scanner.Blocks(context.Background(), strings.NewReader(content), job.file.BlockSize(), -1, nil, true)
+	must(t, err)
+	if !bytes.Equal(job.file.Blocks[0].Hash, blocks[0].Hash) {
+		t.Error("expected the merged file's hash")
+	}
+	if job.file.Version.Compare(cur.Version) != protocol.Greater || job.file.Version.Compare(file.Version) != protocol.Greater {
+		t.Errorf("merged file should supersede both versions, got %v", job.file.Version)
+	}
+	if job.file.ModifiedBy != myID.Short() {
+		t.Errorf("merged file should be modified by us, got %v", job.file.ModifiedBy)
+	}
+	if _, err := f.mtimefs.Lstat(tempName); !fs.IsNotExist(err) {
+		t.Error("expected the temp file to be removed, got", err)
+	}
+}
+
+func TestRunMergeCommand(t *testing.T) {
+	if build.IsWindows {
+		t.Skip("uses sh")
+	}
+
+	_, f, wcfgCancel := setupSendReceiveFolder(t)
+	defer wcfgCancel()
+	f.mtimefs = fs.NewFilesystem(fs.FilesystemTypeBasic, t.TempDir())
+
+	writeFile(t, f.mtimefs, "foo", []byte("local\n"))
+	writeFile(t, f.mtimefs, "remote", []byte("remote\n"))
+
+	must(t, f.runMergeCommand(`sh -c 'cat "$1" >> "$0"' %FILE_PATH% %REMOTE_PATH%`, "foo", "remote"))
+	if content := readContent(t, f.mtimefs, "foo"); content != "local\nremote\n" {
+		t.Errorf("got merged content %q", content)
+	}
+
+	if err := f.runMergeCommand(`sh -c 'echo conflicting >&2; exit 1'`, "foo", "remote"); err == nil {
+		t.Error("expected error from failing merge command")
+	}
+}
+
+func readContent(t *testing.T, filesystem fs.Filesystem, name string) string {
+	t.Helper()
+	fd, err := filesystem.Open(name)
+	must(t, err)
+	defer fd.Close()
+	bs, err := io.ReadAll(fd)
+	must(t, err)
+	return string(bs)
+}
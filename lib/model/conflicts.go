@@ -0,0 +1,181 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+var (
+	ErrNotConflictCopy    = errors.New("not a conflict copy")
+	ErrConflictMissing    = errors.New("no such conflict copy")
+	ErrConflictsEncrypted = errors.New("conflicts can't be resolved on receive encrypted folders")
+)
+
+// Which side was kept when resolving a conflict manually, as reported in
+// the ConflictResolved event.
+const (
+	conflictKeptOriginal = "original"
+	conflictKeptCopy     = "copy"
+)
+
+// conflictNameExp matches the base name of conflict copies as created by
+// conflictName, capturing the original name before the extension, the
+// time of the conflict, the device and the extension.
+var conflictNameExp = regexp.MustCompile(`^(.*)\.sync-conflict-(\d{8}-\d{6})-([A-Z0-9]*)(.*)$`)
+
+// A Conflict is a conflict copy in a folder, along with what we know about
+// the file it is a copy of.
+type Conflict struct {
+	Name         string    `json:"name"`
+	ConflictTime time.Time `json:"conflictTime"`
+	// The short ID of the device that modified the version which won
+	// the conflict, and the full ID if it is a known device.
+	LastModBy  string            `json:"lastModBy"`
+	LastModDev protocol.DeviceID `json:"lastModDevice"`
+	ModTime    time.Time         `json:"modTime"`
+	Size       int64             `json:"size"`
+
+	Original           string    `json:"original"`
+	OriginalExists     bool      `json:"originalExists"`
+	OriginalModTime    time.Time `json:"originalModTime"`
+	OriginalSize       int64     `json:"originalSize"`
+	OriginalModifiedBy string    `json:"originalModifiedBy"`
+}
+
+// parseConflictName returns the name of the file a conflict copy is a copy
+// of, the time of the conflict and the short device ID in its name.
+func parseConflictName(name string) (string, time.Time, string, bool) {
+	dir, base := filepath.Split(name)
+	m := conflictNameExp.FindStringSubmatch(base)
+	if m == nil {
+		return "", time.Time{}, "", false
+	}
+	// The time is formatted in local time without a zone.
+	t, err := time.ParseInLocation("20060102-150405", m[2], time.Local)
+	if err != nil {
+		return "", time.Time{}, "", false
+	}
+	return dir + m[1] + m[4], t, m[3], true
+}
+
+// Conflicts returns the conflict copies in the folder, as found in the
+// database.
+func (m *model) Conflicts(folder string) ([]Conflict, error) {
+	snap, err := m.DBSnapshot(folder)
+	if err != nil {
+		return nil, err
+	}
+	defer snap.Release()
+
+	var conflicts []Conflict
+	snap.WithHaveTruncated(protocol.LocalDeviceID, func(f protocol.FileInfo) bool {
+		if !f.IsDeleted() && !f.IsDirectory() && isConflict(f.Name) {
+			if original, t, lastModBy, ok := parseConflictName(f.Name); ok {
+				conflicts = append(conflicts, Conflict{
+					Name:         f.Name,
+					ConflictTime: t,
+					LastModBy:    lastModBy,
+					ModTime:      f.ModTime(),
+					Size:         f.Size,
+					Original:     original,
+				})
+			}
+		}
+		return true
+	})
+
+	devices := m.cfg.Devices()
+	for i, c := range conflicts {
+		for id := range devices {
+			if id.Short().String() == c.LastModBy {
+				conflicts[i].LastModDev = id
+				break
+			}
+		}
+		if orig, ok := snap.Get(protocol.LocalDeviceID, c.Original); ok && !orig.IsDeleted() {
+			conflicts[i].OriginalExists = true
+			conflicts[i].OriginalModTime = orig.ModTime()
+			conflicts[i].OriginalSize = orig.Size
+			conflicts[i].OriginalModifiedBy = orig.ModifiedBy.String()
+		}
+	}
+	return conflicts, nil
+}
+
+// ResolveConflict resolves a conflict by keeping either the conflict copy,
+// in place of the original, or the original. The other one is archived if
+// the folder has versioning enabled and removed otherwise.
+func (m *model) ResolveConflict(folder, name string, keepCopy bool) error {
+	m.mut.RLock()
+	err := m.checkFolderRunningRLocked(folder)
+	fcfg := m.folderCfgs[folder]
+	ver := m.folderVersioners[folder]
+	m.mut.RUnlock()
+	if err != nil {
+		return err
+	}
+	if fcfg.Type == config.FolderTypeReceiveEncrypted {
+		return ErrConflictsEncrypted
+	}
+
+	original, _, _, ok := parseConflictName(name)
+	if !ok {
+		return ErrNotConflictCopy
+	}
+	filesystem := fcfg.Filesystem(nil)
+	if info, err := filesystem.Lstat(name); fs.IsNotExist(err) || err == nil && !info.IsRegular() {
+		return ErrConflictMissing
+	} else if err != nil {
+		return err
+	}
+
+	remove := func(name string) error {
+		var err error
+		if ver != nil {
+			err = ver.Archive(name)
+		} else {
+			err = filesystem.Remove(name)
+		}
+		if fs.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	resolution := conflictKeptOriginal
+	if keepCopy {
+		resolution = conflictKeptCopy
+		if err := remove(original); err != nil {
+			return fmt.Errorf("removing original: %w", err)
+		}
+		if err := filesystem.Rename(name, original); err != nil {
+			return fmt.Errorf("replacing original: %w", err)
+		}
+	} else if err := remove(name); err != nil {
+		return fmt.Errorf("removing conflict copy: %w", err)
+	}
+
+	m.evLogger.Log(events.ConflictResolved, map[string]string{
+		"folder":       folder,
+		"item":         original,
+		"strategy":     "manual",
+		"resolution":   resolution,
+		"conflictCopy": name,
+	})
+
+	return m.ScanFolderSubdirs(folder, []string{original, name})
+}
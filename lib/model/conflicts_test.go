@@ -0,0 +1,101 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/fs"
+)
+
+func TestParseConflictName(t *testing.T) {
+	name := conflictName(filepath.Join("dir", "file.txt"), device1.Short().String())
+	original, when, lastModBy, ok := parseConflictName(name)
+	if !ok {
+		t.Fatal("failed to parse", name)
+	}
+	if original != filepath.Join("dir", "file.txt") {
+		t.Error("unexpected original", original)
+	}
+	if lastModBy != device1.Short().String() {
+		t.Error("unexpected device", lastModBy)
+	}
+	if d := time.Since(when); d < 0 || d > time.Minute {
+		t.Error("unexpected conflict time", when)
+	}
+
+	if original, _, _, _ := parseConflictName("noext.sync-conflict-20250102-030405-ABCDEFG"); original != "noext" {
+		t.Error("unexpected original", original)
+	}
+	if _, _, _, ok := parseConflictName("file.txt"); ok {
+		t.Error("unexpected match for a regular file")
+	}
+}
+
+func TestConflictsListAndResolve(t *testing.T) {
+	wrapper, fcfg, cancel := newDefaultCfgWrapper()
+	defer cancel()
+	ffs := fcfg.Filesystem(nil)
+	m := setupModel(t, wrapper)
+	defer cleanupModel(m)
+
+	copyName := "a.sync-conflict-20250102-030405-" + device1.Short().String() + ".txt"
+	writeFile(t, ffs, "a.txt", []byte("original"))
+	writeFile(t, ffs, copyName, []byte("copy"))
+	writeFile(t, ffs, "b.sync-conflict-20250102-030405-ABCDEFG", []byte("orphan"))
+	must(t, m.ScanFolder(fcfg.ID))
+
+	conflicts, err := m.Conflicts(fcfg.ID)
+	must(t, err)
+	if len(conflicts) != 2 {
+		t.Fatal("expected two conflicts, got", conflicts)
+	}
+	for _, c := range conflicts {
+		switch c.Name {
+		case copyName:
+			if c.Original != "a.txt" || !c.OriginalExists || c.OriginalSize != 8 || c.Size != 4 {
+				t.Errorf("unexpected conflict %+v", c)
+			}
+			if c.LastModDev != device1 {
+				t.Error("unexpected device", c.LastModDev)
+			}
+			if exp := time.Date(2025, 1, 2, 3, 4, 5, 0, time.Local); !c.ConflictTime.Equal(exp) {
+				t.Error("unexpected conflict time", c.ConflictTime)
+			}
+		case "b.sync-conflict-20250102-030405-ABCDEFG":
+			if c.Original != "b" || c.OriginalExists {
+				t.Errorf("unexpected conflict %+v", c)
+			}
+		default:
+			t.Error("unexpected conflict", c.Name)
+		}
+	}
+
+	if err := m.ResolveConflict(fcfg.ID, "a.txt", true); err != ErrNotConflictCopy {
+		t.Error("expected error resolving a regular file, got", err)
+	}
+
+	// Keep the copy, which replaces the original.
+	must(t, m.ResolveConflict(fcfg.ID, copyName, true))
+	if content := readContent(t, ffs, "a.txt"); content != "copy" {
+		t.Errorf("unexpected content %q", content)
+	}
+	if _, err := ffs.Lstat(copyName); !fs.IsNotExist(err) {
+		t.Error("conflict copy should be gone, got", err)
+	}
+
+	// Keep the (missing) original, removing the copy.
+	must(t, m.ResolveConflict(fcfg.ID, "b.sync-conflict-20250102-030405-ABCDEFG", false))
+
+	conflicts, err = m.Conflicts(fcfg.ID)
+	must(t, err)
+	if len(conflicts) != 0 {
+		t.Error("expected no conflicts after resolving, got", conflicts)
+	}
+}
@@ -15,6 +15,8 @@ import (
 	"sort"
 	"time"
 
+	"golang.org/x/time/rate"
+
 	"github.com/syncthing/syncthing/lib/config"
 	"github.com/syncthing/syncthing/lib/db"
 	"github.com/syncthing/syncthing/lib/events"
@@ -60,14 +62,19 @@ type folder struct {
 	scanScheduled          chan struct{}
 	versionCleanupInterval time.Duration
 	versionCleanupTimer    *time.Timer
+	scrubInterval          time.Duration
+	scrubTimer             *time.Timer
+	scrubLimiter           *rate.Limiter // nil if unlimited
+	scrub                  scrubState
 
 	pullScheduled chan struct{}
 	pullPause     time.Duration
 	pullFailTimer *time.Timer
 
-	scanErrors []FileError
-	pullErrors []FileError
-	errorsMut  sync.Mutex
+	scanErrors  []FileError
+	pullErrors  []FileError
+	scrubErrors []FileError
+	errorsMut   sync.Mutex
 
 	doInSyncChan chan syncRequest
 
@@ -118,6 +125,7 @@ func newFolder(model *model, fset *db.FileSet, ignores *ignore.Matcher, cfg conf
 		scanScheduled:          make(chan struct{}, 1),
 		versionCleanupInterval: time.Duration(cfg.Versioning.CleanupIntervalS) * time.Second,
 		versionCleanupTimer:    time.NewTimer(time.Duration(cfg.Versioning.CleanupIntervalS) * time.Second),
+		scrubInterval:          time.Duration(cfg.ScrubIntervalS) * time.Second,
 
 		pullScheduled: make(chan struct{}, 1), // This needs to be 1-buffered so that we queue a pull if we're busy when it comes.
 
@@ -138,6 +146,10 @@ func newFolder(model *model, fset *db.FileSet, ignores *ignore.Matcher, cfg conf
 	f.pullPause = f.pullBasePause()
 	f.pullFailTimer = time.NewTimer(0)
 	<-f.pullFailTimer.C
+	f.scrubTimer = time.NewTimer(f.initialScrubDelay())
+	if cfg.ScrubMaxKiBps > 0 {
+		f.scrubLimiter = rate.NewLimiter(rate.Limit(cfg.ScrubMaxKiBps*1024), protocol.MaxBlockSize)
+	}
 
 	registerFolderMetrics(f.ID)
 
@@ -156,6 +168,7 @@ func (f *folder) Serve(ctx context.Context) error {
 	defer func() {
 		f.scanTimer.Stop()
 		f.versionCleanupTimer.Stop()
+		f.scrubTimer.Stop()
 		f.setState(FolderIdle)
 	}()
 
@@ -171,6 +184,13 @@ func (f *folder) Serve(ctx context.Context) error {
 		}
 	}
 
+	// Likewise for scrubbing, which isn't supported for encrypted folders.
+	if f.scrubInterval == 0 || f.Type == config.FolderTypeReceiveEncrypted {
+		if !f.scrubTimer.Stop() {
+			<-f.scrubTimer.C
+		}
+	}
+
 	initialCompleted := f.initialScanFinished
 
 	for {
@@ -228,6 +248,10 @@ func (f *folder) Serve(ctx context.Context) error {
 		case <-f.versionCleanupTimer.C:
 			l.Debugln(f, "Doing version cleanup")
 			f.versionCleanupTimerFired()
+
+		case <-f.scrubTimer.C:
+			l.Debugln(f, "Scrubbing due to timer")
+			err = f.scrubTimerFired()
 		}
 
 		if err != nil {
@@ -641,24 +665,32 @@ func (f *folder) scanSubdirsChangedAndNew(subDirs []string, batch *scanBatch) (i
 	scanCtx, scanCancel := context.WithCancel(f.ctx)
 	defer scanCancel()
 
+	// Files are only rehashed to fall back to SHA-256, when another device
+	// doesn't support the configured algorithm. Otherwise devices sharing
+	// with different sets of devices could keep rehashing each others
+	// files.
+	hashAlgorithm, hashAlgorithmSettled := f.model.blockHashAlgorithm(f.FolderConfiguration)
 	scanConfig := scanner.Config{
-		Folder:                f.ID,
-		Subs:                  subDirs,
-		Matcher:               f.ignores,
-		TempLifetime:          time.Duration(f.model.cfg.Options().KeepTemporariesH) * time.Hour,
-		CurrentFiler:          cFiler{snap},
-		Filesystem:            f.mtimefs,
-		IgnorePerms:           f.IgnorePerms,
-		AutoNormalize:         f.AutoNormalize,
-		Hashers:               f.model.numHashers(f.ID),
-		ShortID:               f.shortID,
-		ProgressTickIntervalS: f.ScanProgressIntervalS,
-		LocalFlags:            f.localFlags,
-		ModTimeWindow:         f.modTimeWindow,
-		EventLogger:           f.evLogger,
-		ScanOwnership:         f.SendOwnership || f.SyncOwnership,
-		ScanXattrs:            f.SendXattrs || f.SyncXattrs,
-		XattrFilter:           f.XattrFilter,
+		Folder:                 f.ID,
+		Subs:                   subDirs,
+		Matcher:                f.ignores,
+		TempLifetime:           time.Duration(f.model.cfg.Options().KeepTemporariesH) * time.Hour,
+		CurrentFiler:           cFiler{snap},
+		Filesystem:             f.mtimefs,
+		IgnorePerms:            f.IgnorePerms,
+		AutoNormalize:          f.AutoNormalize,
+		Hashers:                f.model.numHashers(f.ID),
+		ShortID:                f.shortID,
+		ProgressTickIntervalS:  f.ScanProgressIntervalS,
+		LocalFlags:             f.localFlags,
+		ModTimeWindow:          f.modTimeWindow,
+		EventLogger:            f.evLogger,
+		ScanOwnership:          f.SendOwnership || f.SyncOwnership,
+		ScanXattrs:             f.SendXattrs || f.SyncXattrs,
+		XattrFilter:            f.XattrFilter,
+		ContentDefinedChunking: f.model.contentDefinedChunking(f.FolderConfiguration),
+		HashAlgorithm:          hashAlgorithm,
+		RehashOtherAlgorithms:  hashAlgorithmSettled && hashAlgorithm == protocol.HashAlgorithmSHA256,
 	}
 	var fchan chan scanner.ScanResult
 	if f.Type == config.FolderTypeReceiveEncrypted {
@@ -791,6 +823,11 @@ func (f *folder) scanSubdirsDeletedAndIgnored(subDirs []string, batch *scanBatch
 					// the deleted file. Setting to an empty version makes
 					// sure the file gets in sync on the following pull.
 					nf.Version = protocol.Vector{}
+				} else if fi.IsIgnored() && f.Type == config.FolderTypeSelective {
+					// The item was evicted or never pulled as it wasn't
+					// selected, so it isn't deleted, we just don't have it.
+					// With the empty version it gets pulled.
+					nf.Version = protocol.Vector{}
 				}
 				l.Debugln("marking file as deleted", nf)
 				if batch.Update(nf, snap) {
@@ -1196,10 +1233,10 @@ outer:
 func (f *folder) Errors() []FileError {
 	f.errorsMut.Lock()
 	defer f.errorsMut.Unlock()
-	scanLen := len(f.scanErrors)
-	errors := make([]FileError, scanLen+len(f.pullErrors))
-	copy(errors[:scanLen], f.scanErrors)
-	copy(errors[scanLen:], f.pullErrors)
+	errors := make([]FileError, 0, len(f.scanErrors)+len(f.pullErrors)+len(f.scrubErrors))
+	errors = append(errors, f.scanErrors...)
+	errors = append(errors, f.pullErrors...)
+	errors = append(errors, f.scrubErrors...)
 	sort.Sort(fileErrorList(errors))
 	return errors
 }
@@ -1239,6 +1276,8 @@ func (f *folder) updateLocals(fs []protocol.FileInfo) {
 		delete(f.forcedRescanPaths, file.Name)
 	}
 	f.forcedRescanPathsMut.Unlock()
+	// Likewise a scrub mismatch is resolved by the file changing.
+	f.clearScrubErrors(filenames)
 
 	seq := f.fset.Sequence(protocol.LocalDeviceID)
 	f.evLogger.Log(events.LocalIndexUpdated, map[string]interface{}{
@@ -0,0 +1,366 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+/*
+Scrubbing verifies the contents of files on disk against the block hashes in
+the database, to detect corruption that a scan doesn't notice as the size and
+modification time are unchanged. It works as follows:
+
+  - Scrubbing happens in batches from the folder's main loop, with the
+    progress kept in scrubState, such that scans and pulls aren't held up
+    for the duration of a scrub of a large folder.
+
+  - Files are verified in the order of their local sequence numbers, so
+    that each batch continues from where the previous one stopped in the
+    sequence index. Files that change during the scrub get a sequence
+    number after the end of the scrub and are skipped, having just been
+    hashed anyway.
+
+  - Files that changed on disk since they were last scanned are skipped, as
+    a difference to the database is expected. The next scan takes care of
+    them.
+
+  - A mismatch is reported as a folder error and event. If repair is
+    enabled and another device has the same version of the file, we record
+    what is actually on disk with an empty version, like a receive-only
+    revert. The puller then replaces the file with the global version,
+    reusing the intact blocks and fetching the others. We never announce
+    the corrupted contents as a new version.
+*/
+
+const (
+	// The maximum number of files verified in one batch.
+	scrubBatchSize = 100
+	// The time after which we end a batch, between files.
+	scrubBatchTime = 30 * time.Second
+	// How long to wait for the initial scan to complete before scrubbing.
+	scrubInitialScanWait = time.Minute
+)
+
+var errScrubFileChanged = errors.New("file changed on disk")
+
+// scrubState tracks the progress of an ongoing scrub.
+type scrubState struct {
+	started    time.Time
+	cursor     int64 // the sequence number of the last verified file
+	end        int64 // the local sequence number when the scrub started
+	files      int
+	bytes      int64
+	mismatches int
+}
+
+// initialScrubDelay returns the time until the next scrub is due, based on
+// when the last one completed.
+func (f *folder) initialScrubDelay() time.Duration {
+	last, err := f.GetLastScrubTime()
+	if err != nil {
+		l.Debugln(f, "getting last scrub time:", err)
+		return f.scrubInterval
+	}
+	return max(0, f.scrubInterval-time.Since(last))
+}
+
+func (f *folder) scrubTimerFired() error {
+	select {
+	case <-f.initialScanFinished:
+	default:
+		// The database needs to be up to date with what's on disk.
+		f.scrubTimer.Reset(scrubInitialScanWait)
+		return nil
+	}
+
+	done, err := f.scrubBatch()
+	switch {
+	case err != nil:
+		f.scrub = scrubState{}
+		f.scrubTimer.Reset(f.scrubInterval)
+		return err
+	case done:
+		f.scrubTimer.Reset(f.scrubInterval)
+	default:
+		// Continue right away, after anything else that's pending.
+		f.scrubTimer.Reset(0)
+	}
+	return nil
+}
+
+// scrubBatch verifies the next batch of files, and returns true when the
+// scrub of the whole folder is complete.
+func (f *folder) scrubBatch() (bool, error) {
+	f.setState(FolderScrubWaiting)
+	defer f.setState(FolderIdle)
+
+	if err := f.ioLimiter.TakeWithContext(f.ctx, 1); err != nil {
+		return false, nil
+	}
+	defer f.ioLimiter.Give(1)
+
+	f.setState(FolderScrubbing)
+
+	if f.scrub.started.IsZero() {
+		l.Infof("Started scrubbing folder %s", f.Description())
+		f.scrub = scrubState{started: time.Now()}
+		snap, err := f.dbSnapshot()
+		if err != nil {
+			return false, err
+		}
+		f.scrub.end = snap.Sequence(protocol.LocalDeviceID)
+		snap.Release()
+		f.errorsMut.Lock()
+		f.scrubErrors = nil
+		f.errorsMut.Unlock()
+	}
+
+	batch, more, err := f.nextScrubBatch()
+	if err != nil {
+		return false, err
+	}
+
+	mismatches := f.scrub.mismatches
+	deadline := time.Now().Add(scrubBatchTime)
+	for i, item := range batch {
+		if f.ctx.Err() != nil || (i > 0 && time.Now().After(deadline)) {
+			more = true
+			break
+		}
+		if err := f.scrubFile(item.name); err != nil {
+			return false, err
+		}
+		f.scrub.cursor = item.sequence
+	}
+
+	if f.scrub.mismatches > mismatches {
+		f.evLogger.Log(events.FolderErrors, map[string]interface{}{
+			"folder": f.folderID,
+			"errors": f.Errors(),
+		})
+	}
+
+	if more {
+		return false, nil
+	}
+
+	l.Infof("Completed scrubbing folder %s: verified %d files (%d bytes), %d mismatches", f.Description(), f.scrub.files, f.scrub.bytes, f.scrub.mismatches)
+	f.evLogger.Log(events.FolderScrubCompleted, map[string]interface{}{
+		"folder":     f.ID,
+		"files":      f.scrub.files,
+		"bytes":      f.scrub.bytes,
+		"mismatches": f.scrub.mismatches,
+		"duration":   time.Since(f.scrub.started).Seconds(),
+	})
+	if err := f.ScrubCompleted(); err != nil {
+		l.Debugln(f, "recording scrub completion:", err)
+	}
+	f.scrub = scrubState{}
+	return true, nil
+}
+
+type scrubItem struct {
+	name     string
+	sequence int64
+}
+
+// nextScrubBatch returns the next files to verify, after the cursor, and
+// whether there are more to come after those.
+func (f *folder) nextScrubBatch() ([]scrubItem, bool, error) {
+	snap, err := f.dbSnapshot()
+	if err != nil {
+		return nil, false, err
+	}
+	defer snap.Release()
+
+	var batch []scrubItem
+	more := false
+	snap.WithHaveSequence(f.scrub.cursor+1, func(fi protocol.FileInfo) bool {
+		if fi.Sequence > f.scrub.end {
+			return false
+		}
+		if !scrubbable(fi) {
+			return true
+		}
+		if len(batch) == scrubBatchSize {
+			more = true
+			return false
+		}
+		batch = append(batch, scrubItem{fi.Name, fi.Sequence})
+		return true
+	})
+	return batch, more, nil
+}
+
+func scrubbable(fi protocol.FileInfo) bool {
+	return fi.Type == protocol.FileInfoTypeFile && !fi.IsDeleted() && !fi.IsInvalid()
+}
+
+func (f *folder) scrubFile(name string) error {
+	snap, err := f.dbSnapshot()
+	if err != nil {
+		return err
+	}
+	fi, ok := snap.Get(protocol.LocalDeviceID, name)
+	snap.Release()
+	if !ok || !scrubbable(fi) {
+		return nil
+	}
+
+	bad, hashes, err := f.verifyFile(fi)
+	switch {
+	case errors.Is(err, errScrubFileChanged) || fs.IsNotExist(err) || f.ctx.Err() != nil:
+		// The file changed or vanished, which the next scan will take
+		// care of, or we are stopping.
+		l.Debugf("%v scrub: skipping %v: %v", f, name, err)
+		return nil
+	case err != nil:
+		// Failing to read is as much a sign of trouble as a mismatch.
+		l.Warnf("Folder %s, item %q: scrub: %v", f.Description(), name, err)
+		f.newScrubError(name, fmt.Errorf("scrub: %w", err))
+		f.scrub.mismatches++
+		return nil
+	}
+	f.scrub.files++
+	f.scrub.bytes += fi.Size
+	if len(bad) == 0 {
+		return nil
+	}
+
+	f.scrub.mismatches++
+	repairing := f.ScrubRepair && f.Type != config.FolderTypeSendOnly && f.repairFromRemote(fi, hashes)
+	msg := fmt.Sprintf("scrub: %d of %d blocks don't match the database", len(bad), len(fi.Blocks))
+	if repairing {
+		msg += ", re-fetching from remote devices"
+	}
+	l.Warnf("Folder %s, item %q: %s", f.Description(), name, msg)
+	f.newScrubError(name, errors.New(msg))
+	f.evLogger.Log(events.FolderScrubMismatch, map[string]interface{}{
+		"folder":    f.ID,
+		"item":      name,
+		"blocks":    bad,
+		"repairing": repairing,
+	})
+	return nil
+}
+
+// verifyFile hashes the file on disk, returning the indexes of the blocks
+// that don't match the given file info and the hashes of all blocks.
+func (f *folder) verifyFile(fi protocol.FileInfo) ([]int, [][]byte, error) {
+	if !f.unchangedOnDisk(fi) {
+		return nil, nil, errScrubFileChanged
+	}
+
+	fd, err := f.mtimefs.Open(fi.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer fd.Close()
+
+	var bad []int
+	hashes := make([][]byte, len(fi.Blocks))
+	for i, block := range fi.Blocks {
+		if f.scrubLimiter != nil {
+			if err := f.scrubLimiter.WaitN(f.ctx, block.Size); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		buf := protocol.BufferPool.Get(block.Size)
+		_, err := fd.ReadAt(buf, block.Offset)
+		if err != nil {
+			protocol.BufferPool.Put(buf)
+			return nil, nil, err
+		}
+		hashes[i] = protocol.SumBlock(fi.BlockHashAlgorithm, buf)
+		protocol.BufferPool.Put(buf)
+
+		if !bytes.Equal(hashes[i], block.Hash) {
+			bad = append(bad, i)
+		}
+	}
+
+	// A modification while we were reading explains any mismatch.
+	if !f.unchangedOnDisk(fi) {
+		return nil, nil, errScrubFileChanged
+	}
+	return bad, hashes, nil
+}
+
+// unchangedOnDisk returns whether the file on disk matches the file info,
+// as far as we can tell without hashing it.
+func (f *folder) unchangedOnDisk(fi protocol.FileInfo) bool {
+	info, err := f.mtimefs.Lstat(fi.Name)
+	if err != nil {
+		return false
+	}
+	return info.IsRegular() && info.Size() == fi.Size && protocol.ModTimeEqual(info.ModTime(), fi.ModTime(), f.modTimeWindow)
+}
+
+// repairFromRemote makes the puller fetch the file again, if another device
+// has the version we have. It returns whether that's the case.
+func (f *folder) repairFromRemote(fi protocol.FileInfo, hashes [][]byte) bool {
+	snap, err := f.dbSnapshot()
+	if err != nil {
+		return false
+	}
+	defer snap.Release()
+
+	if gf, ok := snap.GetGlobal(fi.Name); !ok || !gf.Version.Equal(fi.Version) {
+		// We aren't in sync anyway, the puller will take care of it.
+		return false
+	}
+	if !slices.ContainsFunc(snap.Availability(fi.Name), func(dev protocol.DeviceID) bool {
+		return dev != protocol.LocalDeviceID
+	}) {
+		return false
+	}
+
+	// The empty version is strictly older than anything else, hence the
+	// global version gets pulled. The blocks reflect what's on disk, such
+	// that the puller doesn't take it as a metadata only change.
+	fi.Version = protocol.Vector{}
+	fi.Blocks = slices.Clone(fi.Blocks)
+	for i := range fi.Blocks {
+		fi.Blocks[i].Hash = hashes[i]
+	}
+	fi.BlocksHash = protocol.BlocksHash(fi.Blocks)
+	f.updateLocals([]protocol.FileInfo{fi})
+	f.SchedulePull()
+	return true
+}
+
+func (f *folder) newScrubError(path string, err error) {
+	f.errorsMut.Lock()
+	f.scrubErrors = append(f.scrubErrors, FileError{
+		Err:  err.Error(),
+		Path: path,
+	})
+	f.errorsMut.Unlock()
+}
+
+// clearScrubErrors removes the scrub errors for the given paths.
+func (f *folder) clearScrubErrors(paths []string) {
+	f.errorsMut.Lock()
+	defer f.errorsMut.Unlock()
+	if len(f.scrubErrors) == 0 {
+		return
+	}
+	f.scrubErrors = slices.DeleteFunc(f.scrubErrors, func(fe FileError) bool {
+		return slices.Contains(paths, fe.Path)
+	})
+}
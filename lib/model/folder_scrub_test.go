@@ -0,0 +1,161 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestScrubDetectsCorruption(t *testing.T) {
+	w, fcfg, cancel := newDefaultCfgWrapper()
+	defer cancel()
+	ffs := fcfg.Filesystem(nil)
+	m := setupModel(t, w)
+	defer cleanupModel(m)
+
+	writeFile(t, ffs, "intact", []byte("intact"))
+	writeFile(t, ffs, "corrupt", []byte("original"))
+	must(t, m.ScanFolder(fcfg.ID))
+	before, _ := localFile(t, m, fcfg.ID, "corrupt")
+
+	sub := m.evLogger.Subscribe(events.FolderScrubMismatch)
+	defer sub.Unsubscribe()
+
+	corrupt(t, ffs, "corrupt", []byte("0riginal"))
+	// A scan doesn't notice.
+	must(t, m.ScanFolder(fcfg.ID))
+	scrub(t, m, fcfg.ID)
+
+	errs, err := m.FolderErrors(fcfg.ID)
+	must(t, err)
+	if len(errs) != 1 || errs[0].Path != "corrupt" {
+		t.Fatal("expected a scrub error for the corrupt file, got", errs)
+	}
+	select {
+	case ev := <-sub.C():
+		if data := ev.Data.(map[string]interface{}); data["item"] != "corrupt" || data["repairing"] != false {
+			t.Error("unexpected event data", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the mismatch event")
+	}
+
+	// The corruption isn't propagated.
+	if after, _ := localFile(t, m, fcfg.ID, "corrupt"); !after.IsEquivalent(before, 0) || !after.Version.Equal(before.Version) {
+		t.Error("file info should be unchanged after scrubbing, got", after)
+	}
+
+	// Changing the file resolves the error.
+	writeFile(t, ffs, "corrupt", []byte("rewritten"))
+	must(t, m.ScanFolder(fcfg.ID))
+	errs, err = m.FolderErrors(fcfg.ID)
+	must(t, err)
+	if len(errs) != 0 {
+		t.Error("expected no errors after changing the file, got", errs)
+	}
+}
+
+func TestScrubRepair(t *testing.T) {
+	w, fcfg, cancel := newDefaultCfgWrapper()
+	defer cancel()
+	fcfg.ScrubRepair = true
+	setFolder(t, w, fcfg)
+	ffs := fcfg.Filesystem(nil)
+	m := setupModel(t, w)
+	defer cleanupModel(m)
+
+	conn := addFakeConn(m, device1, fcfg.ID)
+	conn.addFile("file", 0o644, protocol.FileInfoTypeFile, []byte("remote data"))
+	conn.sendIndexUpdate()
+	waitFor(t, func() bool {
+		_, ok := localFile(t, m, fcfg.ID, "file")
+		return ok
+	})
+
+	corrupt(t, ffs, "file", []byte("remote_data"))
+	scrub(t, m, fcfg.ID)
+
+	// The file gets pulled again, which resolves the error.
+	waitFor(t, func() bool {
+		errs, err := m.FolderErrors(fcfg.ID)
+		must(t, err)
+		return len(errs) == 0
+	})
+	if content := readContent(t, ffs, "file"); content != "remote data" {
+		t.Errorf("unexpected content after repair %q", content)
+	}
+	fi, _ := localFile(t, m, fcfg.ID, "file")
+	gf, _, err := m.CurrentGlobalFile(fcfg.ID, "file")
+	must(t, err)
+	if !fi.Version.Equal(gf.Version) {
+		t.Errorf("expected the global version after repair, got %v", fi.Version)
+	}
+}
+
+func TestScrubBatches(t *testing.T) {
+	w, fcfg, cancel := newDefaultCfgWrapper()
+	defer cancel()
+	ffs := fcfg.Filesystem(nil)
+	m := setupModel(t, w)
+	defer cleanupModel(m)
+
+	const files = 2*scrubBatchSize + 10
+	must(t, ffs.Mkdir("dir", 0o755))
+	for i := 0; i < files; i++ {
+		writeFile(t, ffs, fmt.Sprintf("dir/file%d", i), []byte("data"))
+	}
+	must(t, m.ScanFolder(fcfg.ID))
+
+	sub := m.evLogger.Subscribe(events.FolderScrubCompleted)
+	defer sub.Unsubscribe()
+	scrub(t, m, fcfg.ID)
+
+	// Every file is verified once, across batches.
+	select {
+	case ev := <-sub.C():
+		if data := ev.Data.(map[string]interface{}); data["files"] != files || data["mismatches"] != 0 {
+			t.Error("unexpected event data", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the completion event")
+	}
+}
+
+// corrupt replaces the contents of the file without changing its size,
+// permissions and modification time.
+func corrupt(t *testing.T, filesystem fs.Filesystem, name string, data []byte) {
+	t.Helper()
+	info, err := filesystem.Lstat(name)
+	must(t, err)
+	if info.Size() != int64(len(data)) {
+		t.Fatal("corrupted data must have the same size")
+	}
+	writeFile(t, filesystem, name, data)
+	must(t, filesystem.Chmod(name, info.Mode()))
+	must(t, filesystem.Chtimes(name, info.ModTime(), info.ModTime()))
+}
+
+func scrub(t *testing.T, m *testModel, folder string) {
+	t.Helper()
+	m.mut.RLock()
+	r, _ := m.folderRunners.Get(folder)
+	m.mut.RUnlock()
+	f := r.(*sendReceiveFolder)
+	must(t, f.doInSync(func() error {
+		for {
+			if done, err := f.scrubBatch(); done || err != nil {
+				return err
+			}
+		}
+	}))
+}
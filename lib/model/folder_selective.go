@@ -0,0 +1,149 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"bytes"
+	"context"
+	"sort"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/ignore"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/semaphore"
+	"github.com/syncthing/syncthing/lib/versioner"
+)
+
+func init() {
+	folderFactories[config.FolderTypeSelective] = newSelectiveFolder
+}
+
+/*
+selectiveFolder is a folder that keeps the full global index, but only
+materialises the selected paths on disk. It works as follows:
+
+  - Items that aren't selected are treated like ignored items by the
+    folder's ignore matcher. Hence they are neither pulled nor scanned, and
+    when we announce them they have the ignored flag set, which remote
+    devices don't take as a deletion. Their global metadata is still known,
+    e.g. for browsing.
+
+  - The selection is part of the folder configuration, so changing it
+    restarts the folder. The initial scan then marks items that are no
+    longer selected as ignored and picks up newly selected ones, which have
+    an empty version if they aren't present, such that they get pulled.
+
+  - After the initial scan, items that are no longer selected are evicted,
+    i.e. removed from disk without any further change to the database.
+    Files are only evicted if they are unchanged from the global version, so
+    that no local modifications are lost.
+
+Implementation wise a selectiveFolder is a sendReceiveFolder that evicts
+deselected items after the initial scan.
+*/
+type selectiveFolder struct {
+	*sendReceiveFolder
+}
+
+func newSelectiveFolder(model *model, fset *db.FileSet, ignores *ignore.Matcher, cfg config.FolderConfiguration, ver versioner.Versioner, evLogger events.Logger, ioLimiter *semaphore.Semaphore) service {
+	sr := newSendReceiveFolder(model, fset, ignores, cfg, ver, evLogger, ioLimiter).(*sendReceiveFolder)
+	return &selectiveFolder{sr}
+}
+
+func (f *selectiveFolder) Serve(ctx context.Context) error {
+	go func() {
+		select {
+		case <-f.initialScanFinished:
+			f.doInSync(f.evict)
+		case <-ctx.Done():
+		}
+	}()
+	return f.sendReceiveFolder.Serve(ctx)
+}
+
+// evict removes items that aren't selected from disk.
+func (f *selectiveFolder) evict() error {
+	snap, err := f.dbSnapshot()
+	if err != nil {
+		return err
+	}
+	defer snap.Release()
+
+	var dirs []string
+	evicted := 0
+	snap.WithHaveTruncated(protocol.LocalDeviceID, func(fi protocol.FileInfo) bool {
+		select {
+		case <-f.ctx.Done():
+			return false
+		default:
+		}
+
+		if !fi.IsIgnored() || f.IsSelected(fi.Name) {
+			return true
+		}
+		if fi.IsDirectory() {
+			dirs = append(dirs, fi.Name)
+			return true
+		}
+		gf, ok := snap.GetGlobalTruncated(fi.Name)
+		if !ok || !f.unchangedFromGlobal(gf) {
+			return true
+		}
+		if err := f.inWritableDir(f.mtimefs.Remove, fi.Name); err != nil && !fs.IsNotExist(err) {
+			l.Infof("Evicting %s from folder %s: %v", fi.Name, f.Description(), err)
+			return true
+		}
+		evicted++
+		return true
+	})
+	if err := f.ctx.Err(); err != nil {
+		return err
+	}
+
+	// Remove directories after their contents, i.e. children first. Those
+	// still containing something that wasn't evicted are left in place.
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, dir := range dirs {
+		if err := f.inWritableDir(f.mtimefs.Remove, dir); err != nil {
+			l.Debugln(f, "not evicting directory", dir, err)
+			continue
+		}
+		evicted++
+	}
+
+	if evicted > 0 {
+		l.Infof("Evicted %d deselected items from folder %s", evicted, f.Description())
+	}
+	return nil
+}
+
+// unchangedFromGlobal returns whether the file on disk is the global
+// version, as far as we can tell without hashing it.
+func (f *selectiveFolder) unchangedFromGlobal(gf protocol.FileInfo) bool {
+	if gf.IsDeleted() || gf.IsInvalid() {
+		return false
+	}
+	info, err := f.mtimefs.Lstat(gf.Name)
+	if err != nil {
+		return false
+	}
+	switch {
+	case gf.IsSymlink():
+		if !info.IsSymlink() {
+			return false
+		}
+		target, err := f.mtimefs.ReadSymlink(gf.Name)
+		return err == nil && bytes.Equal([]byte(target), gf.SymlinkTarget)
+	case gf.IsDirectory():
+		return false
+	default:
+		return info.IsRegular() && info.Size() == gf.Size && protocol.ModTimeEqual(info.ModTime(), gf.ModTime(), f.modTimeWindow)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestSelectiveFolder(t *testing.T) {
+	w, cancel := newConfigWrapper(defaultCfg)
+	defer cancel()
+	cfg := w.RawCopy()
+	fcfg := newFolderConfig()
+	fcfg.ID = "sel"
+	fcfg.Type = config.FolderTypeSelective
+	fcfg.SelectedPaths = []string{"keep"}
+	cfg.Folders = []config.FolderConfiguration{fcfg}
+	replace(t, w, cfg)
+	ffs := fcfg.Filesystem(nil)
+
+	m := setupModel(t, w)
+	defer cleanupModel(m)
+	conn := addFakeConn(m, device1, fcfg.ID)
+	conn.addFile("keep", 0o755, protocol.FileInfoTypeDirectory, nil)
+	conn.addFile("keep/a", 0o644, protocol.FileInfoTypeFile, []byte("a"))
+	conn.addFile("other", 0o755, protocol.FileInfoTypeDirectory, nil)
+	conn.addFile("other/b", 0o644, protocol.FileInfoTypeFile, []byte("b"))
+	conn.sendIndexUpdate()
+
+	// Only the selected file is pulled, the other one is known as ignored.
+	waitFor(t, func() bool {
+		fi, ok := localFile(t, m, fcfg.ID, "other/b")
+		return exists(ffs, "keep/a") && ok && fi.IsIgnored()
+	})
+	if exists(ffs, "other") {
+		t.Error("deselected directory should not have been pulled")
+	}
+
+	tree, err := m.GlobalDirectoryTree(fcfg.ID, "", -1, false)
+	must(t, err)
+	for _, entry := range tree {
+		if entry.Placeholder != (entry.Name == "other") {
+			t.Errorf("unexpected placeholder state of %v: %v", entry.Name, entry.Placeholder)
+		}
+	}
+
+	// Selecting the other directory pulls it.
+	setSelectedPaths(t, w, fcfg.ID, "keep", "other")
+	waitFor(t, func() bool {
+		return exists(ffs, "other/b")
+	})
+
+	// Deselecting evicts the directory, apart from local only files.
+	writeFile(t, ffs, "keep/local", []byte("local"))
+	must(t, m.ScanFolder(fcfg.ID))
+	setSelectedPaths(t, w, fcfg.ID, "other")
+	waitFor(t, func() bool {
+		return !exists(ffs, "keep/a")
+	})
+	if !exists(ffs, "keep/local") {
+		t.Error("local only file should not have been evicted")
+	}
+	fi, ok := localFile(t, m, fcfg.ID, "keep/a")
+	if !ok || !fi.IsIgnored() || fi.IsDeleted() {
+		t.Errorf("evicted file should be ignored, not deleted: %v", fi)
+	}
+	snap := dbSnapshot(t, m, fcfg.ID)
+	defer snap.Release()
+	if gf, ok := snap.GetGlobal("keep/a"); !ok || gf.IsDeleted() {
+		t.Errorf("evicted file should not be deleted globally: %v", gf)
+	}
+}
+
+func setSelectedPaths(t *testing.T, w config.Wrapper, folder string, paths ...string) {
+	t.Helper()
+	waiter, err := w.Modify(func(cfg *config.Configuration) {
+		_, i, _ := cfg.Folder(folder)
+		cfg.Folders[i].SelectedPaths = paths
+	})
+	must(t, err)
+	waiter.Wait()
+}
+
+func localFile(t *testing.T, m *testModel, folder, name string) (protocol.FileInfo, bool) {
+	t.Helper()
+	fi, ok, err := m.CurrentFolderFile(folder, name)
+	must(t, err)
+	return fi, ok
+}
+
+func exists(filesystem fs.Filesystem, name string) bool {
+	_, err := filesystem.Lstat(name)
+	return err == nil
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	timeout := time.After(10 * time.Second)
+	for !cond() {
+		select {
+		case <-timeout:
+			t.Fatal("timed out")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
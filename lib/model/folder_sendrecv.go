@@ -9,7 +9,6 @@ package model
 import (
 	"bytes"
 	"context"
-	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
@@ -39,6 +38,12 @@ var (
 	blockStatsMut = sync.NewMutex()
 )
 
+// minBlocks returns the number of blocks of the minimum block size needed
+// for the given number of bytes, which is the unit of the block stats.
+func minBlocks(bytes int64) int {
+	return int((bytes + protocol.MinBlockSize - 1) / protocol.MinBlockSize)
+}
+
 func init() {
 	folderFactories[config.FolderTypeSendReceive] = newSendReceiveFolder
 }
@@ -125,6 +130,8 @@ type sendReceiveFolder struct {
 
 	queue              *jobQueue
 	blockPullReorderer blockPullReorderer
+	conflictResolver   *conflictResolver
+	pullPrioritizer    *pullPrioritizer
 	writeLimiter       *semaphore.Semaphore
 
 	tempPullErrors map[string]string // pull errors that might be just transient
@@ -138,6 +145,8 @@ func newSendReceiveFolder(model *model, fset *db.FileSet, ignores *ignore.Matche
 		writeLimiter:       semaphore.New(cfg.MaxConcurrentWrites),
 	}
 	f.folder.puller = f
+	f.conflictResolver = newConflictResolver(cfg, f.mtimefs)
+	f.pullPrioritizer = newPullPrioritizer(cfg, f.mtimefs)
 
 	if f.Copiers == 0 {
 		f.Copiers = defaultCopiers
@@ -448,6 +457,12 @@ func (f *sendReceiveFolder) processNeeded(snap *db.Snapshot, dbUpdateChan chan<-
 		f.queue.SortNewestFirst()
 	}
 
+	// Pull by priority tier first, keeping the order within each tier.
+
+	if f.pullPrioritizer.enabled() {
+		f.queue.SortByPriority(f.pullPrioritizer.priority)
+	}
+
 	// Process the file queue.
 
 nextFile:
@@ -603,9 +618,7 @@ func (f *sendReceiveFolder) handleDir(file protocol.FileInfo, snap *db.Snapshot,
 			// archiving.
 			// Symlinks aren't checked for conflicts.
 
-			err = f.inWritableDir(func(name string) error {
-				return f.moveForConflict(name, file.ModifiedBy.String(), scanChan)
-			}, curFile.Name)
+			err = f.moveConflictingOutOfTheWay(config.ConflictRule{}, file, curFile, scanChan)
 		} else {
 			err = f.deleteItemOnDisk(curFile, snap, scanChan)
 		}
@@ -793,9 +806,7 @@ func (f *sendReceiveFolder) handleSymlinkCheckExisting(file protocol.FileInfo, s
 		// archiving.
 		// Directories and symlinks aren't checked for conflicts.
 
-		return f.inWritableDir(func(name string) error {
-			return f.moveForConflict(name, file.ModifiedBy.String(), scanChan)
-		}, curFile.Name)
+		return f.moveConflictingOutOfTheWay(config.ConflictRule{}, file, curFile, scanChan)
 	} else {
 		return f.deleteItemOnDisk(curFile, snap, scanChan)
 	}
@@ -1032,9 +1043,8 @@ func (f *sendReceiveFolder) renameFile(cur, source, target protocol.FileInfo, sn
 	}
 
 	blockStatsMut.Lock()
-	minBlocksPerBlock := target.BlockSize() / protocol.MinBlockSize
-	blockStats["total"] += len(target.Blocks) * minBlocksPerBlock
-	blockStats["renamed"] += len(target.Blocks) * minBlocksPerBlock
+	blockStats["total"] += minBlocks(target.Size)
+	blockStats["renamed"] += minBlocks(target.Size)
 	blockStatsMut.Unlock()
 
 	// The file was renamed, so we have handled both the necessary delete
@@ -1136,12 +1146,18 @@ func (f *sendReceiveFolder) handleFile(file protocol.FileInfo, snap *db.Snapshot
 func (f *sendReceiveFolder) reuseBlocks(blocks []protocol.BlockInfo, reused []int, file protocol.FileInfo, tempName string) ([]protocol.BlockInfo, []int) {
 	// Check for an old temporary file which might have some blocks we could
 	// reuse.
-	tempBlocks, err := scanner.HashFile(f.ctx, f.ID, f.mtimefs, tempName, file.BlockSize(), nil, false)
+	hashFile := func() ([]protocol.BlockInfo, error) {
+		if file.ContentDefinedBlocks() {
+			return scanner.HashFileChunked(f.ctx, f.ID, f.mtimefs, tempName, file.BlockHashAlgorithm, file.BlockSize(), nil)
+		}
+		return scanner.HashFile(f.ctx, f.ID, f.mtimefs, tempName, file.BlockHashAlgorithm, file.BlockSize(), nil, false)
+	}
+	tempBlocks, err := hashFile()
 	if err != nil {
 		var caseErr *fs.ErrCaseConflict
 		if errors.As(err, &caseErr) {
 			if rerr := f.mtimefs.Rename(caseErr.Real, tempName); rerr == nil {
-				tempBlocks, err = scanner.HashFile(f.ctx, f.ID, f.mtimefs, tempName, file.BlockSize(), nil, false)
+				tempBlocks, err = hashFile()
 			}
 		}
 	}
@@ -1312,6 +1328,10 @@ func (f *sendReceiveFolder) copierRoutine(in <-chan copyBlocksState, pullChan ch
 
 		weakHashFinder, file := f.initWeakHashFinder(state)
 
+		// The source file may use another block size than ours, or content
+		// defined chunking, so the positions of its blocks are looked up.
+		srcOffsets := make(map[string][]int64)
+
 	blocks:
 		for _, block := range state.blocks {
 			select {
@@ -1339,7 +1359,7 @@ func (f *sendReceiveFolder) copierRoutine(in <-chan copyBlocksState, pullChan ch
 			var found bool
 			if f.Type != config.FolderTypeReceiveEncrypted {
 				found, err = weakHashFinder.Iterate(block.WeakHash, buf, func(offset int64) bool {
-					if f.verifyBuffer(buf, block) != nil {
+					if f.verifyBuffer(buf, block, state.file.BlockHashAlgorithm) != nil {
 						return true
 					}
 
@@ -1361,7 +1381,7 @@ func (f *sendReceiveFolder) copierRoutine(in <-chan copyBlocksState, pullChan ch
 			}
 
 			if !found {
-				found = f.model.finder.Iterate(folders, block.Hash, func(folder, path string, index int32) bool {
+				found = f.model.finder.Iterate(folders, block.Hash, state.file.BlockHashAlgorithm, func(folder, path string, index int32) bool {
 					ffs := folderFilesystems[folder]
 					fd, err := ffs.Open(path)
 					if err != nil {
@@ -1369,7 +1389,10 @@ func (f *sendReceiveFolder) copierRoutine(in <-chan copyBlocksState, pullChan ch
 					}
 					defer fd.Close()
 
-					srcOffset := int64(state.file.BlockSize()) * int64(index)
+					srcOffset, ok := f.sourceBlockOffset(srcOffsets, folder, path, index)
+					if !ok {
+						return false
+					}
 					_, err = fd.ReadAt(buf, srcOffset)
 					if err != nil {
 						return false
@@ -1379,7 +1402,7 @@ func (f *sendReceiveFolder) copierRoutine(in <-chan copyBlocksState, pullChan ch
 					// case we can't verify the block integrity so we'll take it on
 					// trust. (The other side can and will verify.)
 					if f.Type != config.FolderTypeReceiveEncrypted {
-						if err := f.verifyBuffer(buf, block); err != nil {
+						if err := f.verifyBuffer(buf, block, state.file.BlockHashAlgorithm); err != nil {
 							l.Debugln("Finder failed to verify buffer", err)
 							return false
 						}
@@ -1431,6 +1454,26 @@ func (f *sendReceiveFolder) copierRoutine(in <-chan copyBlocksState, pullChan ch
 	}
 }
 
+// sourceBlockOffset returns the offset of the block with the given index in
+// the file, as known in the database. The offsets are cached per file.
+func (f *sendReceiveFolder) sourceBlockOffset(cache map[string][]int64, folder, name string, index int32) (int64, bool) {
+	key := folder + "/" + name
+	offsets, ok := cache[key]
+	if !ok {
+		if fi, ok, err := f.model.CurrentFolderFile(folder, name); err == nil && ok {
+			offsets = make([]int64, len(fi.Blocks))
+			for i, b := range fi.Blocks {
+				offsets[i] = b.Offset
+			}
+		}
+		cache[key] = offsets
+	}
+	if index < 0 || int(index) >= len(offsets) {
+		return 0, false
+	}
+	return offsets[index], true
+}
+
 func (f *sendReceiveFolder) initWeakHashFinder(state copyBlocksState) (*weakhash.Finder, fs.File) {
 	if f.Type == config.FolderTypeReceiveEncrypted {
 		l.Debugln("not weak hashing due to folder type", f.Type)
@@ -1473,13 +1516,13 @@ func (f *sendReceiveFolder) initWeakHashFinder(state copyBlocksState) (*weakhash
 	return weakHashFinder, file
 }
 
-func (*sendReceiveFolder) verifyBuffer(buf []byte, block protocol.BlockInfo) error {
+func (*sendReceiveFolder) verifyBuffer(buf []byte, block protocol.BlockInfo, algo protocol.HashAlgorithm) error {
 	if len(buf) != int(block.Size) {
 		return fmt.Errorf("length mismatch %d != %d", len(buf), block.Size)
 	}
 
-	hash := sha256.Sum256(buf)
-	if !bytes.Equal(hash[:], block.Hash) {
+	hash := protocol.SumBlock(algo, buf)
+	if !bytes.Equal(hash, block.Hash) {
 		return fmt.Errorf("hash mismatch %x != %x", hash, block.Hash)
 	}
 
@@ -1573,8 +1616,8 @@ loop:
 		// leastBusy can select another device when someone else asks.
 		activity.using(selected)
 		var buf []byte
-		blockNo := int(state.block.Offset / int64(state.file.BlockSize()))
-		buf, lastError = f.model.RequestGlobal(f.ctx, selected.ID, f.folderID, state.file.Name, blockNo, state.block.Offset, int(state.block.Size), state.block.Hash, state.block.WeakHash, selected.FromTemporary)
+		blockNo := state.file.BlockIndex(state.block.Offset)
+		buf, lastError = f.model.RequestGlobal(f.ctx, selected.ID, f.folderID, state.file.Name, blockNo, state.block.Offset, int(state.block.Size), state.block.Hash, state.block.WeakHash, state.file.BlockHashAlgorithm, selected.FromTemporary)
 		activity.done(selected)
 		if lastError != nil {
 			l.Debugln("request:", f.folderID, state.file.Name, state.block.Offset, state.block.Size, selected.ID.Short(), "returned error:", lastError)
@@ -1588,7 +1631,7 @@ loop:
 		// integrity so we'll take it on trust. (The other side can and
 		// will verify.)
 		if f.Type != config.FolderTypeReceiveEncrypted {
-			lastError = f.verifyBuffer(buf, state.block)
+			lastError = f.verifyBuffer(buf, state.block, state.file.BlockHashAlgorithm)
 		}
 		if lastError != nil {
 			l.Debugln("request:", f.folderID, state.file.Name, state.block.Offset, state.block.Size, "hash mismatch")
@@ -1630,17 +1673,18 @@ func (f *sendReceiveFolder) performFinish(file, curFile protocol.FileInfo, hasCu
 
 		if !curFile.IsDirectory() && !curFile.IsSymlink() && f.inConflict(curFile.Version, file.Version) {
 			// The new file has been changed in conflict with the existing one. We
-			// should file it away as a conflict instead of just removing or
-			// archiving.
+			// should resolve the conflict according to the folder's rules
+			// instead of just removing or archiving.
 			// Directories and symlinks aren't checked for conflicts.
 
-			err = f.inWritableDir(func(name string) error {
-				return f.moveForConflict(name, file.ModifiedBy.String(), scanChan)
-			}, curFile.Name)
-		} else {
-			err = f.deleteItemOnDisk(curFile, snap, scanChan)
-		}
-		if err != nil {
+			keptLocal, err := f.resolveConflict(file, curFile, tempName, dbUpdateChan, scanChan)
+			if err != nil {
+				return fmt.Errorf("resolving conflict: %w", err)
+			}
+			if keptLocal {
+				return nil
+			}
+		} else if err := f.deleteItemOnDisk(curFile, snap, scanChan); err != nil {
 			return fmt.Errorf("moving for conflict: %w", err)
 		}
 	} else if !fs.IsNotExist(err) {
@@ -1675,17 +1719,18 @@ func (f *sendReceiveFolder) finisherRoutine(snap *db.Snapshot, in <-chan *shared
 			if err != nil {
 				f.newPullError(state.file.Name, fmt.Errorf("finishing: %w", err))
 			} else {
-				minBlocksPerBlock := state.file.BlockSize() / protocol.MinBlockSize
+				state.mut.RLock()
 				blockStatsMut.Lock()
-				blockStats["total"] += (state.reused + state.copyTotal + state.pullTotal) * minBlocksPerBlock
-				blockStats["reused"] += state.reused * minBlocksPerBlock
-				blockStats["pulled"] += state.pullTotal * minBlocksPerBlock
+				blockStats["total"] += minBlocks(state.totalBytes)
+				blockStats["reused"] += minBlocks(state.reusedBytes)
+				blockStats["pulled"] += minBlocks(state.pulledBytes)
 				// copyOriginShifted is counted towards copyOrigin due to progress bar reasons
 				// for reporting reasons we want to separate these.
-				blockStats["copyOrigin"] += (state.copyOrigin - state.copyOriginShifted) * minBlocksPerBlock
-				blockStats["copyOriginShifted"] += state.copyOriginShifted * minBlocksPerBlock
-				blockStats["copyElsewhere"] += (state.copyTotal - state.copyOrigin) * minBlocksPerBlock
+				blockStats["copyOrigin"] += minBlocks(state.originBytes - state.shiftedBytes)
+				blockStats["copyOriginShifted"] += minBlocks(state.shiftedBytes)
+				blockStats["copyElsewhere"] += minBlocks(state.copiedBytes - state.originBytes)
 				blockStatsMut.Unlock()
+				state.mut.RUnlock()
 			}
 
 			if f.Type != config.FolderTypeReceiveEncrypted {
@@ -1838,20 +1883,22 @@ func (f *sendReceiveFolder) inConflict(current, replacement protocol.Vector) boo
 	return false
 }
 
-func (f *sendReceiveFolder) moveForConflict(name, lastModBy string, scanChan chan<- string) error {
+// moveForConflict renames the named file to a conflict copy, returning the
+// new name, or removes it if conflict copies are disabled.
+func (f *sendReceiveFolder) moveForConflict(name, lastModBy string, scanChan chan<- string) (string, error) {
 	if isConflict(name) {
 		l.Infoln("Conflict for", name, "which is already a conflict copy; not copying again.")
 		if err := f.mtimefs.Remove(name); err != nil && !fs.IsNotExist(err) {
-			return fmt.Errorf("%s: %w", contextRemovingOldItem, err)
+			return "", fmt.Errorf("%s: %w", contextRemovingOldItem, err)
 		}
-		return nil
+		return "", nil
 	}
 
 	if f.MaxConflicts == 0 {
 		if err := f.mtimefs.Remove(name); err != nil && !fs.IsNotExist(err) {
-			return fmt.Errorf("%s: %w", contextRemovingOldItem, err)
+			return "", fmt.Errorf("%s: %w", contextRemovingOldItem, err)
 		}
-		return nil
+		return "", nil
 	}
 
 	metricFolderConflictsTotal.WithLabelValues(f.ID).Inc()
@@ -1864,21 +1911,29 @@ func (f *sendReceiveFolder) moveForConflict(name, lastModBy string, scanChan cha
 		// matter, go ahead as if the move succeeded.
 		err = nil
 	}
-	if f.MaxConflicts > -1 {
-		matches := existingConflicts(name, f.mtimefs)
-		if len(matches) > f.MaxConflicts {
-			sort.Sort(sort.Reverse(sort.StringSlice(matches)))
-			for _, match := range matches[f.MaxConflicts:] {
-				if gerr := f.mtimefs.Remove(match); gerr != nil {
-					l.Debugln(f, "removing extra conflict", gerr)
-				}
+	f.removeExtraConflicts(name)
+	if err != nil {
+		return "", err
+	}
+	scanChan <- newName
+	return newName, nil
+}
+
+// removeExtraConflicts removes the oldest conflict copies of the named file
+// beyond the configured maximum.
+func (f *sendReceiveFolder) removeExtraConflicts(name string) {
+	if f.MaxConflicts < 1 {
+		return
+	}
+	matches := existingConflicts(name, f.mtimefs)
+	if len(matches) > f.MaxConflicts {
+		sort.Sort(sort.Reverse(sort.StringSlice(matches)))
+		for _, match := range matches[f.MaxConflicts:] {
+			if gerr := f.mtimefs.Remove(match); gerr != nil {
+				l.Debugln(f, "removing extra conflict", gerr)
 			}
 		}
 	}
-	if err == nil {
-		scanChan <- newName
-	}
-	return err
 }
 
 func (f *sendReceiveFolder) newPullError(path string, err error) {
@@ -9,6 +9,7 @@ package model
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
@@ -300,7 +301,7 @@ func TestCopierFinder(t *testing.T) {
 	}
 
 	// Verify that the fetched blocks have actually been written to the temp file
-	blks, err := scanner.HashFile(context.TODO(), f.ID, f.Filesystem(nil), tempFile, protocol.MinBlockSize, nil, false)
+	blks, err := scanner.HashFile(context.TODO(), f.ID, f.Filesystem(nil), tempFile, protocol.HashAlgorithmSHA256, protocol.MinBlockSize, nil, false)
 	if err != nil {
 		t.Log(err)
 	}
@@ -312,6 +313,88 @@ func TestCopierFinder(t *testing.T) {
 	}
 }
 
+func TestCopierChunkedSource(t *testing.T) {
+	// The source file was chunked by content, so its blocks aren't where
+	// the block size of the required file would put them.
+	data := make([]byte, 3*protocol.MinBlockSize)
+	if _, err := io.ReadFull(rand.Reader, data); err != nil {
+		t.Fatal(err)
+	}
+	chunk := func(offset, size int) protocol.BlockInfo {
+		hash := sha256.Sum256(data[offset : offset+size])
+		return protocol.BlockInfo{Offset: int64(offset), Size: size, Hash: hash[:]}
+	}
+	source := protocol.FileInfo{
+		Name:    "source",
+		Size:    int64(len(data)),
+		Version: protocol.Vector{}.Update(myID.Short()),
+		Blocks: []protocol.BlockInfo{
+			chunk(0, 50000),
+			chunk(50000, protocol.MinBlockSize),
+			chunk(50000+protocol.MinBlockSize, len(data)-50000-protocol.MinBlockSize),
+		},
+	}
+
+	_, f, wcfgCancel := setupSendReceiveFolder(t, source)
+	defer wcfgCancel()
+	writeFile(t, f.Filesystem(nil), source.Name, data)
+
+	// The first block of the required file is the second one of the source.
+	required := protocol.FileInfo{
+		Name:         "required",
+		Size:         2 * protocol.MinBlockSize,
+		RawBlockSize: protocol.MinBlockSize,
+		Version:      protocol.Vector{}.Update(device1.Short()),
+		Blocks: []protocol.BlockInfo{
+			source.Blocks[1],
+			chunk(2*protocol.MinBlockSize, protocol.MinBlockSize),
+		},
+	}
+	required.Blocks[0].Offset = 0
+	required.Blocks[1].Offset = protocol.MinBlockSize
+
+	copyChan := make(chan copyBlocksState)
+	pullChan := make(chan pullBlockState, 2)
+	finisherChan := make(chan *sharedPullerState, 1)
+	go f.copierRoutine(copyChan, pullChan, finisherChan)
+	defer close(copyChan)
+
+	f.handleFile(required, fsetSnapshot(t, f.fset), copyChan)
+
+	select {
+	case pull := <-pullChan:
+		if pull.block.Offset != protocol.MinBlockSize {
+			t.Error("Unexpected pull of block at offset", pull.block.Offset)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for the pull")
+	}
+	select {
+	case finish := <-finisherChan:
+		defer cleanupSharedPullerState(finish)
+		if p := finish.Progress(); p.BytesTotal != required.Size || p.BytesDone != protocol.MinBlockSize {
+			t.Errorf("Progress is %d of %d bytes, expected %d of %d", p.BytesDone, p.BytesTotal, protocol.MinBlockSize, required.Size)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timed out waiting for the finisher")
+	}
+	select {
+	case pull := <-pullChan:
+		t.Error("Unexpected pull of block at offset", pull.block.Offset)
+	default:
+	}
+
+	fd, err := f.Filesystem(nil).Open(fs.TempName(required.Name))
+	must(t, err)
+	defer fd.Close()
+	buf := make([]byte, protocol.MinBlockSize)
+	_, err = fd.ReadAt(buf, 0)
+	must(t, err)
+	if !bytes.Equal(buf, data[50000:50000+protocol.MinBlockSize]) {
+		t.Error("Copied block doesn't match the source")
+	}
+}
+
 func TestWeakHash(t *testing.T) {
 	// Setup the model/pull environment
 	_, fo, wcfgCancel := setupSendReceiveFolder(t)
@@ -451,11 +534,11 @@ func TestCopierCleanup(t *testing.T) {
 	// Update index (removing old blocks)
 	f.updateLocalsFromScanning([]protocol.FileInfo{file})
 
-	if m.finder.Iterate(folders, blocks[0].Hash, iterFn) {
+	if m.finder.Iterate(folders, blocks[0].Hash, protocol.HashAlgorithmSHA256, iterFn) {
 		t.Error("Unexpected block found")
 	}
 
-	if !m.finder.Iterate(folders, blocks[1].Hash, iterFn) {
+	if !m.finder.Iterate(folders, blocks[1].Hash, protocol.HashAlgorithmSHA256, iterFn) {
 		t.Error("Expected block not found")
 	}
 
@@ -464,11 +547,11 @@ func TestCopierCleanup(t *testing.T) {
 	// Update index (removing old blocks)
 	f.updateLocalsFromScanning([]protocol.FileInfo{file})
 
-	if !m.finder.Iterate(folders, blocks[0].Hash, iterFn) {
+	if !m.finder.Iterate(folders, blocks[0].Hash, protocol.HashAlgorithmSHA256, iterFn) {
 		t.Error("Unexpected block found")
 	}
 
-	if m.finder.Iterate(folders, blocks[1].Hash, iterFn) {
+	if m.finder.Iterate(folders, blocks[1].Hash, protocol.HashAlgorithmSHA256, iterFn) {
 		t.Error("Expected block not found")
 	}
 }
@@ -117,6 +117,38 @@ type FolderSummary struct {
 
 	IgnorePatterns bool   `json:"ignorePatterns"`
 	WatchError     string `json:"watchError"`
+
+	Schedule *FolderScheduleSummary `json:"schedule,omitempty"` // only set for folders with a schedule
+}
+
+// FolderScheduleSummary describes where a folder is in its sync schedule.
+// Zero times mean there is no such window, or it doesn't end within the
+// next two weeks.
+type FolderScheduleSummary struct {
+	InWindow        bool      `json:"inWindow"`
+	WindowEnd       time.Time `json:"windowEnd"` // end of the current window, if InWindow
+	NextWindowStart time.Time `json:"nextWindowStart"`
+	NextWindowEnd   time.Time `json:"nextWindowEnd"`
+}
+
+func newFolderScheduleSummary(schedule config.Schedule, now time.Time) *FolderScheduleSummary {
+	res := new(FolderScheduleSummary)
+	start, end, ok := schedule.Window(now)
+	if !ok {
+		return res
+	}
+	if !start.After(now) {
+		res.InWindow = true
+		res.WindowEnd = end
+		if end.IsZero() {
+			return res
+		}
+		if start, end, ok = schedule.Window(end); !ok {
+			return res
+		}
+	}
+	res.NextWindowStart, res.NextWindowEnd = start, end
+	return res
 }
 
 func (c *folderSummaryService) Summary(folder string) (*FolderSummary, error) {
@@ -181,6 +213,10 @@ func (c *folderSummaryService) Summary(folder string) (*FolderSummary, error) {
 
 	res.InSyncFiles, res.InSyncBytes = global.Files-need.Files, global.Bytes-need.Bytes
 
+	if haveFcfg && len(fcfg.Schedule) > 0 {
+		res.Schedule = newFolderScheduleSummary(fcfg.Schedule, time.Now())
+	}
+
 	res.State, res.StateChanged, err = c.model.State(folder)
 	if err != nil {
 		res.Error = err.Error()
@@ -24,6 +24,8 @@ const (
 	FolderSyncing
 	FolderCleaning
 	FolderCleanWaiting
+	FolderScrubbing
+	FolderScrubWaiting
 	FolderError
 )
 
@@ -45,6 +47,10 @@ func (s folderState) String() string {
 		return "cleaning"
 	case FolderCleanWaiting:
 		return "clean-waiting"
+	case FolderScrubbing:
+		return "scrubbing"
+	case FolderScrubWaiting:
+		return "scrub-waiting"
 	case FolderError:
 		return "error"
 	default:
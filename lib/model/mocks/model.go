@@ -76,6 +76,19 @@ type Model struct {
 		result1 model.FolderCompletion
 		result2 error
 	}
+	ConflictsStub        func(string) ([]model.Conflict, error)
+	conflictsMutex       sync.RWMutex
+	conflictsArgsForCall []struct {
+		arg1 string
+	}
+	conflictsReturns struct {
+		result1 []model.Conflict
+		result2 error
+	}
+	conflictsReturnsOnCall map[int]struct {
+		result1 []model.Conflict
+		result2 error
+	}
 	ConnectedToStub        func(protocol.DeviceID) bool
 	connectedToMutex       sync.RWMutex
 	connectedToArgsForCall []struct {
@@ -435,7 +448,7 @@ type Model struct {
 		result1 protocol.RequestResponse
 		result2 error
 	}
-	RequestGlobalStub        func(context.Context, protocol.DeviceID, string, string, int, int64, int, []byte, uint32, bool) ([]byte, error)
+	RequestGlobalStub        func(context.Context, protocol.DeviceID, string, string, int, int64, int, []byte, uint32, protocol.HashAlgorithm, bool) ([]byte, error)
 	requestGlobalMutex       sync.RWMutex
 	requestGlobalArgsForCall []struct {
 		arg1  context.Context
@@ -447,7 +460,8 @@ type Model struct {
 		arg7  int
 		arg8  []byte
 		arg9  uint32
-		arg10 bool
+		arg10 protocol.HashAlgorithm
+		arg11 bool
 	}
 	requestGlobalReturns struct {
 		result1 []byte
@@ -468,6 +482,19 @@ type Model struct {
 	resetFolderReturnsOnCall map[int]struct {
 		result1 error
 	}
+	ResolveConflictStub        func(string, string, bool) error
+	resolveConflictMutex       sync.RWMutex
+	resolveConflictArgsForCall []struct {
+		arg1 string
+		arg2 string
+		arg3 bool
+	}
+	resolveConflictReturns struct {
+		result1 error
+	}
+	resolveConflictReturnsOnCall map[int]struct {
+		result1 error
+	}
 	RestoreFolderVersionsStub        func(string, map[string]time.Time) (map[string]error, error)
 	restoreFolderVersionsMutex       sync.RWMutex
 	restoreFolderVersionsArgsForCall []struct {
@@ -482,6 +509,23 @@ type Model struct {
 		result1 map[string]error
 		result2 error
 	}
+	RestoreFolderVersionsAtStub        func(string, time.Time, bool) (map[string]versioner.FileVersion, map[string]error, error)
+	restoreFolderVersionsAtMutex       sync.RWMutex
+	restoreFolderVersionsAtArgsForCall []struct {
+		arg1 string
+		arg2 time.Time
+		arg3 bool
+	}
+	restoreFolderVersionsAtReturns struct {
+		result1 map[string]versioner.FileVersion
+		result2 map[string]error
+		result3 error
+	}
+	restoreFolderVersionsAtReturnsOnCall map[int]struct {
+		result1 map[string]versioner.FileVersion
+		result2 map[string]error
+		result3 error
+	}
 	RevertStub        func(string)
 	revertMutex       sync.RWMutex
 	revertArgsForCall []struct {
@@ -872,6 +916,70 @@ func (fake *Model) CompletionReturnsOnCall(i int, result1 model.FolderCompletion
 	}{result1, result2}
 }
 
+func (fake *Model) Conflicts(arg1 string) ([]model.Conflict, error) {
+	fake.conflictsMutex.Lock()
+	ret, specificReturn := fake.conflictsReturnsOnCall[len(fake.conflictsArgsForCall)]
+	fake.conflictsArgsForCall = append(fake.conflictsArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	stub := fake.ConflictsStub
+	fakeReturns := fake.conflictsReturns
+	fake.recordInvocation("Conflicts", []interface{}{arg1})
+	fake.conflictsMutex.Unlock()
+	if stub != nil {
+		return stub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *Model) ConflictsCallCount() int {
+	fake.conflictsMutex.RLock()
+	defer fake.conflictsMutex.RUnlock()
+	return len(fake.conflictsArgsForCall)
+}
+
+func (fake *Model) ConflictsCalls(stub func(string) ([]model.Conflict, error)) {
+	fake.conflictsMutex.Lock()
+	defer fake.conflictsMutex.Unlock()
+	fake.ConflictsStub = stub
+}
+
+func (fake *Model) ConflictsArgsForCall(i int) string {
+	fake.conflictsMutex.RLock()
+	defer fake.conflictsMutex.RUnlock()
+	argsForCall := fake.conflictsArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *Model) ConflictsReturns(result1 []model.Conflict, result2 error) {
+	fake.conflictsMutex.Lock()
+	defer fake.conflictsMutex.Unlock()
+	fake.ConflictsStub = nil
+	fake.conflictsReturns = struct {
+		result1 []model.Conflict
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *Model) ConflictsReturnsOnCall(i int, result1 []model.Conflict, result2 error) {
+	fake.conflictsMutex.Lock()
+	defer fake.conflictsMutex.Unlock()
+	fake.ConflictsStub = nil
+	if fake.conflictsReturnsOnCall == nil {
+		fake.conflictsReturnsOnCall = make(map[int]struct {
+			result1 []model.Conflict
+			result2 error
+		})
+	}
+	fake.conflictsReturnsOnCall[i] = struct {
+		result1 []model.Conflict
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *Model) ConnectedTo(arg1 protocol.DeviceID) bool {
 	fake.connectedToMutex.Lock()
 	ret, specificReturn := fake.connectedToReturnsOnCall[len(fake.connectedToArgsForCall)]
@@ -2580,7 +2688,7 @@ func (fake *Model) RequestReturnsOnCall(i int, result1 protocol.RequestResponse,
 	}{result1, result2}
 }
 
-func (fake *Model) RequestGlobal(arg1 context.Context, arg2 protocol.DeviceID, arg3 string, arg4 string, arg5 int, arg6 int64, arg7 int, arg8 []byte, arg9 uint32, arg10 bool) ([]byte, error) {
+func (fake *Model) RequestGlobal(arg1 context.Context, arg2 protocol.DeviceID, arg3 string, arg4 string, arg5 int, arg6 int64, arg7 int, arg8 []byte, arg9 uint32, arg10 protocol.HashAlgorithm, arg11 bool) ([]byte, error) {
 	var arg8Copy []byte
 	if arg8 != nil {
 		arg8Copy = make([]byte, len(arg8))
@@ -2598,14 +2706,15 @@ func (fake *Model) RequestGlobal(arg1 context.Context, arg2 protocol.DeviceID, a
 		arg7  int
 		arg8  []byte
 		arg9  uint32
-		arg10 bool
-	}{arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8Copy, arg9, arg10})
+		arg10 protocol.HashAlgorithm
+		arg11 bool
+	}{arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8Copy, arg9, arg10, arg11})
 	stub := fake.RequestGlobalStub
 	fakeReturns := fake.requestGlobalReturns
-	fake.recordInvocation("RequestGlobal", []interface{}{arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8Copy, arg9, arg10})
+	fake.recordInvocation("RequestGlobal", []interface{}{arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8Copy, arg9, arg10, arg11})
 	fake.requestGlobalMutex.Unlock()
 	if stub != nil {
-		return stub(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9, arg10)
+		return stub(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9, arg10, arg11)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -2619,17 +2728,17 @@ func (fake *Model) RequestGlobalCallCount() int {
 	return len(fake.requestGlobalArgsForCall)
 }
 
-func (fake *Model) RequestGlobalCalls(stub func(context.Context, protocol.DeviceID, string, string, int, int64, int, []byte, uint32, bool) ([]byte, error)) {
+func (fake *Model) RequestGlobalCalls(stub func(context.Context, protocol.DeviceID, string, string, int, int64, int, []byte, uint32, protocol.HashAlgorithm, bool) ([]byte, error)) {
 	fake.requestGlobalMutex.Lock()
 	defer fake.requestGlobalMutex.Unlock()
 	fake.RequestGlobalStub = stub
 }
 
-func (fake *Model) RequestGlobalArgsForCall(i int) (context.Context, protocol.DeviceID, string, string, int, int64, int, []byte, uint32, bool) {
+func (fake *Model) RequestGlobalArgsForCall(i int) (context.Context, protocol.DeviceID, string, string, int, int64, int, []byte, uint32, protocol.HashAlgorithm, bool) {
 	fake.requestGlobalMutex.RLock()
 	defer fake.requestGlobalMutex.RUnlock()
 	argsForCall := fake.requestGlobalArgsForCall[i]
-	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5, argsForCall.arg6, argsForCall.arg7, argsForCall.arg8, argsForCall.arg9, argsForCall.arg10
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5, argsForCall.arg6, argsForCall.arg7, argsForCall.arg8, argsForCall.arg9, argsForCall.arg10, argsForCall.arg11
 }
 
 func (fake *Model) RequestGlobalReturns(result1 []byte, result2 error) {
@@ -2719,6 +2828,69 @@ func (fake *Model) ResetFolderReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *Model) ResolveConflict(arg1 string, arg2 string, arg3 bool) error {
+	fake.resolveConflictMutex.Lock()
+	ret, specificReturn := fake.resolveConflictReturnsOnCall[len(fake.resolveConflictArgsForCall)]
+	fake.resolveConflictArgsForCall = append(fake.resolveConflictArgsForCall, struct {
+		arg1 string
+		arg2 string
+		arg3 bool
+	}{arg1, arg2, arg3})
+	stub := fake.ResolveConflictStub
+	fakeReturns := fake.resolveConflictReturns
+	fake.recordInvocation("ResolveConflict", []interface{}{arg1, arg2, arg3})
+	fake.resolveConflictMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fakeReturns.result1
+}
+
+func (fake *Model) ResolveConflictCallCount() int {
+	fake.resolveConflictMutex.RLock()
+	defer fake.resolveConflictMutex.RUnlock()
+	return len(fake.resolveConflictArgsForCall)
+}
+
+func (fake *Model) ResolveConflictCalls(stub func(string, string, bool) error) {
+	fake.resolveConflictMutex.Lock()
+	defer fake.resolveConflictMutex.Unlock()
+	fake.ResolveConflictStub = stub
+}
+
+func (fake *Model) ResolveConflictArgsForCall(i int) (string, string, bool) {
+	fake.resolveConflictMutex.RLock()
+	defer fake.resolveConflictMutex.RUnlock()
+	argsForCall := fake.resolveConflictArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *Model) ResolveConflictReturns(result1 error) {
+	fake.resolveConflictMutex.Lock()
+	defer fake.resolveConflictMutex.Unlock()
+	fake.ResolveConflictStub = nil
+	fake.resolveConflictReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *Model) ResolveConflictReturnsOnCall(i int, result1 error) {
+	fake.resolveConflictMutex.Lock()
+	defer fake.resolveConflictMutex.Unlock()
+	fake.ResolveConflictStub = nil
+	if fake.resolveConflictReturnsOnCall == nil {
+		fake.resolveConflictReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.resolveConflictReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *Model) RestoreFolderVersions(arg1 string, arg2 map[string]time.Time) (map[string]error, error) {
 	fake.restoreFolderVersionsMutex.Lock()
 	ret, specificReturn := fake.restoreFolderVersionsReturnsOnCall[len(fake.restoreFolderVersionsArgsForCall)]
@@ -2784,6 +2956,75 @@ func (fake *Model) RestoreFolderVersionsReturnsOnCall(i int, result1 map[string]
 	}{result1, result2}
 }
 
+func (fake *Model) RestoreFolderVersionsAt(arg1 string, arg2 time.Time, arg3 bool) (map[string]versioner.FileVersion, map[string]error, error) {
+	fake.restoreFolderVersionsAtMutex.Lock()
+	ret, specificReturn := fake.restoreFolderVersionsAtReturnsOnCall[len(fake.restoreFolderVersionsAtArgsForCall)]
+	fake.restoreFolderVersionsAtArgsForCall = append(fake.restoreFolderVersionsAtArgsForCall, struct {
+		arg1 string
+		arg2 time.Time
+		arg3 bool
+	}{arg1, arg2, arg3})
+	stub := fake.RestoreFolderVersionsAtStub
+	fakeReturns := fake.restoreFolderVersionsAtReturns
+	fake.recordInvocation("RestoreFolderVersionsAt", []interface{}{arg1, arg2, arg3})
+	fake.restoreFolderVersionsAtMutex.Unlock()
+	if stub != nil {
+		return stub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
+}
+
+func (fake *Model) RestoreFolderVersionsAtCallCount() int {
+	fake.restoreFolderVersionsAtMutex.RLock()
+	defer fake.restoreFolderVersionsAtMutex.RUnlock()
+	return len(fake.restoreFolderVersionsAtArgsForCall)
+}
+
+func (fake *Model) RestoreFolderVersionsAtCalls(stub func(string, time.Time, bool) (map[string]versioner.FileVersion, map[string]error, error)) {
+	fake.restoreFolderVersionsAtMutex.Lock()
+	defer fake.restoreFolderVersionsAtMutex.Unlock()
+	fake.RestoreFolderVersionsAtStub = stub
+}
+
+func (fake *Model) RestoreFolderVersionsAtArgsForCall(i int) (string, time.Time, bool) {
+	fake.restoreFolderVersionsAtMutex.RLock()
+	defer fake.restoreFolderVersionsAtMutex.RUnlock()
+	argsForCall := fake.restoreFolderVersionsAtArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *Model) RestoreFolderVersionsAtReturns(result1 map[string]versioner.FileVersion, result2 map[string]error, result3 error) {
+	fake.restoreFolderVersionsAtMutex.Lock()
+	defer fake.restoreFolderVersionsAtMutex.Unlock()
+	fake.RestoreFolderVersionsAtStub = nil
+	fake.restoreFolderVersionsAtReturns = struct {
+		result1 map[string]versioner.FileVersion
+		result2 map[string]error
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *Model) RestoreFolderVersionsAtReturnsOnCall(i int, result1 map[string]versioner.FileVersion, result2 map[string]error, result3 error) {
+	fake.restoreFolderVersionsAtMutex.Lock()
+	defer fake.restoreFolderVersionsAtMutex.Unlock()
+	fake.RestoreFolderVersionsAtStub = nil
+	if fake.restoreFolderVersionsAtReturnsOnCall == nil {
+		fake.restoreFolderVersionsAtReturnsOnCall = make(map[int]struct {
+			result1 map[string]versioner.FileVersion
+			result2 map[string]error
+			result3 error
+		})
+	}
+	fake.restoreFolderVersionsAtReturnsOnCall[i] = struct {
+		result1 map[string]versioner.FileVersion
+		result2 map[string]error
+		result3 error
+	}{result1, result2, result3}
+}
+
 func (fake *Model) Revert(arg1 string) {
 	fake.revertMutex.Lock()
 	fake.revertArgsForCall = append(fake.revertArgsForCall, struct {
@@ -3288,6 +3529,8 @@ func (fake *Model) WatchErrorReturnsOnCall(i int, result1 error) {
 }
 
 func (fake *Model) Invocations() map[string][][]interface{} {
+	fake.conflictsMutex.RLock()
+	defer fake.conflictsMutex.RUnlock()
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
 	fake.addConnectionMutex.RLock()
@@ -3362,8 +3605,12 @@ func (fake *Model) Invocations() map[string][][]interface{} {
 	defer fake.requestGlobalMutex.RUnlock()
 	fake.resetFolderMutex.RLock()
 	defer fake.resetFolderMutex.RUnlock()
+	fake.resolveConflictMutex.RLock()
+	defer fake.resolveConflictMutex.RUnlock()
 	fake.restoreFolderVersionsMutex.RLock()
 	defer fake.restoreFolderVersionsMutex.RUnlock()
+	fake.restoreFolderVersionsAtMutex.RLock()
+	defer fake.restoreFolderVersionsAtMutex.RUnlock()
 	fake.revertMutex.RLock()
 	defer fake.revertMutex.RUnlock()
 	fake.scanFolderMutex.RLock()
@@ -92,6 +92,10 @@ type Model interface {
 
 	GetFolderVersions(folder string) (map[string][]versioner.FileVersion, error)
 	RestoreFolderVersions(folder string, versions map[string]time.Time) (map[string]error, error)
+	RestoreFolderVersionsAt(folder string, at time.Time, dryRun bool) (map[string]versioner.FileVersion, map[string]error, error)
+
+	Conflicts(folder string) ([]Conflict, error)
+	ResolveConflict(folder, name string, keepCopy bool) error
 
 	DBSnapshot(folder string) (*db.Snapshot, error)
 	NeedFolderFiles(folder string, page, perpage int) ([]protocol.FileInfo, []protocol.FileInfo, []protocol.FileInfo, error)
@@ -118,7 +122,7 @@ type Model interface {
 
 	GlobalDirectoryTree(folder, prefix string, levels int, dirsOnly bool) ([]*TreeEntry, error)
 
-	RequestGlobal(ctx context.Context, deviceID protocol.DeviceID, folder, name string, blockNo int, offset int64, size int, hash []byte, weakHash uint32, fromTemporary bool) ([]byte, error)
+	RequestGlobal(ctx context.Context, deviceID protocol.DeviceID, folder, name string, blockNo int, offset int64, size int, hash []byte, weakHash uint32, algo protocol.HashAlgorithm, fromTemporary bool) ([]byte, error)
 }
 
 type model struct {
@@ -145,6 +149,7 @@ type model struct {
 	started         chan struct{}
 	keyGen          *protocol.KeyGenerator
 	promotionTimer  *time.Timer
+	scheduler       *scheduler
 
 	// fields protected by mut
 	mut                            sync.RWMutex
@@ -165,6 +170,7 @@ type model struct {
 	helloMessages                  map[protocol.DeviceID]protocol.Hello
 	deviceDownloads                map[protocol.DeviceID]*deviceDownloadState
 	remoteFolderStates             map[protocol.DeviceID]map[string]remoteFolderState // deviceID -> folders
+	remoteFolderFeatures           map[protocol.DeviceID]map[string]folderFeatures    // deviceID -> folders
 	indexHandlers                  *serviceMap[protocol.DeviceID, *indexHandlerRegistry]
 
 	// for testing only
@@ -243,8 +249,10 @@ func NewModel(cfg config.Wrapper, id protocol.DeviceID, ldb *db.Lowlevel, protec
 		helloMessages:                  make(map[protocol.DeviceID]protocol.Hello),
 		deviceDownloads:                make(map[protocol.DeviceID]*deviceDownloadState),
 		remoteFolderStates:             make(map[protocol.DeviceID]map[string]remoteFolderState),
+		remoteFolderFeatures:           make(map[protocol.DeviceID]map[string]folderFeatures),
 		indexHandlers:                  newServiceMap[protocol.DeviceID, *indexHandlerRegistry](evLogger),
 	}
+	m.scheduler = newScheduler(cfg, m.started)
 	for devID, cfg := range cfg.Devices() {
 		m.deviceStatRefs[devID] = stats.NewDeviceStatisticsReference(m.db, devID)
 		m.setConnRequestLimitersLocked(cfg)
@@ -253,6 +261,7 @@ func NewModel(cfg config.Wrapper, id protocol.DeviceID, ldb *db.Lowlevel, protec
 	m.Add(m.progressEmitter)
 	m.Add(m.indexHandlers)
 	m.Add(svcutil.AsService(m.serve, m.String()))
+	m.Add(svcutil.AsService(m.scheduler.serve, m.scheduler.String()))
 
 	return m
 }
@@ -328,7 +337,14 @@ func (m *model) fatal(err error) {
 
 // Need to hold lock on m.mut when calling this.
 func (m *model) addAndStartFolderLocked(cfg config.FolderConfiguration, fset *db.FileSet, cacheIgnoredFiles bool) {
-	ignores := ignore.New(cfg.Filesystem(nil), ignore.WithCache(cacheIgnoredFiles))
+	opts := []ignore.Option{ignore.WithCache(cacheIgnoredFiles)}
+	if cfg.Type == config.FolderTypeSelective {
+		// Items that aren't selected are handled like ignored ones, i.e.
+		// they are neither pulled nor scanned and remote devices don't
+		// consider them to be deleted.
+		opts = append(opts, ignore.WithSelection(cfg.IsSelected))
+	}
+	ignores := ignore.New(cfg.Filesystem(nil), opts...)
 	if cfg.Type != config.FolderTypeReceiveEncrypted {
 		if err := ignores.Load(".stignore"); err != nil && !fs.IsNotExist(err) {
 			l.Warnln("Loading ignores:", err)
@@ -1277,8 +1293,17 @@ func (m *model) ClusterConfig(conn protocol.Connection, cm *protocol.ClusterConf
 		return err
 	}
 
+	features := make(map[string]folderFeatures, len(cm.Folders))
+	for _, folder := range cm.Folders {
+		features[folder.ID] = folderFeatures{
+			contentDefinedChunking: folder.ContentDefinedChunking,
+			hashAlgorithm:          folder.HashAlgorithm,
+		}
+	}
+
 	m.mut.Lock()
 	m.remoteFolderStates[deviceID] = states
+	m.remoteFolderFeatures[deviceID] = features
 	m.mut.Unlock()
 
 	m.evLogger.Log(events.ClusterConfigReceived, ClusterConfigReceivedEventData{
@@ -2036,7 +2061,7 @@ func (m *model) Request(conn protocol.Connection, req *protocol.Request) (out pr
 			return nil, protocol.ErrNoSuchFile
 		}
 		_, err := readOffsetIntoBuf(folderFs, tempFn, req.Offset, res.data)
-		if err == nil && scanner.Validate(res.data, req.Hash, req.WeakHash) {
+		if err == nil && scanner.Validate(res.data, req.Hash, req.WeakHash, req.BlockHashAlgorithm) {
 			return res, nil
 		}
 		// Fall through to reading from a non-temp file, just in case the temp
@@ -2065,7 +2090,7 @@ func (m *model) Request(conn protocol.Connection, req *protocol.Request) (out pr
 		return nil, protocol.ErrGeneric
 	}
 
-	if folderCfg.Type != config.FolderTypeReceiveEncrypted && len(req.Hash) > 0 && !scanner.Validate(res.data[:n], req.Hash, req.WeakHash) {
+	if folderCfg.Type != config.FolderTypeReceiveEncrypted && len(req.Hash) > 0 && !scanner.Validate(res.data[:n], req.Hash, req.WeakHash, req.BlockHashAlgorithm) {
 		m.recheckFile(deviceID, req.Folder, req.Name, req.Offset, req.Hash, req.WeakHash)
 		l.Debugf("%v REQ(in) failed validating data: %s: %q / %q o=%d s=%d", m, deviceID.Short(), req.Folder, req.Name, req.Offset, req.Size)
 		return nil, protocol.ErrNoSuchFile
@@ -2108,9 +2133,9 @@ func (m *model) recheckFile(deviceID protocol.DeviceID, folder, name string, off
 		return
 	}
 
-	blockIndex := int(offset / int64(cf.BlockSize()))
-	if blockIndex >= len(cf.Blocks) {
-		l.Debugf("%v recheckFile: %s: %q / %q i=%d: block index too far", m, deviceID, folder, name, blockIndex)
+	blockIndex := cf.BlockIndex(offset)
+	if blockIndex < 0 {
+		l.Debugf("%v recheckFile: %s: %q / %q o=%d: no block at offset", m, deviceID, folder, name, offset)
 		return
 	}
 
@@ -2462,14 +2487,14 @@ func (m *model) deviceDidCloseRLocked(deviceID protocol.DeviceID, duration time.
 	}
 }
 
-func (m *model) RequestGlobal(ctx context.Context, deviceID protocol.DeviceID, folder, name string, blockNo int, offset int64, size int, hash []byte, weakHash uint32, fromTemporary bool) ([]byte, error) {
+func (m *model) RequestGlobal(ctx context.Context, deviceID protocol.DeviceID, folder, name string, blockNo int, offset int64, size int, hash []byte, weakHash uint32, algo protocol.HashAlgorithm, fromTemporary bool) ([]byte, error) {
 	conn, connOK := m.requestConnectionForDevice(deviceID)
 	if !connOK {
 		return nil, fmt.Errorf("requestGlobal: no connection to device: %s", deviceID.Short())
 	}
 
 	l.Debugf("%v REQ(out): %s (%s): %q / %q b=%d o=%d s=%d h=%x wh=%x ft=%t", m, deviceID.Short(), conn, folder, name, blockNo, offset, size, hash, weakHash, fromTemporary)
-	return conn.Request(ctx, &protocol.Request{Folder: folder, Name: name, BlockNo: blockNo, Offset: offset, Size: size, Hash: hash, WeakHash: weakHash, FromTemporary: fromTemporary})
+	return conn.Request(ctx, &protocol.Request{Folder: folder, Name: name, BlockNo: blockNo, Offset: offset, Size: size, Hash: hash, WeakHash: weakHash, BlockHashAlgorithm: algo, FromTemporary: fromTemporary})
 }
 
 // requestConnectionForDevice returns a connection to the given device, to
@@ -2609,12 +2634,14 @@ func (m *model) generateClusterConfigRLocked(device protocol.DeviceID) (*protoco
 		}
 
 		protocolFolder := protocol.Folder{
-			ID:                 folderCfg.ID,
-			Label:              folderCfg.Label,
-			ReadOnly:           folderCfg.Type == config.FolderTypeSendOnly,
-			IgnorePermissions:  folderCfg.IgnorePerms,
-			IgnoreDelete:       folderCfg.IgnoreDelete,
-			DisableTempIndexes: folderCfg.DisableTempIndexes,
+			ID:                     folderCfg.ID,
+			Label:                  folderCfg.Label,
+			ReadOnly:               folderCfg.Type == config.FolderTypeSendOnly,
+			IgnorePermissions:      folderCfg.IgnorePerms,
+			IgnoreDelete:           folderCfg.IgnoreDelete,
+			DisableTempIndexes:     folderCfg.DisableTempIndexes,
+			ContentDefinedChunking: folderCfg.ContentDefinedChunking && folderCfg.Type != config.FolderTypeReceiveEncrypted,
+			HashAlgorithm:          announcedHashAlgorithm(folderCfg),
 		}
 
 		fs := m.folderFiles[folderCfg.ID]
@@ -2737,6 +2764,9 @@ type TreeEntry struct {
 	Size     int64        `json:"size"`
 	Type     string       `json:"type"`
 	Children []*TreeEntry `json:"children,omitempty"`
+	// Placeholder is set on items of selective folders that aren't
+	// selected, i.e. that only exist in the global index.
+	Placeholder bool `json:"placeholder,omitempty"`
 }
 
 func findByName(slice []*TreeEntry, name string) *TreeEntry {
@@ -2751,6 +2781,7 @@ func findByName(slice []*TreeEntry, name string) *TreeEntry {
 func (m *model) GlobalDirectoryTree(folder, prefix string, levels int, dirsOnly bool) ([]*TreeEntry, error) {
 	m.mut.RLock()
 	files, ok := m.folderFiles[folder]
+	fcfg := m.folderCfgs[folder]
 	m.mut.RUnlock()
 	if !ok {
 		return nil, ErrFolderMissing
@@ -2803,10 +2834,11 @@ func (m *model) GlobalDirectoryTree(folder, prefix string, levels int, dirsOnly
 		}
 
 		parent.Children = append(parent.Children, &TreeEntry{
-			Name:    base,
-			Type:    f.Type.String(),
-			ModTime: f.ModTime(),
-			Size:    f.FileSize(),
+			Name:        base,
+			Type:        f.Type.String(),
+			ModTime:     f.ModTime(),
+			Size:        f.FileSize(),
+			Placeholder: !fcfg.IsSelected(prefix + f.Name),
 		})
 
 		return true
@@ -2862,6 +2894,42 @@ func (m *model) RestoreFolderVersions(folder string, versions map[string]time.Ti
 	return restoreErrors, nil
 }
 
+// RestoreFolderVersionsAt restores every file in the folder that has
+// changed since the given time to the version that was live at that time
+// (see versioner.VersionsAt). The selected versions are returned; in a dry
+// run nothing is restored.
+func (m *model) RestoreFolderVersionsAt(folder string, at time.Time, dryRun bool) (map[string]versioner.FileVersion, map[string]error, error) {
+	versions, err := m.GetFolderVersions(folder)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m.mut.RLock()
+	ffs := m.folderCfgs[folder].Filesystem(nil)
+	m.mut.RUnlock()
+	current := make(map[string]time.Time, len(versions))
+	for file := range versions {
+		if info, err := ffs.Lstat(file); err == nil && info.IsRegular() {
+			current[file] = info.ModTime()
+		}
+	}
+
+	selected := versioner.VersionsAt(versions, current, at)
+	if dryRun {
+		return selected, nil, nil
+	}
+
+	restore := make(map[string]time.Time, len(selected))
+	for file, version := range selected {
+		restore[file] = version.VersionTime
+	}
+	restoreErrors, err := m.RestoreFolderVersions(folder, restore)
+	if err != nil {
+		return nil, nil, err
+	}
+	return selected, restoreErrors, nil
+}
+
 func (m *model) Availability(folder string, file protocol.FileInfo, block protocol.BlockInfo) ([]Availability, error) {
 	m.mut.RLock()
 	defer m.mut.RUnlock()
@@ -2920,10 +2988,88 @@ func (m *model) fileAvailabilityRLocked(cfg config.FolderConfiguration, snap *db
 	return availabilities
 }
 
+// folderFeatures are the optional features a device announced for a
+// folder in its cluster config.
+type folderFeatures struct {
+	contentDefinedChunking bool
+	hashAlgorithm          protocol.HashAlgorithm
+}
+
+// contentDefinedChunking returns whether files in the folder should be
+// hashed using content defined chunking. This is the case if it's enabled
+// for the folder and all devices we share it with announced the same,
+// i.e. they run a version supporting it. It's never used when sharing
+// with untrusted (encrypted) devices.
+func (m *model) contentDefinedChunking(cfg config.FolderConfiguration) bool {
+	if !cfg.ContentDefinedChunking || cfg.Type == config.FolderTypeReceiveEncrypted {
+		return false
+	}
+	agreed, _ := m.remotesAgree(cfg, func(features folderFeatures) bool {
+		return features.contentDefinedChunking
+	})
+	return agreed
+}
+
+// announcedHashAlgorithm returns the hash algorithm we announce for the
+// folder.
+func announcedHashAlgorithm(cfg config.FolderConfiguration) protocol.HashAlgorithm {
+	if cfg.Type == config.FolderTypeReceiveEncrypted {
+		return protocol.HashAlgorithmSHA256
+	}
+	return cfg.HashAlgorithm.ToProtocol()
+}
+
+// blockHashAlgorithm returns the algorithm to hash blocks in the folder
+// with. That's the configured one if all devices we share the folder with
+// announced the same, otherwise SHA-256, which all devices support. The
+// second return value is false if the choice isn't settled yet, because
+// we haven't heard from all devices since starting up.
+func (m *model) blockHashAlgorithm(cfg config.FolderConfiguration) (protocol.HashAlgorithm, bool) {
+	algo := announcedHashAlgorithm(cfg)
+	if algo == protocol.HashAlgorithmSHA256 {
+		return algo, true
+	}
+	agreed, settled := m.remotesAgree(cfg, func(features folderFeatures) bool {
+		return features.hashAlgorithm == algo
+	})
+	if !agreed {
+		return protocol.HashAlgorithmSHA256, settled
+	}
+	return algo, true
+}
+
+// remotesAgree returns whether all devices the folder is shared with
+// announced it with features satisfying the given function. Sharing with
+// untrusted devices never agrees. The second return value is false if we
+// haven't received a cluster config from some device, and we'd agree
+// otherwise.
+func (m *model) remotesAgree(cfg config.FolderConfiguration, agrees func(folderFeatures) bool) (bool, bool) {
+	m.mut.RLock()
+	defer m.mut.RUnlock()
+	settled := true
+	for _, device := range cfg.Devices {
+		if device.DeviceID == m.id {
+			continue
+		}
+		if device.EncryptionPassword != "" {
+			return false, true
+		}
+		features, ok := m.remoteFolderFeatures[device.DeviceID][cfg.ID]
+		if !ok {
+			settled = false
+			continue
+		}
+		if !agrees(features) {
+			return false, true
+		}
+	}
+	return settled, settled
+}
+
 func (m *model) blockAvailabilityFromTemporaryRLocked(cfg config.FolderConfiguration, file protocol.FileInfo, block protocol.BlockInfo) []Availability {
 	var availabilities []Availability
 	for _, device := range cfg.Devices {
-		if m.deviceDownloads[device.DeviceID].Has(cfg.ID, file.Name, file.Version, int(block.Offset/int64(file.BlockSize()))) {
+		if m.deviceDownloads[device.DeviceID].Has(cfg.ID, file.Name, file.Version, file.BlockIndex(block.Offset)) {
 			availabilities = append(availabilities, Availability{ID: device.DeviceID, FromTemporary: true})
 		}
 	}
@@ -3054,7 +3200,11 @@ func (m *model) CommitConfiguration(from, to config.Configuration) bool {
 			if toCfg.Paused {
 				eventType = events.FolderPaused
 			}
-			m.evLogger.Log(eventType, map[string]string{"id": toCfg.ID, "label": toCfg.Label})
+			m.evLogger.Log(eventType, map[string]string{
+				"id":     toCfg.ID,
+				"label":  toCfg.Label,
+				"reason": m.scheduler.pauseReason(folderReasonKey(toCfg.ID), toCfg.Paused),
+			})
 		}
 	}
 
@@ -3078,7 +3228,10 @@ func (m *model) CommitConfiguration(from, to config.Configuration) bool {
 		if toCfg.Paused {
 			l.Infoln("Pausing", deviceID)
 			closeDevices = append(closeDevices, deviceID)
-			m.evLogger.Log(events.DevicePaused, map[string]string{"device": deviceID.String()})
+			m.evLogger.Log(events.DevicePaused, map[string]string{
+				"device": deviceID.String(),
+				"reason": m.scheduler.pauseReason(deviceReasonKey(deviceID), true),
+			})
 		} else {
 			// Ignored folder was removed, reconnect to retrigger the prompt.
 			if len(fromCfg.IgnoredFolders) > len(toCfg.IgnoredFolders) {
@@ -3086,7 +3239,10 @@ func (m *model) CommitConfiguration(from, to config.Configuration) bool {
 			}
 
 			l.Infoln("Resuming", deviceID)
-			m.evLogger.Log(events.DeviceResumed, map[string]string{"device": deviceID.String()})
+			m.evLogger.Log(events.DeviceResumed, map[string]string{
+				"device": deviceID.String(),
+				"reason": m.scheduler.pauseReason(deviceReasonKey(deviceID), false),
+			})
 		}
 
 		if toCfg.MaxRequestKiB != fromCfg.MaxRequestKiB {
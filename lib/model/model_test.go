@@ -222,7 +222,7 @@ func BenchmarkRequestOut(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		data, err := m.RequestGlobal(context.Background(), device1, "default", files[i%n].Name, 0, 0, 32, nil, 0, false)
+		data, err := m.RequestGlobal(context.Background(), device1, "default", files[i%n].Name, 0, 0, 32, nil, 0, protocol.HashAlgorithmSHA256, false)
 		if err != nil {
 			b.Error(err)
 		}
@@ -2973,7 +2973,7 @@ func TestConnCloseOnRestart(t *testing.T) {
 	nw := &testutil.NoopRW{}
 	ci := &protocolmocks.ConnectionInfo{}
 	ci.ConnectionIDReturns(srand.String(16))
-	m.AddConnection(protocol.NewConnection(device1, br, nw, testutil.NoopCloser{}, m, ci, protocol.CompressionNever, nil, m.keyGen), protocol.Hello{})
+	m.AddConnection(protocol.NewConnection(device1, br, nw, testutil.NoopCloser{}, m, ci, protocol.CompressionNever, protocol.MessageCompressionLZ4, 0, nil, m.keyGen), protocol.Hello{})
 	m.mut.RLock()
 	if len(m.closed) != 1 {
 		t.Fatalf("Expected just one conn (len(m.closed) == %v)", len(m.closed))
@@ -3175,6 +3175,10 @@ func TestFolderAPIErrors(t *testing.T) {
 			_, err := m.RestoreFolderVersions(folder, nil)
 			return err
 		},
+		func(folder string) error {
+			_, _, err := m.RestoreFolderVersionsAt(folder, time.Now(), true)
+			return err
+		},
 	}
 
 	for i, method := range methods {
@@ -4142,3 +4146,86 @@ type modtimeTruncatingFileInfo struct {
 func (fi modtimeTruncatingFileInfo) ModTime() time.Time {
 	return fi.FileInfo.ModTime().Truncate(fi.trunc)
 }
+
+func TestContentDefinedChunkingNegotiation(t *testing.T) {
+	w, fcfg, cancel := newDefaultCfgWrapper()
+	defer cancel()
+	fcfg.ContentDefinedChunking = true
+	setFolder(t, w, fcfg)
+	ffs := fcfg.Filesystem(nil)
+	m := setupModel(t, w)
+	defer cleanupModel(m)
+
+	cc, _ := m.generateClusterConfig(device1)
+	if len(cc.Folders) != 1 || !cc.Folders[0].ContentDefinedChunking {
+		t.Fatal("expected content defined chunking to be announced")
+	}
+
+	conn := newFakeConnection(device1, m)
+	m.AddConnection(conn, protocol.Hello{})
+	cc = basicClusterConfig(device1, myID, fcfg.ID)
+	m.ClusterConfig(conn, cc)
+	if m.contentDefinedChunking(fcfg) {
+		t.Error("content defined chunking used without the remote announcing it")
+	}
+
+	cc.Folders[0].ContentDefinedChunking = true
+	m.ClusterConfig(conn, cc)
+	if !m.contentDefinedChunking(fcfg) {
+		t.Fatal("content defined chunking not used after the remote announced it")
+	}
+
+	data := make([]byte, 4<<20)
+	srand.Read(data)
+	writeFile(t, ffs, "file", data)
+	must(t, m.ScanFolder(fcfg.ID))
+	fi, ok, err := m.CurrentFolderFile(fcfg.ID, "file")
+	must(t, err)
+	if !ok || !fi.ContentDefinedBlocks() {
+		t.Error("expected content defined blocks after scanning")
+	}
+}
+
+func TestBlockHashAlgorithmNegotiation(t *testing.T) {
+	w, fcfg, cancel := newDefaultCfgWrapper()
+	defer cancel()
+	fcfg.HashAlgorithm = config.HashAlgorithmBLAKE2b
+	setFolder(t, w, fcfg)
+	ffs := fcfg.Filesystem(nil)
+	m := setupModel(t, w)
+	defer cleanupModel(m)
+
+	cc, _ := m.generateClusterConfig(device1)
+	if len(cc.Folders) != 1 || cc.Folders[0].HashAlgorithm != protocol.HashAlgorithmBLAKE2b {
+		t.Fatal("expected BLAKE2b to be announced")
+	}
+	if algo, settled := m.blockHashAlgorithm(fcfg); algo != protocol.HashAlgorithmSHA256 || settled {
+		t.Error("expected unsettled SHA-256 before hearing from the remote, got", algo, settled)
+	}
+
+	conn := newFakeConnection(device1, m)
+	m.AddConnection(conn, protocol.Hello{})
+	cc = basicClusterConfig(device1, myID, fcfg.ID)
+	cc.Folders[0].HashAlgorithm = protocol.HashAlgorithmBLAKE2b
+	m.ClusterConfig(conn, cc)
+	if algo, settled := m.blockHashAlgorithm(fcfg); algo != protocol.HashAlgorithmBLAKE2b || !settled {
+		t.Fatal("expected settled BLAKE2b, got", algo, settled)
+	}
+
+	data := []byte("some data")
+	writeFile(t, ffs, "file", data)
+	must(t, m.ScanFolder(fcfg.ID))
+	fi, _ := localFile(t, m, fcfg.ID, "file")
+	if fi.BlockHashAlgorithm != protocol.HashAlgorithmBLAKE2b || !bytes.Equal(fi.Blocks[0].Hash, protocol.SumBlock(protocol.HashAlgorithmBLAKE2b, data)) {
+		t.Errorf("expected file to be hashed using BLAKE2b: %v", fi)
+	}
+
+	// The remote falling back to SHA-256 causes a rehash.
+	cc.Folders[0].HashAlgorithm = protocol.HashAlgorithmSHA256
+	m.ClusterConfig(conn, cc)
+	must(t, m.ScanFolder(fcfg.ID))
+	fi, _ = localFile(t, m, fcfg.ID, "file")
+	if fi.BlockHashAlgorithm != protocol.HashAlgorithmSHA256 || !bytes.Equal(fi.Blocks[0].Hash, protocol.SumBlock(protocol.HashAlgorithmSHA256, data)) {
+		t.Errorf("expected file to be rehashed using SHA-256: %v", fi)
+	}
+}
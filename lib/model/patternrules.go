@@ -0,0 +1,53 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"strings"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/ignore"
+)
+
+// patternRules are folder rules that apply to the files matching their
+// pattern, in ignore pattern syntax. The first matching rule applies.
+type patternRules[T any] []patternRule[T]
+
+type patternRule[T any] struct {
+	rule    T
+	matcher *ignore.Matcher // nil matches all files
+}
+
+// newPatternRules compiles the patterns of the given rules. Rules with an
+// invalid pattern are skipped, with a warning describing them as kind.
+func newPatternRules[T any](cfg config.FolderConfiguration, filesystem fs.Filesystem, kind string, rules []T, pattern func(T) string) patternRules[T] {
+	var prs patternRules[T]
+	for _, rule := range rules {
+		pr := patternRule[T]{rule: rule}
+		if pat := pattern(rule); pat != "" {
+			pr.matcher = ignore.New(filesystem)
+			if err := pr.matcher.Parse(strings.NewReader(pat), ""); err != nil {
+				l.Warnf("Ignoring %s of folder %s with invalid pattern %q: %v", kind, cfg.Description(), pat, err)
+				continue
+			}
+		}
+		prs = append(prs, pr)
+	}
+	return prs
+}
+
+// match returns the first rule matching the given file, if any.
+func (prs patternRules[T]) match(name string) (T, bool) {
+	for _, pr := range prs {
+		if pr.matcher == nil || pr.matcher.Match(name).IsIgnored() {
+			return pr.rule, true
+		}
+	}
+	var zero T
+	return zero, false
+}
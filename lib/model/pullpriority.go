@@ -0,0 +1,37 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+)
+
+// A pullPrioritizer assigns files to the priority tiers configured for a
+// folder.
+type pullPrioritizer struct {
+	tiers patternRules[config.PullPriority]
+}
+
+func newPullPrioritizer(cfg config.FolderConfiguration, filesystem fs.Filesystem) *pullPrioritizer {
+	return &pullPrioritizer{
+		tiers: newPatternRules(cfg, filesystem, "pull priority", cfg.PullPriorities, func(p config.PullPriority) string { return p.Pattern }),
+	}
+}
+
+// enabled returns whether there are any tiers, i.e. whether sorting by
+// priority can change the pull order at all.
+func (p *pullPrioritizer) enabled() bool {
+	return len(p.tiers) > 0
+}
+
+// priority returns the priority of the first tier matching the given file,
+// or zero if none does.
+func (p *pullPrioritizer) priority(name string) int {
+	tier, _ := p.tiers.match(name)
+	return tier.Priority
+}
@@ -24,6 +24,7 @@ type jobQueueEntry struct {
 	name     string
 	size     int64
 	modified int64
+	priority int
 }
 
 func newJobQueue() *jobQueue {
@@ -35,7 +36,7 @@ func newJobQueue() *jobQueue {
 func (q *jobQueue) Push(file string, size int64, modified time.Time) {
 	q.mut.Lock()
 	// The range of UnixNano covers a range of reasonable timestamps.
-	q.queued = append(q.queued, jobQueueEntry{file, size, modified.UnixNano()})
+	q.queued = append(q.queued, jobQueueEntry{name: file, size: size, modified: modified.UnixNano()})
 	q.mut.Unlock()
 }
 
@@ -134,6 +135,21 @@ func (q *jobQueue) Shuffle() {
 	rand.Shuffle(q.queued)
 }
 
+// SortByPriority sorts the queue by the priority of the files as given by
+// the priority function, highest first. The sort is stable, i.e. files of
+// the same priority stay in their current order.
+func (q *jobQueue) SortByPriority(priority func(name string) int) {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+
+	for i := range q.queued {
+		q.queued[i].priority = priority(q.queued[i].name)
+	}
+	sort.SliceStable(q.queued, func(a, b int) bool {
+		return q.queued[a].priority > q.queued[b].priority
+	})
+}
+
 func (q *jobQueue) Reset() {
 	q.mut.Lock()
 	defer q.mut.Unlock()
@@ -14,6 +14,9 @@ import (
 	"time"
 
 	"github.com/d4l3k/messagediff"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
 )
 
 func TestJobQueue(t *testing.T) {
@@ -252,6 +255,36 @@ func TestSortByAge(t *testing.T) {
 	}
 }
 
+func TestSortByPriority(t *testing.T) {
+	p := newPullPrioritizer(config.FolderConfiguration{
+		PullPriorities: []config.PullPriority{
+			{Pattern: "/docs", Priority: 10},
+			{Pattern: "*.db", Priority: -10},
+			{Pattern: "*.txt", Priority: 5},
+		},
+	}, fs.NewFilesystem(fs.FilesystemTypeFake, "sortbypriority"))
+
+	q := newJobQueue()
+	q.Push("a.db", 1, time.Time{})
+	q.Push("b.txt", 2, time.Time{})
+	q.Push("c.bin", 3, time.Time{})
+	q.Push("docs/d.db", 4, time.Time{})
+	q.Push("e.bin", 5, time.Time{})
+	q.Push("f.txt", 6, time.Time{})
+
+	q.SortLargestFirst()
+	q.SortByPriority(p.priority)
+
+	_, actual, _ := q.Jobs(1, 100)
+	// The first matching pattern decides the tier, and the order within a
+	// tier is kept.
+	expected := []string{"docs/d.db", "f.txt", "b.txt", "e.bin", "c.bin", "a.db"}
+
+	if diff, equal := messagediff.PrettyDiff(expected, actual); !equal {
+		t.Errorf("SortByPriority() diff:\n%s", diff)
+	}
+}
+
 func BenchmarkJobQueueBump(b *testing.B) {
 	files := genFiles(10000)
 
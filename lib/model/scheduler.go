@@ -0,0 +1,233 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// Reasons given in pause and resume events.
+const (
+	pauseReasonUser     = "user"
+	pauseReasonSchedule = "schedule"
+)
+
+// The scheduler pauses and resumes folders and devices at the boundaries of
+// their configured schedules. Outside of the boundaries the paused state is
+// left alone, i.e. a folder resumed manually outside its schedule stays
+// resumed until the next window ends. That goes for startup and schedule
+// changes as well, as we can't tell whether the current state is the
+// user's choice; the schedule takes effect at its next boundary.
+type scheduler struct {
+	cfg     config.Wrapper
+	now     func() time.Time
+	started <-chan struct{}
+
+	// Last seen schedule and state per folder ID or device ID
+	evalMut sync.Mutex
+	folders map[string]scheduleState
+	devices map[protocol.DeviceID]scheduleState
+
+	// Pending reasons for pause state changes, consumed when the
+	// corresponding event is emitted.
+	mut     sync.Mutex
+	reasons map[string]pauseReason
+}
+
+type scheduleState struct {
+	schedule config.Schedule
+	active   bool
+	// paused is true if the scheduler paused the folder or device
+	paused bool
+}
+
+type pauseReason struct {
+	paused bool
+	reason string
+}
+
+func newScheduler(cfg config.Wrapper, started <-chan struct{}) *scheduler {
+	return &scheduler{
+		cfg:     cfg,
+		now:     time.Now,
+		started: started,
+		evalMut: sync.NewMutex(),
+		folders: make(map[string]scheduleState),
+		devices: make(map[protocol.DeviceID]scheduleState),
+		mut:     sync.NewMutex(),
+		reasons: make(map[string]pauseReason),
+	}
+}
+
+func (s *scheduler) String() string {
+	return fmt.Sprintf("scheduler@%p", s)
+}
+
+func (s *scheduler) serve(ctx context.Context) error {
+	// Config changes made before the model is running don't result in
+	// events.
+	select {
+	case <-s.started:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		now := s.now()
+		s.evaluate(now)
+		// Windows start and end on full minutes.
+		timer.Reset(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+	}
+}
+
+// evaluate applies the schedules at the given time. The paused state only
+// changes when crossing a window boundary of an unchanged schedule since
+// the previous evaluation.
+func (s *scheduler) evaluate(now time.Time) {
+	s.evalMut.Lock()
+	defer s.evalMut.Unlock()
+
+	folderPaused := make(map[string]bool)
+	seenFolders := make(map[string]struct{})
+	for id, fcfg := range s.cfg.Folders() {
+		prev, ok := s.folders[id]
+		if len(fcfg.Schedule) == 0 {
+			// Don't leave a folder paused when its schedule is removed.
+			if ok && prev.paused && fcfg.Paused {
+				folderPaused[id] = false
+			}
+			continue
+		}
+		seenFolders[id] = struct{}{}
+		cur := scheduleState{schedule: fcfg.Schedule, active: fcfg.Schedule.Active(now), paused: prev.paused}
+		if ok && slices.Equal(prev.schedule, cur.schedule) && prev.active != cur.active {
+			if fcfg.Paused == cur.active {
+				folderPaused[id] = !cur.active
+			}
+			cur.paused = !cur.active
+		}
+		s.folders[id] = cur
+	}
+	for id := range s.folders {
+		if _, ok := seenFolders[id]; !ok {
+			delete(s.folders, id)
+		}
+	}
+
+	devicePaused := make(map[protocol.DeviceID]bool)
+	seenDevices := make(map[protocol.DeviceID]struct{})
+	for id, dcfg := range s.cfg.Devices() {
+		if id == s.cfg.MyID() {
+			continue
+		}
+		prev, ok := s.devices[id]
+		if len(dcfg.Schedule) == 0 {
+			if ok && prev.paused && dcfg.Paused {
+				devicePaused[id] = false
+			}
+			continue
+		}
+		seenDevices[id] = struct{}{}
+		cur := scheduleState{schedule: dcfg.Schedule, active: dcfg.Schedule.Active(now), paused: prev.paused}
+		if ok && slices.Equal(prev.schedule, cur.schedule) && prev.active != cur.active {
+			if dcfg.Paused == cur.active {
+				devicePaused[id] = !cur.active
+			}
+			cur.paused = !cur.active
+		}
+		s.devices[id] = cur
+	}
+	for id := range s.devices {
+		if _, ok := seenDevices[id]; !ok {
+			delete(s.devices, id)
+		}
+	}
+
+	if len(folderPaused) == 0 && len(devicePaused) == 0 {
+		return
+	}
+
+	s.mut.Lock()
+	for id, paused := range folderPaused {
+		s.reasons[folderReasonKey(id)] = pauseReason{paused, pauseReasonSchedule}
+	}
+	for id, paused := range devicePaused {
+		s.reasons[deviceReasonKey(id)] = pauseReason{paused, pauseReasonSchedule}
+	}
+	s.mut.Unlock()
+
+	waiter, err := s.cfg.Modify(func(cfg *config.Configuration) {
+		for i := range cfg.Folders {
+			if paused, ok := folderPaused[cfg.Folders[i].ID]; ok {
+				l.Infof("%s folder %s according to its schedule", pausingVerb(paused), cfg.Folders[i].Description())
+				cfg.Folders[i].Paused = paused
+			}
+		}
+		for i := range cfg.Devices {
+			if paused, ok := devicePaused[cfg.Devices[i].DeviceID]; ok {
+				l.Infof("%s device %s according to its schedule", pausingVerb(paused), cfg.Devices[i].DeviceID)
+				cfg.Devices[i].Paused = paused
+			}
+		}
+	})
+	if err != nil {
+		l.Warnln("Applying schedules:", err)
+	} else {
+		waiter.Wait()
+	}
+
+	// Drop whatever wasn't consumed, e.g. because the change failed.
+	s.mut.Lock()
+	for id := range folderPaused {
+		delete(s.reasons, folderReasonKey(id))
+	}
+	for id := range devicePaused {
+		delete(s.reasons, deviceReasonKey(id))
+	}
+	s.mut.Unlock()
+}
+
+// pauseReason returns the reason for the given folder or device having
+// been paused or resumed.
+func (s *scheduler) pauseReason(key string, paused bool) string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	if r, ok := s.reasons[key]; ok && r.paused == paused {
+		delete(s.reasons, key)
+		return r.reason
+	}
+	return pauseReasonUser
+}
+
+func folderReasonKey(id string) string {
+	return "folder:" + id
+}
+
+func deviceReasonKey(id protocol.DeviceID) string {
+	return "device:" + id.String()
+}
+
+func pausingVerb(paused bool) string {
+	if paused {
+		return "Pausing"
+	}
+	return "Resuming"
+}
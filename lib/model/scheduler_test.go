@@ -0,0 +1,155 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+)
+
+func TestFolderSchedule(t *testing.T) {
+	w, fcfg, wCancel := newDefaultCfgWrapper()
+	defer wCancel()
+	fcfg.Schedule = config.Schedule{{Start: "22:00", End: "06:00"}}
+	setFolder(t, w, fcfg)
+
+	// Monday noon, outside of the window
+	noon := time.Date(2025, 3, 3, 12, 0, 0, 0, time.Local)
+	m := newModel(t, w, myID, nil)
+	m.scheduler.now = func() time.Time { return noon }
+	sub := m.evLogger.Subscribe(events.FolderPaused | events.FolderResumed)
+	defer sub.Unsubscribe()
+	m.ServeBackground()
+	defer cleanupModelAndRemoveDir(m, fcfg.Filesystem(nil).URI())
+
+	expectEvent := func(typ events.EventType, reason string) {
+		t.Helper()
+		ev, err := sub.Poll(5 * time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := ev.Data.(map[string]string)
+		if ev.Type != typ || data["id"] != fcfg.ID || data["reason"] != reason {
+			t.Fatalf("Unexpected event %v %v, expected %v with reason %v", ev.Type, data, typ, reason)
+		}
+	}
+	expectPaused := func(paused bool) {
+		t.Helper()
+		if cfg, _ := w.Folder(fcfg.ID); cfg.Paused != paused {
+			t.Fatalf("Folder paused is %v, expected %v", cfg.Paused, paused)
+		}
+	}
+
+	// The schedule isn't applied on start, as the folder may have been
+	// resumed manually.
+	m.scheduler.evaluate(noon)
+	m.scheduler.evaluate(noon.Add(time.Hour))
+	expectPaused(false)
+
+	// Entering the window while resumed changes nothing.
+	m.scheduler.evaluate(time.Date(2025, 3, 3, 22, 30, 0, 0, time.Local))
+	expectPaused(false)
+
+	m.scheduler.evaluate(time.Date(2025, 3, 4, 6, 0, 0, 0, time.Local))
+	expectEvent(events.FolderPaused, pauseReasonSchedule)
+	expectPaused(true)
+
+	// A manual change sticks until the next window boundary.
+	pauseFolder(t, w, fcfg.ID, false)
+	expectEvent(events.FolderResumed, pauseReasonUser)
+	m.scheduler.evaluate(time.Date(2025, 3, 4, 7, 0, 0, 0, time.Local))
+	expectPaused(false)
+	m.scheduler.evaluate(time.Date(2025, 3, 4, 22, 30, 0, 0, time.Local))
+	expectPaused(false)
+
+	m.scheduler.evaluate(time.Date(2025, 3, 5, 6, 0, 0, 0, time.Local))
+	expectEvent(events.FolderPaused, pauseReasonSchedule)
+	m.scheduler.evaluate(time.Date(2025, 3, 5, 22, 0, 0, 0, time.Local))
+	expectEvent(events.FolderResumed, pauseReasonSchedule)
+	expectPaused(false)
+
+	// Removing the schedule resumes a folder it paused.
+	m.scheduler.evaluate(time.Date(2025, 3, 6, 7, 0, 0, 0, time.Local))
+	expectEvent(events.FolderPaused, pauseReasonSchedule)
+	fcfg, _ = w.Folder(fcfg.ID)
+	fcfg.Schedule = nil
+	setFolder(t, w, fcfg)
+	m.scheduler.evaluate(time.Date(2025, 3, 6, 8, 0, 0, 0, time.Local))
+	expectEvent(events.FolderResumed, pauseReasonSchedule)
+	expectPaused(false)
+}
+
+func TestFolderScheduleRestart(t *testing.T) {
+	// The folder was paused manually during its window before a restart.
+	w, fcfg, wCancel := newDefaultCfgWrapper()
+	defer wCancel()
+	fcfg.Schedule = config.Schedule{{Start: "22:00", End: "06:00"}}
+	fcfg.Paused = true
+	setFolder(t, w, fcfg)
+
+	night := time.Date(2025, 3, 3, 23, 0, 0, 0, time.Local)
+	m := newModel(t, w, myID, nil)
+	m.scheduler.now = func() time.Time { return night }
+	m.ServeBackground()
+	defer cleanupModelAndRemoveDir(m, fcfg.Filesystem(nil).URI())
+
+	expectPaused := func(paused bool) {
+		t.Helper()
+		if cfg, _ := w.Folder(fcfg.ID); cfg.Paused != paused {
+			t.Fatalf("Folder paused is %v, expected %v", cfg.Paused, paused)
+		}
+	}
+
+	m.scheduler.evaluate(night)
+	m.scheduler.evaluate(night.Add(30 * time.Minute))
+	expectPaused(true)
+
+	// Nor does changing the schedule override a manual change.
+	pauseFolder(t, w, fcfg.ID, false)
+	fcfg, _ = w.Folder(fcfg.ID)
+	fcfg.Schedule = config.Schedule{{Start: "08:00", End: "09:00"}}
+	setFolder(t, w, fcfg)
+	m.scheduler.evaluate(night.Add(45 * time.Minute))
+	expectPaused(false)
+
+	// The new schedule applies from its next boundary.
+	m.scheduler.evaluate(time.Date(2025, 3, 4, 8, 0, 0, 0, time.Local))
+	expectPaused(false)
+	m.scheduler.evaluate(time.Date(2025, 3, 4, 9, 0, 0, 0, time.Local))
+	expectPaused(true)
+}
+
+func TestFolderScheduleSummary(t *testing.T) {
+	schedule := config.Schedule{{Days: "mon-fri", Start: "22:00", End: "06:00"}}
+
+	// Saturday noon, the next window starts Monday evening.
+	sum := newFolderScheduleSummary(schedule, time.Date(2025, 3, 8, 12, 0, 0, 0, time.Local))
+	if sum.InWindow || !sum.WindowEnd.IsZero() {
+		t.Error("Unexpectedly in window:", sum)
+	}
+	if exp := time.Date(2025, 3, 10, 22, 0, 0, 0, time.Local); !sum.NextWindowStart.Equal(exp) {
+		t.Errorf("Next window starts %v, expected %v", sum.NextWindowStart, exp)
+	}
+	if exp := time.Date(2025, 3, 11, 6, 0, 0, 0, time.Local); !sum.NextWindowEnd.Equal(exp) {
+		t.Errorf("Next window ends %v, expected %v", sum.NextWindowEnd, exp)
+	}
+
+	// Friday night, the window extends into Saturday morning.
+	sum = newFolderScheduleSummary(schedule, time.Date(2025, 3, 7, 23, 0, 0, 0, time.Local))
+	if !sum.InWindow {
+		t.Error("Unexpectedly not in window:", sum)
+	}
+	if exp := time.Date(2025, 3, 8, 6, 0, 0, 0, time.Local); !sum.WindowEnd.Equal(exp) {
+		t.Errorf("Window ends %v, expected %v", sum.WindowEnd, exp)
+	}
+	if exp := time.Date(2025, 3, 10, 22, 0, 0, 0, time.Local); !sum.NextWindowStart.Equal(exp) {
+		t.Errorf("Next window starts %v, expected %v", sum.NextWindowStart, exp)
+	}
+}
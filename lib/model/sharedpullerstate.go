@@ -30,7 +30,9 @@ type sharedPullerState struct {
 	folder      string
 	tempName    string
 	realName    string
-	reused      int // Number of blocks reused from temporary file
+	reused      int   // Number of blocks reused from temporary file
+	reusedBytes int64 // Size of the blocks reused from temporary file
+	totalBytes  int64 // Size of all blocks of the job, including reused ones
 	ignorePerms bool
 	hasCurFile  bool              // Whether curFile is set
 	curFile     protocol.FileInfo // The file as it exists now in our database
@@ -45,6 +47,10 @@ type sharedPullerState struct {
 	pullTotal         int             // Total number of pull actions for the whole job
 	copyOrigin        int             // Number of blocks copied from the original file
 	copyOriginShifted int             // Number of blocks copied from the original file but shifted
+	copiedBytes       int64           // Size of the blocks copied, including from the original file
+	originBytes       int64           // Size of the blocks copied from the original file
+	shiftedBytes      int64           // Size of the blocks copied from the original file but shifted
+	pulledBytes       int64           // Size of the blocks pulled
 	copyNeeded        int             // Number of copy actions still pending
 	pullNeeded        int             // Number of block pulls still pending
 	updated           time.Time       // Time when any of the counters above were last updated
@@ -55,6 +61,14 @@ type sharedPullerState struct {
 }
 
 func newSharedPullerState(file protocol.FileInfo, fs fs.Filesystem, folderID, tempName string, blocks []protocol.BlockInfo, reused []int, ignorePerms, hasCurFile bool, curFile protocol.FileInfo, sparse bool, fsync bool) *sharedPullerState {
+	var reusedBytes, totalBytes int64
+	for _, i := range reused {
+		reusedBytes += int64(file.Blocks[i].Size)
+	}
+	totalBytes = reusedBytes
+	for _, b := range blocks {
+		totalBytes += int64(b.Size)
+	}
 	return &sharedPullerState{
 		file:             file,
 		fs:               fs,
@@ -64,6 +78,8 @@ func newSharedPullerState(file protocol.FileInfo, fs fs.Filesystem, folderID, te
 		copyTotal:        len(blocks),
 		copyNeeded:       len(blocks),
 		reused:           len(reused),
+		reusedBytes:      reusedBytes,
+		totalBytes:       totalBytes,
 		updated:          time.Now(),
 		available:        reused,
 		availableUpdated: time.Now(),
@@ -257,8 +273,9 @@ func (s *sharedPullerState) failed() error {
 func (s *sharedPullerState) copyDone(block protocol.BlockInfo) {
 	s.mut.Lock()
 	s.copyNeeded--
+	s.copiedBytes += int64(block.Size)
 	s.updated = time.Now()
-	s.available = append(s.available, int(block.Offset/int64(s.file.BlockSize())))
+	s.available = append(s.available, s.file.BlockIndex(block.Offset))
 	s.availableUpdated = time.Now()
 	l.Debugln("sharedPullerState", s.folder, s.file.Name, "copyNeeded ->", s.copyNeeded)
 	s.mut.Unlock()
@@ -267,6 +284,7 @@ func (s *sharedPullerState) copyDone(block protocol.BlockInfo) {
 func (s *sharedPullerState) copiedFromOrigin(bytes int) {
 	s.mut.Lock()
 	s.copyOrigin++
+	s.originBytes += int64(bytes)
 	s.updated = time.Now()
 	s.mut.Unlock()
 	metricFolderProcessedBytesTotal.WithLabelValues(s.folder, metricSourceLocalOrigin).Add(float64(bytes))
@@ -280,6 +298,7 @@ func (s *sharedPullerState) skippedSparseBlock(bytes int) {
 	// pretend we copied it, historical
 	s.mut.Lock()
 	s.copyOrigin++
+	s.originBytes += int64(bytes)
 	s.updated = time.Now()
 	s.mut.Unlock()
 	metricFolderProcessedBytesTotal.WithLabelValues(s.folder, metricSourceSkipped).Add(float64(bytes))
@@ -289,6 +308,8 @@ func (s *sharedPullerState) copiedFromOriginShifted(bytes int) {
 	s.mut.Lock()
 	s.copyOrigin++
 	s.copyOriginShifted++
+	s.originBytes += int64(bytes)
+	s.shiftedBytes += int64(bytes)
 	s.updated = time.Now()
 	s.mut.Unlock()
 	metricFolderProcessedBytesTotal.WithLabelValues(s.folder, metricSourceLocalShifted).Add(float64(bytes))
@@ -308,8 +329,9 @@ func (s *sharedPullerState) pullStarted() {
 func (s *sharedPullerState) pullDone(block protocol.BlockInfo) {
 	s.mut.Lock()
 	s.pullNeeded--
+	s.pulledBytes += int64(block.Size)
 	s.updated = time.Now()
-	s.available = append(s.available, int(block.Offset/int64(s.file.BlockSize())))
+	s.available = append(s.available, s.file.BlockIndex(block.Offset))
 	s.availableUpdated = time.Now()
 	l.Debugln("sharedPullerState", s.folder, s.file.Name, "pullNeeded done ->", s.pullNeeded)
 	s.mut.Unlock()
@@ -412,8 +434,6 @@ func (s *sharedPullerState) Progress() *PullerProgress {
 	s.mut.RLock()
 	defer s.mut.RUnlock()
 	total := s.reused + s.copyTotal + s.pullTotal
-	done := total - s.copyNeeded - s.pullNeeded
-	file := len(s.file.Blocks)
 	return &PullerProgress{
 		Total:               total,
 		Reused:              s.reused,
@@ -421,8 +441,8 @@ func (s *sharedPullerState) Progress() *PullerProgress {
 		CopiedFromElsewhere: s.copyTotal - s.copyNeeded - s.copyOrigin,
 		Pulled:              s.pullTotal - s.pullNeeded,
 		Pulling:             s.pullNeeded,
-		BytesTotal:          blocksToSize(total, file, s.file.BlockSize(), s.file.Size),
-		BytesDone:           blocksToSize(done, file, s.file.BlockSize(), s.file.Size),
+		BytesTotal:          s.totalBytes,
+		BytesDone:           s.reusedBytes + s.copiedBytes + s.pulledBytes,
 	}
 }
 
@@ -449,13 +469,3 @@ func (s *sharedPullerState) Available() []int {
 	s.mut.RUnlock()
 	return blocks
 }
-
-func blocksToSize(blocks, blocksInFile, blockSize int, fileSize int64) int64 {
-	// The last/only block has somewhere between 1 and blockSize bytes. We do
-	// not know whether the smaller block is part of the blocks and use an
-	// estimate assuming a random chance that the small block is contained.
-	if blocksInFile == 0 {
-		return 0
-	}
-	return int64(blocks)*int64(blockSize) - (int64(blockSize)-fileSize%int64(blockSize))*int64(blocks)/int64(blocksInFile)
-}
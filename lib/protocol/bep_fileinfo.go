@@ -11,6 +11,7 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/syncthing/syncthing/internal/gen/bep"
@@ -75,6 +76,8 @@ type FileInfo struct {
 	Permissions  uint32
 	ModifiedNs   int32
 	RawBlockSize int32
+	// The algorithm used for the block hashes
+	BlockHashAlgorithm HashAlgorithm
 
 	// The local_flags fields stores flags that are relevant to the local
 	// host only. It is not part of the protocol, doesn't get sent or
@@ -110,24 +113,25 @@ func (f *FileInfo) ToWire(withInternalFields bool) *bep.FileInfo {
 		blocks[j] = b.ToWire()
 	}
 	w := &bep.FileInfo{
-		Name:          f.Name,
-		Size:          f.Size,
-		ModifiedS:     f.ModifiedS,
-		ModifiedBy:    uint64(f.ModifiedBy),
-		Version:       f.Version.ToWire(),
-		Sequence:      f.Sequence,
-		Blocks:        blocks,
-		SymlinkTarget: f.SymlinkTarget,
-		BlocksHash:    f.BlocksHash,
-		Encrypted:     f.Encrypted,
-		Type:          f.Type,
-		Permissions:   f.Permissions,
-		ModifiedNs:    f.ModifiedNs,
-		BlockSize:     f.RawBlockSize,
-		Platform:      f.Platform.toWire(),
-		Deleted:       f.Deleted,
-		Invalid:       f.RawInvalid,
-		NoPermissions: f.NoPermissions,
+		Name:               f.Name,
+		Size:               f.Size,
+		ModifiedS:          f.ModifiedS,
+		ModifiedBy:         uint64(f.ModifiedBy),
+		Version:            f.Version.ToWire(),
+		Sequence:           f.Sequence,
+		Blocks:             blocks,
+		SymlinkTarget:      f.SymlinkTarget,
+		BlocksHash:         f.BlocksHash,
+		Encrypted:          f.Encrypted,
+		Type:               f.Type,
+		Permissions:        f.Permissions,
+		ModifiedNs:         f.ModifiedNs,
+		BlockSize:          f.RawBlockSize,
+		Platform:           f.Platform.toWire(),
+		Deleted:            f.Deleted,
+		Invalid:            f.RawInvalid,
+		NoPermissions:      f.NoPermissions,
+		BlockHashAlgorithm: f.BlockHashAlgorithm,
 	}
 	if withInternalFields {
 		w.LocalFlags = f.LocalFlags
@@ -176,7 +180,9 @@ func FileInfoFromWire(w *bep.FileInfo) FileInfo {
 			blocks[j] = BlockInfoFromWire(b)
 		}
 	}
-	return fileInfoFromWireWithBlocks(w, blocks)
+	f := fileInfoFromWireWithBlocks(w, blocks)
+	f.BlockHashAlgorithm = w.BlockHashAlgorithm
+	return f
 }
 
 type FileInfoWithoutBlocks interface {
@@ -324,6 +330,34 @@ func (f FileInfo) BlockSize() int {
 	return int(f.RawBlockSize)
 }
 
+// BlockIndex returns the index of the block starting at the given offset,
+// or -1 if there is no such block. Blocks are usually of BlockSize(), but
+// may vary in size when content defined chunking is used.
+func (f FileInfo) BlockIndex(offset int64) int {
+	if i := int(offset / int64(f.BlockSize())); i < len(f.Blocks) && f.Blocks[i].Offset == offset {
+		return i
+	}
+	i := sort.Search(len(f.Blocks), func(i int) bool {
+		return f.Blocks[i].Offset >= offset
+	})
+	if i < len(f.Blocks) && f.Blocks[i].Offset == offset {
+		return i
+	}
+	return -1
+}
+
+// ContentDefinedBlocks returns whether the blocks of the file vary in size,
+// i.e. the file was hashed using content defined chunking.
+func (f FileInfo) ContentDefinedBlocks() bool {
+	blockSize := f.BlockSize()
+	for i := 0; i < len(f.Blocks)-1; i++ {
+		if f.Blocks[i].Size != blockSize {
+			return true
+		}
+	}
+	return false
+}
+
 // BlockSize returns the block size to use for the given file size
 func BlockSize(fileSize int64) int {
 	var blockSize int
@@ -291,3 +291,43 @@ func TestBlocksEqual(t *testing.T) {
 		}
 	}
 }
+
+func TestBlockIndex(t *testing.T) {
+	fixed := FileInfo{RawBlockSize: MinBlockSize, Blocks: []BlockInfo{
+		{Offset: 0, Size: MinBlockSize},
+		{Offset: MinBlockSize, Size: MinBlockSize},
+		{Offset: 2 * MinBlockSize, Size: 10},
+	}}
+	chunked := FileInfo{RawBlockSize: MinBlockSize, Blocks: []BlockInfo{
+		{Offset: 0, Size: 1000},
+		{Offset: 1000, Size: 2 * MinBlockSize},
+		{Offset: 1000 + 2*MinBlockSize, Size: 10},
+	}}
+
+	if fixed.ContentDefinedBlocks() {
+		t.Error("fixed size blocks reported as content defined")
+	}
+	if !chunked.ContentDefinedBlocks() {
+		t.Error("varying size blocks not reported as content defined")
+	}
+
+	cases := []struct {
+		file   FileInfo
+		offset int64
+		index  int
+	}{
+		{fixed, 0, 0},
+		{fixed, 2 * MinBlockSize, 2},
+		{fixed, 10, -1},
+		{chunked, 0, 0},
+		{chunked, 1000, 1},
+		{chunked, 1000 + 2*MinBlockSize, 2},
+		{chunked, MinBlockSize, -1},
+		{chunked, 3 * MinBlockSize, -1},
+	}
+	for _, tc := range cases {
+		if index := tc.file.BlockIndex(tc.offset); index != tc.index {
+			t.Errorf("BlockIndex(%d) == %d, expected %d", tc.offset, index, tc.index)
+		}
+	}
+}
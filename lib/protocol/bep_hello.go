@@ -10,6 +10,7 @@ import (
 	"encoding/binary"
 	"errors"
 	"io"
+	"slices"
 
 	"google.golang.org/protobuf/proto"
 
@@ -36,6 +37,9 @@ type Hello struct {
 	ClientVersion  string
 	NumConnections int
 	Timestamp      int64
+	// Compressions lists the message compressions, in addition to LZ4,
+	// which the sender is able to decompress.
+	Compressions []MessageCompression
 }
 
 func (h *Hello) toWire() *bep.Hello {
@@ -45,6 +49,7 @@ func (h *Hello) toWire() *bep.Hello {
 		ClientVersion:  h.ClientVersion,
 		NumConnections: int32(h.NumConnections),
 		Timestamp:      h.Timestamp,
+		Compressions:   h.Compressions,
 	}
 }
 
@@ -55,6 +60,19 @@ func helloFromWire(w *bep.Hello) Hello {
 		ClientVersion:  w.ClientVersion,
 		NumConnections: int(w.NumConnections),
 		Timestamp:      w.Timestamp,
+		Compressions:   w.Compressions,
+	}
+}
+
+// SupportsCompression returns whether the sender of the hello is able to
+// decompress messages using the given compression. LZ4 is supported by all
+// devices, even those that don't announce any compressions.
+func (h Hello) SupportsCompression(c MessageCompression) bool {
+	switch c {
+	case MessageCompressionNone, MessageCompressionLZ4:
+		return true
+	default:
+		return slices.Contains(h.Compressions, c)
 	}
 }
 
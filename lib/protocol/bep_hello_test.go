@@ -113,3 +113,31 @@ func (rw *readWriter) Write(data []byte) (int, error) {
 func (rw *readWriter) Read(data []byte) (int, error) {
 	return rw.r.Read(data)
 }
+
+func TestNegotiateCompression(t *testing.T) {
+	var old Hello // announces nothing, like devices predating zstd support
+	current := Hello{Compressions: SupportedCompressions}
+
+	cases := []struct {
+		preferred MessageCompression
+		remote    Hello
+		expected  MessageCompression
+	}{
+		{MessageCompressionLZ4, old, MessageCompressionLZ4},
+		{MessageCompressionLZ4, current, MessageCompressionLZ4},
+		{MessageCompressionZstd, old, MessageCompressionLZ4},
+		{MessageCompressionZstd, current, MessageCompressionZstd},
+		{MessageCompressionNone, current, MessageCompressionLZ4},
+	}
+
+	for _, tc := range cases {
+		if res := NegotiateCompression(tc.preferred, tc.remote); res != tc.expected {
+			t.Errorf("preferring %v with remote %v: got %v, expected %v", tc.preferred, tc.remote.Compressions, res, tc.expected)
+		}
+	}
+
+	// The announced compressions survive the round trip over the wire.
+	if res := helloFromWire(current.toWire()); !res.SupportsCompression(MessageCompressionZstd) {
+		t.Error("zstd support lost in wire conversion")
+	}
+}
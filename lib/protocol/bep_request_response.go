@@ -27,33 +27,37 @@ type Request struct {
 	FromTemporary bool
 	WeakHash      uint32
 	BlockNo       int
+	// The algorithm used for Hash
+	BlockHashAlgorithm HashAlgorithm
 }
 
 func (r *Request) toWire() *bep.Request {
 	return &bep.Request{
-		Id:            int32(r.ID),
-		Folder:        r.Folder,
-		Name:          r.Name,
-		Offset:        r.Offset,
-		Size:          int32(r.Size),
-		Hash:          r.Hash,
-		FromTemporary: r.FromTemporary,
-		WeakHash:      r.WeakHash,
-		BlockNo:       int32(r.BlockNo),
+		Id:                 int32(r.ID),
+		Folder:             r.Folder,
+		Name:               r.Name,
+		Offset:             r.Offset,
+		Size:               int32(r.Size),
+		Hash:               r.Hash,
+		FromTemporary:      r.FromTemporary,
+		WeakHash:           r.WeakHash,
+		BlockNo:            int32(r.BlockNo),
+		BlockHashAlgorithm: r.BlockHashAlgorithm,
 	}
 }
 
 func requestFromWire(w *bep.Request) *Request {
 	return &Request{
-		ID:            int(w.Id),
-		Folder:        w.Folder,
-		Name:          w.Name,
-		Offset:        w.Offset,
-		Size:          int(w.Size),
-		Hash:          w.Hash,
-		FromTemporary: w.FromTemporary,
-		WeakHash:      w.WeakHash,
-		BlockNo:       int(w.BlockNo),
+		ID:                 int(w.Id),
+		Folder:             w.Folder,
+		Name:               w.Name,
+		Offset:             w.Offset,
+		Size:               int(w.Size),
+		Hash:               w.Hash,
+		FromTemporary:      w.FromTemporary,
+		WeakHash:           w.WeakHash,
+		BlockNo:            int(w.BlockNo),
+		BlockHashAlgorithm: w.BlockHashAlgorithm,
 	}
 }
 
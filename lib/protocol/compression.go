@@ -0,0 +1,125 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package protocol
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/syncthing/syncthing/internal/gen/bep"
+)
+
+// MessageCompression is the algorithm used to compress an individual
+// message on the wire, as opposed to Compression which decides which
+// messages are compressed.
+type MessageCompression = bep.MessageCompression
+
+const (
+	MessageCompressionNone = bep.MessageCompression_MESSAGE_COMPRESSION_NONE
+	MessageCompressionLZ4  = bep.MessageCompression_MESSAGE_COMPRESSION_LZ4
+	MessageCompressionZstd = bep.MessageCompression_MESSAGE_COMPRESSION_ZSTD
+)
+
+// SupportedCompressions are the message compressions, apart from LZ4, that
+// we are able to decompress. They are announced in the hello message.
+var SupportedCompressions = []MessageCompression{MessageCompressionZstd}
+
+// NegotiateCompression returns the message compression to use when sending
+// to the remote device, given the one we prefer. It is LZ4 unless the
+// remote has announced support for the preferred compression.
+func NegotiateCompression(preferred MessageCompression, remote Hello) MessageCompression {
+	if preferred == MessageCompressionNone || !remote.SupportsCompression(preferred) {
+		return MessageCompressionLZ4
+	}
+	return preferred
+}
+
+// The zstd encoders are safe for concurrent use and fairly expensive to
+// create, hence we share one per compression level among all connections.
+var (
+	zstdEncoders    = make(map[zstd.EncoderLevel]*zstd.Encoder)
+	zstdEncodersMut sync.Mutex
+	zstdDecoder     *zstd.Decoder
+	zstdDecoderOnce sync.Once
+)
+
+// zstdEncoder returns an encoder for the given zstd compression level, as
+// understood by the zstd command line tool. Zero selects the default level.
+func zstdEncoder(level int) (*zstd.Encoder, error) {
+	encLevel := zstd.SpeedDefault
+	if level != 0 {
+		encLevel = zstd.EncoderLevelFromZstd(level)
+	}
+
+	zstdEncodersMut.Lock()
+	defer zstdEncodersMut.Unlock()
+	if enc, ok := zstdEncoders[encLevel]; ok {
+		return enc, nil
+	}
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(encLevel), zstd.WithEncoderCRC(false))
+	if err != nil {
+		return nil, err
+	}
+	zstdEncoders[encLevel] = enc
+	return enc, nil
+}
+
+// zstdCompress compresses src into buf, using the same framing as
+// lz4Compress: the compressed data is prefixed by the size of the
+// uncompressed data.
+func zstdCompress(src, buf []byte, level int) (int, error) {
+	enc, err := zstdEncoder(level)
+	if err != nil {
+		return -1, err
+	}
+
+	out := enc.EncodeAll(src, buf[4:4])
+	if len(out) > len(buf)-4 {
+		return -1, errNotCompressible
+	}
+	// The encoder may have had to reallocate, in which case the output
+	// isn't in buf yet.
+	copy(buf[4:], out)
+	binary.BigEndian.PutUint32(buf, uint32(len(src)))
+
+	return len(out) + 4, nil
+}
+
+func zstdDecompress(src []byte) ([]byte, error) {
+	zstdDecoderOnce.Do(func() {
+		var err error
+		zstdDecoder, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderMaxMemory(MaxMessageLen))
+		if err != nil {
+			panic("bug: creating zstd decoder: " + err.Error())
+		}
+	})
+
+	if len(src) < 4 {
+		return nil, errors.New("compressed message too short")
+	}
+	size := binary.BigEndian.Uint32(src)
+	if size > MaxMessageLen {
+		return nil, fmt.Errorf("decompressed size %d exceeds maximum %d", size, MaxMessageLen)
+	}
+	buf := BufferPool.Get(int(size))
+
+	out, err := zstdDecoder.DecodeAll(src[4:], buf[:0])
+	if err != nil {
+		BufferPool.Put(buf)
+		return nil, err
+	}
+	if len(out) != int(size) {
+		BufferPool.Put(buf)
+		return nil, fmt.Errorf("decompressed size %d does not match announced size %d", len(out), size)
+	}
+
+	return out, nil
+}
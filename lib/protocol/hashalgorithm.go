@@ -0,0 +1,46 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package protocol
+
+import (
+	"crypto/sha256"
+	"hash"
+
+	"golang.org/x/crypto/blake2b"
+
+	"github.com/syncthing/syncthing/internal/gen/bep"
+)
+
+// HashAlgorithm is the algorithm used for block hashes. Both algorithms
+// produce 32 byte hashes.
+type HashAlgorithm = bep.HashAlgorithm
+
+const (
+	HashAlgorithmSHA256  = bep.HashAlgorithm_HASH_ALGORITHM_SHA256
+	HashAlgorithmBLAKE2b = bep.HashAlgorithm_HASH_ALGORITHM_BLAKE2B
+)
+
+// NewBlockHash returns a new hash.Hash computing block hashes using the
+// given algorithm.
+func NewBlockHash(algo HashAlgorithm) hash.Hash {
+	if algo == HashAlgorithmBLAKE2b {
+		h, _ := blake2b.New256(nil) // only fails for a too long key
+		return h
+	}
+	return sha256.New()
+}
+
+// SumBlock returns the hash of the given block data using the given
+// algorithm.
+func SumBlock(algo HashAlgorithm, data []byte) []byte {
+	if algo == HashAlgorithmBLAKE2b {
+		sum := blake2b.Sum256(data)
+		return sum[:]
+	}
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
@@ -186,6 +186,8 @@ type rawConnection struct {
 	closeOnce             sync.Once
 	sendCloseOnce         sync.Once
 	compression           Compression
+	msgCompression        MessageCompression
+	compressionLevel      int
 	startStopMut          sync.Mutex // start and stop must be serialized
 
 	loopWG sync.WaitGroup // Need to ensure no leftover routines in testing
@@ -215,7 +217,11 @@ const (
 // Should not be modified in production code, just for testing.
 var CloseTimeout = 10 * time.Second
 
-func NewConnection(deviceID DeviceID, reader io.Reader, writer io.Writer, closer io.Closer, model Model, connInfo ConnectionInfo, compress Compression, passwords map[string]string, keyGen *KeyGenerator) Connection {
+// NewConnection creates a connection to the given device. The compress
+// setting decides which messages are compressed, using msgCompression at the
+// given level (for zstd; zero is the default). The message compression must
+// be supported by the remote device, see NegotiateCompression.
+func NewConnection(deviceID DeviceID, reader io.Reader, writer io.Writer, closer io.Closer, model Model, connInfo ConnectionInfo, compress Compression, msgCompression MessageCompression, compressionLevel int, passwords map[string]string, keyGen *KeyGenerator) Connection {
 	// We create the wrapper for the model first, as it needs to be passed
 	// in at the lowest level in the stack. At the end of construction,
 	// before returning, we add the connection to cwm so that it can be used
@@ -229,7 +235,7 @@ func NewConnection(deviceID DeviceID, reader io.Reader, writer io.Writer, closer
 
 	// We do the wire format conversion first (outermost) so that the
 	// metadata is in wire format when it reaches the encryption step.
-	rc := newRawConnection(deviceID, reader, writer, closer, em, connInfo, compress)
+	rc := newRawConnection(deviceID, reader, writer, closer, em, connInfo, compress, msgCompression, compressionLevel)
 	ec := newEncryptedConnection(rc, rc, em.folderKeys, keyGen)
 	wc := wireFormatConnection{ec}
 
@@ -237,7 +243,7 @@ func NewConnection(deviceID DeviceID, reader io.Reader, writer io.Writer, closer
 	return wc
 }
 
-func newRawConnection(deviceID DeviceID, reader io.Reader, writer io.Writer, closer io.Closer, receiver rawModel, connInfo ConnectionInfo, compress Compression) *rawConnection {
+func newRawConnection(deviceID DeviceID, reader io.Reader, writer io.Writer, closer io.Closer, receiver rawModel, connInfo ConnectionInfo, compress Compression, msgCompression MessageCompression, compressionLevel int) *rawConnection {
 	idString := deviceID.String()
 	cr := &countingReader{Reader: reader, idString: idString}
 	cw := &countingWriter{Writer: writer, idString: idString}
@@ -260,6 +266,8 @@ func newRawConnection(deviceID DeviceID, reader io.Reader, writer io.Writer, clo
 		dispatcherLoopStopped: make(chan struct{}),
 		closed:                make(chan struct{}),
 		compression:           compress,
+		msgCompression:        msgCompression,
+		compressionLevel:      compressionLevel,
 		loopWG:                sync.WaitGroup{},
 	}
 }
@@ -531,6 +539,14 @@ func (c *rawConnection) readMessageAfterHeader(hdr *bep.Header, fourByteBuf []by
 		}
 		buf = decomp
 
+	case bep.MessageCompression_MESSAGE_COMPRESSION_ZSTD:
+		decomp, err := zstdDecompress(buf)
+		BufferPool.Put(buf)
+		if err != nil {
+			return nil, fmt.Errorf("decompressing message: %w", err)
+		}
+		buf = decomp
+
 	default:
 		return nil, fmt.Errorf("unknown message compression %d", hdr.Compression)
 	}
@@ -814,9 +830,14 @@ func (c *rawConnection) writeMessage(msg proto.Message) error {
 // The first return value indicates whether compression succeeded.
 // If not, the caller should retry without compression.
 func (c *rawConnection) writeCompressedMessage(msg proto.Message, marshaled []byte) (ok bool, err error) {
+	// LZ4 is the default, supported by every device.
+	msgCompression := MessageCompressionLZ4
+	if c.msgCompression == MessageCompressionZstd {
+		msgCompression = MessageCompressionZstd
+	}
 	hdr := &bep.Header{
 		Type:        typeOf(msg),
-		Compression: bep.MessageCompression_MESSAGE_COMPRESSION_LZ4,
+		Compression: msgCompression,
 	}
 	hdrSize := proto.Size(hdr)
 	if hdrSize > 1<<16-1 {
@@ -834,7 +855,12 @@ func (c *rawConnection) writeCompressedMessage(msg proto.Message, marshaled []by
 	buf := BufferPool.Get(maxCompressed)
 	defer BufferPool.Put(buf)
 
-	compressedSize, err := lz4Compress(marshaled, buf[cOverhead:])
+	var compressedSize int
+	if msgCompression == MessageCompressionZstd {
+		compressedSize, err = zstdCompress(marshaled, buf[cOverhead:], c.compressionLevel)
+	} else {
+		compressedSize, err = lz4Compress(marshaled, buf[cOverhead:])
+	}
 	totSize := compressedSize + cOverhead
 	if err != nil {
 		return false, nil
@@ -35,10 +35,10 @@ func TestPing(t *testing.T) {
 	ar, aw := io.Pipe()
 	br, bw := io.Pipe()
 
-	c0 := getRawConnection(NewConnection(c0ID, ar, bw, testutil.NoopCloser{}, newTestModel(), new(mockedConnectionInfo), CompressionAlways, nil, testKeyGen))
+	c0 := getRawConnection(NewConnection(c0ID, ar, bw, testutil.NoopCloser{}, newTestModel(), new(mockedConnectionInfo), CompressionAlways, MessageCompressionLZ4, 0, nil, testKeyGen))
 	c0.Start()
 	defer closeAndWait(c0, ar, bw)
-	c1 := getRawConnection(NewConnection(c1ID, br, aw, testutil.NoopCloser{}, newTestModel(), new(mockedConnectionInfo), CompressionAlways, nil, testKeyGen))
+	c1 := getRawConnection(NewConnection(c1ID, br, aw, testutil.NoopCloser{}, newTestModel(), new(mockedConnectionInfo), CompressionAlways, MessageCompressionLZ4, 0, nil, testKeyGen))
 	c1.Start()
 	defer closeAndWait(c1, ar, bw)
 	c0.ClusterConfig(&ClusterConfig{})
@@ -61,10 +61,10 @@ func TestClose(t *testing.T) {
 	ar, aw := io.Pipe()
 	br, bw := io.Pipe()
 
-	c0 := getRawConnection(NewConnection(c0ID, ar, bw, testutil.NoopCloser{}, m0, new(mockedConnectionInfo), CompressionAlways, nil, testKeyGen))
+	c0 := getRawConnection(NewConnection(c0ID, ar, bw, testutil.NoopCloser{}, m0, new(mockedConnectionInfo), CompressionAlways, MessageCompressionLZ4, 0, nil, testKeyGen))
 	c0.Start()
 	defer closeAndWait(c0, ar, bw)
-	c1 := NewConnection(c1ID, br, aw, testutil.NoopCloser{}, m1, new(mockedConnectionInfo), CompressionAlways, nil, testKeyGen)
+	c1 := NewConnection(c1ID, br, aw, testutil.NoopCloser{}, m1, new(mockedConnectionInfo), CompressionAlways, MessageCompressionLZ4, 0, nil, testKeyGen)
 	c1.Start()
 	defer closeAndWait(c1, ar, bw)
 	c0.ClusterConfig(&ClusterConfig{})
@@ -106,7 +106,7 @@ func TestCloseOnBlockingSend(t *testing.T) {
 	m := newTestModel()
 
 	rw := testutil.NewBlockingRW()
-	c := getRawConnection(NewConnection(c0ID, rw, rw, testutil.NoopCloser{}, m, new(mockedConnectionInfo), CompressionAlways, nil, testKeyGen))
+	c := getRawConnection(NewConnection(c0ID, rw, rw, testutil.NoopCloser{}, m, new(mockedConnectionInfo), CompressionAlways, MessageCompressionLZ4, 0, nil, testKeyGen))
 	c.Start()
 	defer closeAndWait(c, rw)
 
@@ -157,10 +157,10 @@ func TestCloseRace(t *testing.T) {
 	ar, aw := io.Pipe()
 	br, bw := io.Pipe()
 
-	c0 := getRawConnection(NewConnection(c0ID, ar, bw, testutil.NoopCloser{}, m0, new(mockedConnectionInfo), CompressionNever, nil, testKeyGen))
+	c0 := getRawConnection(NewConnection(c0ID, ar, bw, testutil.NoopCloser{}, m0, new(mockedConnectionInfo), CompressionNever, MessageCompressionLZ4, 0, nil, testKeyGen))
 	c0.Start()
 	defer closeAndWait(c0, ar, bw)
-	c1 := NewConnection(c1ID, br, aw, testutil.NoopCloser{}, m1, new(mockedConnectionInfo), CompressionNever, nil, testKeyGen)
+	c1 := NewConnection(c1ID, br, aw, testutil.NoopCloser{}, m1, new(mockedConnectionInfo), CompressionNever, MessageCompressionLZ4, 0, nil, testKeyGen)
 	c1.Start()
 	defer closeAndWait(c1, ar, bw)
 	c0.ClusterConfig(&ClusterConfig{})
@@ -197,7 +197,7 @@ func TestClusterConfigFirst(t *testing.T) {
 	m := newTestModel()
 
 	rw := testutil.NewBlockingRW()
-	c := getRawConnection(NewConnection(c0ID, rw, &testutil.NoopRW{}, testutil.NoopCloser{}, m, new(mockedConnectionInfo), CompressionAlways, nil, testKeyGen))
+	c := getRawConnection(NewConnection(c0ID, rw, &testutil.NoopRW{}, testutil.NoopCloser{}, m, new(mockedConnectionInfo), CompressionAlways, MessageCompressionLZ4, 0, nil, testKeyGen))
 	c.Start()
 	defer closeAndWait(c, rw)
 
@@ -249,7 +249,7 @@ func TestCloseTimeout(t *testing.T) {
 	m := newTestModel()
 
 	rw := testutil.NewBlockingRW()
-	c := getRawConnection(NewConnection(c0ID, rw, rw, testutil.NoopCloser{}, m, new(mockedConnectionInfo), CompressionAlways, nil, testKeyGen))
+	c := getRawConnection(NewConnection(c0ID, rw, rw, testutil.NoopCloser{}, m, new(mockedConnectionInfo), CompressionAlways, MessageCompressionLZ4, 0, nil, testKeyGen))
 	c.Start()
 	defer closeAndWait(c, rw)
 
@@ -303,37 +303,46 @@ func testMarshal(t *testing.T, prefix string, m1, m2 proto.Message) bool {
 }
 
 func TestWriteCompressed(t *testing.T) {
-	for _, random := range []bool{false, true} {
-		buf := new(bytes.Buffer)
-		c := &rawConnection{
-			cr:          &countingReader{Reader: buf},
-			cw:          &countingWriter{Writer: buf},
-			compression: CompressionAlways,
+	for _, msgCompression := range []MessageCompression{MessageCompressionLZ4, MessageCompressionZstd} {
+		for _, random := range []bool{false, true} {
+			testWriteCompressed(t, msgCompression, random)
 		}
+	}
+}
 
-		msg := (&Response{Data: make([]byte, 10240)}).toWire()
-		if random {
-			// This should make the message uncompressible.
-			rand.Read(msg.Data)
-		}
+func testWriteCompressed(t *testing.T, msgCompression MessageCompression, random bool) {
+	t.Helper()
 
-		if err := c.writeMessage(msg); err != nil {
-			t.Fatal(err)
-		}
-		got, err := c.readMessage(make([]byte, 4))
-		if err != nil {
-			t.Fatal(err)
-		}
-		if !bytes.Equal(got.(*bep.Response).Data, msg.Data) {
-			t.Error("received the wrong message")
-		}
+	buf := new(bytes.Buffer)
+	c := &rawConnection{
+		cr:             &countingReader{Reader: buf},
+		cw:             &countingWriter{Writer: buf},
+		compression:    CompressionAlways,
+		msgCompression: msgCompression,
+	}
 
-		hdr := &bep.Header{Type: typeOf(msg)}
-		size := int64(2 + proto.Size(hdr) + 4 + proto.Size(msg))
-		if c.cr.Tot() > size {
-			t.Errorf("compression enlarged message from %d to %d",
-				size, c.cr.Tot())
-		}
+	msg := (&Response{Data: make([]byte, 10240)}).toWire()
+	if random {
+		// This should make the message uncompressible.
+		rand.Read(msg.Data)
+	}
+
+	if err := c.writeMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.readMessage(make([]byte, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.(*bep.Response).Data, msg.Data) {
+		t.Error("received the wrong message")
+	}
+
+	hdr := &bep.Header{Type: typeOf(msg)}
+	size := int64(2 + proto.Size(hdr) + 4 + proto.Size(msg))
+	if c.cr.Tot() > size {
+		t.Errorf("compression enlarged message from %d to %d",
+			size, c.cr.Tot())
 	}
 }
 
@@ -398,6 +407,36 @@ func TestLZ4CompressionUpdate(t *testing.T) {
 	}
 }
 
+func TestZstdCompression(t *testing.T) {
+	data := bytes.Repeat([]byte("this is some arbitrary yet fairly compressible data"), 100)
+
+	for _, level := range []int{0, 1, 19} {
+		buf := make([]byte, len(data))
+		n, err := zstdCompress(data, buf, level)
+		if err != nil {
+			t.Fatalf("compressing at level %d: %v", level, err)
+		}
+		if n >= len(data) {
+			t.Errorf("level %d: compressed size %d not smaller than %d", level, n, len(data))
+		}
+
+		res, err := zstdDecompress(buf[:n])
+		if err != nil {
+			t.Fatalf("decompressing at level %d: %v", level, err)
+		}
+		if !bytes.Equal(data, res) {
+			t.Errorf("level %d: incorrect decompressed data", level)
+		}
+	}
+
+	// Incompressible data doesn't fit in a buffer of the same size.
+	random := make([]byte, 1024)
+	rand.Read(random)
+	if _, err := zstdCompress(random, make([]byte, len(random)), 0); err == nil {
+		t.Error("expected random data not to be compressible")
+	}
+}
+
 func TestCheckFilename(t *testing.T) {
 	cases := []struct {
 		name string
@@ -531,7 +570,7 @@ func TestClusterConfigAfterClose(t *testing.T) {
 	m := newTestModel()
 
 	rw := testutil.NewBlockingRW()
-	c := getRawConnection(NewConnection(c0ID, rw, rw, testutil.NoopCloser{}, m, new(mockedConnectionInfo), CompressionAlways, nil, testKeyGen))
+	c := getRawConnection(NewConnection(c0ID, rw, rw, testutil.NoopCloser{}, m, new(mockedConnectionInfo), CompressionAlways, MessageCompressionLZ4, 0, nil, testKeyGen))
 	c.Start()
 	defer closeAndWait(c, rw)
 
@@ -555,7 +594,7 @@ func TestDispatcherToCloseDeadlock(t *testing.T) {
 	// the model callbacks (ClusterConfig).
 	m := newTestModel()
 	rw := testutil.NewBlockingRW()
-	c := getRawConnection(NewConnection(c0ID, rw, &testutil.NoopRW{}, testutil.NoopCloser{}, m, new(mockedConnectionInfo), CompressionAlways, nil, testKeyGen))
+	c := getRawConnection(NewConnection(c0ID, rw, &testutil.NoopRW{}, testutil.NoopCloser{}, m, new(mockedConnectionInfo), CompressionAlways, MessageCompressionLZ4, 0, nil, testKeyGen))
 	m.ccFn = func(*ClusterConfig) {
 		c.Close(errManual)
 	}
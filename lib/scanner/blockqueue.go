@@ -9,6 +9,7 @@ package scanner
 import (
 	"context"
 	"errors"
+	"io"
 
 	"github.com/syncthing/syncthing/lib/fs"
 	"github.com/syncthing/syncthing/lib/protocol"
@@ -16,7 +17,21 @@ import (
 )
 
 // HashFile hashes the files and returns a list of blocks representing the file.
-func HashFile(ctx context.Context, folderID string, fs fs.Filesystem, path string, blockSize int, counter Counter, useWeakHashes bool) ([]protocol.BlockInfo, error) {
+func HashFile(ctx context.Context, folderID string, fs fs.Filesystem, path string, algo protocol.HashAlgorithm, blockSize int, counter Counter, useWeakHashes bool) ([]protocol.BlockInfo, error) {
+	return hashFile(folderID, fs, path, func(r io.Reader, size int64) ([]protocol.BlockInfo, error) {
+		return BlocksWithAlgorithm(ctx, r, algo, blockSize, size, counter, useWeakHashes)
+	})
+}
+
+// HashFileChunked is like HashFile, but splits the file into content
+// defined blocks of the given average size.
+func HashFileChunked(ctx context.Context, folderID string, fs fs.Filesystem, path string, algo protocol.HashAlgorithm, avgSize int, counter Counter) ([]protocol.BlockInfo, error) {
+	return hashFile(folderID, fs, path, func(r io.Reader, size int64) ([]protocol.BlockInfo, error) {
+		return ChunkedBlocks(ctx, r, algo, avgSize, size, counter)
+	})
+}
+
+func hashFile(folderID string, fs fs.Filesystem, path string, blocksFn func(r io.Reader, size int64) ([]protocol.BlockInfo, error)) ([]protocol.BlockInfo, error) {
 	fd, err := fs.Open(path)
 	if err != nil {
 		l.Debugln("open:", err)
@@ -36,7 +51,7 @@ func HashFile(ctx context.Context, folderID string, fs fs.Filesystem, path strin
 
 	// Hash the file. This may take a while for large files.
 
-	blocks, err := Blocks(ctx, fd, blockSize, size, counter, useWeakHashes)
+	blocks, err := blocksFn(fd, size)
 	if err != nil {
 		l.Debugln("blocks:", err)
 		return nil, err
@@ -70,10 +85,12 @@ type parallelHasher struct {
 	inbox    <-chan protocol.FileInfo
 	counter  Counter
 	done     chan<- struct{}
+	chunked  bool
+	algo     protocol.HashAlgorithm
 	wg       sync.WaitGroup
 }
 
-func newParallelHasher(ctx context.Context, folderID string, fs fs.Filesystem, workers int, outbox chan<- ScanResult, inbox <-chan protocol.FileInfo, counter Counter, done chan<- struct{}) {
+func newParallelHasher(ctx context.Context, folderID string, fs fs.Filesystem, workers int, outbox chan<- ScanResult, inbox <-chan protocol.FileInfo, counter Counter, done chan<- struct{}, chunked bool, algo protocol.HashAlgorithm) {
 	ph := &parallelHasher{
 		folderID: folderID,
 		fs:       fs,
@@ -81,6 +98,8 @@ func newParallelHasher(ctx context.Context, folderID string, fs fs.Filesystem, w
 		inbox:    inbox,
 		counter:  counter,
 		done:     done,
+		chunked:  chunked,
+		algo:     algo,
 		wg:       sync.NewWaitGroup(),
 	}
 
@@ -108,7 +127,13 @@ func (ph *parallelHasher) hashFiles(ctx context.Context) {
 				panic("Bug. Asked to hash a directory or a deleted file.")
 			}
 
-			blocks, err := HashFile(ctx, ph.folderID, ph.fs, f.Name, f.BlockSize(), ph.counter, true)
+			var blocks []protocol.BlockInfo
+			var err error
+			if ph.chunked {
+				blocks, err = HashFileChunked(ctx, ph.folderID, ph.fs, f.Name, ph.algo, f.BlockSize(), ph.counter)
+			} else {
+				blocks, err = HashFile(ctx, ph.folderID, ph.fs, f.Name, ph.algo, f.BlockSize(), ph.counter, true)
+			}
 			if err != nil {
 				handleError(ctx, "hashing", f.Name, err, ph.outbox)
 				continue
@@ -116,6 +141,7 @@ func (ph *parallelHasher) hashFiles(ctx context.Context) {
 
 			f.Blocks = blocks
 			f.BlocksHash = protocol.BlocksHash(blocks)
+			f.BlockHashAlgorithm = ph.algo
 
 			// The size we saw when initially deciding to hash the file
 			// might not have been the size it actually had when we hashed
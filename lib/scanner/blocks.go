@@ -9,7 +9,6 @@ package scanner
 import (
 	"bytes"
 	"context"
-	"crypto/sha256"
 	"hash"
 	"hash/adler32"
 	"io"
@@ -23,14 +22,20 @@ type Counter interface {
 	Update(bytes int64)
 }
 
-// Blocks returns the blockwise hash of the reader.
+// Blocks returns the blockwise SHA-256 hash of the reader.
 func Blocks(ctx context.Context, r io.Reader, blocksize int, sizehint int64, counter Counter, useWeakHashes bool) ([]protocol.BlockInfo, error) {
+	return BlocksWithAlgorithm(ctx, r, protocol.HashAlgorithmSHA256, blocksize, sizehint, counter, useWeakHashes)
+}
+
+// BlocksWithAlgorithm returns the blockwise hash of the reader, using the
+// given hash algorithm.
+func BlocksWithAlgorithm(ctx context.Context, r io.Reader, algo protocol.HashAlgorithm, blocksize int, sizehint int64, counter Counter, useWeakHashes bool) ([]protocol.BlockInfo, error) {
 	if counter == nil {
 		counter = &noopCounter{}
 	}
 
-	hf := sha256.New()
-	const hashLength = sha256.Size
+	hf := protocol.NewBlockHash(algo)
+	hashLength := hf.Size()
 
 	var weakHf hash.Hash32 = noopHash{}
 	var multiHf io.Writer = hf
@@ -54,7 +59,7 @@ func Blocks(ctx context.Context, r io.Reader, blocksize int, sizehint int64, cou
 			numBlocks++
 		}
 		blocks = make([]protocol.BlockInfo, 0, numBlocks)
-		hashes = make([]byte, 0, hashLength*numBlocks)
+		hashes = make([]byte, 0, int64(hashLength)*numBlocks)
 	}
 
 	// A 32k buffer is used for copying into the hash function.
@@ -105,24 +110,31 @@ func Blocks(ctx context.Context, r io.Reader, blocksize int, sizehint int64, cou
 		blocks = append(blocks, protocol.BlockInfo{
 			Offset: 0,
 			Size:   0,
-			Hash:   SHA256OfNothing,
+			Hash:   hashOfNothing(algo),
 		})
 	}
 
 	return blocks, nil
 }
 
+func hashOfNothing(algo protocol.HashAlgorithm) []byte {
+	if algo == protocol.HashAlgorithmSHA256 {
+		return SHA256OfNothing
+	}
+	return protocol.SumBlock(algo, nil)
+}
+
 // Validate quickly validates buf against the 32-bit weakHash, if not zero,
-// else against the cryptohash hash, if len(hash)>0. It is satisfied if
-// either hash matches or neither hash is given.
-func Validate(buf, hash []byte, weakHash uint32) bool {
+// else against the cryptohash hash using the given algorithm, if
+// len(hash)>0. It is satisfied if either hash matches or neither hash is
+// given.
+func Validate(buf, hash []byte, weakHash uint32, algo protocol.HashAlgorithm) bool {
 	if weakHash != 0 && adler32.Checksum(buf) == weakHash {
 		return true
 	}
 
 	if len(hash) > 0 {
-		hbuf := sha256.Sum256(buf)
-		return bytes.Equal(hbuf[:], hash)
+		return bytes.Equal(protocol.SumBlock(algo, buf), hash)
 	}
 
 	return true
@@ -129,6 +129,34 @@ func TestBlocks(t *testing.T) {
 	}
 }
 
+func TestBlocksBLAKE2b(t *testing.T) {
+	blocks, err := BlocksWithAlgorithm(context.TODO(), bytes.NewReader(nil), protocol.HashAlgorithmBLAKE2b, 3, -1, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := fmt.Sprintf("%x", blocks[0].Hash); len(blocks) != 1 || h != "0e5751c026e543b2e8ab2eb06099daa1d1e5df47778f7787faab45cdf12fe3a8" {
+		t.Errorf("Incorrect hash of nothing %v", blocks)
+	}
+
+	data := []byte("contents")
+	blocks, err = BlocksWithAlgorithm(context.TODO(), bytes.NewReader(data), protocol.HashAlgorithmBLAKE2b, 3, -1, nil, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 3 {
+		t.Fatalf("Incorrect number of blocks %d != 3", len(blocks))
+	}
+	for i, b := range blocks {
+		buf := data[b.Offset : b.Offset+int64(b.Size)]
+		if !Validate(buf, b.Hash, 0, protocol.HashAlgorithmBLAKE2b) {
+			t.Errorf("%d: Block doesn't validate", i)
+		}
+		if Validate(buf, b.Hash, 0, protocol.HashAlgorithmSHA256) {
+			t.Errorf("%d: Block validates using the wrong algorithm", i)
+		}
+	}
+}
+
 func TestAdler32Variants(t *testing.T) {
 	// Verify that the two adler32 functions give matching results for a few
 	// different blocks of data.
@@ -148,7 +176,7 @@ func TestAdler32Variants(t *testing.T) {
 
 		// Make sure whatever we use in Validate matches too resp. this
 		// tests gets adjusted if we ever switch the weak hash algo.
-		return sum1 == sum2 && Validate(data, nil, sum1)
+		return sum1 == sum2 && Validate(data, nil, sum1, protocol.HashAlgorithmSHA256)
 	}
 
 	// protocol block sized data
@@ -195,7 +223,7 @@ func TestAdler32Variants(t *testing.T) {
 				t.Errorf("Mismatch after roll; i=%d, sum1=%08x, sum3=%08x", i, sum1, sum3)
 				break
 			}
-			if !Validate(window, nil, sum1) {
+			if !Validate(window, nil, sum1, protocol.HashAlgorithmSHA256) {
 				t.Errorf("Validation failure after roll; i=%d", i)
 			}
 		}
@@ -238,7 +266,7 @@ func BenchmarkValidate(b *testing.B) {
 
 	for i := 0; i < b.N; i++ {
 		for _, b := range blocks {
-			Validate(b.data, b.hash[:], b.weakhash)
+			Validate(b.data, b.hash[:], b.weakhash, protocol.HashAlgorithmSHA256)
 		}
 	}
 }
@@ -0,0 +1,148 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package scanner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+// Content defined chunking splits a file at positions determined by the
+// data itself instead of at fixed offsets, such that inserting or removing
+// data only changes the blocks around the modification. The boundaries are
+// found using a gear hash, which effectively rolls over the last 64 bytes,
+// as in FastCDC: A block ends where the masked bits of the hash are all
+// zero. A stricter mask is used before the average block size is reached
+// and a looser one afterwards, which narrows the distribution of block
+// sizes around the average.
+
+// gearTable holds the values for the gear hash. The block boundaries must be
+// the same on all devices, hence it's derived deterministically.
+var gearTable = func() (table [256]uint64) {
+	for i := range table {
+		sum := sha256.Sum256([]byte{'g', 'e', 'a', 'r', byte(i)})
+		table[i] = binary.BigEndian.Uint64(sum[:])
+	}
+	return table
+}()
+
+type chunker struct {
+	minSize, avgSize, maxSize int
+	maskS, maskL              uint64
+}
+
+// newChunker returns a chunker for blocks of the given average size, which
+// must be a power of two.
+func newChunker(avgSize int) chunker {
+	bits := bits.Len(uint(avgSize)) - 1
+	return chunker{
+		minSize: avgSize / 4,
+		avgSize: avgSize,
+		maxSize: min(4*avgSize, protocol.MaxBlockSize),
+		// The masks use the high bits of the hash, as those depend on the
+		// most bytes.
+		maskS: ^uint64(0) << (64 - bits - 2),
+		maskL: ^uint64(0) << (64 - bits + 2),
+	}
+}
+
+// next returns the size of the block at the start of data, which must hold
+// at least maxSize bytes unless at the end of the input.
+func (c chunker) next(data []byte) int {
+	n := len(data)
+	if n <= c.minSize {
+		return n
+	}
+	n = min(n, c.maxSize)
+	normal := min(n, c.avgSize)
+
+	var hash uint64
+	i := c.minSize
+	for ; i < normal; i++ {
+		hash = hash<<1 + gearTable[data[i]]
+		if hash&c.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < n; i++ {
+		hash = hash<<1 + gearTable[data[i]]
+		if hash&c.maskL == 0 {
+			return i + 1
+		}
+	}
+	return n
+}
+
+// ChunkedBlocks returns the hashes of the reader's content defined blocks,
+// whose size averages avgSize, using the given hash algorithm. No weak
+// hashes are computed, as they rely on a fixed block size.
+func ChunkedBlocks(ctx context.Context, r io.Reader, algo protocol.HashAlgorithm, avgSize int, sizehint int64, counter Counter) ([]protocol.BlockInfo, error) {
+	if counter == nil {
+		counter = &noopCounter{}
+	}
+	if sizehint >= 0 {
+		r = io.LimitReader(r, sizehint)
+	}
+
+	c := newChunker(avgSize)
+	buf := make([]byte, 2*c.maxSize)
+	var blocks []protocol.BlockInfo
+	var offset int64
+	start, end := 0, 0
+	eof := false
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		// Make sure there is a full maximum sized block in the buffer,
+		// unless we reached the end of the file.
+		if !eof && end-start < c.maxSize {
+			end = copy(buf, buf[start:end])
+			start = 0
+			n, err := io.ReadFull(r, buf[end:])
+			end += n
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				eof = true
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		if start == end {
+			break
+		}
+
+		size := c.next(buf[start:end])
+		blocks = append(blocks, protocol.BlockInfo{
+			Offset: offset,
+			Size:   size,
+			Hash:   protocol.SumBlock(algo, buf[start:start+size]),
+		})
+		counter.Update(int64(size))
+		offset += int64(size)
+		start += size
+	}
+
+	if len(blocks) == 0 {
+		// Empty file
+		blocks = append(blocks, protocol.BlockInfo{
+			Offset: 0,
+			Size:   0,
+			Hash:   hashOfNothing(algo),
+		})
+	}
+
+	return blocks, nil
+}
@@ -0,0 +1,80 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"math/rand"
+	"testing"
+
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestChunkedBlocks(t *testing.T) {
+	const avgSize = protocol.MinBlockSize
+	data := make([]byte, 64*avgSize)
+	rand.New(rand.NewSource(42)).Read(data)
+
+	blocks, err := ChunkedBlocks(context.Background(), bytes.NewReader(data), protocol.HashAlgorithmSHA256, avgSize, int64(len(data)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := newChunker(avgSize)
+	var offset int64
+	for i, b := range blocks {
+		if b.Offset != offset {
+			t.Fatalf("block %d at offset %d, expected %d", i, b.Offset, offset)
+		}
+		if b.Size > c.maxSize || b.Size < c.minSize && i != len(blocks)-1 {
+			t.Errorf("block %d has out of bounds size %d", i, b.Size)
+		}
+		hash := sha256.Sum256(data[offset : offset+int64(b.Size)])
+		if !bytes.Equal(b.Hash, hash[:]) {
+			t.Errorf("block %d has incorrect hash", i)
+		}
+		offset += int64(b.Size)
+	}
+	if offset != int64(len(data)) {
+		t.Errorf("blocks cover %d bytes, expected %d", offset, len(data))
+	}
+	if n := len(blocks); n < 32 || n > 128 {
+		t.Errorf("unexpected number of blocks %d for average size", n)
+	}
+
+	// Inserting data at the start only changes the blocks around it.
+	modified := append([]byte("some inserted data"), data...)
+	modBlocks, err := ChunkedBlocks(context.Background(), bytes.NewReader(modified), protocol.HashAlgorithmSHA256, avgSize, int64(len(modified)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashes := make(map[string]struct{})
+	for _, b := range blocks {
+		hashes[string(b.Hash)] = struct{}{}
+	}
+	changed := 0
+	for _, b := range modBlocks {
+		if _, ok := hashes[string(b.Hash)]; !ok {
+			changed++
+		}
+	}
+	if changed > 2 {
+		t.Errorf("insertion changed %d of %d blocks", changed, len(modBlocks))
+	}
+}
+
+func TestChunkedBlocksEmpty(t *testing.T) {
+	blocks, err := ChunkedBlocks(context.Background(), bytes.NewReader(nil), protocol.HashAlgorithmSHA256, protocol.MinBlockSize, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 1 || blocks[0].Size != 0 || !bytes.Equal(blocks[0].Hash, SHA256OfNothing) {
+		t.Errorf("unexpected blocks for empty input: %v", blocks)
+	}
+}
@@ -65,6 +65,15 @@ type Config struct {
 	ScanXattrs bool
 	// Filter for extended attributes
 	XattrFilter XattrFilter
+	// If ContentDefinedChunking is true, files are split into blocks of
+	// varying size based on their content.
+	ContentDefinedChunking bool
+	// The algorithm to hash blocks with
+	HashAlgorithm protocol.HashAlgorithm
+	// If RehashOtherAlgorithms is true, unchanged files that were hashed
+	// using another algorithm than HashAlgorithm are rehashed. They keep
+	// their version, as the contents are the same.
+	RehashOtherAlgorithms bool
 }
 
 type CurrentFiler interface {
@@ -134,7 +143,7 @@ func (w *walker) walk(ctx context.Context) chan ScanResult {
 	// We're not required to emit scan progress events, just kick off hashers,
 	// and feed inputs directly from the walker.
 	if w.ProgressTickIntervalS < 0 {
-		newParallelHasher(ctx, w.Folder, w.Filesystem, w.Hashers, finishedChan, toHashChan, nil, nil)
+		newParallelHasher(ctx, w.Folder, w.Filesystem, w.Hashers, finishedChan, toHashChan, nil, nil, w.ContentDefinedChunking, w.HashAlgorithm)
 		return finishedChan
 	}
 
@@ -168,7 +177,7 @@ func (w *walker) walk(ctx context.Context) chan ScanResult {
 		done := make(chan struct{})
 		progress := newByteCounter()
 
-		newParallelHasher(ctx, w.Folder, w.Filesystem, w.Hashers, finishedChan, realToHashChan, progress, done)
+		newParallelHasher(ctx, w.Folder, w.Filesystem, w.Hashers, finishedChan, realToHashChan, progress, done, w.ContentDefinedChunking, w.HashAlgorithm)
 
 		// A routine which actually emits the FolderScanProgress events
 		// every w.ProgressTicker ticks, until the hasher routines terminate.
@@ -457,18 +466,26 @@ func (w *walker) walkRegular(ctx context.Context, relPath string, info fs.FileIn
 	l.Debugln(w, "checking:", f)
 
 	if hasCurFile {
-		if curFile.IsEquivalentOptional(f, protocol.FileInfoComparison{
+		unchanged := curFile.IsEquivalentOptional(f, protocol.FileInfoComparison{
 			ModTimeWindow:   w.ModTimeWindow,
 			IgnorePerms:     w.IgnorePerms,
 			IgnoreBlocks:    true,
 			IgnoreFlags:     w.LocalFlags,
 			IgnoreOwnership: !w.ScanOwnership,
 			IgnoreXattrs:    !w.ScanXattrs,
-		}) {
+		})
+		switch {
+		case unchanged && (!w.RehashOtherAlgorithms || curFile.BlockHashAlgorithm == w.HashAlgorithm):
 			l.Debugln(w, "unchanged:", curFile)
 			return nil
-		}
-		if curFile.ShouldConflict() && !f.ShouldConflict() {
+		case unchanged:
+			// Every device rehashes its copy the same way, so this isn't
+			// a new version of the file. Bumping the version would make
+			// the devices' versions conflict with each other.
+			f.Version = curFile.Version
+			f.ModifiedBy = curFile.ModifiedBy
+			f.LocalFlags = curFile.LocalFlags
+		case curFile.ShouldConflict() && !f.ShouldConflict():
 			// The old file was invalid for whatever reason and probably not
 			// up to date with what was out there in the cluster. Drop all
 			// others from the version vector to indicate that we haven't
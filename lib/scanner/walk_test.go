@@ -493,6 +493,61 @@ func TestWalkReceiveOnly(t *testing.T) {
 	}
 }
 
+func TestWalkRehashOtherAlgorithms(t *testing.T) {
+	sf := fs.NewWalkFilesystem(&singleFileFS{
+		name:     "testfile.dat",
+		filesize: 1024,
+	})
+
+	walk := func(current fakeCurrentFiler, rehash bool) []protocol.FileInfo {
+		t.Helper()
+		cfg, cancel := testConfig()
+		defer cancel()
+		cfg.Filesystem = sf
+		cfg.CurrentFiler = current
+		cfg.ShortID = protocol.LocalDeviceID.Short()
+		cfg.HashAlgorithm = protocol.HashAlgorithmSHA256
+		cfg.RehashOtherAlgorithms = rehash
+		var files []protocol.FileInfo
+		for f := range Walk(context.TODO(), cfg) {
+			if f.Err != nil {
+				t.Fatal(f.Err)
+			}
+			files = append(files, f.File)
+		}
+		return files
+	}
+
+	files := walk(make(fakeCurrentFiler), false)
+	if len(files) != 1 {
+		t.Fatal("Should have scanned one file")
+	}
+
+	// The file was last changed by another device and hashed with another
+	// algorithm.
+	cur := files[0]
+	cur.BlockHashAlgorithm = protocol.HashAlgorithmBLAKE2b
+	cur.Version = protocol.Vector{}.Update(42)
+	cur.ModifiedBy = 42
+	current := fakeCurrentFiler{cur.Name: cur}
+
+	if files := walk(current, false); len(files) != 0 {
+		t.Fatal("Should not have rehashed without RehashOtherAlgorithms")
+	}
+
+	// Rehashing doesn't change the file, so it keeps its version.
+	files = walk(current, true)
+	if len(files) != 1 {
+		t.Fatal("Should have rehashed the file")
+	}
+	if files[0].BlockHashAlgorithm != protocol.HashAlgorithmSHA256 {
+		t.Error("Unexpected block hash algorithm", files[0].BlockHashAlgorithm)
+	}
+	if !files[0].Version.Equal(cur.Version) || files[0].ModifiedBy != cur.ModifiedBy {
+		t.Errorf("Rehashing changed the version to %v by %v, expected %v by %v", files[0].Version, files[0].ModifiedBy, cur.Version, cur.ModifiedBy)
+	}
+}
+
 func TestScanOwnershipPOSIX(t *testing.T) {
 	// This test works on all operating systems because the FakeFS is always POSIXy.
 
@@ -640,7 +695,7 @@ func BenchmarkHashFile(b *testing.B) {
 	b.ResetTimer()
 
 	for i := 0; i < b.N; i++ {
-		if _, err := HashFile(context.TODO(), "", testFs, testdataName, protocol.MinBlockSize, nil, true); err != nil {
+		if _, err := HashFile(context.TODO(), "", testFs, testdataName, protocol.HashAlgorithmSHA256, protocol.MinBlockSize, nil, true); err != nil {
 			b.Fatal(err)
 		}
 	}
@@ -13,8 +13,9 @@ import (
 )
 
 type FolderStatistics struct {
-	LastFile LastFile  `json:"lastFile"`
-	LastScan time.Time `json:"lastScan"`
+	LastFile  LastFile  `json:"lastFile"`
+	LastScan  time.Time `json:"lastScan"`
+	LastScrub time.Time `json:"lastScrub"`
 }
 
 type FolderStatisticsReference struct {
@@ -87,6 +88,20 @@ func (s *FolderStatisticsReference) GetLastScanTime() (time.Time, error) {
 	return lastScan, nil
 }
 
+func (s *FolderStatisticsReference) ScrubCompleted() error {
+	return s.ns.PutTime("lastScrub", time.Now().Truncate(time.Second))
+}
+
+func (s *FolderStatisticsReference) GetLastScrubTime() (time.Time, error) {
+	lastScrub, ok, err := s.ns.Time("lastScrub")
+	if err != nil {
+		return time.Time{}, err
+	} else if !ok {
+		return time.Time{}, nil
+	}
+	return lastScrub, nil
+}
+
 func (s *FolderStatisticsReference) GetStatistics() (FolderStatistics, error) {
 	lastFile, err := s.GetLastFile()
 	if err != nil {
@@ -96,8 +111,13 @@ func (s *FolderStatisticsReference) GetStatistics() (FolderStatistics, error) {
 	if err != nil {
 		return FolderStatistics{}, err
 	}
+	lastScrubTime, err := s.GetLastScrubTime()
+	if err != nil {
+		return FolderStatistics{}, err
+	}
 	return FolderStatistics{
-		LastFile: lastFile,
-		LastScan: lastScanTime,
+		LastFile:  lastFile,
+		LastScan:  lastScanTime,
+		LastScrub: lastScrubTime,
 	}, nil
 }
@@ -11,6 +11,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/syncthing/syncthing/lib/events"
 )
@@ -19,19 +20,44 @@ import (
 // event per line, to the specified writer.
 type auditService struct {
 	w        io.Writer // audit destination
+	mask     events.EventType
 	evLogger events.Logger
 }
 
-func newAuditService(w io.Writer, evLogger events.Logger) *auditService {
+func newAuditService(w io.Writer, mask events.EventType, evLogger events.Logger) *auditService {
 	return &auditService{
 		w:        w,
+		mask:     mask,
 		evLogger: evLogger,
 	}
 }
 
+// auditEventMask returns the mask of the event types to audit, given as
+// by the auditEvents option: all of them if there are none, except those
+// prefixed with "-".
+func auditEventMask(types []string) events.EventType {
+	var include, exclude events.EventType
+	for _, name := range types {
+		excluded := strings.HasPrefix(name, "-")
+		t := events.UnmarshalEventType(strings.TrimPrefix(name, "-"))
+		switch {
+		case t == 0:
+			l.Warnf("Unknown event type %q in audit events", name)
+		case excluded:
+			exclude |= t
+		default:
+			include |= t
+		}
+	}
+	if include == 0 {
+		include = events.AllEvents
+	}
+	return include &^ exclude
+}
+
 // serve runs the audit service.
 func (s *auditService) Serve(ctx context.Context) error {
-	sub := s.evLogger.Subscribe(events.AllEvents)
+	sub := s.evLogger.Subscribe(s.mask)
 	defer sub.Unsubscribe()
 
 	enc := json.NewEncoder(s.w)
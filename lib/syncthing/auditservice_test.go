@@ -31,7 +31,7 @@ func TestAuditService(t *testing.T) {
 	<-sub.C()
 
 	auditCtx, auditCancel := context.WithCancel(context.Background())
-	service := newAuditService(buf, evLogger)
+	service := newAuditService(buf, events.AllEvents, evLogger)
 	done := make(chan struct{})
 	go func() {
 		service.Serve(auditCtx)
@@ -68,3 +68,20 @@ func TestAuditService(t *testing.T) {
 		t.Error("Missing third event")
 	}
 }
+
+func TestAuditEventMask(t *testing.T) {
+	cases := []struct {
+		types []string
+		mask  events.EventType
+	}{
+		{nil, events.AllEvents},
+		{[]string{"UserAction", "LoginAttempt"}, events.UserAction | events.LoginAttempt},
+		{[]string{"-ItemStarted", "-ItemFinished"}, events.AllEvents &^ events.ItemStarted &^ events.ItemFinished},
+		{[]string{"UserAction", "LoginAttempt", "-LoginAttempt", "NoSuchEvent"}, events.UserAction},
+	}
+	for _, tc := range cases {
+		if mask := auditEventMask(tc.types); mask != tc.mask {
+			t.Errorf("%v: expected mask %b, got %b", tc.types, tc.mask, mask)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package syncthing
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+const desiredStatePollInterval = 10 * time.Second
+
+// The desiredStateService reconciles the configuration towards the desired
+// state described in a file, whenever either of them changes. While the
+// file is missing or invalid, the last valid desired state is kept.
+type desiredStateService struct {
+	path     string
+	cfg      config.Wrapper
+	myID     protocol.DeviceID
+	evLogger events.Logger
+	changed  chan struct{}
+
+	// only accessed from Serve
+	contents []byte
+	readErr  string
+	state    *config.DesiredState
+}
+
+func newDesiredStateService(path string, cfg config.Wrapper, myID protocol.DeviceID, evLogger events.Logger) *desiredStateService {
+	return &desiredStateService{
+		path:     path,
+		cfg:      cfg,
+		myID:     myID,
+		evLogger: evLogger,
+		changed:  make(chan struct{}, 1),
+	}
+}
+
+func (s *desiredStateService) Serve(ctx context.Context) error {
+	s.cfg.Subscribe(s)
+	defer s.cfg.Unsubscribe(s)
+
+	ticker := time.NewTicker(desiredStatePollInterval)
+	defer ticker.Stop()
+
+	s.readFile()
+	s.reconcile()
+	for {
+		select {
+		case <-ticker.C:
+			if s.readFile() {
+				s.reconcile()
+			}
+		case <-s.changed:
+			s.reconcile()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// readFile reads the desired state file, returning true if it changed to a
+// new, valid desired state.
+func (s *desiredStateService) readFile() bool {
+	bs, err := os.ReadFile(s.path)
+	if err != nil {
+		s.setReadError(err)
+		return false
+	}
+	if s.contents != nil && bytes.Equal(bs, s.contents) {
+		return false
+	}
+	s.contents = bs
+
+	state, err := config.ParseDesiredState(bs)
+	if err != nil {
+		s.setReadError(err)
+		return false
+	}
+	s.readErr = ""
+	l.Infoln("Loaded desired state from", s.path)
+	s.state = &state
+	return true
+}
+
+// setReadError logs the error unless it's the same as the last one, to
+// avoid repeating it every poll interval.
+func (s *desiredStateService) setReadError(err error) {
+	if err.Error() == s.readErr {
+		return
+	}
+	s.readErr = err.Error()
+	l.Warnf("Reading desired state %s: %v", s.path, err)
+}
+
+func (s *desiredStateService) reconcile() {
+	if s.state == nil {
+		return
+	}
+
+	_, changes, err := s.state.Reconcile(s.cfg.RawCopy(), s.myID)
+	if err != nil {
+		l.Warnf("Applying desired state %s: %v", s.path, err)
+		return
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	var applyErr error
+	waiter, err := s.cfg.Modify(func(cfg *config.Configuration) {
+		applyErr = s.state.Apply(cfg, s.myID)
+	})
+	if err == nil {
+		err = applyErr
+	}
+	if err != nil {
+		l.Warnf("Applying desired state %s: %v", s.path, err)
+		return
+	}
+	waiter.Wait()
+
+	for _, c := range changes {
+		l.Infoln("Corrected configuration drift:", c)
+	}
+	s.evLogger.Log(events.ConfigDriftCorrected, map[string]interface{}{
+		"file":    s.path,
+		"changes": changes,
+	})
+}
+
+func (s *desiredStateService) CommitConfiguration(_, _ config.Configuration) bool {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+func (s *desiredStateService) String() string {
+	return fmt.Sprintf("desiredStateService@%p", s)
+}
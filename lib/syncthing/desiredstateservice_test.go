@@ -0,0 +1,54 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package syncthing
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestDesiredStateService(t *testing.T) {
+	myID := protocol.DeviceID{1, 2, 3}
+	cfg := config.Wrap(tempCfgFilename(t), config.New(myID), myID, events.NoopLogger)
+	defer os.Remove(cfg.ConfigPath())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cfg.Serve(ctx)
+
+	path := filepath.Join(t.TempDir(), "desired.yaml")
+	if err := os.WriteFile(path, []byte("options:\n  relaysEnabled: false\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	go newDesiredStateService(path, cfg, myID, events.NoopLogger).Serve(ctx)
+
+	waitForRelaysEnabled := func(enabled bool) {
+		t.Helper()
+		for start := time.Now(); cfg.Options().RelaysEnabled != enabled; time.Sleep(10 * time.Millisecond) {
+			if time.Since(start) > 10*time.Second {
+				t.Fatal("timed out")
+			}
+		}
+	}
+	waitForRelaysEnabled(false)
+
+	// Drift is corrected.
+	waiter, err := cfg.Modify(func(cfg *config.Configuration) {
+		cfg.Options.RelaysEnabled = true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waiter.Wait()
+	waitForRelaysEnabled(false)
+}
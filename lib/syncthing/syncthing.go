@@ -40,6 +40,7 @@ import (
 	"github.com/syncthing/syncthing/lib/tlsutil"
 	"github.com/syncthing/syncthing/lib/upgrade"
 	"github.com/syncthing/syncthing/lib/ur"
+	"github.com/syncthing/syncthing/lib/webhook"
 )
 
 const (
@@ -52,11 +53,12 @@ const (
 )
 
 type Options struct {
-	AuditWriter    io.Writer
-	NoUpgrade      bool
-	ProfilerAddr   string
-	ResetDeltaIdxs bool
-	Verbose        bool
+	AuditWriter      io.Writer
+	DesiredStateFile string
+	NoUpgrade        bool
+	ProfilerAddr     string
+	ResetDeltaIdxs   bool
+	Verbose          bool
 	// null duration means use default value
 	DBRecheckInterval    time.Duration
 	DBIndirectGCInterval time.Duration
@@ -127,7 +129,7 @@ func (a *App) startup() error {
 	a.mainService.Add(a.ll)
 
 	if a.opts.AuditWriter != nil {
-		a.mainService.Add(newAuditService(a.opts.AuditWriter, a.evLogger))
+		a.mainService.Add(newAuditService(a.opts.AuditWriter, auditEventMask(a.cfg.Options().AuditEvents), a.evLogger))
 	}
 
 	if a.opts.Verbose {
@@ -192,6 +194,7 @@ func (a *App) startup() error {
 
 	protectedFiles := []string{
 		locations.Get(locations.Database),
+		locations.Get(locations.SQLiteDB),
 		locations.Get(locations.ConfigFile),
 		locations.Get(locations.CertFile),
 		locations.Get(locations.KeyFile),
@@ -249,6 +252,12 @@ func (a *App) startup() error {
 
 	a.mainService.Add(m)
 
+	a.mainService.Add(webhook.New(a.cfg, a.ll, a.evLogger))
+
+	if a.opts.DesiredStateFile != "" {
+		a.mainService.Add(newDesiredStateService(a.opts.DesiredStateFile, a.cfg, a.myID, a.evLogger))
+	}
+
 	// The TLS configuration is used for both the listening socket and outgoing
 	// connections.
 
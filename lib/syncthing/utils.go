@@ -153,3 +153,7 @@ func copyFile(src, dst string) error {
 func OpenDBBackend(path string, tuning config.Tuning) (backend.Backend, error) {
 	return backend.Open(path, backend.Tuning(tuning))
 }
+
+func OpenSQLiteDBBackend(path string, tuning config.Tuning) (backend.Backend, error) {
+	return backend.OpenSQLite(path, backend.Tuning(tuning))
+}
@@ -52,6 +52,7 @@ type Report struct {
 		ExternalVersioning  int `json:"externalVersioning,omitempty" metric:"folder_feature{feature=VersioningExternal},summary" since:"2"`
 		StaggeredVersioning int `json:"staggeredVersioning,omitempty" metric:"folder_feature{feature=VersioningStaggered},summary" since:"2"`
 		TrashcanVersioning  int `json:"trashcanVersioning,omitempty" metric:"folder_feature{feature=VersioningTrashcan},summary" since:"2"`
+		DedupVersioning     int `json:"dedupVersioning,omitempty" metric:"folder_feature{feature=VersioningDedup},summary" since:"3"`
 	} `json:"folderUses,omitempty" since:"2"`
 
 	DeviceUses struct {
@@ -158,6 +158,8 @@ func (s *Service) reportData(ctx context.Context, urVersion int, preview bool) (
 			report.FolderUses.ExternalVersioning++
 		case "trashcan":
 			report.FolderUses.TrashcanVersioning++
+		case "dedup":
+			report.FolderUses.DedupVersioning++
 		default:
 			l.Warnf("Unhandled versioning type for usage reports: %s", cfg.Versioning.Type)
 		}
@@ -0,0 +1,461 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package versioner
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/fs"
+	"github.com/syncthing/syncthing/lib/osutil"
+	"github.com/syncthing/syncthing/lib/protocol"
+	"github.com/syncthing/syncthing/lib/scanner"
+)
+
+func init() {
+	// Register the constructor for this type of versioner with the name "dedup"
+	Register("dedup", newDedup)
+}
+
+const (
+	// Below the versions directory, blocks are stored by their hash in
+	// dedupBlocksDir and each version is a manifest file, named like the
+	// simple versioner would name the copy, in dedupVersionsDir.
+	dedupDir         = ".dedup"
+	dedupBlocksDir   = ".dedup/blocks"
+	dedupVersionsDir = ".dedup/versions"
+)
+
+var (
+	errBlockCorrupt = errors.New("stored block does not match its hash")
+	errFileChanged  = errors.New("file changed while being archived")
+)
+
+// The dedup versioner keeps versions like the simple versioner does, but
+// instead of storing a full copy of each version it splits the file into
+// the same blocks the scanner uses and stores each distinct block only
+// once. A version is a manifest listing the blocks in order. Files that
+// change a little at a time thus only cost the changed blocks per version.
+//
+// Archiving relies on blocks already stored before it writes the manifest
+// that uses them, and restoring reads the blocks of a manifest that may be
+// expired meanwhile, so garbage collection must not run at the same time
+// as either. Archiving and restoring hold mut for reading, garbage
+// collection for writing.
+type dedup struct {
+	simple     // for the version expiry policy
+	blocksFs   fs.Filesystem
+	manifestFs fs.Filesystem
+	mut        *sync.RWMutex
+}
+
+// dedupManifest describes one archived version of a file.
+type dedupManifest struct {
+	Size      int64     `json:"size"`
+	ModTime   time.Time `json:"modTime"`
+	Mode      uint32    `json:"mode"`
+	BlockSize int       `json:"blockSize"`
+	Blocks    []string  `json:"blocks"` // hex encoded SHA-256 hashes
+}
+
+func newDedup(cfg config.FolderConfiguration) Versioner {
+	keep, err := strconv.Atoi(cfg.Versioning.Params["keep"])
+	cleanoutDays, _ := strconv.Atoi(cfg.Versioning.Params["cleanoutDays"])
+	// On error we default to 0, "do not clean out the versioned items"
+
+	if err != nil {
+		keep = 5 // A reasonable default
+	}
+
+	versionsFs := versionerFsFromFolderCfg(cfg)
+	v := dedup{
+		simple: simple{
+			keep:            keep,
+			cleanoutDays:    cleanoutDays,
+			folderFs:        cfg.Filesystem(nil),
+			versionsFs:      versionsFs,
+			copyRangeMethod: cfg.CopyRangeMethod.ToFS(),
+		},
+		blocksFs:   fs.NewSubFilesystem(versionsFs, dedupBlocksDir),
+		manifestFs: fs.NewSubFilesystem(versionsFs, dedupVersionsDir),
+		mut:        new(sync.RWMutex),
+	}
+
+	l.Debugf("instantiated %#v", v)
+	return v
+}
+
+// Archive stores the blocks and manifest of the named file and removes
+// it. If this function returns nil, the named file does not exist any
+// more (has been archived).
+func (v dedup) Archive(filePath string) error {
+	v.mut.RLock()
+	defer v.mut.RUnlock()
+
+	filePath = osutil.NativeFilename(filePath)
+	if err := v.archive(filePath); err != nil {
+		return err
+	}
+
+	cleanVersions(v.manifestFs, findAllVersions(v.manifestFs, filePath), v.toRemove)
+
+	return nil
+}
+
+func (v dedup) archive(filePath string) error {
+	info, err := v.folderFs.Lstat(filePath)
+	if fs.IsNotExist(err) {
+		l.Debugln("not archiving nonexistent file", filePath)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if info.IsSymlink() {
+		panic("bug: attempting to version a symlink")
+	}
+
+	manifest, err := v.storeBlocks(filePath, info)
+	if err != nil {
+		return err
+	}
+
+	if err := v.manifestFs.MkdirAll(filepath.Dir(filePath), 0o755); err != nil && !fs.IsExist(err) {
+		return err
+	}
+	_ = v.versionsFs.Hide(".")
+	_ = v.versionsFs.Hide(dedupDir)
+
+	ver := TagFilename(filePath, time.Now().Format(TimeFormat))
+	if err := writeDedupManifest(v.manifestFs, ver, manifest); err != nil {
+		return err
+	}
+	l.Debugln("archived", filePath, "as", ver, "with", len(manifest.Blocks), "blocks")
+
+	return v.folderFs.Remove(filePath)
+}
+
+// storeBlocks hashes the file and stores any blocks not already present
+// in the block store.
+func (v dedup) storeBlocks(filePath string, info fs.FileInfo) (dedupManifest, error) {
+	fd, err := v.folderFs.Open(filePath)
+	if err != nil {
+		return dedupManifest{}, err
+	}
+	defer fd.Close()
+
+	blockSize := protocol.BlockSize(info.Size())
+	blocks, err := scanner.Blocks(context.Background(), fd, blockSize, info.Size(), nil, false)
+	if err != nil {
+		return dedupManifest{}, err
+	}
+
+	manifest := dedupManifest{
+		Size:      info.Size(),
+		ModTime:   info.ModTime(),
+		Mode:      uint32(info.Mode() & fs.ModePerm),
+		BlockSize: blockSize,
+		Blocks:    make([]string, len(blocks)),
+	}
+
+	buf := make([]byte, blockSize)
+	for i, block := range blocks {
+		hash := hex.EncodeToString(block.Hash)
+		manifest.Blocks[i] = hash
+
+		name := dedupBlockName(hash)
+		if _, err := v.blocksFs.Lstat(name); err == nil {
+			continue
+		} else if !fs.IsNotExist(err) {
+			return dedupManifest{}, err
+		}
+
+		data := buf[:block.Size]
+		if _, err := fd.ReadAt(data, block.Offset); err != nil {
+			return dedupManifest{}, err
+		}
+		if sum := sha256.Sum256(data); !bytes.Equal(sum[:], block.Hash) {
+			return dedupManifest{}, errFileChanged
+		}
+		if err := v.writeBlock(name, data); err != nil {
+			return dedupManifest{}, err
+		}
+	}
+
+	return manifest, nil
+}
+
+// dedupBlockName returns the path of a block in the store. Blocks are
+// spread over subdirectories by the first byte of the hash to keep
+// directory sizes manageable.
+func dedupBlockName(hash string) string {
+	return filepath.Join(hash[:2], hash)
+}
+
+func (v dedup) writeBlock(name string, data []byte) error {
+	if err := v.blocksFs.MkdirAll(filepath.Dir(name), 0o755); err != nil && !fs.IsExist(err) {
+		return err
+	}
+	// Write to a temporary name and rename, so that a block that exists is
+	// always complete.
+	tmp := fs.TempName(name)
+	if err := fs.WriteFile(v.blocksFs, tmp, data, 0o644); err != nil {
+		_ = v.blocksFs.Remove(tmp)
+		return err
+	}
+	return v.blocksFs.Rename(tmp, name)
+}
+
+func writeDedupManifest(manifestFs fs.Filesystem, name string, manifest dedupManifest) error {
+	bs, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	return fs.WriteFile(manifestFs, name, bs, 0o644)
+}
+
+func readDedupManifest(manifestFs fs.Filesystem, name string) (dedupManifest, error) {
+	fd, err := manifestFs.Open(name)
+	if err != nil {
+		return dedupManifest{}, err
+	}
+	defer fd.Close()
+	var manifest dedupManifest
+	if err := json.NewDecoder(fd).Decode(&manifest); err != nil {
+		return dedupManifest{}, fmt.Errorf("%s: %w", name, err)
+	}
+	return manifest, nil
+}
+
+func (v dedup) GetVersions() (map[string][]FileVersion, error) {
+	files := make(map[string][]FileVersion)
+	if _, err := v.manifestFs.Lstat("."); fs.IsNotExist(err) {
+		return files, nil
+	}
+
+	err := v.manifestFs.Walk(".", func(path string, f fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !f.IsRegular() {
+			return nil
+		}
+
+		name, tag := UntagFilename(osutil.NormalizedFilename(path))
+		if name == "" {
+			return nil
+		}
+		versionTime, err := time.ParseInLocation(TimeFormat, tag, time.Local)
+		if err != nil {
+			return nil
+		}
+		manifest, err := readDedupManifest(v.manifestFs, path)
+		if err != nil {
+			l.Debugln("dedup versioner: skipping unreadable manifest:", err)
+			return nil
+		}
+
+		files[name] = append(files[name], FileVersion{
+			VersionTime: versionTime,
+			ModTime:     manifest.ModTime.Truncate(time.Second),
+			Size:        manifest.Size,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (v dedup) Restore(filePath string, versionTime time.Time) error {
+	v.mut.RLock()
+	defer v.mut.RUnlock()
+
+	filePath = osutil.NativeFilename(filePath)
+	tag := versionTime.In(time.Local).Truncate(time.Second).Format(TimeFormat)
+	ver := TagFilename(filePath, tag)
+
+	manifest, err := readDedupManifest(v.manifestFs, ver)
+	if fs.IsNotExist(err) {
+		return errNotFound
+	} else if err != nil {
+		return err
+	}
+
+	// If something already exists where we are restoring to, archive it,
+	// remove it if it's a symlink, or fail if it's a directory.
+	if info, err := v.folderFs.Lstat(filePath); err == nil {
+		switch {
+		case info.IsDir():
+			return ErrDirectory
+		case info.IsSymlink():
+			if err := v.folderFs.Remove(filePath); err != nil {
+				return fmt.Errorf("removing existing symlink: %w", err)
+			}
+		case info.IsRegular():
+			// Not expiring old versions here, as that might include the
+			// one we're about to restore.
+			if err := v.archive(filePath); err != nil {
+				return fmt.Errorf("archiving existing file: %w", err)
+			}
+		default:
+			panic("bug: unknown item type")
+		}
+	} else if !fs.IsNotExist(err) {
+		return err
+	}
+
+	_ = v.folderFs.MkdirAll(filepath.Dir(filePath), 0o755)
+	tmp := fs.TempName(filePath)
+	if err := v.assemble(tmp, manifest); err != nil {
+		_ = v.folderFs.Remove(tmp)
+		return err
+	}
+	_ = v.folderFs.Chtimes(tmp, manifest.ModTime, manifest.ModTime)
+	if err := v.folderFs.Rename(tmp, filePath); err != nil {
+		_ = v.folderFs.Remove(tmp)
+		return err
+	}
+
+	// Like the other versioners, a restored version is no longer kept in
+	// the archive. Its blocks go away on the next clean, unless used by
+	// other versions.
+	return v.manifestFs.Remove(ver)
+}
+
+// assemble writes the file described by the manifest to name in the
+// folder, verifying each block as it goes.
+func (v dedup) assemble(name string, manifest dedupManifest) error {
+	mode := fs.FileMode(manifest.Mode)
+	if mode == 0 {
+		mode = 0o644
+	}
+	fd, err := v.folderFs.OpenFile(name, fs.OptWriteOnly|fs.OptCreate|fs.OptTruncate, mode)
+	if err != nil {
+		return err
+	}
+
+	var written int64
+	for _, hash := range manifest.Blocks {
+		data, err := v.readBlock(hash)
+		if err != nil {
+			fd.Close()
+			return err
+		}
+		if _, err := fd.Write(data); err != nil {
+			fd.Close()
+			return err
+		}
+		written += int64(len(data))
+	}
+	if written != manifest.Size {
+		fd.Close()
+		return fmt.Errorf("restored size %d does not match expected %d", written, manifest.Size)
+	}
+	return fd.Close()
+}
+
+func (v dedup) readBlock(hash string) ([]byte, error) {
+	fd, err := v.blocksFs.Open(dedupBlockName(hash))
+	if err != nil {
+		return nil, fmt.Errorf("block %s: %w", hash, err)
+	}
+	defer fd.Close()
+	data, err := io.ReadAll(fd)
+	if err != nil {
+		return nil, fmt.Errorf("block %s: %w", hash, err)
+	}
+	expected, err := hex.DecodeString(hash)
+	if err != nil {
+		return nil, fmt.Errorf("block %s: %w", hash, err)
+	}
+	if sum := sha256.Sum256(data); !bytes.Equal(sum[:], expected) {
+		return nil, fmt.Errorf("block %s: %w", hash, errBlockCorrupt)
+	}
+	return data, nil
+}
+
+// Clean expires old versions, then removes blocks no longer referenced
+// by any version.
+func (v dedup) Clean(ctx context.Context) error {
+	if err := clean(ctx, v.manifestFs, v.toRemove); err != nil {
+		return err
+	}
+	return v.collectGarbage(ctx)
+}
+
+func (v dedup) collectGarbage(ctx context.Context) error {
+	v.mut.Lock()
+	defer v.mut.Unlock()
+
+	if _, err := v.blocksFs.Lstat("."); fs.IsNotExist(err) {
+		return nil
+	}
+
+	used := make(map[string]struct{})
+	if _, err := v.manifestFs.Lstat("."); err == nil {
+		err := v.manifestFs.Walk(".", func(path string, f fs.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !f.IsRegular() {
+				return nil
+			}
+			manifest, err := readDedupManifest(v.manifestFs, path)
+			if err != nil {
+				// We can't know what blocks an unreadable manifest
+				// refers to, so we can't safely remove anything.
+				return err
+			}
+			for _, hash := range manifest.Blocks {
+				used[hash] = struct{}{}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var removed int
+	err := v.blocksFs.Walk(".", func(path string, f fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !f.IsRegular() {
+			return nil
+		}
+		if _, ok := used[filepath.Base(path)]; ok {
+			return nil
+		}
+		if err := v.blocksFs.Remove(path); err != nil {
+			l.Warnf("Versioner: can't remove unused block %q: %v", path, err)
+			return nil
+		}
+		removed++
+		return nil
+	})
+	l.Debugln("dedup versioner: removed", removed, "unused blocks")
+	return err
+}
@@ -0,0 +1,336 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package versioner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+func TestDedupVersioning(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Test takes some time, skipping.")
+	}
+
+	dir := t.TempDir()
+	v := newDedupTestVersioner(dir, "5")
+
+	// Three blocks of random data, versioned, then a change to the middle
+	// block and versioned again.
+	orig := make([]byte, 3*protocol.MinBlockSize)
+	rand.New(rand.NewSource(42)).Read(orig)
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.Local)
+	writeDedupTestFile(t, dir, "sub/test.bin", orig, mtime)
+	if err := v.Archive(filepath.Join("sub", "test.bin")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "sub", "test.bin")); !os.IsNotExist(err) {
+		t.Fatal("expected file to be archived, got", err)
+	}
+
+	// Version tags have second resolution.
+	time.Sleep(time.Second)
+
+	changed := bytes.Clone(orig)
+	copy(changed[protocol.MinBlockSize:], "something else")
+	writeDedupTestFile(t, dir, "sub/test.bin", changed, mtime.Add(time.Hour))
+	if err := v.Archive(filepath.Join("sub", "test.bin")); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := countDedupTestBlocks(t, dir); n != 4 {
+		t.Errorf("expected 4 stored blocks, got %d", n)
+	}
+
+	versions, err := v.GetVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileVersions := versions["sub/test.bin"]
+	if len(fileVersions) != 2 {
+		t.Fatalf("expected two versions, got %v", versions)
+	}
+	for _, fv := range fileVersions {
+		if fv.Size != int64(len(orig)) {
+			t.Errorf("unexpected version size %d", fv.Size)
+		}
+	}
+	oldest := fileVersions[0]
+	if fileVersions[1].VersionTime.Before(oldest.VersionTime) {
+		oldest = fileVersions[1]
+	}
+	if !oldest.ModTime.Equal(mtime) {
+		t.Errorf("unexpected mod time %v", oldest.ModTime)
+	}
+
+	// Restoring on top of an existing file archives that file first.
+	time.Sleep(time.Second)
+	current := []byte("current")
+	writeDedupTestFile(t, dir, "sub/test.bin", current, time.Now())
+	if err := v.Restore(filepath.Join("sub", "test.bin"), oldest.VersionTime); err != nil {
+		t.Fatal(err)
+	}
+	bs, err := os.ReadFile(filepath.Join(dir, "sub", "test.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bs, orig) {
+		t.Error("restored file differs from the original")
+	}
+	if info, err := os.Stat(filepath.Join(dir, "sub", "test.bin")); err != nil {
+		t.Fatal(err)
+	} else if !info.ModTime().Equal(mtime) {
+		t.Errorf("unexpected restored mod time %v", info.ModTime())
+	}
+
+	versions, err = v.GetVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions["sub/test.bin"]) != 2 {
+		t.Errorf("expected the restored version replaced by the current file, got %v", versions)
+	}
+	if err := v.Restore(filepath.Join("sub", "test.bin"), oldest.VersionTime); !errors.Is(err, errNotFound) {
+		t.Error("expected restored version to be gone, got", err)
+	}
+}
+
+func TestDedupVersioningClean(t *testing.T) {
+	dir := t.TempDir()
+	v := newDedupTestVersioner(dir, "5")
+
+	writeDedupTestFile(t, dir, "a", []byte("file a"), time.Now())
+	writeDedupTestFile(t, dir, "b", []byte("file b"), time.Now())
+	if err := v.Archive("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Archive("b"); err != nil {
+		t.Fatal(err)
+	}
+	if n := countDedupTestBlocks(t, dir); n != 2 {
+		t.Fatalf("expected 2 stored blocks, got %d", n)
+	}
+
+	// Drop the version of b behind the versioner's back; its block is now
+	// unreferenced and should be collected.
+	manifests, err := filepath.Glob(filepath.Join(dir, DefaultPath, dedupVersionsDir, "b~*"))
+	if err != nil || len(manifests) != 1 {
+		t.Fatal("expected one manifest for b:", manifests, err)
+	}
+	if err := os.Remove(manifests[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := v.Clean(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := countDedupTestBlocks(t, dir); n != 1 {
+		t.Fatalf("expected 1 stored block after clean, got %d", n)
+	}
+
+	versions, err := v.GetVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions["a"]) != 1 {
+		t.Fatalf("expected one version of a, got %v", versions)
+	}
+	if err := v.Restore("a", versions["a"][0].VersionTime); err != nil {
+		t.Fatal(err)
+	}
+	if bs, err := os.ReadFile(filepath.Join(dir, "a")); err != nil {
+		t.Fatal(err)
+	} else if string(bs) != "file a" {
+		t.Errorf("unexpected restored contents %q", bs)
+	}
+}
+
+func TestDedupVersioningCleanWaitsForArchive(t *testing.T) {
+	dir := t.TempDir()
+	v := newDedupTestVersioner(dir, "5")
+
+	writeDedupTestFile(t, dir, "a", []byte("file a"), time.Now())
+	if err := v.Archive("a"); err != nil {
+		t.Fatal(err)
+	}
+	manifests, err := filepath.Glob(filepath.Join(dir, DefaultPath, dedupVersionsDir, "a~*"))
+	if err != nil || len(manifests) != 1 {
+		t.Fatal("expected one manifest for a:", manifests, err)
+	}
+	manifest, err := os.ReadFile(manifests[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(manifests[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	// An archive of the same contents is underway: it has found the block
+	// already stored, but not yet written its manifest.
+	v.(dedup).mut.RLock()
+	done := make(chan error, 1)
+	go func() { done <- v.Clean(context.Background()) }()
+
+	select {
+	case err := <-done:
+		t.Fatal("clean should wait for the archive, returned", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if err := os.WriteFile(manifests[0], manifest, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	v.(dedup).mut.RUnlock()
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if n := countDedupTestBlocks(t, dir); n != 1 {
+		t.Fatalf("expected the block in use to be kept, got %d blocks", n)
+	}
+}
+
+func TestDedupVersioningCorruptBlock(t *testing.T) {
+	dir := t.TempDir()
+	v := newDedupTestVersioner(dir, "5")
+
+	writeDedupTestFile(t, dir, "test", []byte("test data"), time.Now())
+	if err := v.Archive("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	blocksDir := filepath.Join(dir, DefaultPath, dedupBlocksDir)
+	err := filepath.WalkDir(blocksDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		return os.WriteFile(path, []byte("garbage"), 0o644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := v.GetVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Restore("test", versions["test"][0].VersionTime); !errors.Is(err, errBlockCorrupt) {
+		t.Fatal("expected corrupt block error, got", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "test")); !os.IsNotExist(err) {
+		t.Error("expected no restored file, got", err)
+	}
+}
+
+func TestDedupVersioningURIParams(t *testing.T) {
+	// Filesystem URIs may have parameters, which the block store and
+	// manifests must be created with as well.
+	cfg := config.FolderConfiguration{
+		FilesystemType: config.FilesystemTypeFake,
+		Path:           t.Name() + "?content=true",
+		Versioning: config.VersioningConfiguration{
+			Type:   "dedup",
+			FSType: config.FilesystemTypeFake,
+			FSPath: t.Name() + "-versions?content=true",
+		},
+	}
+	ffs := cfg.Filesystem(nil)
+	v := newDedup(cfg)
+
+	data := make([]byte, 2*protocol.MinBlockSize)
+	rand.New(rand.NewSource(42)).Read(data)
+	fd, err := ffs.Create("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fd.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	fd.Close()
+	if err := v.Archive("test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := versionerFsFromFolderCfg(cfg).Lstat(dedupBlocksDir); err != nil {
+		t.Fatal("expected the block store in the versions directory:", err)
+	}
+
+	versions, err := v.GetVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions["test"]) != 1 {
+		t.Fatal("expected one version, got", versions)
+	}
+	if err := v.Restore("test", versions["test"][0].VersionTime); err != nil {
+		t.Fatal(err)
+	}
+	fd, err = ffs.Open("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	restored, err := io.ReadAll(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(restored, data) {
+		t.Error("restored data doesn't match")
+	}
+}
+
+func newDedupTestVersioner(dir, keep string) Versioner {
+	cfg := config.FolderConfiguration{
+		FilesystemType: config.FilesystemTypeBasic,
+		Path:           dir,
+		Versioning: config.VersioningConfiguration{
+			Type: "dedup",
+			Params: map[string]string{
+				"keep": keep,
+			},
+		},
+	}
+	return newDedup(cfg)
+}
+
+func writeDedupTestFile(t *testing.T, dir, name string, data []byte, mtime time.Time) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func countDedupTestBlocks(t *testing.T, dir string) int {
+	t.Helper()
+	n := 0
+	err := filepath.WalkDir(filepath.Join(dir, DefaultPath, dedupBlocksDir), func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
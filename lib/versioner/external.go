@@ -24,7 +24,7 @@ import (
 
 func init() {
 	// Register the constructor for this type of versioner with the name "external"
-	factories["external"] = newExternal
+	Register("external", newExternal)
 }
 
 type external struct {
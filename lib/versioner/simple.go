@@ -18,7 +18,7 @@ import (
 
 func init() {
 	// Register the constructor for this type of versioner with the name "simple"
-	factories["simple"] = newSimple
+	Register("simple", newSimple)
 }
 
 type simple struct {
@@ -19,7 +19,7 @@ import (
 
 func init() {
 	// Register the constructor for this type of versioner with the name "staggered"
-	factories["staggered"] = newStaggered
+	Register("staggered", newStaggered)
 }
 
 type interval struct {
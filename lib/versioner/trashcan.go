@@ -18,7 +18,7 @@ import (
 
 func init() {
 	// Register the constructor for this type of versioner
-	factories["trashcan"] = newTrashcan
+	Register("trashcan", newTrashcan)
 }
 
 type trashcan struct {
@@ -12,6 +12,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/syncthing/syncthing/lib/config"
@@ -30,9 +31,51 @@ type FileVersion struct {
 	Size        int64     `json:"size"`
 }
 
-type factory func(cfg config.FolderConfiguration) Versioner
+// VersionsAt returns, for each file, the version that was live at the
+// given time. A version's time is when it was archived, that is when it
+// was replaced, so that's the earliest version archived after the given
+// time. Files whose current content, by the modification times in
+// current, is older than the given time haven't changed since and are not
+// included, nor are files with no version archived after it. Versions
+// modified after the given time didn't exist yet, so files created since
+// aren't included either.
+func VersionsAt(versions map[string][]FileVersion, current map[string]time.Time, at time.Time) map[string]FileVersion {
+	res := make(map[string]FileVersion)
+	for name, fileVersions := range versions {
+		if modTime, ok := current[name]; ok && modTime.Before(at) {
+			continue
+		}
+		for _, fv := range fileVersions {
+			if !fv.VersionTime.After(at) || fv.ModTime.After(at) {
+				continue
+			}
+			if cur, ok := res[name]; !ok || fv.VersionTime.Before(cur.VersionTime) {
+				res[name] = fv
+			}
+		}
+	}
+	return res
+}
 
-var factories = make(map[string]factory)
+// A Factory creates a Versioner for the given folder configuration.
+type Factory func(cfg config.FolderConfiguration) Versioner
+
+var (
+	factories    = make(map[string]Factory)
+	factoriesMut sync.Mutex
+)
+
+// Register makes a versioner available under the given type name, as used
+// in the folder versioning configuration. Registering the same name twice
+// is a programming error.
+func Register(vtype string, fac Factory) {
+	factoriesMut.Lock()
+	defer factoriesMut.Unlock()
+	if _, ok := factories[vtype]; ok {
+		panic("bug: versioner type " + vtype + " registered twice")
+	}
+	factories[vtype] = fac
+}
 
 var ErrRestorationNotSupported = errors.New("version restoration not supported with the current versioner")
 
@@ -42,7 +85,9 @@ const (
 )
 
 func New(cfg config.FolderConfiguration) (Versioner, error) {
+	factoriesMut.Lock()
 	fac, ok := factories[cfg.Versioning.Type]
+	factoriesMut.Unlock()
 	if !ok {
 		return nil, fmt.Errorf("requested versioning type %q does not exist", cfg.Versioning.Type)
 	}
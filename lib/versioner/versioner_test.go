@@ -0,0 +1,58 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package versioner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVersionsAt(t *testing.T) {
+	base := time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC)
+	mins := func(minutes int) time.Time {
+		return base.Add(time.Duration(minutes) * time.Minute)
+	}
+	version := func(archived, modified int) FileVersion {
+		return FileVersion{VersionTime: mins(archived), ModTime: mins(modified)}
+	}
+
+	versions := map[string][]FileVersion{
+		"unsorted":    {version(3, 2), version(8, 7), version(1, 0), version(6, 4), version(9, 8)},
+		"exact":       {version(5, 4), version(7, 5)},
+		"only-after":  {version(6, 6), version(7, 6)},
+		"only-before": {version(-60, -90)},
+		"unchanged":   {version(-60, -90), version(6, -60)},
+		"deleted":     {version(2, 1), version(6, 3)},
+	}
+	current := map[string]time.Time{
+		"unsorted":    mins(9),
+		"exact":       mins(7),
+		"only-after":  mins(7),
+		"only-before": mins(-60),
+		// Current content from before the time, so whatever versions
+		// there are can't be what was there then.
+		"unchanged": mins(-30),
+	}
+
+	res := VersionsAt(versions, current, mins(5))
+
+	// The version live at the time is the one replaced next after it. The
+	// file that was created after the time has nothing to restore.
+	expected := map[string]FileVersion{
+		"unsorted": version(6, 4),
+		"exact":    version(7, 5),
+		"deleted":  version(6, 3),
+	}
+	if len(res) != len(expected) {
+		t.Fatalf("expected %d files, got %v", len(expected), res)
+	}
+	for name, fv := range expected {
+		if !res[name].VersionTime.Equal(fv.VersionTime) {
+			t.Errorf("%s: expected version %v, got %v", name, fv.VersionTime, res[name].VersionTime)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/syncthing/syncthing/lib/db"
+	"github.com/syncthing/syncthing/lib/db/backend"
+	"github.com/syncthing/syncthing/lib/sync"
+)
+
+// maxQueued is the number of undelivered events kept per webhook. When
+// exceeded the oldest events are dropped.
+const maxQueued = 10000
+
+// A queue is a persistent FIFO of events for a webhook. Events are numbered
+// sequentially, and the sequence numbers of the first undelivered event and
+// of the next event to be added are persisted along with the events, so
+// that delivery resumes where it stopped after a restart.
+type queue struct {
+	kv          *db.NamespacedKV
+	mut         sync.Mutex
+	first, next int64
+}
+
+func openQueue(ll backend.Backend, id string) (*queue, error) {
+	q := &queue{
+		kv:  db.NewNamespacedKV(ll, string(db.KeyTypeMiscData)+"webhook/"+id+"/"),
+		mut: sync.NewMutex(),
+	}
+	var err error
+	if q.first, _, err = q.kv.Int64("first"); err != nil {
+		return nil, err
+	}
+	if q.next, _, err = q.kv.Int64("next"); err != nil {
+		return nil, err
+	}
+	if q.next < q.first {
+		q.next = q.first
+	}
+	return q, nil
+}
+
+func eventKey(seq int64) string {
+	return fmt.Sprintf("event/%016x", seq)
+}
+
+// push adds an event to the end of the queue.
+func (q *queue) push(data []byte) error {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	if err := q.kv.PutBytes(eventKey(q.next), data); err != nil {
+		return err
+	}
+	q.next++
+	if err := q.kv.PutInt64("next", q.next); err != nil {
+		return err
+	}
+	if q.next-q.first > maxQueued {
+		l.Debugf("webhook queue %p full, dropping event %d", q, q.first)
+		return q.ackLocked(q.first)
+	}
+	return nil
+}
+
+func (q *queue) len() int {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	return int(q.next - q.first)
+}
+
+// peek returns up to max events from the start of the queue, and the
+// sequence numbers of the first and last of them.
+func (q *queue) peek(max int) ([]json.RawMessage, int64, int64, error) {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	var res []json.RawMessage
+	last := q.first - 1
+	for seq := q.first; seq < q.next && len(res) < max; seq++ {
+		last = seq
+		data, ok, err := q.kv.Bytes(eventKey(seq))
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		if !ok {
+			// Can't happen unless the database was tampered with,
+			// in which case there is no point in sending an empty
+			// event.
+			continue
+		}
+		res = append(res, data)
+	}
+	return res, q.first, last, nil
+}
+
+// ack removes events up to and including the given sequence number.
+func (q *queue) ack(last int64) error {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	return q.ackLocked(last)
+}
+
+func (q *queue) ackLocked(last int64) error {
+	for ; q.first <= last && q.first < q.next; q.first++ {
+		if err := q.kv.Delete(eventKey(q.first)); err != nil {
+			return err
+		}
+	}
+	return q.kv.PutInt64("first", q.first)
+}
+
+// drop removes the queue and all its events.
+func (q *queue) drop() error {
+	q.mut.Lock()
+	defer q.mut.Unlock()
+	if err := q.ackLocked(q.next - 1); err != nil {
+		return err
+	}
+	if err := q.kv.Delete("first"); err != nil {
+		return err
+	}
+	return q.kv.Delete("next")
+}
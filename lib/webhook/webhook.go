@@ -0,0 +1,384 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package webhook implements POSTing events to configured URLs.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/thejerf/suture/v4"
+
+	"github.com/syncthing/syncthing/lib/build"
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db/backend"
+	"github.com/syncthing/syncthing/lib/dialer"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/svcutil"
+	"github.com/syncthing/syncthing/lib/tlsutil"
+)
+
+const (
+	SignatureHeader = "X-Syncthing-Signature"
+	WebhookHeader   = "X-Syncthing-Webhook"
+)
+
+var (
+	sendTimeout = time.Minute
+	minBackoff  = time.Second
+	maxBackoff  = 5 * time.Minute
+)
+
+// Payload is the body of the requests sent to webhooks. The sequence
+// numbers are specific to the webhook and, unlike event IDs, persist across
+// restarts. A batch is resent in full if delivery fails, so receivers may
+// use them to detect duplicates.
+type Payload struct {
+	Webhook       string            `json:"webhook"`
+	FirstSequence int64             `json:"firstSequence"`
+	LastSequence  int64             `json:"lastSequence"`
+	Events        []json.RawMessage `json:"events"`
+}
+
+type Service interface {
+	suture.Service
+	config.Committer
+}
+
+type service struct {
+	*suture.Supervisor
+	cfg      config.Wrapper
+	db       backend.Backend
+	sub      events.Subscription
+	cfgChan  chan []config.WebhookConfiguration
+	webhooks map[string]*webhook // only accessed from serve
+}
+
+// New returns a service that sends events to the configured webhooks. The
+// events subscription is set up right away, so that events emitted before
+// the service is started aren't missed.
+func New(cfg config.Wrapper, db backend.Backend, evLogger events.Logger) Service {
+	s := &service{
+		Supervisor: suture.New("webhook.Service", svcutil.SpecWithDebugLogger(l)),
+		cfg:        cfg,
+		db:         db,
+		sub:        evLogger.Subscribe(events.AllEvents),
+		cfgChan:    make(chan []config.WebhookConfiguration),
+		webhooks:   make(map[string]*webhook),
+	}
+	s.Add(svcutil.AsService(s.serve, s.String()))
+	svcutil.OnSupervisorDone(s.Supervisor, s.sub.Unsubscribe)
+	return s
+}
+
+func (s *service) serve(ctx context.Context) error {
+	cfg := s.cfg.Subscribe(s)
+	defer s.cfg.Unsubscribe(s)
+	s.applyConfig(cfg.Webhooks)
+
+	for {
+		select {
+		case hooks := <-s.cfgChan:
+			s.applyConfig(hooks)
+		case ev := <-s.sub.C():
+			s.dispatch(ev)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *service) CommitConfiguration(from, to config.Configuration) bool {
+	if !reflect.DeepEqual(from.Webhooks, to.Webhooks) {
+		s.cfgChan <- to.Webhooks
+	}
+	return true
+}
+
+func (s *service) String() string {
+	return fmt.Sprintf("webhook.Service@%p", s)
+}
+
+// applyConfig starts, restarts and stops the senders of the webhooks
+// according to the configuration. The queued events of removed webhooks
+// are dropped, while those of disabled webhooks are kept.
+func (s *service) applyConfig(hooks []config.WebhookConfiguration) {
+	seen := make(map[string]struct{}, len(hooks))
+	for _, hcfg := range hooks {
+		seen[hcfg.ID] = struct{}{}
+		w, ok := s.webhooks[hcfg.ID]
+		if ok && reflect.DeepEqual(w.cfg, hcfg) {
+			continue
+		}
+		if ok {
+			_ = s.RemoveAndWait(w.token, 0)
+			delete(s.webhooks, hcfg.ID)
+		}
+		if !hcfg.Enabled {
+			continue
+		}
+		if u, err := url.Parse(hcfg.URL); err != nil || u.Scheme != "http" && u.Scheme != "https" {
+			l.Warnf("Not starting webhook %s with invalid URL %q", hcfg.ID, hcfg.URL)
+			continue
+		}
+		q, err := openQueue(s.db, hcfg.ID)
+		if err != nil {
+			l.Warnf("Failed to open queue for webhook %s: %v", hcfg.ID, err)
+			continue
+		}
+		w = newWebhook(hcfg, q)
+		w.token = s.Add(svcutil.AsService(w.serve, w.String()))
+		s.webhooks[hcfg.ID] = w
+	}
+	for id, w := range s.webhooks {
+		if _, ok := seen[id]; !ok {
+			// Wait for the sender to stop before removing the queue.
+			_ = s.RemoveAndWait(w.token, 0)
+			delete(s.webhooks, id)
+			if err := w.queue.drop(); err != nil {
+				l.Warnf("Failed to remove queue for webhook %s: %v", id, err)
+			}
+		}
+	}
+}
+
+// dispatch queues the event for all matching webhooks.
+func (s *service) dispatch(ev events.Event) {
+	var data []byte
+	var refs eventRefs
+	for _, w := range s.webhooks {
+		if !w.filter.matchType(ev.Type) {
+			continue
+		}
+		if data == nil {
+			var err error
+			if data, err = json.Marshal(ev); err != nil {
+				l.Debugln("marshalling event:", err)
+				return
+			}
+			refs = refsFromEvent(ev.Type, data)
+		}
+		if !w.filter.matchRefs(refs) {
+			continue
+		}
+		if err := w.queue.push(data); err != nil {
+			l.Warnf("Failed to queue event for webhook %s: %v", w.cfg.ID, err)
+			continue
+		}
+		w.notify()
+	}
+}
+
+type webhook struct {
+	cfg     config.WebhookConfiguration
+	filter  filter
+	queue   *queue
+	client  *http.Client
+	trigger chan struct{}
+	token   suture.ServiceToken
+}
+
+func newWebhook(cfg config.WebhookConfiguration, q *queue) *webhook {
+	return &webhook{
+		cfg:    cfg,
+		filter: newFilter(cfg),
+		queue:  q,
+		client: &http.Client{
+			Timeout: sendTimeout,
+			Transport: &http.Transport{
+				DialContext:     dialer.DialContext,
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsutil.SecureDefaultWithTLS12(),
+			},
+		},
+		trigger: make(chan struct{}, 1),
+	}
+}
+
+func (w *webhook) String() string {
+	return fmt.Sprintf("webhook@%p(%s)", w, w.cfg.ID)
+}
+
+func (w *webhook) notify() {
+	select {
+	case w.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// serve sends the queued events in batches. After new events arrive it
+// waits for the batch delay to collect more, unless a full batch is
+// already available. Failed deliveries are retried with exponential
+// backoff.
+func (w *webhook) serve(ctx context.Context) error {
+	// Send whatever was left over from before a restart.
+	if w.queue.len() > 0 {
+		w.notify()
+	}
+
+	batchDelay := time.Duration(w.cfg.BatchDelayS) * time.Second
+	backoff := minBackoff
+	for {
+		select {
+		case <-w.trigger:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if w.queue.len() < w.cfg.MaxBatchSize && batchDelay > 0 {
+			select {
+			case <-time.After(batchDelay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		for w.queue.len() > 0 {
+			evs, first, last, err := w.queue.peek(w.cfg.MaxBatchSize)
+			if err == nil && len(evs) > 0 {
+				err = w.send(ctx, Payload{
+					Webhook:       w.cfg.ID,
+					FirstSequence: first,
+					LastSequence:  last,
+					Events:        evs,
+				})
+			}
+			if err != nil {
+				l.Infof("Failed to deliver events to webhook %s (retrying in %v): %v", w.cfg.ID, backoff, err)
+				select {
+				case <-time.After(backoff):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				backoff = min(2*backoff, maxBackoff)
+				continue
+			}
+			backoff = minBackoff
+			if err := w.queue.ack(last); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (w *webhook) send(ctx context.Context, payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "syncthing/"+build.Version)
+	req.Header.Set(WebhookHeader, w.cfg.ID)
+	if w.cfg.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign([]byte(w.cfg.Secret), body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	l.Debugf("Delivered events %d-%d to webhook %s", payload.FirstSequence, payload.LastSequence, w.cfg.ID)
+	return nil
+}
+
+// Sign returns the signature header value for the given body, i.e. the
+// hex encoded HMAC-SHA256 prefixed by "sha256=".
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type filter struct {
+	mask    events.EventType
+	folders map[string]struct{}
+	devices map[string]struct{}
+}
+
+func newFilter(cfg config.WebhookConfiguration) filter {
+	f := filter{mask: cfg.EventMask()}
+	if len(cfg.Folders) > 0 {
+		f.folders = make(map[string]struct{}, len(cfg.Folders))
+		for _, folder := range cfg.Folders {
+			f.folders[folder] = struct{}{}
+		}
+	}
+	if len(cfg.Devices) > 0 {
+		f.devices = make(map[string]struct{}, len(cfg.Devices))
+		for _, dev := range cfg.Devices {
+			f.devices[dev.String()] = struct{}{}
+		}
+	}
+	return f
+}
+
+func (f filter) matchType(t events.EventType) bool {
+	return f.mask&t != 0
+}
+
+// matchRefs returns whether an event with the given references passes the
+// folder and device filters. With a filter set, events that don't refer to
+// a folder or device respectively don't pass.
+func (f filter) matchRefs(refs eventRefs) bool {
+	if f.folders != nil {
+		if _, ok := f.folders[refs.folder]; !ok {
+			return false
+		}
+	}
+	if f.devices != nil {
+		if _, ok := f.devices[refs.device]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// eventRefs are the folder and device an event refers to, if any.
+type eventRefs struct {
+	folder, device string
+}
+
+func refsFromEvent(t events.EventType, data []byte) eventRefs {
+	var ev struct {
+		Data map[string]any `json:"data"`
+	}
+	// Events with data that isn't an object don't refer to anything.
+	_ = json.Unmarshal(data, &ev)
+	str := func(key string) string {
+		s, _ := ev.Data[key].(string)
+		return s
+	}
+
+	refs := eventRefs{folder: str("folder"), device: str("device")}
+	// Some events about a folder or device use "id" instead.
+	name := t.String()
+	switch {
+	case refs.folder == "" && strings.HasPrefix(name, "Folder"):
+		refs.folder = str("id")
+	case refs.device == "" && strings.HasPrefix(name, "Device"):
+		refs.device = str("id")
+	}
+	return refs
+}
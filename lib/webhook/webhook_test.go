@@ -0,0 +1,232 @@
+// Copyright (C) 2025 The Syncthing Authors.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this file,
+// You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/syncthing/syncthing/lib/config"
+	"github.com/syncthing/syncthing/lib/db/backend"
+	"github.com/syncthing/syncthing/lib/events"
+	"github.com/syncthing/syncthing/lib/protocol"
+)
+
+var device1, _ = protocol.DeviceIDFromString("AIR6LPZ-7K4PTTV-UXQSMUU-CPQ5YWH-OEDFIIQ-JUG777G-2YQXXR5-YD6AWQR")
+
+func init() {
+	minBackoff = 10 * time.Millisecond
+}
+
+type receiver struct {
+	*httptest.Server
+	failures atomic.Int32 // number of requests to fail
+	payloads chan Payload
+}
+
+func newReceiver(t *testing.T, secret string) *receiver {
+	r := &receiver{payloads: make(chan Payload, 10)}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if r.failures.Add(-1) >= 0 {
+			http.Error(w, "failure", http.StatusInternalServerError)
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		expected := ""
+		if secret != "" {
+			expected = Sign([]byte(secret), body)
+		}
+		if sig := req.Header.Get(SignatureHeader); sig != expected {
+			t.Errorf("Unexpected signature %q", sig)
+		}
+		var p Payload
+		if err := json.Unmarshal(body, &p); err != nil {
+			t.Error(err)
+		}
+		r.payloads <- p
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) next(t *testing.T) Payload {
+	t.Helper()
+	select {
+	case p := <-r.payloads:
+		return p
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for payload")
+	}
+	return Payload{}
+}
+
+func setup(t *testing.T, db backend.Backend, hook config.WebhookConfiguration) events.Logger {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	cfg := config.New(device1)
+	cfg.Webhooks = []config.WebhookConfiguration{hook}
+	w := config.Wrap("", cfg, device1, events.NoopLogger)
+	go w.Serve(ctx)
+
+	evLogger := events.NewLogger()
+	go evLogger.Serve(ctx)
+
+	svc := New(w, db, evLogger)
+	done := make(chan struct{})
+	go func() {
+		svc.Serve(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return evLogger
+}
+
+func TestWebhookFilterAndSign(t *testing.T) {
+	r := newReceiver(t, "s3cret")
+	hook := config.WebhookConfiguration{
+		ID:           "test",
+		URL:          r.URL,
+		Enabled:      true,
+		Events:       []string{"FolderPaused", "DeviceConnected"},
+		Folders:      []string{"a"},
+		Secret:       "s3cret",
+		MaxBatchSize: 10,
+	}
+	evLogger := setup(t, backend.OpenMemory(), hook)
+
+	evLogger.Log(events.FolderPaused, map[string]string{"id": "b"})
+	evLogger.Log(events.FolderResumed, map[string]string{"id": "a"})
+	evLogger.Log(events.DeviceConnected, map[string]string{"id": device1.String()})
+	evLogger.Log(events.FolderPaused, map[string]string{"id": "a", "reason": "user"})
+
+	p := r.next(t)
+	if p.Webhook != "test" || len(p.Events) != 1 || p.FirstSequence != 0 || p.LastSequence != 0 {
+		t.Fatalf("Unexpected payload %+v", p)
+	}
+	var ev struct {
+		Type string            `json:"type"`
+		Data map[string]string `json:"data"`
+	}
+	if err := json.Unmarshal(p.Events[0], &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != "FolderPaused" || ev.Data["id"] != "a" || ev.Data["reason"] != "user" {
+		t.Errorf("Unexpected event %+v", ev)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	r := newReceiver(t, "")
+	r.failures.Store(3)
+	hook := config.WebhookConfiguration{
+		ID:           "test",
+		URL:          r.URL,
+		Enabled:      true,
+		Events:       []string{"Failure"},
+		MaxBatchSize: 2,
+	}
+	evLogger := setup(t, backend.OpenMemory(), hook)
+
+	for _, s := range []string{"one", "two", "three"} {
+		evLogger.Log(events.Failure, s)
+	}
+
+	// Delivery is retried until it succeeds, in batches of two events.
+	var got []string
+	for len(got) < 3 {
+		p := r.next(t)
+		if p.FirstSequence != int64(len(got)) || p.LastSequence != p.FirstSequence+int64(len(p.Events))-1 {
+			t.Fatalf("Unexpected sequence numbers in %+v", p)
+		}
+		for _, raw := range p.Events {
+			var ev events.Event
+			if err := json.Unmarshal(raw, &ev); err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, ev.Data.(string))
+		}
+	}
+	if got[0] != "one" || got[1] != "two" || got[2] != "three" {
+		t.Error("Unexpected events", got)
+	}
+}
+
+func TestQueuePersistence(t *testing.T) {
+	db := backend.OpenMemory()
+
+	q, err := openQueue(db, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`"a"`, `"b"`, `"c"`} {
+		if err := q.push([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.ack(0); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new queue picks up where the previous left off.
+	q, err = openQueue(db, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	evs, first, last, err := q.peek(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != 1 || last != 2 || len(evs) != 2 || string(evs[0]) != `"b"` || string(evs[1]) != `"c"` {
+		t.Errorf("Unexpected queue contents %s (%d-%d)", evs, first, last)
+	}
+
+	if err := q.drop(); err != nil {
+		t.Fatal(err)
+	}
+	it, err := db.NewPrefixIterator(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Release()
+	if it.Next() {
+		t.Errorf("Unexpected key %q after dropping queue", it.Key())
+	}
+}
+
+func TestRefsFromEvent(t *testing.T) {
+	cases := []struct {
+		typ    events.EventType
+		data   any
+		folder string
+		device string
+	}{
+		{events.FolderPaused, map[string]string{"id": "f"}, "f", ""},
+		{events.DeviceConnected, map[string]string{"id": "d"}, "", "d"},
+		{events.FolderCompletion, map[string]any{"folder": "f", "device": "d", "completion": 50}, "f", "d"},
+		{events.Failure, "some failure", "", ""},
+	}
+	for _, tc := range cases {
+		data, err := json.Marshal(events.Event{Type: tc.typ, Data: tc.data})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if refs := refsFromEvent(tc.typ, data); refs.folder != tc.folder || refs.device != tc.device {
+			t.Errorf("%v: unexpected refs %+v", tc.typ, refs)
+		}
+	}
+}